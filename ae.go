@@ -1,8 +1,11 @@
 package main
 
 import (
-	"errors"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
 	"golang.org/x/sys/unix"
+	"sync"
 	"time"
 )
 
@@ -29,24 +32,107 @@ type AeTimeEvent struct {
 	interval int64 // ms
 	proc     TimeProc
 	extra    interface{}
-	next     *AeTimeEvent
+	index    int // index in timeEventHeap, -1 if not in heap
+}
+
+// timeEventHeap is the min-heap of time events ordered by when, so that adding,
+// removing and finding the nearest one are O(log n) at most.
+type timeEventHeap []*AeTimeEvent
+
+func (h timeEventHeap) Len() int { return len(h) }
+
+func (h timeEventHeap) Less(i, j int) bool {
+	if h[i].when == h[j].when {
+		return h[i].id < h[j].id
+	}
+	return h[i].when < h[j].when
+}
+
+func (h timeEventHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timeEventHeap) Push(x any) {
+	te := x.(*AeTimeEvent)
+	te.index = len(*h)
+	*h = append(*h, te)
+}
+
+func (h *timeEventHeap) Pop() any {
+	old := *h
+	n := len(old)
+	te := old[n-1]
+	old[n-1] = nil
+	te.index = -1
+	*h = old[:n-1]
+	return te
+}
+
+// pollMask is the readiness of fd watched by poller, file event is either
+// readable or writable in AeLoop.
+type pollMask uint32
+
+const (
+	pollRead pollMask = 1 << iota
+	pollWrite
+)
+
+type pollEvent struct {
+	fd   int
+	mask pollMask
+}
+
+// poller is the I/O multiplexing backend of AeLoop. epoll is level-triggered,
+// while io_uring reports fd only when it becomes ready, so file procs must read,
+// write or accept until net.ErrAgain.
+type poller interface {
+	add(fd int, mask pollMask) error
+	mod(fd int, mask pollMask) error
+	del(fd int) error
+	// wait waits at most timeout(ms), and appends the ready events to evs.
+	wait(evs []pollEvent, timeout int) ([]pollEvent, error)
+	name() string
+}
+
+// SendRequest is a send of Buf to socket Fd, N and Err are the result like
+// write(2).
+type SendRequest struct {
+	Fd   int
+	Buf  []byte
+	N    int
+	Err  error
+	done bool
+}
+
+// batchSender is implemented by poller which sends to many sockets by one syscall.
+type batchSender interface {
+	sendBatch(reqs []SendRequest)
 }
 
 type AeLoop struct {
 	FileEvents      map[int]*AeFileEvent
-	TimeEvents      *AeTimeEvent
-	fileEventFd     int
+	TimeEvents      timeEventHeap
+	timeEventsByID  map[int]*AeTimeEvent
+	poller          poller
 	timeEventNextId int
 
-	events []*AeFileEvent // file events cache
+	events     []*AeFileEvent // file events cache
+	pollEvents []pollEvent
+
+	// BeforeSleep is called before waiting for events in each iteration.
+	BeforeSleep func(loop *AeLoop)
+
+	// posted is the functions queued by Post from other goroutines, wakeFd is
+	// the eventfd signaled to wake up the loop to call them.
+	postMu sync.Mutex
+	posted []func()
+	wakeFd int
 }
 
 func (loop *AeLoop) AddRead(fd int, proc FileProc, extra interface{}) {
-	err := unix.EpollCtl(loop.fileEventFd, unix.EPOLL_CTL_ADD, fd, &unix.EpollEvent{
-		Fd:     int32(fd),
-		Events: unix.EPOLLIN,
-	})
-	if err != nil {
+	if err := loop.poller.add(fd, pollRead); err != nil {
 		panic(err)
 	}
 	loop.FileEvents[fd] = &AeFileEvent{
@@ -57,11 +143,7 @@ func (loop *AeLoop) AddRead(fd int, proc FileProc, extra interface{}) {
 }
 
 func (loop *AeLoop) ModRead(fd int, proc FileProc, extra interface{}) {
-	err := unix.EpollCtl(loop.fileEventFd, unix.EPOLL_CTL_MOD, fd, &unix.EpollEvent{
-		Fd:     int32(fd),
-		Events: unix.EPOLLIN,
-	})
-	if err != nil {
+	if err := loop.poller.mod(fd, pollRead); err != nil {
 		panic(err)
 	}
 	fe := loop.FileEvents[fd]
@@ -70,11 +152,7 @@ func (loop *AeLoop) ModRead(fd int, proc FileProc, extra interface{}) {
 }
 
 func (loop *AeLoop) ModWrite(fd int, proc FileProc, extra interface{}) {
-	err := unix.EpollCtl(loop.fileEventFd, unix.EPOLL_CTL_MOD, fd, &unix.EpollEvent{
-		Fd:     int32(fd),
-		Events: unix.EPOLLOUT,
-	})
-	if err != nil {
+	if err := loop.poller.mod(fd, pollWrite); err != nil {
 		panic(err)
 	}
 	fe := loop.FileEvents[fd]
@@ -83,16 +161,26 @@ func (loop *AeLoop) ModWrite(fd int, proc FileProc, extra interface{}) {
 }
 
 func (loop *AeLoop) ModDetach(fd int) {
-	err := unix.EpollCtl(loop.fileEventFd, unix.EPOLL_CTL_DEL, fd, &unix.EpollEvent{
-		Fd:     int32(fd),
-		Events: unix.EPOLLIN | unix.EPOLLOUT,
-	})
-	if err != nil {
+	if err := loop.poller.del(fd); err != nil {
 		panic(err)
 	}
 	delete(loop.FileEvents, fd)
 }
 
+// SendBatch sends reqs in batch, and reports false if poller does not support.
+func (loop *AeLoop) SendBatch(reqs []SendRequest) bool {
+	bs, ok := loop.poller.(batchSender)
+	if ok {
+		bs.sendBatch(reqs)
+	}
+	return ok
+}
+
+// PollerName returns the name of I/O multiplexing backend.
+func (loop *AeLoop) PollerName() string {
+	return loop.poller.name()
+}
+
 func GetMsTime() int64 {
 	return time.Now().UnixMilli()
 }
@@ -100,55 +188,98 @@ func GetMsTime() int64 {
 func (loop *AeLoop) AddTimeEvent(mask TeType, interval int64, proc TimeProc, extra interface{}) int {
 	id := loop.timeEventNextId
 	loop.timeEventNextId++
-	loop.TimeEvents = &AeTimeEvent{
+	te := &AeTimeEvent{
 		id:       id,
 		mask:     mask,
 		interval: interval,
 		when:     GetMsTime() + interval,
 		proc:     proc,
 		extra:    extra,
-		next:     loop.TimeEvents,
 	}
+	loop.timeEventsByID[id] = te
+	heap.Push(&loop.TimeEvents, te)
 	return id
 }
 
+// RemoveTimeEvent removes time event of id, it is safe to be called in procs of
+// time events, including the removed one.
 func (loop *AeLoop) RemoveTimeEvent(id int) {
-	p := loop.TimeEvents
-	var pre *AeTimeEvent
-	for p != nil {
-		if p.id == id {
-			if pre == nil {
-				loop.TimeEvents = p.next
-			} else {
-				pre.next = p.next
-			}
-			p.next = nil
-			break
-		}
-		pre = p
-		p = p.next
+	te, ok := loop.timeEventsByID[id]
+	if !ok {
+		return
+	}
+	delete(loop.timeEventsByID, id)
+	// te is not in heap while being processed.
+	if te.index >= 0 {
+		heap.Remove(&loop.TimeEvents, te.index)
 	}
 }
 
-func AeLoopCreate() (*AeLoop, error) {
-	epollFd, err := unix.EpollCreate1(0)
+// AeLoopCreate creates AeLoop with poller of backend, which is "epoll" or
+// "io_uring". It falls back to epoll if io_uring is not supported by kernel.
+func AeLoopCreate(backend string) (*AeLoop, error) {
+	var p poller
+	var err error
+	switch backend {
+	case "", "epoll":
+	case "io_uring":
+		if p, err = newUringPoller(uringEntries); err != nil {
+			log.Warn().Msgf("io_uring not available, fall back to epoll: %v", err)
+			p = nil
+		}
+	default:
+		return nil, fmt.Errorf("invalid ae backend: %q", backend)
+	}
+	if p == nil {
+		if p, err = newEpollPoller(); err != nil {
+			return nil, err
+		}
+	}
+	wakeFd, err := unix.Eventfd(0, unix.EFD_NONBLOCK|unix.EFD_CLOEXEC)
 	if err != nil {
 		return nil, err
 	}
-	return &AeLoop{
+	loop := &AeLoop{
 		FileEvents:      make(map[int]*AeFileEvent),
-		fileEventFd:     epollFd,
+		timeEventsByID:  make(map[int]*AeTimeEvent),
+		poller:          p,
 		timeEventNextId: 1,
 		events:          make([]*AeFileEvent, 128), // pre alloc
-	}, nil
+		wakeFd:          wakeFd,
+	}
+	loop.AddRead(wakeFd, runPosted, nil)
+	return loop, nil
+}
+
+// Post queues fn to be called in the loop, it is safe to be called from other
+// goroutines, and wakes up the loop if it is waiting for events.
+func (loop *AeLoop) Post(fn func()) {
+	loop.postMu.Lock()
+	loop.posted = append(loop.posted, fn)
+	loop.postMu.Unlock()
+
+	var buf [8]byte
+	binary.NativeEndian.PutUint64(buf[:], 1)
+	_, _ = unix.Write(loop.wakeFd, buf[:])
+}
+
+func runPosted(loop *AeLoop, fd int, _ interface{}) {
+	var buf [8]byte
+	_, _ = unix.Read(fd, buf[:])
+
+	loop.postMu.Lock()
+	posted := loop.posted
+	loop.posted = nil
+	loop.postMu.Unlock()
+	for _, fn := range posted {
+		fn()
+	}
 }
 
 func (loop *AeLoop) nearestTime() int64 {
 	var nearest = GetMsTime() + 1000
-	p := loop.TimeEvents
-	for p != nil {
-		nearest = min(nearest, p.when)
-		p = p.next
+	if len(loop.TimeEvents) > 0 {
+		nearest = min(nearest, loop.TimeEvents[0].when)
 	}
 	return nearest
 }
@@ -158,64 +289,79 @@ func (loop *AeLoop) AeWait() (tes []*AeTimeEvent, fes []*AeFileEvent) {
 	if timeout <= 0 {
 		timeout = 10 // at least wait 10ms
 	}
+	fes = loop.waitFileEvents(loop.events[:0], int(timeout))
+
+	// collect time events, they are popped from heap and pushed back by
+	// AeProcess if repeated.
+	now := GetMsTime()
+	for len(loop.TimeEvents) > 0 && loop.TimeEvents[0].when <= now {
+		tes = append(tes, heap.Pop(&loop.TimeEvents).(*AeTimeEvent))
+	}
+	return
+}
 
-	var events [128]unix.EpollEvent
-retry:
-	n, err := unix.EpollWait(loop.fileEventFd, events[:], int(timeout))
+// waitFileEvents waits at most timeout(ms) and appends the ready file events to fes.
+func (loop *AeLoop) waitFileEvents(fes []*AeFileEvent, timeout int) []*AeFileEvent {
+	evs, err := loop.poller.wait(loop.pollEvents[:0], timeout)
 	if err != nil {
-		// interrupted system call
-		if errors.Is(err, unix.EINTR) {
-			goto retry
-		}
-		log.Error().Msgf("epoll wait error: %v", err)
-		return
+		log.Error().Msgf("%s wait error: %v", loop.poller.name(), err)
+		return fes
 	}
+	loop.pollEvents = evs[:0]
 
 	// collect file events
-	fes = loop.events[:0]
-	for _, ev := range events[:n] {
-		if ev.Events&unix.EPOLLIN != 0 {
-			fe := loop.FileEvents[int(ev.Fd)]
+	for _, ev := range evs {
+		if ev.mask&pollRead != 0 {
+			fe := loop.FileEvents[ev.fd]
 			if fe != nil {
 				fes = append(fes, fe)
 			}
 		}
-		if ev.Events&unix.EPOLLOUT != 0 {
-			fe := loop.FileEvents[int(ev.Fd)]
+		if ev.mask&pollWrite != 0 {
+			fe := loop.FileEvents[ev.fd]
 			if fe != nil {
 				fes = append(fes, fe)
 			}
 		}
 	}
+	return fes
+}
 
-	// collect time events
-	now := GetMsTime()
-	p := loop.TimeEvents
-	for p != nil {
-		if p.when <= now {
-			tes = append(tes, p)
-		}
-		p = p.next
-	}
-	return
+// ProcessFileEvents waits at most timeout(ms) and processes file events only, it
+// is used to serve clients while the loop is blocked by a busy script.
+// The events cache is not used since it is called inside processing of events.
+func (loop *AeLoop) ProcessFileEvents(timeout int) {
+	loop.AeProcess(nil, loop.waitFileEvents(nil, timeout))
 }
 
 func (loop *AeLoop) AeProcess(tes []*AeTimeEvent, fes []*AeFileEvent) {
 	for _, te := range tes {
+		// te may be removed by the procs before.
+		if loop.timeEventsByID[te.id] != te {
+			continue
+		}
 		te.proc(loop, te.id, te.extra)
 		if te.mask == AeOnce {
 			loop.RemoveTimeEvent(te.id)
-		} else {
+		} else if loop.timeEventsByID[te.id] == te {
 			te.when = GetMsTime() + te.interval
+			heap.Push(&loop.TimeEvents, te)
 		}
 	}
 	for _, fe := range fes {
+		// fe may be removed by the procs before, and fd may be reused.
+		if loop.FileEvents[fe.fd] != fe {
+			continue
+		}
 		fe.proc(loop, fe.fd, fe.extra)
 	}
 }
 
 func (loop *AeLoop) AeMain() {
 	for {
+		if loop.BeforeSleep != nil {
+			loop.BeforeSleep(loop)
+		}
 		loop.AeProcess(loop.AeWait())
 	}
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// epollPoller is the default poller of AeLoop.
+type epollPoller struct {
+	fd     int
+	events [128]unix.EpollEvent
+}
+
+func newEpollPoller() (*epollPoller, error) {
+	fd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	return &epollPoller{fd: fd}, nil
+}
+
+func epollEvents(mask pollMask) uint32 {
+	var events uint32
+	if mask&pollRead != 0 {
+		events |= unix.EPOLLIN
+	}
+	if mask&pollWrite != 0 {
+		events |= unix.EPOLLOUT
+	}
+	return events
+}
+
+func (p *epollPoller) add(fd int, mask pollMask) error {
+	return unix.EpollCtl(p.fd, unix.EPOLL_CTL_ADD, fd, &unix.EpollEvent{
+		Fd:     int32(fd),
+		Events: epollEvents(mask),
+	})
+}
+
+func (p *epollPoller) mod(fd int, mask pollMask) error {
+	return unix.EpollCtl(p.fd, unix.EPOLL_CTL_MOD, fd, &unix.EpollEvent{
+		Fd:     int32(fd),
+		Events: epollEvents(mask),
+	})
+}
+
+func (p *epollPoller) del(fd int) error {
+	return unix.EpollCtl(p.fd, unix.EPOLL_CTL_DEL, fd, &unix.EpollEvent{
+		Fd:     int32(fd),
+		Events: unix.EPOLLIN | unix.EPOLLOUT,
+	})
+}
+
+func (p *epollPoller) wait(evs []pollEvent, timeout int) ([]pollEvent, error) {
+retry:
+	n, err := unix.EpollWait(p.fd, p.events[:], timeout)
+	if err != nil {
+		// interrupted system call
+		if errors.Is(err, unix.EINTR) {
+			goto retry
+		}
+		return evs, err
+	}
+	for _, ev := range p.events[:n] {
+		var mask pollMask
+		if ev.Events&unix.EPOLLIN != 0 {
+			mask |= pollRead
+		}
+		if ev.Events&unix.EPOLLOUT != 0 {
+			mask |= pollWrite
+		}
+		evs = append(evs, pollEvent{fd: int(ev.Fd), mask: mask})
+	}
+	return evs, nil
+}
+
+func (p *epollPoller) name() string { return "epoll" }
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestPoller(t *testing.T) {
+	pollers := map[string]func() (poller, error){
+		"epoll": func() (poller, error) { return newEpollPoller() },
+		"io_uring": func() (poller, error) {
+			p, err := newUringPoller(4)
+			if err != nil {
+				t.Skipf("io_uring not available: %v", err)
+			}
+			return p, nil
+		},
+	}
+	for name, newPoller := range pollers {
+		t.Run(name, func(t *testing.T) {
+			ast := assert.New(t)
+			p, err := newPoller()
+			ast.Nil(err)
+			ast.Equal(name, p.name())
+
+			fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_NONBLOCK, 0)
+			ast.Nil(err)
+			defer unix.Close(fds[0])
+			defer unix.Close(fds[1])
+			fd := fds[0]
+
+			// timeout
+			ast.Nil(p.add(fd, pollRead))
+			evs, err := p.wait(nil, 10)
+			ast.Nil(err)
+			ast.Empty(evs)
+
+			// readable, epoll reports it again until read.
+			_, _ = unix.Write(fds[1], []byte("ping"))
+			evs, err = p.wait(nil, 100)
+			ast.Nil(err)
+			ast.Equal([]pollEvent{{fd: fd, mask: pollRead}}, evs)
+			evs, _ = p.wait(nil, 10)
+			if name == "epoll" {
+				ast.Equal([]pollEvent{{fd: fd, mask: pollRead}}, evs)
+			} else {
+				ast.Empty(evs)
+			}
+			buf := make([]byte, 16)
+			n, _ := unix.Read(fd, buf)
+			ast.Equal(4, n)
+			evs, _ = p.wait(nil, 10)
+			ast.Empty(evs)
+
+			// writable
+			ast.Nil(p.mod(fd, pollWrite))
+			evs, _ = p.wait(nil, 100)
+			ast.Equal([]pollEvent{{fd: fd, mask: pollWrite}}, evs)
+
+			// back to readable while the request is armed.
+			ast.Nil(p.mod(fd, pollRead))
+			_, _ = unix.Write(fds[1], []byte("pong"))
+			evs, _ = p.wait(nil, 100)
+			ast.Equal([]pollEvent{{fd: fd, mask: pollRead}}, evs)
+
+			// detached
+			ast.Nil(p.del(fd))
+			evs, _ = p.wait(nil, 10)
+			ast.Empty(evs)
+
+			// hangup is reported as readable.
+			ast.Nil(p.add(fd, pollRead))
+			_, _ = unix.Read(fd, buf)
+			_ = unix.Shutdown(fds[1], unix.SHUT_WR)
+			evs, _ = p.wait(nil, 100)
+			ast.Equal([]pollEvent{{fd: fd, mask: pollRead}}, evs)
+		})
+	}
+}
+
+func TestSendBatch(t *testing.T) {
+	ast := assert.New(t)
+	p, err := newUringPoller(4)
+	if err != nil {
+		t.Skipf("io_uring not available: %v", err)
+	}
+	var reqs []SendRequest
+	var peers []int
+	for i := range 8 {
+		pair, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_NONBLOCK, 0)
+		ast.Nil(err)
+		defer unix.Close(pair[0])
+		defer unix.Close(pair[1])
+		ast.Nil(p.add(pair[0], pollRead))
+		reqs = append(reqs, SendRequest{Fd: pair[0], Buf: []byte(strconv.Itoa(i))})
+		peers = append(peers, pair[1])
+	}
+	// socket is full
+	full := reqs[7].Fd
+	for {
+		if _, err := unix.Write(full, make([]byte, 4096)); err != nil {
+			break
+		}
+	}
+	// socket is closed
+	reqs = append(reqs, SendRequest{Fd: -1, Buf: []byte("x")})
+
+	p.sendBatch(reqs)
+	for i, peer := range peers[:7] {
+		ast.Nil(reqs[i].Err)
+		ast.Equal(1, reqs[i].N)
+		buf := make([]byte, 4)
+		n, _ := unix.Read(peer, buf)
+		ast.Equal(strconv.Itoa(i), string(buf[:n]))
+	}
+	ast.Equal(unix.EAGAIN, reqs[7].Err)
+	ast.Equal(unix.EBADF, reqs[8].Err)
+
+	// polls are not affected
+	_, _ = unix.Write(peers[0], []byte("ping"))
+	evs, err := p.wait(nil, 100)
+	ast.Nil(err)
+	ast.Equal([]pollEvent{{fd: reqs[0].Fd, mask: pollRead}}, evs)
+}
+
+func TestPollerManyFds(t *testing.T) {
+	ast := assert.New(t)
+	// more fds than entries of ring, requests are submitted when ring is full.
+	p, err := newUringPoller(4)
+	if err != nil {
+		t.Skipf("io_uring not available: %v", err)
+	}
+	var fds [][2]int
+	for range 64 {
+		pair, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_NONBLOCK, 0)
+		ast.Nil(err)
+		defer unix.Close(pair[0])
+		defer unix.Close(pair[1])
+		fds = append(fds, [2]int{pair[0], pair[1]})
+		ast.Nil(p.add(pair[0], pollRead))
+	}
+	for _, pair := range fds {
+		_, _ = unix.Write(pair[1], []byte("ping"))
+	}
+	ready := make(map[int]bool)
+	for range 10 {
+		evs, err := p.wait(nil, 100)
+		ast.Nil(err)
+		for _, ev := range evs {
+			ready[ev.fd] = true
+		}
+	}
+	ast.Equal(len(fds), len(ready))
+}
+
+func TestFileEventRemoved(t *testing.T) {
+	ast := assert.New(t)
+	loop, err := AeLoopCreate("epoll")
+	ast.Nil(err)
+
+	var fds []int
+	for range 2 {
+		pair, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_NONBLOCK, 0)
+		ast.Nil(err)
+		defer unix.Close(pair[1])
+		_, _ = unix.Write(pair[1], []byte("ping"))
+		fds = append(fds, pair[0])
+	}
+	// both are ready in the same batch, the first one processed closes the other,
+	// whose fd is reused by a new file event.
+	var called []int
+	proc := func(loop *AeLoop, fd int, _ interface{}) {
+		called = append(called, fd)
+		other := fds[0] + fds[1] - fd
+		if loop.FileEvents[other] == nil {
+			return
+		}
+		loop.ModDetach(other)
+		_ = unix.Close(other)
+		pair, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_NONBLOCK, 0)
+		ast.Nil(err)
+		defer unix.Close(pair[1])
+		loop.AddRead(pair[0], func(*AeLoop, int, interface{}) { t.Error("new file event fired") }, nil)
+	}
+	for _, fd := range fds {
+		loop.AddRead(fd, proc, nil)
+	}
+	tes, fes := loop.AeWait()
+	ast.Len(fes, 2)
+	loop.AeProcess(tes, fes)
+	ast.Len(called, 1)
+}
+
+func TestTimeEvent(t *testing.T) {
+	ast := assert.New(t)
+	loop, err := AeLoopCreate("epoll")
+	ast.Nil(err)
+
+	var onceCount, normalCount int
+	loop.AddTimeEvent(AeOnce, 10, func(_ *AeLoop, _ int, _ interface{}) { onceCount++ }, nil)
+	normal := loop.AddTimeEvent(AeNormal, 10, func(_ *AeLoop, _ int, _ interface{}) { normalCount++ }, nil)
+
+	// timers removed before fired.
+	removed := make([]int, 0, 1000)
+	for i := range 1000 {
+		removed = append(removed, loop.AddTimeEvent(AeOnce, int64(i%50), func(_ *AeLoop, _ int, _ interface{}) {
+			t.Error("removed time event fired")
+		}, nil))
+	}
+	for _, id := range removed {
+		loop.RemoveTimeEvent(id)
+	}
+	ast.Equal(2, len(loop.TimeEvents))
+
+	for range 5 {
+		time.Sleep(15 * time.Millisecond)
+		loop.AeProcess(loop.AeWait())
+	}
+	ast.Equal(1, onceCount)
+	ast.GreaterOrEqual(normalCount, 4)
+	ast.Equal(1, len(loop.TimeEvents))
+
+	// removes itself and another one due at the same time.
+	var first, second int
+	var fired int
+	first = loop.AddTimeEvent(AeNormal, 0, func(loop *AeLoop, id int, _ interface{}) {
+		fired++
+		loop.RemoveTimeEvent(id)
+		loop.RemoveTimeEvent(second)
+	}, nil)
+	second = loop.AddTimeEvent(AeNormal, 0, func(loop *AeLoop, id int, _ interface{}) {
+		fired++
+		loop.RemoveTimeEvent(first)
+	}, nil)
+	loop.RemoveTimeEvent(normal)
+	time.Sleep(time.Millisecond)
+	loop.AeProcess(loop.AeWait())
+	ast.Equal(1, fired)
+	ast.Empty(loop.TimeEvents)
+	ast.Empty(loop.timeEventsByID)
+
+	// the nearest one
+	loop.AddTimeEvent(AeOnce, 500, func(_ *AeLoop, _ int, _ interface{}) {}, nil)
+	loop.AddTimeEvent(AeOnce, 200, func(_ *AeLoop, _ int, _ interface{}) {}, nil)
+	ast.InDelta(GetMsTime()+200, loop.nearestTime(), 5)
+}
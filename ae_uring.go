@@ -0,0 +1,506 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// io_uring ABI, see include/uapi/linux/io_uring.h.
+const (
+	uringOpPollAdd    = 6
+	uringOpPollRemove = 7
+	uringOpSend       = 26
+
+	uringPollAddMulti     = 1 << 0
+	uringPollUpdateEvents = 1 << 1
+
+	uringCqeFMore = 1 << 1
+
+	uringEnterGetEvents = 1 << 0
+	uringEnterExtArg    = 1 << 3
+
+	uringFeatSingleMmap = 1 << 0
+	uringFeatNoDrop     = 1 << 1
+	uringFeatExtArg     = 1 << 8
+	// uringFeatRsrcTags comes with kernel 5.13, which supports multishot poll
+	// and POLL_UPDATE too.
+	uringFeatRsrcTags = 1 << 10
+
+	uringOffSqRing = 0
+	uringOffSqes   = 0x10000000
+
+	uringEntries = 1024
+
+	// uringCtlTag marks user data of POLL_REMOVE and POLL_UPDATE requests, whose
+	// completions are ignored.
+	uringCtlTag = 1 << 63
+	// uringSendTag marks user data of SEND requests, with the sequence of batch
+	// and the index in batch.
+	uringSendTag = 1 << 62
+)
+
+type uringSqOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type uringCqOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type uringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFd uint32
+	resv                                                                   [3]uint32
+	sqOff                                                                  uringSqOffsets
+	cqOff                                                                  uringCqOffsets
+}
+
+type uringSqe struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	opFlags     uint32 // poll32_events for POLL_ADD
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	addr3       uint64
+	pad         uint64
+}
+
+type uringCqe struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+type uringGetEventsArg struct {
+	sigmask   uint64
+	sigmaskSz uint32
+	pad       uint32
+	ts        uint64
+}
+
+// uringFd is the state of fd watched by uringPoller.
+type uringFd struct {
+	mask pollMask
+	// userData identifies the armed poll request, stale completions of removed
+	// requests are ignored by comparing it.
+	userData uint64
+	armed    bool
+	queued   bool
+	// revents is the events completed since last wait, failed is set if the
+	// poll request completed with error.
+	revents uint32
+	failed  bool
+}
+
+// uringPoller is the poller based on io_uring. Each fd has a multishot poll
+// request, which stays armed and completes each time fd becomes ready, so it is
+// edge-triggered. The mask is changed by POLL_UPDATE in place. Requests are
+// queued in the submission ring and submitted in batch by the io_uring_enter
+// that waits for completions, and replies to many clients are sent by a batch
+// of SEND requests with one io_uring_enter.
+type uringPoller struct {
+	fd     int
+	ring   []byte
+	sqeMem []byte
+
+	sqHead, sqTail *uint32
+	sqMask         uint32
+	sqTailLocal    uint32
+	sqes           []uringSqe
+
+	cqHead, cqTail *uint32
+	cqMask         uint32
+	cqes           []uringCqe
+
+	fds     map[int]*uringFd
+	toArm   []int
+	ready   []int // fds completed, which may be duplicated
+	nextSeq uint64
+
+	// sending is the batch being sent, pending is the number of sends not
+	// completed.
+	sending []SendRequest
+	pending int
+	sendSeq uint32
+
+	// arg and ts are kept in heap since they are passed to kernel by address.
+	arg uringGetEventsArg
+	ts  unix.Timespec
+}
+
+func newUringPoller(entries uint32) (*uringPoller, error) {
+	var params uringParams
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(&params)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("io_uring_setup: %w", errno)
+	}
+	p := &uringPoller{fd: int(fd), fds: make(map[int]*uringFd)}
+	unix.CloseOnExec(p.fd)
+
+	const required = uringFeatSingleMmap | uringFeatNoDrop | uringFeatExtArg | uringFeatRsrcTags
+	if params.features&required != required {
+		p.close()
+		return nil, fmt.Errorf("io_uring features %#x not supported", params.features)
+	}
+
+	sqSize := params.sqOff.array + params.sqEntries*4
+	cqSize := params.cqOff.cqes + params.cqEntries*uint32(unsafe.Sizeof(uringCqe{}))
+	ring, err := unix.Mmap(p.fd, uringOffSqRing, int(max(sqSize, cqSize)),
+		unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		p.close()
+		return nil, err
+	}
+	p.ring = ring
+	sqeMem, err := unix.Mmap(p.fd, uringOffSqes, int(params.sqEntries)*int(unsafe.Sizeof(uringSqe{})),
+		unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		p.close()
+		return nil, err
+	}
+	p.sqeMem = sqeMem
+
+	p.sqHead = p.ringU32(params.sqOff.head)
+	p.sqTail = p.ringU32(params.sqOff.tail)
+	p.sqMask = *p.ringU32(params.sqOff.ringMask)
+	p.sqTailLocal = *p.sqTail
+	p.sqes = unsafe.Slice((*uringSqe)(unsafe.Pointer(&sqeMem[0])), params.sqEntries)
+	// sqes are used in order, so that the index array is fixed.
+	array := unsafe.Slice(p.ringU32(params.sqOff.array), params.sqEntries)
+	for i := range array {
+		array[i] = uint32(i)
+	}
+
+	p.cqHead = p.ringU32(params.cqOff.head)
+	p.cqTail = p.ringU32(params.cqOff.tail)
+	p.cqMask = *p.ringU32(params.cqOff.ringMask)
+	p.cqes = unsafe.Slice((*uringCqe)(unsafe.Pointer(&ring[params.cqOff.cqes])), params.cqEntries)
+
+	return p, nil
+}
+
+func (p *uringPoller) ringU32(off uint32) *uint32 {
+	return (*uint32)(unsafe.Pointer(&p.ring[off]))
+}
+
+func (p *uringPoller) close() {
+	if p.sqeMem != nil {
+		_ = unix.Munmap(p.sqeMem)
+	}
+	if p.ring != nil {
+		_ = unix.Munmap(p.ring)
+	}
+	_ = unix.Close(p.fd)
+}
+
+// getSqe returns the next free sqe, queued sqes are submitted if ring is full.
+func (p *uringPoller) getSqe() (*uringSqe, error) {
+	for p.sqTailLocal-atomic.LoadUint32(p.sqHead) > p.sqMask {
+		if err := p.enter(0, 0); err != nil {
+			return nil, err
+		}
+	}
+	sqe := &p.sqes[p.sqTailLocal&p.sqMask]
+	*sqe = uringSqe{}
+	p.sqTailLocal++
+	return sqe, nil
+}
+
+// enter submits the queued sqes and waits for at least minComplete cqes.
+func (p *uringPoller) enter(minComplete uint32, flags uintptr) error {
+	atomic.StoreUint32(p.sqTail, p.sqTailLocal)
+	toSubmit := p.sqTailLocal - atomic.LoadUint32(p.sqHead)
+	if minComplete > 0 {
+		flags |= uringEnterGetEvents
+	}
+	var arg, argSz uintptr
+	if flags&uringEnterExtArg != 0 {
+		arg, argSz = uintptr(unsafe.Pointer(&p.arg)), unsafe.Sizeof(p.arg)
+	}
+	_, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(p.fd), uintptr(toSubmit), uintptr(minComplete), flags, arg, argSz)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func pollEvents(mask pollMask) uint32 {
+	var events uint32
+	if mask&pollRead != 0 {
+		events |= unix.POLLIN
+	}
+	if mask&pollWrite != 0 {
+		events |= unix.POLLOUT
+	}
+	return events
+}
+
+// queueArm queues fd to be armed in the next wait.
+func (p *uringPoller) queueArm(fd int, st *uringFd) {
+	if !st.queued {
+		st.queued = true
+		p.toArm = append(p.toArm, fd)
+	}
+}
+
+// disarm queues the removal of armed poll request of fd.
+func (p *uringPoller) disarm(st *uringFd) error {
+	if !st.armed {
+		return nil
+	}
+	sqe, err := p.getSqe()
+	if err != nil {
+		return err
+	}
+	sqe.opcode = uringOpPollRemove
+	sqe.fd = -1
+	sqe.addr = st.userData
+	sqe.userData = uringCtlTag
+	st.armed = false
+	return nil
+}
+
+func (p *uringPoller) add(fd int, mask pollMask) error {
+	if _, ok := p.fds[fd]; ok {
+		return unix.EEXIST
+	}
+	st := &uringFd{mask: mask}
+	p.fds[fd] = st
+	p.queueArm(fd, st)
+	return nil
+}
+
+// mod updates the events of armed poll request in place, which is re-evaluated
+// by kernel so that fd already ready is completed at once.
+func (p *uringPoller) mod(fd int, mask pollMask) error {
+	st, ok := p.fds[fd]
+	if !ok {
+		return unix.ENOENT
+	}
+	if st.mask == mask {
+		return nil
+	}
+	st.mask = mask
+	if !st.armed {
+		p.queueArm(fd, st)
+		return nil
+	}
+	sqe, err := p.getSqe()
+	if err != nil {
+		return err
+	}
+	sqe.opcode = uringOpPollRemove
+	sqe.fd = -1
+	sqe.len = uringPollUpdateEvents | uringPollAddMulti
+	sqe.addr = st.userData
+	sqe.opFlags = pollEvents(mask)
+	sqe.userData = uringCtlTag
+	return nil
+}
+
+// del removes fd and submits at once, since the armed request holds the file
+// until removed, which delays closing of the connection.
+func (p *uringPoller) del(fd int) error {
+	st, ok := p.fds[fd]
+	if !ok {
+		return unix.ENOENT
+	}
+	delete(p.fds, fd)
+	if !st.armed {
+		return nil
+	}
+	if err := p.disarm(st); err != nil {
+		return err
+	}
+	return p.enter(0, 0)
+}
+
+// arm queues multishot poll requests of fds not armed.
+func (p *uringPoller) arm() error {
+	for i, fd := range p.toArm {
+		st, ok := p.fds[fd]
+		if !ok || !st.queued {
+			continue
+		}
+		sqe, err := p.getSqe()
+		if err != nil {
+			p.toArm = p.toArm[i:]
+			return err
+		}
+		p.nextSeq++
+		st.userData = uint64(fd)<<32 | p.nextSeq&0xffffffff
+		st.armed = true
+		st.queued = false
+		sqe.opcode = uringOpPollAdd
+		sqe.fd = int32(fd)
+		sqe.len = uringPollAddMulti
+		sqe.opFlags = pollEvents(st.mask)
+		sqe.userData = st.userData
+	}
+	p.toArm = p.toArm[:0]
+	return nil
+}
+
+func (p *uringPoller) wait(evs []pollEvent, timeout int) ([]pollEvent, error) {
+	if err := p.arm(); err != nil {
+		return evs, err
+	}
+	var err error
+	if len(p.ready) > 0 || atomic.LoadUint32(p.cqTail) != *p.cqHead || timeout == 0 {
+		// completions ready, submit without waiting
+		err = p.enter(0, 0)
+	} else {
+		flags := uintptr(uringEnterExtArg)
+		if timeout > 0 {
+			p.ts = unix.NsecToTimespec(int64(timeout) * 1e6)
+			p.arg.ts = uint64(uintptr(unsafe.Pointer(&p.ts)))
+		} else {
+			p.arg.ts = 0
+		}
+		err = p.enter(1, flags)
+	}
+	if err != nil && !errors.Is(err, unix.ETIME) && !errors.Is(err, unix.EINTR) {
+		return evs, err
+	}
+	p.reap()
+
+	for _, fd := range p.ready {
+		st, ok := p.fds[fd]
+		if !ok || st.revents == 0 && !st.failed {
+			continue
+		}
+		var mask pollMask
+		if st.failed {
+			// let the handler meet the error
+			mask = st.mask
+		}
+		if st.revents&unix.POLLIN != 0 {
+			mask |= pollRead
+		}
+		if st.revents&unix.POLLOUT != 0 {
+			mask |= pollWrite
+		}
+		if st.revents&(unix.POLLERR|unix.POLLHUP) != 0 {
+			mask |= st.mask
+		}
+		st.revents, st.failed = 0, false
+		if mask &= st.mask; mask != 0 {
+			evs = append(evs, pollEvent{fd: fd, mask: mask})
+		}
+	}
+	p.ready = p.ready[:0]
+	return evs, nil
+}
+
+// reap consumes the completions. Events of fds are merged until the next wait,
+// and fds whose multishot request terminated are queued to be re-armed.
+func (p *uringPoller) reap() {
+	head := *p.cqHead
+	tail := atomic.LoadUint32(p.cqTail)
+	for ; head != tail; head++ {
+		cqe := p.cqes[head&p.cqMask]
+		switch {
+		case cqe.userData&uringCtlTag != 0:
+			continue
+		case cqe.userData&uringSendTag != 0:
+			p.sendDone(uint32(cqe.userData>>32), int(uint32(cqe.userData)), cqe.res)
+			continue
+		}
+		fd := int(cqe.userData >> 32)
+		st, ok := p.fds[fd]
+		if !ok || !st.armed || st.userData != cqe.userData {
+			continue // stale
+		}
+		if cqe.flags&uringCqeFMore == 0 {
+			st.armed = false
+			p.queueArm(fd, st)
+		}
+		if st.revents == 0 && !st.failed {
+			p.ready = append(p.ready, fd)
+		}
+		if cqe.res < 0 {
+			st.failed = true
+		} else {
+			st.revents |= uint32(cqe.res)
+		}
+	}
+	atomic.StoreUint32(p.cqHead, head)
+}
+
+// sendBatch sends reqs by SEND requests submitted with one io_uring_enter. Since
+// sockets are non-blocking and MSG_DONTWAIT is set, requests complete at once
+// with the result like write(2).
+func (p *uringPoller) sendBatch(reqs []SendRequest) {
+	p.sendSeq++
+	p.sending, p.pending = reqs, 0
+	defer func() {
+		p.sending = nil
+		runtime.KeepAlive(reqs)
+	}()
+	for i := range reqs {
+		reqs[i].N, reqs[i].Err, reqs[i].done = 0, nil, false
+	}
+	for i := range reqs {
+		req := &reqs[i]
+		sqe, err := p.getSqe()
+		if err != nil {
+			p.failSends(i, err)
+			break
+		}
+		sqe.opcode = uringOpSend
+		sqe.fd = int32(req.Fd)
+		sqe.addr = uint64(uintptr(unsafe.Pointer(unsafe.SliceData(req.Buf))))
+		sqe.len = uint32(min(len(req.Buf), math.MaxInt32))
+		sqe.opFlags = unix.MSG_DONTWAIT | unix.MSG_NOSIGNAL
+		sqe.userData = uringSendTag | uint64(p.sendSeq&(1<<30-1))<<32 | uint64(uint32(i))
+		p.pending++
+	}
+	for p.pending > 0 {
+		err := p.enter(uint32(p.pending), 0)
+		p.reap()
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			p.failSends(0, err)
+			return
+		}
+	}
+}
+
+func (p *uringPoller) sendDone(seq uint32, i int, res int32) {
+	// requests of failed batch may complete later.
+	if seq&(1<<30-1) != p.sendSeq&(1<<30-1) || i >= len(p.sending) || p.sending[i].done {
+		return
+	}
+	req := &p.sending[i]
+	if res < 0 {
+		req.Err = unix.Errno(-res)
+	} else {
+		req.N = int(res)
+	}
+	req.done = true
+	p.pending--
+}
+
+// failSends sets err to the requests from i not completed.
+func (p *uringPoller) failSends(i int, err error) {
+	for ; i < len(p.sending); i++ {
+		if req := &p.sending[i]; !req.done {
+			req.Err, req.done = err, true
+		}
+	}
+}
+
+func (p *uringPoller) name() string { return "io_uring" }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/tidwall/redcon"
+)
+
+// blockedState is the state of a client blocked on keys.
+type blockedState struct {
+	keys []string
+	cmd  *Command
+	args []redcon.RESP
+	// timer is the id of timeout time event, 0 means block forever.
+	timer int
+}
+
+// blockForKeys blocks the current client on keys until one of them is signaled
+// by signalKeyAsReady, and then the command is executed again with args.
+// The client gets a null array reply if timeout(ms) reached, 0 means block
+// forever, and the deadline of the first block is kept when blocked again.
+// It returns false when there is no client to block, such as loading aof, or
+// when executing a transaction or script, which never blocks.
+func blockForKeys(keys []string, timeout int64, args []redcon.RESP) bool {
+	client := server.current
+	if client == nil || server.inExec || server.script != nil {
+		return false
+	}
+	bs := &blockedState{
+		keys: keys,
+		cmd:  client.lastCmd,
+		args: make([]redcon.RESP, 0, len(args)),
+	}
+	// args refer to queryBuf, so copy them.
+	for _, arg := range args {
+		bs.args = append(bs.args, redcon.RESP{Data: slices.Clone(arg.Bytes())})
+	}
+	for _, key := range keys {
+		server.blockingKeys[key] = append(server.blockingKeys[key], client)
+	}
+	if client.blockDeadline == 0 && timeout > 0 {
+		// overflowed deadline means never.
+		client.blockDeadline = max(GetMsTime()+timeout, 0)
+	}
+	if client.blockDeadline > 0 {
+		remain := max(client.blockDeadline-GetMsTime(), 1)
+		bs.timer = server.aeLoop.AddTimeEvent(AeOnce, remain, blockTimeoutProc, client)
+	}
+	client.blocked = bs
+	return true
+}
+
+func unblockClient(client *Client) {
+	bs := client.blocked
+	for _, key := range bs.keys {
+		clients := slices.DeleteFunc(server.blockingKeys[key], func(c *Client) bool {
+			return c == client
+		})
+		if len(clients) == 0 {
+			delete(server.blockingKeys, key)
+		} else {
+			server.blockingKeys[key] = clients
+		}
+	}
+	if bs.timer > 0 {
+		server.aeLoop.RemoveTimeEvent(bs.timer)
+	}
+	client.blocked = nil
+}
+
+func blockTimeoutProc(_ *AeLoop, id int, extra interface{}) {
+	client := extra.(*Client)
+	if client.blocked == nil || client.blocked.timer != id {
+		return
+	}
+	unblockClient(client)
+	client.blockDeadline = 0
+	client.replyWriter.WriteNullArray()
+	ProcessQueryBuf(client)
+}
+
+// signalKeyAsReady marks key as ready to serve clients blocked on it.
+func signalKeyAsReady(key string) {
+	if _, ok := server.blockingKeys[key]; ok && !slices.Contains(server.readyKeys, key) {
+		server.readyKeys = append(server.readyKeys, key)
+	}
+}
+
+// handleClientsBlockedOnKeys executes again the commands of clients blocked
+// on ready keys, clients not served remain blocked.
+func handleClientsBlockedOnKeys() {
+	for len(server.readyKeys) > 0 {
+		keys := server.readyKeys
+		server.readyKeys = nil
+
+		for _, key := range keys {
+			clients := slices.Clone(server.blockingKeys[key])
+			for _, client := range clients {
+				bs := client.blocked
+				if bs == nil {
+					continue
+				}
+				unblockClient(client)
+				call(client, bs.cmd, bs.args, nil)
+				// continue processing the rest commands of client.
+				if client.blocked == nil {
+					client.blockDeadline = 0
+					ProcessQueryBuf(client)
+				}
+			}
+		}
+	}
+}
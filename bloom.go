@@ -0,0 +1,253 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/bloom"
+	"github.com/xgzlucario/rotom/internal/cuckoo"
+	"github.com/xgzlucario/rotom/internal/resp"
+)
+
+const (
+	Expansion  = "EXPANSION"
+	NonScaling = "NONSCALING"
+)
+
+func bfReserveCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := b2s(args[0].Bytes())
+	errorRate, err := strconv.ParseFloat(b2s(args[1].Bytes()), 64)
+	if err != nil {
+		writer.WriteError(errBadErrorRate.Error())
+		return
+	}
+	if errorRate <= 0 || errorRate >= 1 {
+		writer.WriteError(errErrorRateRange.Error())
+		return
+	}
+	capacity, err := strconv.ParseUint(b2s(args[2].Bytes()), 10, 64)
+	if err != nil {
+		writer.WriteError(errBadCapacity.Error())
+		return
+	}
+	if capacity == 0 || capacity > bloom.MaxCapacity {
+		writer.WriteError(errCapacityRange.Error())
+		return
+	}
+	if !bloom.ValidParams(capacity, errorRate) {
+		writer.WriteError(errBloomTooLarge.Error())
+		return
+	}
+
+	var expansion uint64 = bloom.DefaultExpansion
+	var nonScaling, hasExpansion bool
+	extra := args[3:]
+	for len(extra) > 0 {
+		arg := b2s(extra[0].Bytes())
+		if equalFold(arg, Expansion) && len(extra) >= 2 {
+			expansion, err = strconv.ParseUint(b2s(extra[1].Bytes()), 10, 64)
+			if err != nil {
+				writer.WriteError(errBadExpansion.Error())
+				return
+			}
+			if expansion == 0 || expansion > bloom.MaxExpansion {
+				writer.WriteError(errExpansionRange.Error())
+				return
+			}
+			hasExpansion = true
+			extra = extra[2:]
+
+		} else if equalFold(arg, NonScaling) {
+			nonScaling = true
+			extra = extra[1:]
+
+		} else {
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+	}
+	if nonScaling {
+		if hasExpansion {
+			writer.WriteError(errNonScalingExpansion.Error())
+			return
+		}
+		expansion = 0
+	}
+
+	if _, ttl := db.dict.Get(key); ttl != KeyNotExist {
+		writer.WriteError(errItemExists.Error())
+		return
+	}
+	db.dict.Set(strings.Clone(key), bloom.New(capacity, errorRate, expansion))
+	writer.WriteString("OK")
+}
+
+func bfAddCommand(writer *resp.Writer, args []redcon.RESP) {
+	bf, err := fetchBloom(args[0].Bytes(), true)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	added, err := bf.Add(b2s(args[1].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writer.WriteInt(b2i(added))
+}
+
+func bfMAddCommand(writer *resp.Writer, args []redcon.RESP) {
+	bf, err := fetchBloom(args[0].Bytes(), true)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writer.WriteArray(len(args) - 1)
+	for _, arg := range args[1:] {
+		added, err := bf.Add(b2s(arg.Bytes()))
+		if err != nil {
+			writer.WriteError(err.Error())
+		} else {
+			writer.WriteInt(b2i(added))
+		}
+	}
+}
+
+func bfExistsCommand(writer *resp.Writer, args []redcon.RESP) {
+	bf, err := fetchBloom(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writer.WriteInt(b2i(bf.Exist(b2s(args[1].Bytes()))))
+}
+
+func bfMExistsCommand(writer *resp.Writer, args []redcon.RESP) {
+	bf, err := fetchBloom(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writer.WriteArray(len(args) - 1)
+	for _, arg := range args[1:] {
+		writer.WriteInt(b2i(bf.Exist(b2s(arg.Bytes()))))
+	}
+}
+
+func bfInfoCommand(writer *resp.Writer, args []redcon.RESP) {
+	object, ttl := db.dict.Get(b2s(args[0].Bytes()))
+	if ttl == KeyNotExist {
+		writer.WriteError(errNotFound.Error())
+		return
+	}
+	bf, ok := object.(*bloom.Bloom)
+	if !ok {
+		writer.WriteError(errWrongType.Error())
+		return
+	}
+
+	writeExpansion := func() {
+		if bf.Expansion() == 0 {
+			writer.WriteNull()
+		} else {
+			writer.WriteUint64(bf.Expansion())
+		}
+	}
+	if len(args) == 1 {
+		writer.WriteArray(10)
+		writer.WriteBulkString("Capacity")
+		writer.WriteUint64(bf.Capacity())
+		writer.WriteBulkString("Size")
+		writer.WriteUint64(bf.Size())
+		writer.WriteBulkString("Number of filters")
+		writer.WriteInt(bf.NumFilters())
+		writer.WriteBulkString("Number of items inserted")
+		writer.WriteUint64(bf.Len())
+		writer.WriteBulkString("Expansion rate")
+		writeExpansion()
+		return
+	}
+
+	writer.WriteArray(1)
+	switch strings.ToUpper(b2s(args[1].Bytes())) {
+	case "CAPACITY":
+		writer.WriteUint64(bf.Capacity())
+	case "SIZE":
+		writer.WriteUint64(bf.Size())
+	case "FILTERS":
+		writer.WriteInt(bf.NumFilters())
+	case "ITEMS":
+		writer.WriteUint64(bf.Len())
+	case "EXPANSION":
+		writeExpansion()
+	default:
+		writer.WriteError(errInvalidInfoArg.Error())
+	}
+}
+
+func cfAddCommand(writer *resp.Writer, args []redcon.RESP) {
+	cf, err := fetchCuckoo(args[0].Bytes(), true)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if err = cf.Add(b2s(args[1].Bytes())); err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writer.WriteInt(1)
+}
+
+func cfAddNXCommand(writer *resp.Writer, args []redcon.RESP) {
+	cf, err := fetchCuckoo(args[0].Bytes(), true)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	added, err := cf.AddNX(b2s(args[1].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writer.WriteInt(b2i(added))
+}
+
+func cfExistsCommand(writer *resp.Writer, args []redcon.RESP) {
+	cf, err := fetchCuckoo(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writer.WriteInt(b2i(cf.Exist(b2s(args[1].Bytes()))))
+}
+
+func cfDelCommand(writer *resp.Writer, args []redcon.RESP) {
+	object, ttl := db.dict.Get(b2s(args[0].Bytes()))
+	if ttl == KeyNotExist {
+		writer.WriteError(errCuckooNotFound.Error())
+		return
+	}
+	cf, ok := object.(*cuckoo.Cuckoo)
+	if !ok {
+		writer.WriteError(errWrongType.Error())
+		return
+	}
+	writer.WriteInt(b2i(cf.Delete(b2s(args[1].Bytes()))))
+}
+
+func cfCountCommand(writer *resp.Writer, args []redcon.RESP) {
+	cf, err := fetchCuckoo(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writer.WriteInt(cf.Count(b2s(args[1].Bytes())))
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
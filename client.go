@@ -0,0 +1,441 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/resp"
+)
+
+const (
+	Type   = "TYPE"
+	ID     = "ID"
+	Addr   = "ADDR"
+	LAddr  = "LADDR"
+	User   = "USER"
+	SkipMe = "SKIPME"
+	Write  = "WRITE"
+	All    = "ALL"
+	Skip   = "SKIP"
+
+	// defaultUser is the user of all clients, since there is no ACL.
+	defaultUser = "default"
+)
+
+// pauseType is the mode of CLIENT PAUSE.
+type pauseType int
+
+const (
+	pauseOff pauseType = iota
+	// pauseWrite pauses the commands which may modify the dataset.
+	pauseWrite
+	pauseAll
+)
+
+// clientClass is the class of client that output buffer limits apply to.
+type clientClass int
+
+const (
+	clientClassNormal clientClass = iota
+	clientClassReplica
+	clientClassPubSub
+	numClientClasses
+)
+
+// outputBufferLimit disconnects client when its output buffer reaches hard, or
+// stays over soft for softSeconds continuously, 0 means no limit.
+type outputBufferLimit struct {
+	hard        int
+	soft        int
+	softSeconds int64
+}
+
+var defaultOutputBufferLimits = [numClientClasses]outputBufferLimit{
+	clientClassNormal:  {},
+	clientClassReplica: {hard: 256 << 20, soft: 64 << 20, softSeconds: 60},
+	clientClassPubSub:  {hard: 32 << 20, soft: 8 << 20, softSeconds: 60},
+}
+
+func clientClassByName(name string) (clientClass, bool) {
+	switch name {
+	case "normal":
+		return clientClassNormal, true
+	case "replica", "slave":
+		return clientClassReplica, true
+	case "pubsub":
+		return clientClassPubSub, true
+	}
+	return 0, false
+}
+
+// outputBufferSize returns the bytes of replies not sent to client.
+func outputBufferSize(client *Client) int {
+	return len(client.replyWriter.Buffer()) + client.replyBytes + connBuffered(client)
+}
+
+// checkClientOutputBufferLimits reports whether client reaches the output buffer
+// limits of its class, it also records the time client goes over soft limit.
+func checkClientOutputBufferLimits(client *Client) bool {
+	class := clientClassNormal
+	if client.subscribed() {
+		class = clientClassPubSub
+	}
+	limit := server.outputBufferLimits[class]
+	size := outputBufferSize(client)
+	if limit.hard > 0 && size >= limit.hard {
+		return true
+	}
+	if limit.soft == 0 || size < limit.soft {
+		client.obufSoftLimitReachedTime = 0
+		return false
+	}
+	now := nowMs()
+	if client.obufSoftLimitReachedTime == 0 {
+		client.obufSoftLimitReachedTime = now
+	}
+	return now-client.obufSoftLimitReachedTime >= limit.softSeconds*1000
+}
+
+// clientFlags returns the flags of client shown in CLIENT LIST.
+func clientFlags(client *Client) string {
+	var flags []byte
+	if client.subscribed() {
+		flags = append(flags, 'P')
+	}
+	if client.multi != nil {
+		flags = append(flags, 'x')
+	}
+	if client.blocked != nil {
+		flags = append(flags, 'b')
+	}
+	if client.dirty {
+		flags = append(flags, 'd')
+	}
+	if client.tracking != nil {
+		flags = append(flags, 't')
+		if client.tracking.bcast {
+			flags = append(flags, 'B')
+		}
+		if id := client.tracking.redirect; id != 0 && server.clientsByID[id] == nil {
+			flags = append(flags, 'R')
+		}
+	}
+	if client.closeAfterReply || client.closeASAP {
+		flags = append(flags, 'A')
+	}
+	if len(flags) == 0 {
+		return "N"
+	}
+	return string(flags)
+}
+
+// clientInfoString returns the line of client in CLIENT LIST and CLIENT INFO.
+func clientInfoString(client *Client) string {
+	now := nowMs()
+	multi := -1
+	if client.multi != nil {
+		multi = len(client.multi.commands)
+	}
+	cmd := "NULL"
+	if client.lastCmd != nil {
+		cmd = client.lastCmd.name
+	}
+	redir := int64(-1)
+	if client.tracking != nil {
+		redir = int64(client.tracking.redirect)
+	}
+	return fmt.Sprintf("id=%d addr=%s laddr=%s fd=%d name=%s age=%d idle=%d flags=%s db=0 "+
+		"sub=%d psub=%d ssub=%d multi=%d qbuf=%d qbuf-free=%d obl=%d oll=%d omem=%d cmd=%s user=%s redir=%d resp=%d",
+		client.id, client.addr, client.laddr, client.fd, client.name,
+		(now-client.ctime)/1000, (now-client.lastInteraction)/1000, clientFlags(client),
+		len(client.channels), len(client.patterns), len(client.shardChannels), multi,
+		client.recvx-client.readx, len(client.queryBuf)-client.recvx, len(client.replyWriter.Buffer()),
+		len(client.reply), client.replyBytes, cmd, defaultUser, redir, client.replyWriter.Proto())
+}
+
+// clientType returns the type of client used by TYPE filter.
+func clientType(client *Client) string {
+	if client.subscribed() {
+		return "pubsub"
+	}
+	return "normal"
+}
+
+func validClientType(typ string) bool {
+	switch typ {
+	case "normal", "master", "replica", "slave", "pubsub":
+		return true
+	}
+	return false
+}
+
+// sortedClients returns all clients ordered by ID.
+func sortedClients() []*Client {
+	return slices.SortedFunc(maps.Values(server.clients), func(a, b *Client) int {
+		return cmp.Compare(a.id, b.id)
+	})
+}
+
+// killClient closes client, and the current client is closed after its reply is
+// sent. Others are freed asynchronously, since their file events may be ready in
+// the same batch.
+func killClient(client *Client) {
+	if client == server.current {
+		client.closeAfterReply = true
+		return
+	}
+	freeClientAsync(client)
+}
+
+// commandPaused reports whether cmd of client is postponed by CLIENT PAUSE, the
+// WRITE mode pauses commands that may modify the dataset, including EXEC of them.
+func commandPaused(client *Client, cmd *Command) bool {
+	switch server.pause {
+	case pauseOff:
+		return false
+	case pauseAll:
+		return cmd.name != "client|unpause"
+	}
+	if cmd.name == "exec" && client.multi != nil {
+		return slices.ContainsFunc(client.multi.commands, func(qc queuedCommand) bool {
+			return qc.cmd.persist
+		})
+	}
+	return cmd.persist || cmd.hasFlag("may_replicate")
+}
+
+func unpauseProc(_ *AeLoop, _ int, _ interface{}) {
+	server.pause = pauseOff
+	server.pauseTimer = 0
+}
+
+// unpauseClients stops pausing, paused clients are resumed before the event loop
+// sleeps.
+func unpauseClients() {
+	if server.pauseTimer > 0 {
+		server.aeLoop.RemoveTimeEvent(server.pauseTimer)
+	}
+	unpauseProc(nil, 0, nil)
+}
+
+func resumePausedClients() {
+	for client := range server.pausedClients {
+		delete(server.pausedClients, client)
+		ProcessQueryBuf(client)
+	}
+}
+
+func clientIDCommand(writer *resp.Writer, _ []redcon.RESP) {
+	if client := server.current; client != nil {
+		writer.WriteInt64(int64(client.id))
+		return
+	}
+	writer.WriteInt(0)
+}
+
+// CLIENT LIST [TYPE normal|master|replica|pubsub] [ID client-id [client-id ...]]
+func clientListCommand(writer *resp.Writer, args []redcon.RESP) {
+	var typ string
+	var ids map[uint64]struct{}
+	for i := 0; i < len(args); i++ {
+		switch arg := b2s(args[i].Bytes()); {
+		case equalFold(arg, Type) && i+1 < len(args):
+			i++
+			typ = strings.ToLower(args[i].String())
+			if !validClientType(typ) {
+				writer.WriteError(errUnknownClientType(typ).Error())
+				return
+			}
+		case equalFold(arg, ID) && i+1 < len(args):
+			ids = make(map[uint64]struct{})
+			for _, arg := range args[i+1:] {
+				id, err := strconv.ParseUint(b2s(arg.Bytes()), 10, 64)
+				if err != nil || id == 0 {
+					writer.WriteError(errInvalidClientID.Error())
+					return
+				}
+				ids[id] = struct{}{}
+			}
+			i = len(args)
+		default:
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+	}
+	var sb strings.Builder
+	for _, client := range sortedClients() {
+		if typ != "" && clientType(client) != typ {
+			continue
+		}
+		if _, ok := ids[client.id]; ids != nil && !ok {
+			continue
+		}
+		sb.WriteString(clientInfoString(client))
+		sb.WriteByte('\n')
+	}
+	writer.WriteVerbatim("txt", sb.String())
+}
+
+func clientInfoCommand(writer *resp.Writer, _ []redcon.RESP) {
+	client := server.current
+	if client == nil {
+		writer.WriteNull()
+		return
+	}
+	writer.WriteVerbatim("txt", clientInfoString(client)+"\n")
+}
+
+// CLIENT SETNAME connection-name
+func clientSetNameCommand(writer *resp.Writer, args []redcon.RESP) {
+	name := args[0].String()
+	if !validClientName(name) {
+		writer.WriteError(errInvalidClientName.Error())
+		return
+	}
+	if client := server.current; client != nil {
+		client.name = name
+	}
+	writer.WriteString("OK")
+}
+
+func clientGetNameCommand(writer *resp.Writer, _ []redcon.RESP) {
+	client := server.current
+	if client == nil || client.name == "" {
+		writer.WriteNull()
+		return
+	}
+	writer.WriteBulkString(client.name)
+}
+
+// CLIENT KILL ip:port
+// CLIENT KILL [ID client-id] [TYPE normal|master|replica|pubsub] [USER username]
+// [ADDR ip:port] [LADDR ip:port] [SKIPME yes|no] [...]
+func clientKillCommand(writer *resp.Writer, args []redcon.RESP) {
+	// the old form kills the client of address and replies OK.
+	if len(args) == 1 {
+		addr := args[0].String()
+		for _, client := range server.clients {
+			if client.addr == addr {
+				killClient(client)
+				writer.WriteString("OK")
+				return
+			}
+		}
+		writer.WriteError(errNoSuchClient.Error())
+		return
+	}
+	if len(args)%2 == 1 {
+		writer.WriteError(errSyntax.Error())
+		return
+	}
+
+	var id uint64
+	var typ, user, addr, laddr string
+	skipMe := true
+	for i := 0; i < len(args); i += 2 {
+		value := args[i+1].String()
+		switch arg := b2s(args[i].Bytes()); {
+		case equalFold(arg, ID):
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || n == 0 {
+				writer.WriteError(errInvalidClientID.Error())
+				return
+			}
+			id = n
+		case equalFold(arg, Type):
+			typ = strings.ToLower(value)
+			if !validClientType(typ) {
+				writer.WriteError(errUnknownClientType(typ).Error())
+				return
+			}
+		case equalFold(arg, User):
+			user = value
+		case equalFold(arg, Addr):
+			addr = value
+		case equalFold(arg, LAddr):
+			laddr = value
+		case equalFold(arg, SkipMe) && equalFold(value, Yes):
+			skipMe = true
+		case equalFold(arg, SkipMe) && equalFold(value, No):
+			skipMe = false
+		default:
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+	}
+
+	killed := 0
+	for _, client := range sortedClients() {
+		switch {
+		case id != 0 && client.id != id,
+			typ != "" && clientType(client) != typ,
+			user != "" && user != defaultUser,
+			addr != "" && client.addr != addr,
+			laddr != "" && client.laddr != laddr,
+			skipMe && client == server.current:
+			continue
+		}
+		killClient(client)
+		killed++
+	}
+	writer.WriteInt(killed)
+}
+
+// CLIENT PAUSE timeout [WRITE | ALL]
+func clientPauseCommand(writer *resp.Writer, args []redcon.RESP) {
+	timeout, err := strconv.ParseInt(b2s(args[0].Bytes()), 10, 64)
+	if err != nil {
+		writer.WriteError(errTimeoutNotInteger.Error())
+		return
+	}
+	if timeout < 0 {
+		writer.WriteError(errTimeoutNegative.Error())
+		return
+	}
+	pause := pauseAll
+	if len(args) > 1 {
+		switch arg := b2s(args[1].Bytes()); {
+		case len(args) == 2 && equalFold(arg, Write):
+			pause = pauseWrite
+		case len(args) == 2 && equalFold(arg, All):
+		default:
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+	}
+	unpauseClients()
+	server.pause = pause
+	server.pauseTimer = server.aeLoop.AddTimeEvent(AeOnce, timeout, unpauseProc, nil)
+	writer.WriteString("OK")
+}
+
+func clientUnpauseCommand(writer *resp.Writer, _ []redcon.RESP) {
+	unpauseClients()
+	writer.WriteString("OK")
+}
+
+// CLIENT REPLY ON|OFF|SKIP
+func clientReplyCommand(writer *resp.Writer, args []redcon.RESP) {
+	client := server.current
+	if client == nil {
+		return
+	}
+	switch arg := b2s(args[0].Bytes()); {
+	case equalFold(arg, On):
+		client.replyOff, client.replySkipNext = false, false
+		writer.WriteString("OK")
+	case equalFold(arg, Off):
+		client.replyOff = true
+	case equalFold(arg, Skip):
+		if !client.replyOff {
+			client.replySkipNext = true
+		}
+	default:
+		writer.WriteError(errSyntax.Error())
+	}
+}
@@ -3,15 +3,27 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"github.com/spf13/viper"
+	"github.com/tidwall/match"
 	"github.com/tidwall/redcon"
 	"github.com/xgzlucario/rotom/internal/resp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 	"unsafe"
 
+	"github.com/xgzlucario/rotom/internal/bloom"
+	"github.com/xgzlucario/rotom/internal/cms"
+	"github.com/xgzlucario/rotom/internal/cuckoo"
 	"github.com/xgzlucario/rotom/internal/hash"
+	"github.com/xgzlucario/rotom/internal/json"
 	"github.com/xgzlucario/rotom/internal/list"
+	"github.com/xgzlucario/rotom/internal/stream"
+	"github.com/xgzlucario/rotom/internal/tdigest"
+	"github.com/xgzlucario/rotom/internal/timeseries"
+	"github.com/xgzlucario/rotom/internal/topk"
+	"github.com/xgzlucario/rotom/internal/vector"
 	"github.com/xgzlucario/rotom/internal/zset"
 )
 
@@ -21,7 +33,11 @@ const (
 	NX         = "NX"
 	EX         = "EX"
 	PX         = "PX"
+	EXAT       = "EXAT"
+	PXAT       = "PXAT"
 	WithScores = "WITHSCORES"
+	Auth       = "AUTH"
+	SetName    = "SETNAME"
 )
 
 type Command struct {
@@ -31,70 +47,317 @@ type Command struct {
 	// handler is this command real database handler function.
 	handler func(writer *resp.Writer, args []redcon.RESP)
 
-	// minArgsNum represents the minimal number of arguments that command accepts.
-	minArgsNum int
+	// arity is the number of arguments including command name, -N means at
+	// least N arguments. Arguments of subcommands include both names.
+	arity int
 
 	// persist indicates whether this command needs to be persisted.
 	// effective when `appendonly` is true.
 	persist bool
+
+	// The metadata below is served by COMMAND.
+
+	// flags is space separated, such as "write denyoom" or "readonly fast".
+	flags string
+	// firstKey, lastKey and keyStep are the positions of keys in arguments, lastKey
+	// is negative when counting from the end. They are all 0 if command has no key,
+	// or keys are found by commandGetKeys for commands with movable keys.
+	firstKey, lastKey, keyStep int
+	group                      string
+	summary                    string
 }
 
-// cmdTable is the list of all available commands.
-var cmdTable = []*Command{
-	{"set", setCommand, 2, true},
-	{"get", getCommand, 1, false},
-	{"del", delCommand, 1, true},
-	{"type", typeCommand, 1, false},
-	{"scan", scanCommand, 1, false},
-	{"incr", incrCommand, 1, true},
-	{"hset", hsetCommand, 3, true},
-	{"hget", hgetCommand, 2, false},
-	{"hdel", hdelCommand, 2, true},
-	{"hgetall", hgetallCommand, 1, false},
-	{"rpush", rpushCommand, 2, true},
-	{"lpush", lpushCommand, 2, true},
-	{"rpop", rpopCommand, 1, true},
-	{"lpop", lpopCommand, 1, true},
-	{"lrange", lrangeCommand, 3, false},
-	{"sadd", saddCommand, 2, true},
-	{"srem", sremCommand, 2, true},
-	{"spop", spopCommand, 1, true},
-	{"smembers", smembersCommand, 1, false},
-	{"zadd", zaddCommand, 3, true},
-	{"zrem", zremCommand, 2, true},
-	{"zrank", zrankCommand, 2, false},
-	{"zpopmin", zpopminCommand, 1, true},
-	{"zrange", zrangeCommand, 3, false},
-	{"ping", pingCommand, 0, false},
-	{"hello", helloCommand, 0, false},
-	{"flushdb", flushdbCommand, 0, true},
-	{"load", loadCommand, 0, false},
-	{"save", saveCommand, 0, false},
-	{"config", configCommand, 2, false},
+var (
+	// cmdTable is the list of all available commands, it is initialized in init()
+	// because blocking commands refer back to the command processing.
+	cmdTable []*Command
+
+	// subcommandTable lists the subcommands of container commands, which are
+	// named as "container|subcommand". Containers without handler must be called
+	// with a subcommand.
+	subcommandTable map[string][]*Command
+
+	// commands and subcommands index the tables above by lowercase names.
+	commands    map[string]*Command
+	subcommands map[string]map[string]*Command
+)
+
+func init() {
+	cmdTable = []*Command{
+		{"set", setCommand, -3, true, "write denyoom", 1, 1, 1, "string", "Sets the string value of a key, ignoring its type."},
+		{"get", getCommand, 2, false, "readonly fast", 1, 1, 1, "string", "Returns the string value of a key."},
+		{"del", delCommand, -2, true, "write", 1, -1, 1, "generic", "Deletes one or more keys."},
+		{"type", typeCommand, 2, false, "readonly fast", 1, 1, 1, "generic", "Determines the type of value stored at a key."},
+		{"scan", scanCommand, -2, false, "readonly", 0, 0, 0, "generic", "Iterates over the key names in the database."},
+		{"incr", incrCommand, 2, true, "write denyoom fast", 1, 1, 1, "string", "Increments the integer value of a key by one."},
+		{"hset", hsetCommand, -4, true, "write denyoom fast", 1, 1, 1, "hash", "Creates or modifies the value of a field in a hash."},
+		{"hget", hgetCommand, 3, false, "readonly fast", 1, 1, 1, "hash", "Returns the value of a field in a hash."},
+		{"hdel", hdelCommand, -3, true, "write fast", 1, 1, 1, "hash", "Deletes one or more fields and their values from a hash."},
+		{"hgetall", hgetallCommand, 2, false, "readonly", 1, 1, 1, "hash", "Returns all fields and values in a hash."},
+		{"rpush", rpushCommand, -3, true, "write denyoom fast", 1, 1, 1, "list", "Appends one or more elements to a list."},
+		{"lpush", lpushCommand, -3, true, "write denyoom fast", 1, 1, 1, "list", "Prepends one or more elements to a list."},
+		{"rpop", rpopCommand, -2, true, "write fast", 1, 1, 1, "list", "Returns and removes the last elements of a list."},
+		{"lpop", lpopCommand, -2, true, "write fast", 1, 1, 1, "list", "Returns and removes the first elements of a list."},
+		{"lrange", lrangeCommand, 4, false, "readonly", 1, 1, 1, "list", "Returns a range of elements from a list."},
+		{"sadd", saddCommand, -3, true, "write denyoom fast", 1, 1, 1, "set", "Adds one or more members to a set."},
+		{"srem", sremCommand, -3, true, "write fast", 1, 1, 1, "set", "Removes one or more members from a set."},
+		{"spop", spopCommand, -2, true, "write fast", 1, 1, 1, "set", "Returns one or more random members from a set after removing them."},
+		{"smembers", smembersCommand, 2, false, "readonly", 1, 1, 1, "set", "Returns all members of a set."},
+		{"zadd", zaddCommand, -4, true, "write denyoom fast", 1, 1, 1, "sorted-set", "Adds one or more members to a sorted set, or updates their scores."},
+		{"zrem", zremCommand, -3, true, "write fast", 1, 1, 1, "sorted-set", "Removes one or more members from a sorted set."},
+		{"zrank", zrankCommand, -3, false, "readonly fast", 1, 1, 1, "sorted-set", "Returns the index of a member in a sorted set ordered by ascending scores."},
+		{"zpopmin", zpopminCommand, -2, true, "write fast", 1, 1, 1, "sorted-set", "Returns the lowest-scoring members from a sorted set after removing them."},
+		{"zrange", zrangeCommand, -4, false, "readonly", 1, 1, 1, "sorted-set", "Returns members in a sorted set within a range of indexes, scores or lexicographical order."},
+		{"zrangestore", zrangestoreCommand, -5, true, "write denyoom", 1, 2, 1, "sorted-set", "Stores a range of members from sorted set in a key."},
+		{"zrevrange", zrevrangeCommand, -4, false, "readonly", 1, 1, 1, "sorted-set", "Returns members in a sorted set within a range of indexes in reverse order."},
+		{"zrangebyscore", zrangebyscoreCommand, -4, false, "readonly", 1, 1, 1, "sorted-set", "Returns members in a sorted set within a range of scores."},
+		{"zrevrangebyscore", zrevrangebyscoreCommand, -4, false, "readonly", 1, 1, 1, "sorted-set", "Returns members in a sorted set within a range of scores in reverse order."},
+		{"zrangebylex", zrangebylexCommand, -4, false, "readonly", 1, 1, 1, "sorted-set", "Returns members in a sorted set within a lexicographical range."},
+		{"zrevrangebylex", zrevrangebylexCommand, -4, false, "readonly", 1, 1, 1, "sorted-set", "Returns members in a sorted set within a lexicographical range in reverse order."},
+		{"zunion", zunionCommand, -3, false, "readonly movablekeys", 0, 0, 0, "sorted-set", "Returns the union of multiple sorted sets."},
+		{"zinter", zinterCommand, -3, false, "readonly movablekeys", 0, 0, 0, "sorted-set", "Returns the intersect of multiple sorted sets."},
+		{"zdiff", zdiffCommand, -3, false, "readonly movablekeys", 0, 0, 0, "sorted-set", "Returns the difference between multiple sorted sets."},
+		{"zunionstore", zunionstoreCommand, -4, true, "write denyoom movablekeys", 1, 1, 1, "sorted-set", "Stores the union of multiple sorted sets in a key."},
+		{"zinterstore", zinterstoreCommand, -4, true, "write denyoom movablekeys", 1, 1, 1, "sorted-set", "Stores the intersect of multiple sorted sets in a key."},
+		{"zdiffstore", zdiffstoreCommand, -4, true, "write denyoom movablekeys", 1, 1, 1, "sorted-set", "Stores the difference of multiple sorted sets in a key."},
+		{"zintercard", zintercardCommand, -3, false, "readonly movablekeys", 0, 0, 0, "sorted-set", "Returns the number of members of the intersect of multiple sorted sets."},
+		{"xadd", xaddCommand, -5, true, "write denyoom fast", 1, 1, 1, "stream", "Appends a new message to a stream. Creates the key if it doesn't exist."},
+		{"xlen", xlenCommand, 2, false, "readonly fast", 1, 1, 1, "stream", "Return the number of messages in a stream."},
+		{"xrange", xrangeCommand, -4, false, "readonly", 1, 1, 1, "stream", "Returns the messages from a stream within a range of IDs."},
+		{"xrevrange", xrevrangeCommand, -4, false, "readonly", 1, 1, 1, "stream", "Returns the messages from a stream within a range of IDs in reverse order."},
+		{"xdel", xdelCommand, -3, true, "write fast", 1, 1, 1, "stream", "Returns the number of messages after removing them from a stream."},
+		{"xtrim", xtrimCommand, -4, true, "write", 1, 1, 1, "stream", "Deletes messages from the beginning of a stream."},
+		{"xread", xreadCommand, -4, false, "readonly blocking movablekeys", 0, 0, 0, "stream", "Returns messages from multiple streams with IDs greater than the ones requested."},
+		{"xgroup", nil, -2, false, "", 0, 0, 0, "stream", "A container for consumer groups commands."},
+		{"xreadgroup", xreadgroupCommand, -7, true, "write blocking movablekeys", 0, 0, 0, "stream", "Returns new or historical messages from a stream for a consumer in a group."},
+		{"xack", xackCommand, -4, true, "write fast", 1, 1, 1, "stream", "Returns the number of messages that were successfully acknowledged by the consumer group member of a stream."},
+		{"xpending", xpendingCommand, -3, false, "readonly", 1, 1, 1, "stream", "Returns the information and entries from a stream consumer group's pending entries list."},
+		{"xclaim", xclaimCommand, -6, true, "write fast", 1, 1, 1, "stream", "Changes, or acquires, ownership of a message in a consumer group."},
+		{"xautoclaim", xautoclaimCommand, -6, true, "write fast", 1, 1, 1, "stream", "Changes, or acquires, ownership of messages in a consumer group, as if the messages were delivered to as consumer group member."},
+		{"bf.reserve", bfReserveCommand, -4, true, "write denyoom", 1, 1, 1, "bf", "Creates a new Bloom Filter."},
+		{"bf.add", bfAddCommand, 3, true, "write denyoom", 1, 1, 1, "bf", "Adds an item to a Bloom Filter."},
+		{"bf.madd", bfMAddCommand, -3, true, "write denyoom", 1, 1, 1, "bf", "Adds one or more items to a Bloom Filter."},
+		{"bf.exists", bfExistsCommand, 3, false, "readonly", 1, 1, 1, "bf", "Checks whether an item exists in a Bloom Filter."},
+		{"bf.mexists", bfMExistsCommand, -3, false, "readonly", 1, 1, 1, "bf", "Checks whether one or more items exist in a Bloom Filter."},
+		{"bf.info", bfInfoCommand, -2, false, "readonly", 1, 1, 1, "bf", "Returns information about a Bloom Filter."},
+		{"cf.add", cfAddCommand, 3, true, "write denyoom", 1, 1, 1, "cf", "Adds an item to a Cuckoo Filter."},
+		{"cf.addnx", cfAddNXCommand, 3, true, "write denyoom", 1, 1, 1, "cf", "Adds an item to a Cuckoo Filter if the item did not exist previously."},
+		{"cf.exists", cfExistsCommand, 3, false, "readonly", 1, 1, 1, "cf", "Checks if an item exists in a Cuckoo Filter."},
+		{"cf.del", cfDelCommand, 3, true, "write", 1, 1, 1, "cf", "Deletes an item from a Cuckoo Filter."},
+		{"cf.count", cfCountCommand, 3, false, "readonly", 1, 1, 1, "cf", "Return the number of times an item might be in a Cuckoo Filter."},
+		{"json.set", jsonSetCommand, -4, true, "write denyoom", 1, 1, 1, "json", "Sets or updates the JSON value at a path."},
+		{"json.get", jsonGetCommand, -2, false, "readonly", 1, 1, 1, "json", "Gets the value at one or more paths in JSON serialized form."},
+		{"json.del", jsonDelCommand, -2, true, "write", 1, 1, 1, "json", "Deletes a value."},
+		{"json.numincrby", jsonNumIncrByCommand, 4, true, "write", 1, 1, 1, "json", "Increments the numeric value at path by a value."},
+		{"json.arrappend", jsonArrAppendCommand, -4, true, "write denyoom", 1, 1, 1, "json", "Append one or more JSON values into the array at path after the last element in it."},
+		{"json.type", jsonTypeCommand, -2, false, "readonly", 1, 1, 1, "json", "Returns the type of the JSON value at path."},
+		{"ts.create", tsCreateCommand, -2, true, "write denyoom", 1, 1, 1, "timeseries", "Create a new time series."},
+		{"ts.add", tsAddCommand, -4, true, "write denyoom", 1, 1, 1, "timeseries", "Append a sample to a time series."},
+		{"ts.range", tsRangeCommand, -4, false, "readonly", 1, 1, 1, "timeseries", "Query a range in forward direction."},
+		{"ts.mrange", tsMRangeCommand, -5, false, "readonly", 0, 0, 0, "timeseries", "Query a range across multiple time series by filters in forward direction."},
+		{"ts.createrule", tsCreateRuleCommand, -6, true, "write", 1, 2, 1, "timeseries", "Create a compaction rule."},
+		{"vadd", vaddCommand, -5, true, "write denyoom", 1, 1, 1, "vectorset", "Add one or more elements to a vector set, or update its vector if it already exists."},
+		{"vrem", vremCommand, 3, true, "write", 1, 1, 1, "vectorset", "Remove an element from a vector set."},
+		{"vsim", vsimCommand, -4, false, "readonly", 1, 1, 1, "vectorset", "Return elements by vector similarity."},
+		{"vcard", vcardCommand, 2, false, "readonly fast", 1, 1, 1, "vectorset", "Return the number of elements in a vector set."},
+		{"vdim", vdimCommand, 2, false, "readonly fast", 1, 1, 1, "vectorset", "Return the dimension of vectors in the vector set."},
+		{"vemb", vembCommand, -3, false, "readonly fast", 1, 1, 1, "vectorset", "Return the vector associated with an element."},
+		{"cms.initbydim", cmsInitByDimCommand, 4, true, "write denyoom", 1, 1, 1, "cms", "Initializes a Count-Min Sketch to dimensions specified by user."},
+		{"cms.incrby", cmsIncrByCommand, -4, true, "write denyoom", 1, 1, 1, "cms", "Increases the count of one or more items by increment."},
+		{"cms.query", cmsQueryCommand, -3, false, "readonly", 1, 1, 1, "cms", "Returns the count for one or more items in a sketch."},
+		{"cms.merge", cmsMergeCommand, -4, true, "write denyoom movablekeys", 1, 1, 1, "cms", "Merges several sketches into one sketch."},
+		{"topk.reserve", topkReserveCommand, -3, true, "write denyoom", 1, 1, 1, "topk", "Initializes a TopK with specified parameters."},
+		{"topk.add", topkAddCommand, -3, true, "write denyoom", 1, 1, 1, "topk", "Increases the count of one or more items by increment."},
+		{"topk.query", topkQueryCommand, -3, false, "readonly", 1, 1, 1, "topk", "Checks whether one or more items are in a sketch."},
+		{"topk.list", topkListCommand, -2, false, "readonly", 1, 1, 1, "topk", "Return full list of items in Top K list."},
+		{"tdigest.create", tdigestCreateCommand, -2, true, "write denyoom", 1, 1, 1, "tdigest", "Allocates memory and initializes a new t-digest sketch."},
+		{"tdigest.add", tdigestAddCommand, -3, true, "write denyoom", 1, 1, 1, "tdigest", "Adds one or more observations to a t-digest sketch."},
+		{"tdigest.quantile", tdigestQuantileCommand, -3, false, "readonly", 1, 1, 1, "tdigest", "Returns, for each input fraction, an estimation of the value smaller than the given fraction of observations."},
+		{"tdigest.cdf", tdigestCDFCommand, -3, false, "readonly", 1, 1, 1, "tdigest", "Returns, for each input value, an estimation of the fraction of observations smaller than the given value."},
+		{"tdigest.merge", tdigestMergeCommand, -4, true, "write denyoom movablekeys", 1, 1, 1, "tdigest", "Merges multiple t-digest sketches into a single sketch."},
+		{"cl.throttle", clThrottleCommand, -5, true, "write denyoom", 1, 1, 1, "throttle", "Checks whether an action is allowed by a GCRA rate limiter."},
+		{"subscribe", subscribeCommand, -2, false, "pubsub noscript loading stale", 0, 0, 0, "pubsub", "Listens for messages published to channels."},
+		{"unsubscribe", unsubscribeCommand, -1, false, "pubsub noscript loading stale", 0, 0, 0, "pubsub", "Stops listening to messages posted to channels."},
+		{"psubscribe", psubscribeCommand, -2, false, "pubsub noscript loading stale", 0, 0, 0, "pubsub", "Listens for messages published to channels that match one or more patterns."},
+		{"punsubscribe", punsubscribeCommand, -1, false, "pubsub noscript loading stale", 0, 0, 0, "pubsub", "Stops listening to messages published to channels that match one or more patterns."},
+		{"ssubscribe", ssubscribeCommand, -2, false, "pubsub noscript loading stale", 0, 0, 0, "pubsub", "Listens for messages published to shard channels."},
+		{"sunsubscribe", sunsubscribeCommand, -1, false, "pubsub noscript loading stale", 0, 0, 0, "pubsub", "Stops listening to messages posted to shard channels."},
+		{"publish", publishCommand, 3, false, "pubsub loading stale fast", 0, 0, 0, "pubsub", "Posts a message to a channel."},
+		{"spublish", spublishCommand, 3, false, "pubsub loading stale fast", 0, 0, 0, "pubsub", "Post a message to a shard channel."},
+		{"pubsub", nil, -2, false, "", 0, 0, 0, "pubsub", "A container for Pub/Sub commands."},
+		{"multi", multiCommand, 1, false, "noscript loading stale fast", 0, 0, 0, "transactions", "Starts a transaction."},
+		{"exec", execCommand, 1, false, "noscript loading stale skip_slowlog", 0, 0, 0, "transactions", "Executes all commands in a transaction."},
+		{"discard", discardCommand, 1, false, "noscript loading stale fast", 0, 0, 0, "transactions", "Discards a transaction."},
+		{"watch", watchCommand, -2, false, "noscript loading stale fast", 1, -1, 1, "transactions", "Monitors changes to keys to determine the execution of a transaction."},
+		{"unwatch", unwatchCommand, 1, false, "noscript loading stale fast", 0, 0, 0, "transactions", "Forgets about watched keys of a transaction."},
+		{"eval", evalCommand, -3, true, "noscript stale skip_monitor may_replicate movablekeys", 0, 0, 0, "scripting", "Executes a server-side Lua script."},
+		{"evalsha", evalShaCommand, -3, true, "noscript stale skip_monitor may_replicate movablekeys", 0, 0, 0, "scripting", "Executes a server-side Lua script by SHA1 digest."},
+		{"eval_ro", evalRoCommand, -3, true, "readonly noscript stale skip_monitor movablekeys", 0, 0, 0, "scripting", "Executes a read-only server-side Lua script."},
+		{"evalsha_ro", evalShaRoCommand, -3, true, "readonly noscript stale skip_monitor movablekeys", 0, 0, 0, "scripting", "Executes a read-only server-side Lua script by SHA1 digest."},
+		{"script", nil, -2, false, "", 0, 0, 0, "scripting", "A container for Lua scripts management commands."},
+		{"ping", pingCommand, -1, false, "fast", 0, 0, 0, "connection", "Returns the server's liveliness response."},
+		{"client", nil, -2, false, "", 0, 0, 0, "connection", "A container for client connection commands."},
+		{"hello", helloCommand, -1, false, "noscript loading stale fast no_auth allow_busy", 0, 0, 0, "connection", "Handshakes with the server."},
+		{"config", nil, -2, false, "", 0, 0, 0, "server", "A container for server configuration commands."},
+		{"flushdb", flushdbCommand, -1, true, "write", 0, 0, 0, "server", "Removes all keys from the current database."},
+		{"load", loadCommand, 1, false, "admin noscript", 0, 0, 0, "server", "Loads the database from the rdb file."},
+		{"save", saveCommand, 1, false, "admin noscript", 0, 0, 0, "server", "Synchronously saves the database to disk."},
+		{"command", commandCommand, -1, false, "loading stale", 0, 0, 0, "server", "Returns detailed information about all commands."},
+		{"info", infoCommand, -1, false, "loading stale", 0, 0, 0, "server", "Returns information and statistics about the server."},
+	}
+
+	subcommandTable = map[string][]*Command{
+		"xgroup": {
+			{"xgroup|create", xgroupCreateCommand, -5, true, "write denyoom", 2, 2, 1, "stream", "Creates a consumer group."},
+			{"xgroup|setid", xgroupSetIDCommand, 5, true, "write", 2, 2, 1, "stream", "Sets the last-delivered ID of a consumer group."},
+			{"xgroup|destroy", xgroupDestroyCommand, 4, true, "write", 2, 2, 1, "stream", "Destroys a consumer group."},
+			{"xgroup|createconsumer", xgroupCreateConsumerCommand, 5, true, "write denyoom", 2, 2, 1, "stream", "Creates a consumer in a consumer group."},
+			{"xgroup|delconsumer", xgroupDelConsumerCommand, 5, true, "write", 2, 2, 1, "stream", "Deletes a consumer from a consumer group."},
+		},
+		"pubsub": {
+			{"pubsub|channels", pubsubChannelsCommand, -2, false, "pubsub loading stale", 0, 0, 0, "pubsub", "Returns the active channels."},
+			{"pubsub|numsub", pubsubNumSubCommand, -2, false, "pubsub loading stale", 0, 0, 0, "pubsub", "Returns a count of subscribers to channels."},
+			{"pubsub|numpat", pubsubNumPatCommand, 2, false, "pubsub loading stale", 0, 0, 0, "pubsub", "Returns a count of unique pattern subscriptions."},
+			{"pubsub|shardchannels", pubsubShardChannelsCommand, -2, false, "pubsub loading stale", 0, 0, 0, "pubsub", "Returns the active shard channels."},
+			{"pubsub|shardnumsub", pubsubShardNumSubCommand, -2, false, "pubsub loading stale", 0, 0, 0, "pubsub", "Returns the count of subscribers of shard channels."},
+		},
+		"script": {
+			{"script|load", scriptLoadCommand, 3, false, "noscript stale", 0, 0, 0, "scripting", "Loads a server-side Lua script to the script cache."},
+			{"script|exists", scriptExistsCommand, -3, false, "noscript", 0, 0, 0, "scripting", "Determines whether server-side Lua scripts exist in the script cache."},
+			{"script|flush", scriptFlushCommand, -2, false, "noscript", 0, 0, 0, "scripting", "Removes all server-side Lua scripts from the script cache."},
+			{"script|kill", scriptKillCommand, 2, false, "noscript allow_busy", 0, 0, 0, "scripting", "Terminates a server-side Lua script during execution."},
+		},
+		"config": {
+			{"config|get", configGetCommand, -3, false, "admin noscript loading stale", 0, 0, 0, "server", "Returns the effective values of configuration parameters."},
+			{"config|set", configSetCommand, -4, false, "admin noscript loading stale", 0, 0, 0, "server", "Sets configuration parameters in-flight."},
+		},
+		"client": {
+			{"client|id", clientIDCommand, 2, false, "noscript loading stale", 0, 0, 0, "connection", "Returns the unique client ID of the connection."},
+			{"client|list", clientListCommand, -2, false, "admin noscript loading stale", 0, 0, 0, "connection", "Lists open connections."},
+			{"client|info", clientInfoCommand, 2, false, "noscript loading stale", 0, 0, 0, "connection", "Returns information about the connection."},
+			{"client|setname", clientSetNameCommand, 3, false, "noscript loading stale", 0, 0, 0, "connection", "Sets the connection name."},
+			{"client|getname", clientGetNameCommand, 2, false, "noscript loading stale", 0, 0, 0, "connection", "Returns the name of the connection."},
+			{"client|kill", clientKillCommand, -3, false, "admin noscript loading stale", 0, 0, 0, "connection", "Terminates open connections."},
+			{"client|pause", clientPauseCommand, -3, false, "admin noscript loading stale", 0, 0, 0, "connection", "Suspends commands processing."},
+			{"client|unpause", clientUnpauseCommand, 2, false, "admin noscript loading stale", 0, 0, 0, "connection", "Resumes processing commands from paused clients."},
+			{"client|reply", clientReplyCommand, 3, false, "noscript loading stale", 0, 0, 0, "connection", "Instructs the server whether to reply to commands."},
+			{"client|tracking", clientTrackingCommand, -3, false, "noscript loading stale", 0, 0, 0, "connection", "Controls server-assisted client-side caching for the connection."},
+			{"client|caching", clientCachingCommand, 3, false, "noscript loading stale", 0, 0, 0, "connection", "Instructs the server whether to track the keys in the next request."},
+			{"client|getredir", clientGetRedirCommand, 2, false, "noscript loading stale", 0, 0, 0, "connection", "Returns the client ID to which the connection's tracking notifications are redirected."},
+		},
+		"command": {
+			{"command|count", commandCountCommand, 2, false, "loading stale", 0, 0, 0, "server", "Returns a count of commands."},
+			{"command|info", commandInfoCommand, -2, false, "loading stale", 0, 0, 0, "server", "Returns information about one, multiple or all commands."},
+			{"command|docs", commandDocsCommand, -2, false, "loading stale", 0, 0, 0, "server", "Returns documentary information about one, multiple or all commands."},
+			{"command|getkeys", commandGetKeysCommand, -3, false, "loading stale", 0, 0, 0, "server", "Extracts the key names from an arbitrary command."},
+		},
+	}
+
+	commands = make(map[string]*Command, len(cmdTable))
+	for _, cmd := range cmdTable {
+		commands[cmd.name] = cmd
+	}
+	subcommands = make(map[string]map[string]*Command, len(subcommandTable))
+	for container, table := range subcommandTable {
+		subs := make(map[string]*Command, len(table))
+		for _, cmd := range table {
+			_, name, _ := strings.Cut(cmd.name, "|")
+			subs[name] = cmd
+		}
+		subcommands[container] = subs
+	}
 }
 
 func equalFold(a, b string) bool {
 	return len(a) == len(b) && strings.EqualFold(a, b)
 }
 
+// lookupCommand returns the command of name case-insensitively.
 func lookupCommand(name string) (*Command, error) {
-	for _, c := range cmdTable {
-		if equalFold(name, c.name) {
-			return c, nil
-		}
+	if cmd := lookupFold(commands, name); cmd != nil {
+		return cmd, nil
 	}
 	return nil, fmt.Errorf("%w '%s'", errUnknownCommand, name)
 }
 
-func (cmd *Command) process(writer *resp.Writer, args []redcon.RESP) {
-	if len(args) < cmd.minArgsNum {
-		writer.WriteError(errWrongArguments.Error())
-		return
+// lookupCommandName returns the command of name, or the subcommand of name in the
+// form of "container|subcommand".
+func lookupCommandName(name string) (*Command, error) {
+	container, sub, ok := strings.Cut(name, "|")
+	cmd, err := lookupCommand(container)
+	if err != nil || !ok {
+		return cmd, err
+	}
+	if cmd = lookupFold(subcommands[cmd.name], sub); cmd == nil {
+		return nil, fmt.Errorf("%w '%s'", errUnknownCommand, name)
 	}
-	cmd.handler(writer, args)
+	return cmd, nil
 }
 
-func pingCommand(writer *resp.Writer, _ []redcon.RESP) {
+// resolveCommand returns the command to execute for name and args, which is the
+// subcommand named by args[0] for container commands, and the arguments of it.
+// It checks the number of arguments so that handlers can index args by arity.
+func resolveCommand(name string, args []redcon.RESP) (*Command, []redcon.RESP, error) {
+	cmd, err := lookupCommand(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	n := len(args) + 1
+	if subs := subcommands[cmd.name]; subs != nil && (len(args) > 0 || cmd.handler == nil) {
+		if len(args) == 0 {
+			return nil, nil, errWrongArity(cmd.name)
+		}
+		sub := lookupFold(subs, b2s(args[0].Bytes()))
+		if sub == nil {
+			return nil, nil, errUnknownSubcommand(args[0].String(), cmd.name)
+		}
+		cmd, args = sub, args[1:]
+	}
+	if !cmd.arityMatched(n) {
+		return nil, nil, errWrongArity(cmd.name)
+	}
+	return cmd, args, nil
+}
+
+// lookupFold looks up lowercase name in m case-insensitively, it does not allocate
+// for names shorter than 32 bytes.
+func lookupFold(m map[string]*Command, name string) *Command {
+	if cmd, ok := m[name]; ok {
+		return cmd
+	}
+	var buf [32]byte
+	if len(name) > len(buf) {
+		return m[strings.ToLower(name)]
+	}
+	b := buf[:len(name)]
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		b[i] = c
+	}
+	return m[string(b)]
+}
+
+// rootName returns the container name of subcommand, or the name of command.
+func (cmd *Command) rootName() string {
+	name, _, _ := strings.Cut(cmd.name, "|")
+	return name
+}
+
+func pingCommand(writer *resp.Writer, args []redcon.RESP) {
+	// ping replies in the format of pubsub message in subscriber mode.
+	if client := server.current; client != nil && client.subscribed() {
+		writer.WriteArray(2)
+		writer.WriteBulkString("pong")
+		if len(args) > 0 {
+			writer.WriteBulk(args[0].Bytes())
+		} else {
+			writer.WriteBulkString("")
+		}
+		return
+	}
 	writer.WriteString("PONG")
 }
 
@@ -120,6 +383,16 @@ func setCommand(writer *resp.Writer, args []redcon.RESP) {
 			n := extra[1].Int()
 			ttl = time.Now().Add(time.Duration(n) * time.Millisecond).UnixNano()
 			extra = extra[2:]
+			// EXAT
+		} else if equalFold(arg, EXAT) && len(extra) >= 2 {
+			n := extra[1].Int()
+			ttl = time.Unix(n, 0).UnixNano()
+			extra = extra[2:]
+			// PXAT
+		} else if equalFold(arg, PXAT) && len(extra) >= 2 {
+			n := extra[1].Int()
+			ttl = time.UnixMilli(n).UnixNano()
+			extra = extra[2:]
 			// KEEPTTL
 		} else if equalFold(arg, KeepTtl) {
 			extra = extra[1:]
@@ -213,6 +486,24 @@ func typeCommand(writer *resp.Writer, args []redcon.RESP) {
 		writer.WriteString("list")
 	case *zset.ZipZSet, *zset.ZSet:
 		writer.WriteString("zset")
+	case *stream.Stream:
+		writer.WriteString("stream")
+	case *bloom.Bloom:
+		writer.WriteString("MBbloom--")
+	case *cuckoo.Cuckoo:
+		writer.WriteString("MBbloomCF")
+	case *json.Value:
+		writer.WriteString("ReJSON-RL")
+	case *timeseries.Series:
+		writer.WriteString("TSDB-TYPE")
+	case *vector.Set:
+		writer.WriteString("vectorset")
+	case *cms.CMS:
+		writer.WriteString("CMSk-TYPE")
+	case *topk.TopK:
+		writer.WriteString("TopK-TYPE")
+	case *tdigest.TDigest:
+		writer.WriteString("TDIS-TYPE")
 	default:
 		writer.WriteError(fmt.Sprintf("unknown type: %T", v))
 	}
@@ -255,7 +546,7 @@ func hsetCommand(writer *resp.Writer, args []redcon.RESP) {
 	key := args[0].Bytes()
 	args = args[1:]
 	if len(args)%2 == 1 {
-		writer.WriteError(errWrongArguments.Error())
+		writer.WriteError(errWrongArity("hset").Error())
 		return
 	}
 	hmap, err := fetchMap(key, true)
@@ -314,7 +605,7 @@ func hgetallCommand(writer *resp.Writer, args []redcon.RESP) {
 		writer.WriteError(err.Error())
 		return
 	}
-	writer.WriteArray(hmap.Len() * 2)
+	writer.WriteMap(hmap.Len())
 	hmap.Scan(func(key string, value []byte) {
 		writer.WriteBulkString(key)
 		writer.WriteBulk(value)
@@ -477,6 +768,10 @@ func spopCommand(writer *resp.Writer, args []redcon.RESP) {
 func zaddCommand(writer *resp.Writer, args []redcon.RESP) {
 	key := args[0].Bytes()
 	args = args[1:]
+	if len(args)%2 == 1 {
+		writer.WriteError(errSyntax.Error())
+		return
+	}
 	zs, err := fetchZSet(key, true)
 	if err != nil {
 		writer.WriteError(err.Error())
@@ -526,36 +821,157 @@ func zremCommand(writer *resp.Writer, args []redcon.RESP) {
 }
 
 func zrangeCommand(writer *resp.Writer, args []redcon.RESP) {
-	key := args[0].Bytes()
-	start := int(args[1].Int())
-	stop := int(args[2].Int())
+	zrangeGenericCommand(writer, args, zrangeByRank, false, false)
+}
+
+func zrevrangeCommand(writer *resp.Writer, args []redcon.RESP) {
+	zrangeGenericCommand(writer, args, zrangeByRank, true, true)
+}
 
+func zrangebyscoreCommand(writer *resp.Writer, args []redcon.RESP) {
+	zrangeGenericCommand(writer, args, zrangeByScore, false, true)
+}
+
+func zrevrangebyscoreCommand(writer *resp.Writer, args []redcon.RESP) {
+	zrangeGenericCommand(writer, args, zrangeByScore, true, true)
+}
+
+func zrangebylexCommand(writer *resp.Writer, args []redcon.RESP) {
+	zrangeGenericCommand(writer, args, zrangeByLex, false, true)
+}
+
+func zrevrangebylexCommand(writer *resp.Writer, args []redcon.RESP) {
+	zrangeGenericCommand(writer, args, zrangeByLex, true, true)
+}
+
+func zrangeGenericCommand(writer *resp.Writer, args []redcon.RESP, by zrangeBy, rev, legacy bool) {
+	key := args[0].Bytes()
+	spec, err := parseZRangeSpec(args[1:], by, rev, legacy)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
 	zs, err := fetchZSet(key)
 	if err != nil {
 		writer.WriteError(err.Error())
 		return
 	}
-	if stop == -1 {
-		stop = zs.Len()
+	writeZSetEntries(writer, zrangeGeneric(zs, spec), spec.withScores)
+}
+
+func zrangestoreCommand(writer *resp.Writer, args []redcon.RESP) {
+	dst := args[0].String()
+	src := args[1].Bytes()
+	spec, err := parseZRangeSpec(args[2:], zrangeByRank, false, false)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if spec.withScores {
+		writer.WriteError(errSyntax.Error())
+		return
 	}
-	start = min(start, stop)
+	zs, err := fetchZSet(src)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	entries := zrangeGeneric(zs, spec)
+	storeZSet(dst, entries)
+	writer.WriteInt(len(entries))
+}
 
-	withScores := len(args) == 4 && equalFold(b2s(args[3].Bytes()), WithScores)
-	if withScores {
-		writer.WriteArray((stop - start) * 2)
-	} else {
-		writer.WriteArray(stop - start)
+func zunionCommand(writer *resp.Writer, args []redcon.RESP) {
+	zsetOpCommand(writer, "zunion", zsetOpUnion, args)
+}
+
+func zinterCommand(writer *resp.Writer, args []redcon.RESP) {
+	zsetOpCommand(writer, "zinter", zsetOpInter, args)
+}
+
+func zdiffCommand(writer *resp.Writer, args []redcon.RESP) {
+	zsetOpCommand(writer, "zdiff", zsetOpDiff, args)
+}
+
+func zsetOpCommand(writer *resp.Writer, name string, op zsetOp, args []redcon.RESP) {
+	spec, err := parseZSetOpSpec(name, op, args, false)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	entries, err := zsetOpGeneric(spec)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writeZSetEntries(writer, entries, spec.withScores)
+}
+
+func zunionstoreCommand(writer *resp.Writer, args []redcon.RESP) {
+	zsetOpStoreCommand(writer, "zunionstore", zsetOpUnion, args)
+}
+
+func zinterstoreCommand(writer *resp.Writer, args []redcon.RESP) {
+	zsetOpStoreCommand(writer, "zinterstore", zsetOpInter, args)
+}
+
+func zdiffstoreCommand(writer *resp.Writer, args []redcon.RESP) {
+	zsetOpStoreCommand(writer, "zdiffstore", zsetOpDiff, args)
+}
+
+func zsetOpStoreCommand(writer *resp.Writer, name string, op zsetOp, args []redcon.RESP) {
+	dst := args[0].String()
+	spec, err := parseZSetOpSpec(name, op, args[1:], true)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
 	}
-	zs.Scan(func(key string, score float64) {
-		if start <= 0 && stop >= 0 {
-			writer.WriteBulkString(key)
-			if withScores {
-				writer.WriteAny(score)
+	entries, err := zsetOpGeneric(spec)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	storeZSet(dst, entries)
+	writer.WriteInt(len(entries))
+}
+
+func zintercardCommand(writer *resp.Writer, args []redcon.RESP) {
+	numKeys, err := strconv.Atoi(b2s(args[0].Bytes()))
+	if err != nil || numKeys <= 0 {
+		writer.WriteError(errNumKeys.Error())
+		return
+	}
+	if numKeys > len(args)-1 {
+		writer.WriteError(errSyntax.Error())
+		return
+	}
+	limit := 0
+	extra := args[numKeys+1:]
+	for len(extra) > 0 {
+		arg := b2s(extra[0].Bytes())
+		// LIMIT
+		if equalFold(arg, Limit) && len(extra) >= 2 {
+			limit, err = strconv.Atoi(b2s(extra[1].Bytes()))
+			if err != nil || limit < 0 {
+				writer.WriteError(errLimitNegative.Error())
+				return
 			}
+			extra = extra[2:]
+		} else {
+			writer.WriteError(errSyntax.Error())
+			return
 		}
-		start--
-		stop--
-	})
+	}
+	spec := &zsetOpSpec{op: zsetOpInter, limit: limit}
+	for _, arg := range args[1 : numKeys+1] {
+		spec.keys = append(spec.keys, arg.Bytes())
+	}
+	card, err := zsetInterCard(spec)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writer.WriteInt(card)
 }
 
 func zpopminCommand(writer *resp.Writer, args []redcon.RESP) {
@@ -570,27 +986,100 @@ func zpopminCommand(writer *resp.Writer, args []redcon.RESP) {
 		return
 	}
 	n := min(zs.Len(), count)
-	writer.WriteArray(n * 2)
+	entries := make([]zsetEntry, 0, n)
 	for range n {
 		kstr, score := zs.PopMin()
-		writer.WriteBulkString(kstr)
-		writer.WriteAny(score)
+		entries = append(entries, zsetEntry{kstr, score})
+	}
+	// reply is flat without count even in RESP3.
+	if len(args) == 1 && len(entries) == 1 {
+		writer.WriteArray(2)
+		writer.WriteBulkString(entries[0].key)
+		writer.WriteDouble(entries[0].score)
+		return
 	}
+	writeZSetEntries(writer, entries, true)
 }
 
 func flushdbCommand(writer *resp.Writer, _ []redcon.RESP) {
+	signalFlushedDb()
 	db.dict = New()
 	writer.WriteString("OK")
 }
 
-func helloCommand(writer *resp.Writer, _ []redcon.RESP) {
-	writer.WriteAny(map[string]any{
-		"server":  "rotom",
-		"version": "1.0.0",
-		"proto":   2,
-		"mode":    "standalone",
-		"role":    "master",
-	})
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+func helloCommand(writer *resp.Writer, args []redcon.RESP) {
+	proto := writer.Proto()
+	if len(args) > 0 {
+		ver, err := strconv.Atoi(b2s(args[0].Bytes()))
+		if err != nil {
+			writer.WriteError(errProtocolVersion.Error())
+			return
+		}
+		if ver != resp.RESP2 && ver != resp.RESP3 {
+			writer.WriteError(errNoProto.Error())
+			return
+		}
+		proto = ver
+		args = args[1:]
+	}
+
+	var name string
+	var setName bool
+	for len(args) > 0 {
+		arg := b2s(args[0].Bytes())
+		switch {
+		case equalFold(arg, Auth) && len(args) >= 3:
+			// there is no user in rotom, the default user has no password and
+			// any credentials are accepted, like a nopass user in Redis.
+			args = args[3:]
+		case equalFold(arg, SetName) && len(args) >= 2:
+			name, setName = args[1].String(), true
+			if !validClientName(name) {
+				writer.WriteError(errInvalidClientName.Error())
+				return
+			}
+			args = args[2:]
+		default:
+			writer.WriteError(errHelloSyntax(arg).Error())
+			return
+		}
+	}
+
+	writer.SetProto(proto)
+	var id uint64
+	if client := server.current; client != nil {
+		id = client.id
+		if setName {
+			client.name = name
+		}
+	}
+	writer.WriteMap(7)
+	writer.WriteBulkString("server")
+	writer.WriteBulkString("rotom")
+	writer.WriteBulkString("version")
+	writer.WriteBulkString("1.0.0")
+	writer.WriteBulkString("proto")
+	writer.WriteInt(proto)
+	writer.WriteBulkString("id")
+	writer.WriteInt64(int64(id))
+	writer.WriteBulkString("mode")
+	writer.WriteBulkString("standalone")
+	writer.WriteBulkString("role")
+	writer.WriteBulkString("master")
+	writer.WriteBulkString("modules")
+	writer.WriteArray(0)
+}
+
+// validClientName reports whether name contains no spaces, newlines or special
+// characters.
+func validClientName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] < '!' || name[i] > '~' {
+			return false
+		}
+	}
+	return true
 }
 
 func loadCommand(writer *resp.Writer, _ []redcon.RESP) {
@@ -610,19 +1099,35 @@ func saveCommand(writer *resp.Writer, _ []redcon.RESP) {
 	writer.WriteString("OK")
 }
 
-func configCommand(writer *resp.Writer, args []redcon.RESP) {
-	key := args[0].String()
-	op := args[1].String()
-	switch strings.ToLower(op) {
-	case "get":
-		writer.WriteAny(configGet(key))
-	case "set":
-		writer.WriteString("OK")
-	default:
-		writer.WriteError(fmt.Sprintf("ERR unknown op type: %s", op))
+// CONFIG GET parameter [parameter ...] | SET parameter value
+// CONFIG GET parameter [parameter ...]
+func configGetCommand(writer *resp.Writer, args []redcon.RESP) {
+	var params []string
+	for _, key := range viper.AllKeys() {
+		for _, arg := range args {
+			if match.Match(key, strings.ToLower(arg.String())) {
+				params = append(params, key)
+				break
+			}
+		}
+	}
+	slices.Sort(params)
+	writer.WriteMap(len(params))
+	for _, key := range params {
+		writer.WriteBulkString(key)
+		writer.WriteBulkString(fmt.Sprint(configGet(key)))
 	}
 }
 
+// CONFIG SET parameter value [parameter value ...]
+func configSetCommand(writer *resp.Writer, args []redcon.RESP) {
+	if len(args)%2 == 1 {
+		writer.WriteError(errWrongArity("config|set").Error())
+		return
+	}
+	writer.WriteString("OK")
+}
+
 func fetchMap(key []byte, setnx ...bool) (Map, error) {
 	return fetch(key, func() Map { return hash.New() }, setnx...)
 }
@@ -639,6 +1144,39 @@ func fetchZSet(key []byte, setnx ...bool) (ZSet, error) {
 	return fetch(key, func() ZSet { return zset.NewZipZSet() }, setnx...)
 }
 
+func fetchStream(key []byte, setnx ...bool) (Stream, error) {
+	return fetch(key, func() Stream { return stream.New() }, setnx...)
+}
+
+func fetchBloom(key []byte, setnx ...bool) (*bloom.Bloom, error) {
+	return fetch(key, func() *bloom.Bloom {
+		return bloom.New(bloom.DefaultCapacity, bloom.DefaultErrorRate, bloom.DefaultExpansion)
+	}, setnx...)
+}
+
+func fetchCuckoo(key []byte, setnx ...bool) (*cuckoo.Cuckoo, error) {
+	return fetch(key, func() *cuckoo.Cuckoo {
+		return cuckoo.New(cuckoo.DefaultCapacity, cuckoo.DefaultBucketSize, cuckoo.DefaultMaxIterations, cuckoo.DefaultExpansion)
+	}, setnx...)
+}
+
+// storeZSet replaces key with a new sorted set of entries, deleting key when empty,
+// the ttl of key is cleared.
+func storeZSet(key string, entries []zsetEntry) {
+	if len(entries) == 0 {
+		db.dict.Delete(key)
+		return
+	}
+	var zs ZSet = zset.NewZipZSet()
+	if len(entries) >= maxZipZSetSize {
+		zs = zset.New()
+	}
+	for _, e := range entries {
+		zs.Set(strings.Clone(e.key), e.score)
+	}
+	db.dict.Replace(key, zs)
+}
+
 func fetch[T any](key []byte, new func() T, setnx ...bool) (T, error) {
 	object, ttl := db.dict.Get(b2s(key))
 	if ttl != KeyNotExist {
@@ -650,13 +1188,13 @@ func fetch[T any](key []byte, new func() T, setnx ...bool) (T, error) {
 		if len(setnx) > 0 && setnx[0] {
 			switch data := object.(type) {
 			case *hash.ZipSet:
-				if data.Len() >= 512 {
+				if data.Len() >= maxZipSetSize {
 					object = data.ToSet()
 					db.dict.Set(string(key), object)
 					return object.(T), nil
 				}
 			case *zset.ZipZSet:
-				if data.Len() >= 256 {
+				if data.Len() >= maxZipZSetSize {
 					object = data.ToZSet()
 					db.dict.Set(string(key), object)
 					return object.(T), nil
@@ -694,6 +1232,24 @@ func getObjectType(object any) ObjectType {
 		return TypeZSet
 	case *zset.ZipZSet:
 		return TypeZipZSet
+	case *stream.Stream:
+		return TypeStream
+	case *bloom.Bloom:
+		return TypeBloom
+	case *cuckoo.Cuckoo:
+		return TypeCuckoo
+	case *json.Value:
+		return TypeJSON
+	case *timeseries.Series:
+		return TypeTimeSeries
+	case *vector.Set:
+		return TypeVectorSet
+	case *cms.CMS:
+		return TypeCMS
+	case *topk.TopK:
+		return TypeTopK
+	case *tdigest.TDigest:
+		return TypeTDigest
 	}
 	return TypeUnknown
 }
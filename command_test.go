@@ -1,19 +1,31 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"fmt"
 	"github.com/alicebob/miniredis/v2"
+	"io"
+	"math"
 	"math/rand/v2"
+	"net"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/redcon"
 )
 
 func startup() {
+	if err := writeTestCerts(testTLSDir); err != nil {
+		panic(err)
+	}
 	config4Server("rotom_test.toml")
 	printBanner()
 	RegisterAeLoop(&server)
@@ -405,6 +417,90 @@ func testCommand(t *testing.T, testType string, rdb *redis.Client, sleepFn func(
 			ast.Equal(len(res), 0)
 			ast.Nil(err)
 		}
+		// zrange negative index
+		{
+			res, _ := rdb.ZRange(ctx, "rank", -2, -1).Result()
+			ast.Equal(res, []string{"user3", "user2"})
+
+			res, _ = rdb.ZRange(ctx, "rank", -100, 0).Result()
+			ast.Equal(res, []string{"user1"})
+
+			res, _ = rdb.ZRevRange(ctx, "rank", 0, 1).Result()
+			ast.Equal(res, []string{"user2", "user3"})
+		}
+		// zrange byscore
+		{
+			res, _ := rdb.ZRangeByScore(ctx, "rank", &redis.ZRangeBy{Min: "100", Max: "(300.5"}).Result()
+			ast.Equal(res, []string{"user1", "user3"})
+
+			res, _ = rdb.ZRangeByScore(ctx, "rank", &redis.ZRangeBy{Min: "-inf", Max: "+inf", Offset: 1, Count: 1}).Result()
+			ast.Equal(res, []string{"user3"})
+
+			res, _ = rdb.ZRevRangeByScore(ctx, "rank", &redis.ZRangeBy{Min: "100", Max: "300.5"}).Result()
+			ast.Equal(res, []string{"user2", "user3", "user1"})
+
+			resz, _ := rdb.ZRangeArgsWithScores(ctx, redis.ZRangeArgs{
+				Key: "rank", Start: "(100", Stop: "+inf", ByScore: true, Rev: true,
+			}).Result()
+			ast.Equal(resz, []redis.Z{{Member: "user2", Score: 300.5}})
+
+			_, err := rdb.ZRangeByScore(ctx, "rank", &redis.ZRangeBy{Min: "a", Max: "1"}).Result()
+			ast.NotNil(err)
+		}
+		// zrange bylex
+		{
+			rdb.ZAdd(ctx, "lex",
+				redis.Z{Member: "a"}, redis.Z{Member: "b"},
+				redis.Z{Member: "c"}, redis.Z{Member: "d"})
+
+			res, _ := rdb.ZRangeByLex(ctx, "lex", &redis.ZRangeBy{Min: "-", Max: "+"}).Result()
+			ast.Equal(res, []string{"a", "b", "c", "d"})
+
+			res, _ = rdb.ZRangeByLex(ctx, "lex", &redis.ZRangeBy{Min: "(a", Max: "[c"}).Result()
+			ast.Equal(res, []string{"b", "c"})
+
+			res, _ = rdb.ZRevRangeByLex(ctx, "lex", &redis.ZRangeBy{Min: "-", Max: "(d", Count: 2}).Result()
+			ast.Equal(res, []string{"c", "b"})
+
+			_, err := rdb.ZRangeByLex(ctx, "lex", &redis.ZRangeBy{Min: "a", Max: "+"}).Result()
+			ast.NotNil(err)
+		}
+		// zunion, zinter
+		{
+			rdb.ZAdd(ctx, "week1", redis.Z{Member: "a", Score: 1}, redis.Z{Member: "b", Score: 2})
+			rdb.ZAdd(ctx, "week2", redis.Z{Member: "b", Score: 3}, redis.Z{Member: "c", Score: 4})
+
+			n, err := rdb.ZUnionStore(ctx, "total", &redis.ZStore{
+				Keys: []string{"week1", "week2"}, Weights: []float64{2, 1},
+			}).Result()
+			ast.Nil(err)
+			ast.Equal(n, int64(3))
+			res, _ := rdb.ZRangeWithScores(ctx, "total", 0, -1).Result()
+			ast.Equal(res, []redis.Z{
+				{Member: "a", Score: 2},
+				{Member: "c", Score: 4},
+				{Member: "b", Score: 7},
+			})
+
+			n, _ = rdb.ZInterStore(ctx, "total", &redis.ZStore{
+				Keys: []string{"week1", "week2"}, Aggregate: "MAX",
+			}).Result()
+			ast.Equal(n, int64(1))
+			res, _ = rdb.ZRangeWithScores(ctx, "total", 0, -1).Result()
+			ast.Equal(res, []redis.Z{{Member: "b", Score: 3}})
+
+			res, _ = rdb.ZUnionWithScores(ctx, redis.ZStore{
+				Keys: []string{"week1", "week2"}, Aggregate: "MIN",
+			}).Result()
+			ast.Equal(res, []redis.Z{
+				{Member: "a", Score: 1},
+				{Member: "b", Score: 2},
+				{Member: "c", Score: 4},
+			})
+
+			ress, _ := rdb.ZInter(ctx, &redis.ZStore{Keys: []string{"week1", "week2", "not-exist"}}).Result()
+			ast.Equal(ress, []string{})
+		}
 		// zpopmin
 		{
 			res, _ := rdb.ZPopMin(ctx, "rank", 3).Result()
@@ -442,6 +538,140 @@ func testCommand(t *testing.T, testType string, rdb *redis.Client, sleepFn func(
 		ast.Equal(err.Error(), errWrongType.Error())
 	})
 
+	t.Run("stream", func(t *testing.T) {
+		for i := 1; i <= 5; i++ {
+			id, err := rdb.XAdd(ctx, &redis.XAddArgs{
+				Stream: "events", ID: fmt.Sprintf("%d-0", i), Values: []string{"n", fmt.Sprint(i)},
+			}).Result()
+			ast.Nil(err)
+			ast.Equal(id, fmt.Sprintf("%d-0", i))
+		}
+		_, err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: "events", ID: "3-0", Values: []string{"n", "0"}}).Result()
+		ast.NotNil(err)
+
+		_type, _ := rdb.Type(ctx, "events").Result()
+		ast.Equal(_type, "stream")
+
+		n, _ := rdb.XLen(ctx, "events").Result()
+		ast.Equal(n, int64(5))
+
+		// xrange
+		msgs, _ := rdb.XRange(ctx, "events", "2", "3").Result()
+		ast.Equal(msgs, []redis.XMessage{
+			{ID: "2-0", Values: map[string]interface{}{"n": "2"}},
+			{ID: "3-0", Values: map[string]interface{}{"n": "3"}},
+		})
+		msgs, _ = rdb.XRevRangeN(ctx, "events", "+", "-", 2).Result()
+		ast.Equal(len(msgs), 2)
+		ast.Equal(msgs[0].ID, "5-0")
+		ast.Equal(msgs[1].ID, "4-0")
+
+		// xdel, xtrim
+		n, _ = rdb.XDel(ctx, "events", "1-0", "100-0").Result()
+		ast.Equal(n, int64(1))
+		n, _ = rdb.XTrimMaxLen(ctx, "events", 3).Result()
+		ast.Equal(n, int64(1))
+		msgs, _ = rdb.XRange(ctx, "events", "-", "+").Result()
+		ast.Equal(len(msgs), 3)
+		ast.Equal(msgs[0].ID, "3-0")
+
+		// xread
+		streams, _ := rdb.XRead(ctx, &redis.XReadArgs{Streams: []string{"events", "4-0"}, Count: 10}).Result()
+		ast.Equal(len(streams), 1)
+		ast.Equal(streams[0].Stream, "events")
+		ast.Equal(streams[0].Messages[0].ID, "5-0")
+
+		_, err = rdb.XRead(ctx, &redis.XReadArgs{Streams: []string{"events", "5-0"}, Count: 10, Block: -1}).Result()
+		ast.Equal(err, redis.Nil)
+
+		// consumer group
+		res, err := rdb.XGroupCreate(ctx, "events", "workers", "0").Result()
+		ast.Nil(err)
+		ast.Equal(res, "OK")
+		_, err = rdb.XGroupCreate(ctx, "events", "workers", "0").Result()
+		ast.NotNil(err)
+
+		streams, _ = rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group: "workers", Consumer: "alice", Streams: []string{"events", ">"}, Count: 2, Block: -1,
+		}).Result()
+		ast.Equal(len(streams[0].Messages), 2)
+		ast.Equal(streams[0].Messages[0].ID, "3-0")
+
+		pending, _ := rdb.XPending(ctx, "events", "workers").Result()
+		ast.Equal(pending.Count, int64(2))
+		ast.Equal(pending.Lower, "3-0")
+		ast.Equal(pending.Higher, "4-0")
+		ast.Equal(pending.Consumers, map[string]int64{"alice": 2})
+
+		n, _ = rdb.XAck(ctx, "events", "workers", "3-0", "5-0").Result()
+		ast.Equal(n, int64(1))
+
+		// history of consumer
+		streams, _ = rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group: "workers", Consumer: "alice", Streams: []string{"events", "0"}, Block: -1,
+		}).Result()
+		ast.Equal(len(streams[0].Messages), 1)
+		ast.Equal(streams[0].Messages[0].ID, "4-0")
+
+		_, err = rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group: "not-exist", Consumer: "alice", Streams: []string{"events", ">"}, Block: -1,
+		}).Result()
+		ast.NotNil(err)
+
+		// err wrong type
+		rdb.Set(ctx, "key", "value", 0)
+
+		_, err = rdb.XAdd(ctx, &redis.XAddArgs{Stream: "key", Values: []string{"a", "b"}}).Result()
+		ast.Equal(err.Error(), errWrongType.Error())
+
+		_, err = rdb.XRange(ctx, "key", "-", "+").Result()
+		ast.Equal(err.Error(), errWrongType.Error())
+	})
+
+	t.Run("pubsub", func(t *testing.T) {
+		n, _ := rdb.Publish(ctx, "news", "hello").Result()
+		ast.Equal(n, int64(0))
+
+		sub := rdb.Subscribe(ctx, "news", "sport")
+		defer sub.Close()
+		_, err := sub.Receive(ctx)
+		ast.Nil(err)
+		_, err = sub.Receive(ctx)
+		ast.Nil(err)
+
+		psub := rdb.PSubscribe(ctx, "new*")
+		defer psub.Close()
+		_, err = psub.Receive(ctx)
+		ast.Nil(err)
+
+		channels, _ := rdb.PubSubChannels(ctx, "*").Result()
+		ast.ElementsMatch(channels, []string{"news", "sport"})
+		numsub, _ := rdb.PubSubNumSub(ctx, "news", "none").Result()
+		ast.Equal(numsub, map[string]int64{"news": 1, "none": 0})
+		numpat, _ := rdb.PubSubNumPat(ctx).Result()
+		ast.Equal(numpat, int64(1))
+
+		n, _ = rdb.Publish(ctx, "news", "hello").Result()
+		ast.Equal(n, int64(2))
+
+		msg, err := sub.ReceiveMessage(ctx)
+		ast.Nil(err)
+		ast.Equal(msg.Channel, "news")
+		ast.Equal(msg.Payload, "hello")
+
+		msg, err = psub.ReceiveMessage(ctx)
+		ast.Nil(err)
+		ast.Equal(msg.Pattern, "new*")
+		ast.Equal(msg.Channel, "news")
+		ast.Equal(msg.Payload, "hello")
+
+		ast.Nil(sub.Unsubscribe(ctx, "news"))
+		_, err = sub.Receive(ctx)
+		ast.Nil(err)
+		channels, _ = rdb.PubSubChannels(ctx, "*").Result()
+		ast.Equal(channels, []string{"sport"})
+	})
+
 	t.Run("flushdb", func(t *testing.T) {
 		rdb.Set(ctx, "test-flush", "1", 0)
 		res, _ := rdb.FlushDB(ctx).Result()
@@ -478,6 +708,81 @@ func testCommand(t *testing.T, testType string, rdb *redis.Client, sleepFn func(
 		ast.Equal(sls, []string{"1", "2", "3"})
 	})
 
+	t.Run("transaction", func(t *testing.T) {
+		cmds, err := rdb.TxPipelined(ctx, func(pip redis.Pipeliner) error {
+			pip.Set(ctx, "tx-key", "1", 0)
+			pip.Incr(ctx, "tx-key")
+			pip.RPush(ctx, "tx-ls", "a", "b")
+			return nil
+		})
+		ast.Nil(err)
+		ast.Equal(len(cmds), 3)
+		ast.Equal(cmds[1].(*redis.IntCmd).Val(), int64(2))
+		ast.Equal(cmds[2].(*redis.IntCmd).Val(), int64(2))
+
+		// watched key is not modified
+		err = rdb.Watch(ctx, func(tx *redis.Tx) error {
+			n, _ := tx.Get(ctx, "tx-key").Int()
+			_, err := tx.TxPipelined(ctx, func(pip redis.Pipeliner) error {
+				pip.Set(ctx, "tx-key", n*10, 0)
+				return nil
+			})
+			return err
+		}, "tx-key")
+		ast.Nil(err)
+		res, _ := rdb.Get(ctx, "tx-key").Result()
+		ast.Equal(res, "20")
+
+		// watched key is modified by another client
+		err = rdb.Watch(ctx, func(tx *redis.Tx) error {
+			rdb.Set(ctx, "tx-key", "30", 0)
+			_, err := tx.TxPipelined(ctx, func(pip redis.Pipeliner) error {
+				pip.Set(ctx, "tx-key", "40", 0)
+				return nil
+			})
+			return err
+		}, "tx-key")
+		ast.Equal(err, redis.TxFailedErr)
+		res, _ = rdb.Get(ctx, "tx-key").Result()
+		ast.Equal(res, "30")
+
+		// watched key appears as non-key argument, or write command fails.
+		err = rdb.Watch(ctx, func(tx *redis.Tx) error {
+			rdb.HSet(ctx, "tx-hash", "tx-key", "tx-key")
+			rdb.Set(ctx, "tx-other", "tx-key", 0)
+			ast.NotNil(rdb.LPush(ctx, "tx-key", "a").Err())
+			_, err := tx.TxPipelined(ctx, func(pip redis.Pipeliner) error {
+				pip.Set(ctx, "tx-key", "35", 0)
+				return nil
+			})
+			return err
+		}, "tx-key")
+		ast.Nil(err)
+		ast.Nil(rdb.Set(ctx, "tx-key", "30", 0).Err())
+
+		// EXECABORT
+		conn := rdb.Conn()
+		defer conn.Close()
+		do := func(args ...any) error {
+			cmd := redis.NewCmd(ctx, args...)
+			_ = conn.Process(ctx, cmd)
+			return cmd.Err()
+		}
+		ast.Nil(do("multi"))
+		ast.NotNil(do("set", "tx-key"))
+		ast.Nil(do("set", "tx-key", "50"))
+		ast.Equal(do("exec").Error(), errExecAbort.Error())
+		res, _ = rdb.Get(ctx, "tx-key").Result()
+		ast.Equal(res, "30")
+
+		ast.Equal(do("exec").Error(), errExecWithoutMulti.Error())
+		ast.Equal(do("discard").Error(), errDiscardWithoutMulti.Error())
+		ast.Nil(do("multi"))
+		ast.Equal(do("multi").Error(), errNestedMulti.Error())
+		ast.NotNil(do("watch", "tx-key"))
+		ast.Nil(do("discard"))
+	})
+
 	t.Run("concurrency", func(t *testing.T) {
 		var wg sync.WaitGroup
 		for i := 0; i < 1000; i++ {
@@ -500,11 +805,1278 @@ func testCommand(t *testing.T, testType string, rdb *redis.Client, sleepFn func(
 
 	if testType == testTypeRotom {
 		t.Run("bigKey", func(t *testing.T) {
-			body := make([]byte, MaxQueryDataLen)
+			// larger than proto.max-bulk-len in rotom_test.toml.
+			body := make([]byte, 128*MB)
 			_, err := rdb.Set(ctx, "bigKey", body, 0).Result()
 			ast.NotNil(err)
 		})
 
+		t.Run("zrangestore", func(t *testing.T) {
+			rdb.ZAdd(ctx, "zsrc",
+				redis.Z{Member: "a", Score: 1},
+				redis.Z{Member: "b", Score: 2},
+				redis.Z{Member: "c", Score: 3})
+
+			n, err := rdb.ZRangeStore(ctx, "zdst", redis.ZRangeArgs{
+				Key: "zsrc", Start: 2, Stop: "+inf", ByScore: true,
+			}).Result()
+			ast.Nil(err)
+			ast.Equal(n, int64(2))
+
+			res, _ := rdb.ZRangeWithScores(ctx, "zdst", 0, -1).Result()
+			ast.Equal(res, []redis.Z{{Member: "b", Score: 2}, {Member: "c", Score: 3}})
+
+			// ttl of destination is cleared.
+			rdb.Set(ctx, "zdst-ttl", "v", 100*time.Millisecond)
+			n, _ = rdb.ZRangeStore(ctx, "zdst-ttl", redis.ZRangeArgs{Key: "zsrc", Start: 0, Stop: -1}).Result()
+			ast.Equal(n, int64(3))
+			time.Sleep(150 * time.Millisecond)
+			members, _ := rdb.ZRange(ctx, "zdst-ttl", 0, -1).Result()
+			ast.Equal(members, []string{"a", "b", "c"})
+
+			n, _ = rdb.ZRangeStore(ctx, "zdst", redis.ZRangeArgs{Key: "zsrc", Start: 5, Stop: 10}).Result()
+			ast.Equal(n, int64(0))
+			_type, _ := rdb.Type(ctx, "zdst").Result()
+			ast.Equal(_type, "none")
+		})
+
+		t.Run("zdiff-zintercard", func(t *testing.T) {
+			rdb.ZAdd(ctx, "zs1", redis.Z{Member: "a", Score: 1}, redis.Z{Member: "b", Score: 2}, redis.Z{Member: "c", Score: 3})
+			rdb.ZAdd(ctx, "zs2", redis.Z{Member: "b", Score: 1})
+			rdb.SAdd(ctx, "plain-set", "c", "d")
+
+			res, _ := rdb.ZDiffWithScores(ctx, "zs1", "zs2").Result()
+			ast.Equal(res, []redis.Z{{Member: "a", Score: 1}, {Member: "c", Score: 3}})
+
+			n, _ := rdb.ZDiffStore(ctx, "zs-diff", "zs1", "zs2", "plain-set").Result()
+			ast.Equal(n, int64(1))
+
+			// ttl of destination is cleared.
+			rdb.Set(ctx, "zs-diff-ttl", "v", 100*time.Millisecond)
+			n, _ = rdb.ZDiffStore(ctx, "zs-diff-ttl", "zs1", "zs2").Result()
+			ast.Equal(n, int64(2))
+			time.Sleep(150 * time.Millisecond)
+			members, _ := rdb.ZRange(ctx, "zs-diff-ttl", 0, -1).Result()
+			ast.Equal(members, []string{"a", "c"})
+
+			n, _ = rdb.ZInterCard(ctx, 0, "zs1", "plain-set").Result()
+			ast.Equal(n, int64(1))
+			n, _ = rdb.ZInterCard(ctx, 1, "zs1", "zs1").Result()
+			ast.Equal(n, int64(1))
+			n, _ = rdb.ZInterCard(ctx, 5, "zs1", "zs1").Result()
+			ast.Equal(n, int64(3))
+			n, _ = rdb.ZInterCard(ctx, 0, "zs1", "zs-none").Result()
+			ast.Equal(n, int64(0))
+			rdb.Set(ctx, "zs-str", "v", 0)
+			_, err := rdb.ZInterCard(ctx, 0, "zs-none", "zs-str").Result()
+			ast.Equal(err.Error(), errWrongType.Error())
+
+			// plain set has implicit score 1
+			res, _ = rdb.ZUnionWithScores(ctx, redis.ZStore{Keys: []string{"zs2", "plain-set"}}).Result()
+			ast.Equal(res, []redis.Z{
+				{Member: "b", Score: 1},
+				{Member: "c", Score: 1},
+				{Member: "d", Score: 1},
+			})
+
+			_, err = rdb.ZUnionStore(ctx, "zs-err", &redis.ZStore{Keys: []string{"zs1", "pip-ls"}}).Result()
+			ast.Equal(err.Error(), errWrongType.Error())
+		})
+
+		t.Run("stream-block", func(t *testing.T) {
+			rdb.XAdd(ctx, &redis.XAddArgs{Stream: "xblock", ID: "1-0", Values: []string{"a", "1"}})
+
+			go func() {
+				time.Sleep(time.Second / 10)
+				rdb.XAdd(ctx, &redis.XAddArgs{Stream: "xblock", ID: "2-0", Values: []string{"a", "2"}})
+			}()
+			streams, err := rdb.XRead(ctx, &redis.XReadArgs{Streams: []string{"xblock", "$"}, Block: time.Second}).Result()
+			ast.Nil(err)
+			ast.Equal(streams[0].Messages, []redis.XMessage{{ID: "2-0", Values: map[string]interface{}{"a": "2"}}})
+
+			// timeout
+			_, err = rdb.XRead(ctx, &redis.XReadArgs{Streams: []string{"xblock", "$"}, Block: time.Second / 10}).Result()
+			ast.Equal(err, redis.Nil)
+
+			// xreadgroup block
+			rdb.XGroupCreate(ctx, "xblock", "g1", "$")
+			go func() {
+				time.Sleep(time.Second / 10)
+				rdb.XAdd(ctx, &redis.XAddArgs{Stream: "xblock", ID: "3-0", Values: []string{"a", "3"}})
+			}()
+			streams, err = rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group: "g1", Consumer: "c1", Streams: []string{"xblock", ">"}, Block: time.Second,
+			}).Result()
+			ast.Nil(err)
+			ast.Equal(streams[0].Messages[0].ID, "3-0")
+
+			conn, err := net.Dial("tcp", ":7979")
+			ast.Nil(err)
+			defer conn.Close()
+			do := func(args ...string) string {
+				req := redcon.AppendArray(nil, len(args))
+				for _, arg := range args {
+					req = redcon.AppendBulkString(req, arg)
+				}
+				_, err := conn.Write(req)
+				ast.Nil(err)
+				buf := make([]byte, 4096)
+				_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+				n, _ := conn.Read(buf)
+				return string(buf[:n])
+			}
+
+			// nothing to read or timeout replies null array.
+			ast.Equal("*-1\r\n", do("xread", "streams", "xblock", "$"))
+			ast.Equal("*-1\r\n", do("xreadgroup", "group", "g1", "c1", "streams", "xblock", ">"))
+			ast.Equal("*-1\r\n", do("xread", "block", "10", "streams", "xblock", "$"))
+			ast.Equal("*-1\r\n", do("xreadgroup", "group", "g1", "c1", "block", "10", "streams", "xblock", ">"))
+
+			// the deadline is kept when blocked again.
+			go func() {
+				time.Sleep(time.Second / 5)
+				rdb.Do(ctx, "xadd", "xdeadline", "maxlen", "0", "*", "a", "1")
+			}()
+			start := time.Now()
+			ast.Equal("*-1\r\n", do("xread", "block", "300", "streams", "xdeadline", "$"))
+			ast.Less(time.Since(start), 450*time.Millisecond)
+
+			// history of deleted entry in RESP3.
+			do("hello", "3")
+			rdb.XDel(ctx, "xblock", "3-0")
+			ast.Contains(do("xreadgroup", "group", "g1", "c1", "streams", "xblock", "0"), "$3\r\n3-0\r\n_\r\n")
+
+			// auto id
+			id, err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: "xblock", Values: []string{"a", "4"}, MaxLen: 2}).Result()
+			ast.Nil(err)
+			ast.NotEqual(id, "")
+			n, _ := rdb.XLen(ctx, "xblock").Result()
+			ast.Equal(n, int64(2))
+		})
+
+		t.Run("stream-claim", func(t *testing.T) {
+			for i := 1; i <= 3; i++ {
+				rdb.XAdd(ctx, &redis.XAddArgs{Stream: "xclaim", ID: fmt.Sprintf("%d-0", i), Values: []string{"a", "b"}})
+			}
+			rdb.XGroupCreate(ctx, "xclaim", "g1", "0")
+			rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "g1", Consumer: "c1", Streams: []string{"xclaim", ">"}, Block: -1})
+
+			msgs, err := rdb.XClaim(ctx, &redis.XClaimArgs{
+				Stream: "xclaim", Group: "g1", Consumer: "c2", Messages: []string{"1-0", "2-0"},
+			}).Result()
+			ast.Nil(err)
+			ast.Equal(len(msgs), 2)
+
+			ext, _ := rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+				Stream: "xclaim", Group: "g1", Start: "-", End: "+", Count: 10,
+			}).Result()
+			ast.Equal(len(ext), 3)
+			ast.Equal(ext[0].Consumer, "c2")
+			ast.Equal(ext[0].RetryCount, int64(2))
+			ast.Equal(ext[2].Consumer, "c1")
+
+			rdb.XDel(ctx, "xclaim", "3-0")
+			ids, start, err := rdb.XAutoClaimJustID(ctx, &redis.XAutoClaimArgs{
+				Stream: "xclaim", Group: "g1", Consumer: "c3", Start: "0", Count: 10,
+			}).Result()
+			ast.Nil(err)
+			ast.Equal(ids, []string{"1-0", "2-0"})
+			ast.Equal(start, "0-0")
+
+			n, _ := rdb.XGroupDelConsumer(ctx, "xclaim", "g1", "c3").Result()
+			ast.Equal(n, int64(2))
+		})
+
+		t.Run("bloom", func(t *testing.T) {
+			res, err := rdb.BFReserveWithArgs(ctx, "bf", &redis.BFReserveOptions{
+				Capacity: 10, Error: 0.01, Expansion: 2,
+			}).Result()
+			ast.Nil(err)
+			ast.Equal(res, "OK")
+			_, err = rdb.BFReserve(ctx, "bf", 0.01, 10).Result()
+			ast.Equal(err.Error(), errItemExists.Error())
+			_, err = rdb.BFReserve(ctx, "bf-err", 1.5, 10).Result()
+			ast.Equal(err.Error(), errErrorRateRange.Error())
+			_, err = rdb.BFReserve(ctx, "bf-err", 0.01, 1<<31).Result()
+			ast.Equal(err.Error(), errCapacityRange.Error())
+			_, err = rdb.BFReserve(ctx, "bf-err", 1e-300, 1<<25).Result()
+			ast.Equal(err.Error(), errBloomTooLarge.Error())
+			_, err = rdb.BFReserveExpansion(ctx, "bf-err", 0.01, 10, 1<<20).Result()
+			ast.Equal(err.Error(), errExpansionRange.Error())
+
+			ok, _ := rdb.BFAdd(ctx, "bf", "a").Result()
+			ast.True(ok)
+			ok, _ = rdb.BFAdd(ctx, "bf", "a").Result()
+			ast.False(ok)
+			oks, _ := rdb.BFMAdd(ctx, "bf", "b", "c", "a").Result()
+			ast.Equal(oks, []bool{true, true, false})
+
+			ok, _ = rdb.BFExists(ctx, "bf", "b").Result()
+			ast.True(ok)
+			oks, _ = rdb.BFMExists(ctx, "bf", "a", "c", "not-exist").Result()
+			ast.Equal(oks, []bool{true, true, false})
+			ok, _ = rdb.BFExists(ctx, "bf-none", "a").Result()
+			ast.False(ok)
+
+			// scaling
+			for i := range 20 {
+				rdb.BFAdd(ctx, "bf", fmt.Sprint(i))
+			}
+			info, err := rdb.BFInfo(ctx, "bf").Result()
+			ast.Nil(err)
+			ast.Equal(info.Capacity, int64(30))
+			ast.Equal(info.Filters, int64(2))
+			ast.Equal(info.ExpansionRate, int64(2))
+			ast.GreaterOrEqual(info.ItemsInserted, int64(20))
+
+			capacity, _ := rdb.Do(ctx, "bf.info", "bf", "capacity").Result()
+			ast.Equal(capacity, []any{int64(30)})
+			_, err = rdb.BFInfo(ctx, "bf-none").Result()
+			ast.Equal(err.Error(), errNotFound.Error())
+
+			// non scaling
+			rdb.BFReserveNonScaling(ctx, "bf-ns", 0.01, 2)
+			rdb.BFMAdd(ctx, "bf-ns", "a", "b")
+			_, err = rdb.BFAdd(ctx, "bf-ns", "c").Result()
+			ast.Equal(err.Error(), "ERR non scaling filter is full")
+
+			_type, _ := rdb.Type(ctx, "bf").Result()
+			ast.Equal(_type, "MBbloom--")
+			rdb.Set(ctx, "key", "value", 0)
+			_, err = rdb.BFAdd(ctx, "key", "a").Result()
+			ast.Equal(err.Error(), errWrongType.Error())
+		})
+
+		t.Run("cuckoo", func(t *testing.T) {
+			n, _ := rdb.CFAdd(ctx, "cf", "a").Result()
+			ast.True(n)
+			rdb.CFAdd(ctx, "cf", "a")
+			ok, _ := rdb.CFAddNX(ctx, "cf", "a").Result()
+			ast.False(ok)
+			ok, _ = rdb.CFAddNX(ctx, "cf", "b").Result()
+			ast.True(ok)
+
+			cnt, _ := rdb.CFCount(ctx, "cf", "a").Result()
+			ast.Equal(cnt, int64(2))
+			cnt, _ = rdb.CFCount(ctx, "cf-none", "a").Result()
+			ast.Equal(cnt, int64(0))
+
+			ok, _ = rdb.CFExists(ctx, "cf", "b").Result()
+			ast.True(ok)
+			ok, _ = rdb.CFDel(ctx, "cf", "b").Result()
+			ast.True(ok)
+			ok, _ = rdb.CFExists(ctx, "cf", "b").Result()
+			ast.False(ok)
+			ok, _ = rdb.CFDel(ctx, "cf", "b").Result()
+			ast.False(ok)
+			_, err := rdb.CFDel(ctx, "cf-none", "b").Result()
+			ast.Equal(err.Error(), errCuckooNotFound.Error())
+
+			_type, _ := rdb.Type(ctx, "cf").Result()
+			ast.Equal(_type, "MBbloomCF")
+		})
+
+		t.Run("json", func(t *testing.T) {
+			res, err := rdb.JSONSet(ctx, "doc", "$", `{"name":"rotom","tags":["a"],"stats":{"stars":1,"score":1.5}}`).Result()
+			ast.Nil(err)
+			ast.Equal(res, "OK")
+			_, err = rdb.JSONSet(ctx, "doc-new", "$.a", `1`).Result()
+			ast.Equal(err.Error(), errJSONNewAtRoot.Error())
+			_, err = rdb.JSONSet(ctx, "doc", "$", `{"a":`).Result()
+			ast.NotNil(err)
+
+			str, _ := rdb.JSONGet(ctx, "doc").Result()
+			ast.Equal(str, `{"name":"rotom","tags":["a"],"stats":{"stars":1,"score":1.5}}`)
+			str, _ = rdb.JSONGet(ctx, "doc", "$.name").Result()
+			ast.Equal(str, `["rotom"]`)
+			str, _ = rdb.JSONGet(ctx, "doc", ".stats.stars").Result()
+			ast.Equal(str, `1`)
+			str, _ = rdb.JSONGet(ctx, "doc", "$.name", "$..stars").Result()
+			ast.Equal(str, `{"$.name":["rotom"],"$..stars":[1]}`)
+			_, err = rdb.JSONGet(ctx, "doc", ".not.exist").Result()
+			ast.Equal(err.Error(), "ERR Path '.not.exist' does not exist")
+			_, err = rdb.Do(ctx, "json.get", "doc-none").Result()
+			ast.Equal(err, redis.Nil)
+
+			// set path
+			res, _ = rdb.JSONSet(ctx, "doc", "$.stats.forks", `2`).Result()
+			ast.Equal(res, "OK")
+			res, _ = rdb.JSONSetMode(ctx, "doc", "$.stats.forks", `3`, "NX").Result()
+			ast.Equal(res, "")
+			res, _ = rdb.JSONSetMode(ctx, "doc", "$.stats.watch", `3`, "XX").Result()
+			ast.Equal(res, "")
+
+			// numincrby
+			v, _ := rdb.Do(ctx, "json.numincrby", "doc", "$..stars", "2").Result()
+			ast.Equal(v, "[3]")
+			v, _ = rdb.Do(ctx, "json.numincrby", "doc", "$.stats.*", "1").Result()
+			ast.Equal(v, "[4,2.5,3]")
+			v, _ = rdb.Do(ctx, "json.numincrby", "doc", ".stats.score", "0.5").Result()
+			ast.Equal(v, "3.0")
+			_, err = rdb.Do(ctx, "json.numincrby", "doc", ".name", "1").Result()
+			ast.Equal(err.Error(), "ERR WRONGTYPE wrong type of path value - expected a number but found string")
+
+			// arrappend
+			n, _ := rdb.JSONArrAppend(ctx, "doc", "$.tags", `"b"`, `{"c":1}`).Result()
+			ast.Equal(n, []int64{3})
+			v, _ = rdb.Do(ctx, "json.arrappend", "doc", "$.name", `1`).Result()
+			ast.Equal(v, []any{nil})
+			str, _ = rdb.JSONGet(ctx, "doc", "$.tags").Result()
+			ast.Equal(str, `[["a","b",{"c":1}]]`)
+
+			// type
+			v, _ = rdb.Do(ctx, "json.type", "doc", "$..*").Result()
+			ast.Equal(v, []any{"string", "array", "object", "string", "string", "object", "integer", "integer", "number", "integer"})
+			v, _ = rdb.Do(ctx, "json.type", "doc").Result()
+			ast.Equal(v, "object")
+
+			// del
+			cnt, _ := rdb.JSONDel(ctx, "doc", "$.tags[0,1]").Result()
+			ast.Equal(cnt, int64(2))
+			cnt, _ = rdb.JSONDel(ctx, "doc", "$..c").Result()
+			ast.Equal(cnt, int64(1))
+			str, _ = rdb.JSONGet(ctx, "doc").Result()
+			ast.Equal(str, `{"name":"rotom","tags":[{}],"stats":{"stars":4,"score":3.0,"forks":3}}`)
+			cnt, _ = rdb.JSONDel(ctx, "doc", "$").Result()
+			ast.Equal(cnt, int64(1))
+			cnt, _ = rdb.Exists(ctx, "doc").Result()
+			ast.Equal(cnt, int64(0))
+
+			_type, _ := rdb.Type(ctx, "doc-type").Result()
+			ast.Equal(_type, "none")
+			rdb.JSONSet(ctx, "doc-type", "$", `1`)
+			_type, _ = rdb.Type(ctx, "doc-type").Result()
+			ast.Equal(_type, "ReJSON-RL")
+		})
+
+		t.Run("timeseries", func(t *testing.T) {
+			res, _ := rdb.Do(ctx, "ts.create", "ts1", "retention", "1000", "labels", "area", "a", "sensor", "1").Result()
+			ast.Equal(res, "OK")
+			_, err := rdb.Do(ctx, "ts.create", "ts1").Result()
+			ast.Equal(err.Error(), errTSKeyExists.Error())
+			_, err = rdb.Do(ctx, "ts.create", "ts-bad", "duplicate_policy", "none").Result()
+			ast.Equal(err.Error(), errTSBadDuplicatePolicy.Error())
+
+			for i := 1; i <= 5; i++ {
+				ts, _ := rdb.Do(ctx, "ts.add", "ts1", i*10, i).Result()
+				ast.Equal(ts, int64(i*10))
+			}
+			_, err = rdb.Do(ctx, "ts.add", "ts1", 10, 100).Result()
+			ast.Equal(err.Error(), "ERR TSDB: Error at upsert, update is not supported when DUPLICATE_POLICY is set to BLOCK mode")
+			rdb.Do(ctx, "ts.add", "ts1", 10, 100, "on_duplicate", "sum")
+			_, err = rdb.Do(ctx, "ts.add", "ts1", "abc", 1).Result()
+			ast.Equal(err.Error(), errTSInvalidTimestamp.Error())
+			ts, _ := rdb.Do(ctx, "ts.add", "ts-auto", "*", 1).Result()
+			ast.Greater(ts, int64(0))
+
+			// range
+			res, _ = rdb.Do(ctx, "ts.range", "ts1", "-", "+").Result()
+			ast.Equal(res, []any{
+				[]any{int64(10), "101"}, []any{int64(20), "2"}, []any{int64(30), "3"},
+				[]any{int64(40), "4"}, []any{int64(50), "5"},
+			})
+			res, _ = rdb.Do(ctx, "ts.range", "ts1", "20", "40", "count", "2").Result()
+			ast.Equal(res, []any{[]any{int64(20), "2"}, []any{int64(30), "3"}})
+			res, _ = rdb.Do(ctx, "ts.range", "ts1", "-", "+", "filter_by_ts", "20", "50", "filter_by_value", "3", "10").Result()
+			ast.Equal(res, []any{[]any{int64(50), "5"}})
+			res, _ = rdb.Do(ctx, "ts.range", "ts1", "-", "+", "aggregation", "avg", "20").Result()
+			ast.Equal(res, []any{[]any{int64(0), "101"}, []any{int64(20), "2.5"}, []any{int64(40), "4.5"}})
+			_, err = rdb.Do(ctx, "ts.range", "ts1", "-", "+", "aggregation", "foo", "20").Result()
+			ast.Equal(err.Error(), errTSUnknownAggregation.Error())
+			_, err = rdb.Do(ctx, "ts.range", "ts-none", "-", "+").Result()
+			ast.Equal(err.Error(), errTSKeyNotExist.Error())
+
+			// compaction
+			rdb.Do(ctx, "ts.create", "ts2", "labels", "area", "b")
+			rdb.Do(ctx, "ts.create", "ts2-max")
+			res, _ = rdb.Do(ctx, "ts.createrule", "ts2", "ts2-max", "aggregation", "max", "10").Result()
+			ast.Equal(res, "OK")
+			_, err = rdb.Do(ctx, "ts.createrule", "ts2", "ts2-max", "aggregation", "max", "10").Result()
+			ast.Equal(err.Error(), errTSRuleExists.Error())
+			_, err = rdb.Do(ctx, "ts.createrule", "ts1", "ts2-max", "aggregation", "max", "10").Result()
+			ast.Equal(err.Error(), errTSDestHasSrc.Error())
+			for _, s := range [][2]int{{1, 5}, {3, 7}, {12, 1}, {25, 3}} {
+				rdb.Do(ctx, "ts.add", "ts2", s[0], s[1])
+			}
+			res, _ = rdb.Do(ctx, "ts.range", "ts2-max", "-", "+").Result()
+			ast.Equal(res, []any{[]any{int64(0), "7"}, []any{int64(10), "1"}})
+
+			// mrange
+			res, _ = rdb.Do(ctx, "ts.mrange", "-", "+", "withlabels", "aggregation", "sum", "100", "filter", "area=(a,b)").Result()
+			ast.Equal(res, []any{
+				[]any{"ts1", []any{[]any{"area", "a"}, []any{"sensor", "1"}}, []any{[]any{int64(0), "115"}}},
+				[]any{"ts2", []any{[]any{"area", "b"}}, []any{[]any{int64(0), "16"}}},
+			})
+			res, _ = rdb.Do(ctx, "ts.mrange", "0", "20", "filter", "area=a", "sensor!=").Result()
+			ast.Equal(res, []any{
+				[]any{"ts1", []any{}, []any{[]any{int64(10), "101"}, []any{int64(20), "2"}}},
+			})
+			_, err = rdb.Do(ctx, "ts.mrange", "-", "+", "filter", "area!=a").Result()
+			ast.Equal(err.Error(), errTSBadFilter.Error())
+
+			_type, _ := rdb.Type(ctx, "ts1").Result()
+			ast.Equal(_type, "TSDB-TYPE")
+		})
+
+		t.Run("vectorset", func(t *testing.T) {
+			n, _ := rdb.Do(ctx, "vadd", "vs", "values", "3", "1", "0", "0", "a", "noquant").Int()
+			ast.Equal(n, 1)
+			rdb.Do(ctx, "vadd", "vs", "values", "3", "0", "1", "0", "b")
+			rdb.Do(ctx, "vadd", "vs", "values", "3", "0.9", "0.1", "0", "c")
+			blob := make([]byte, 12)
+			binary.LittleEndian.PutUint32(blob[4:], math.Float32bits(-1))
+			n, _ = rdb.Do(ctx, "vadd", "vs", "fp32", blob, "d").Int()
+			ast.Equal(n, 1)
+			n, _ = rdb.Do(ctx, "vadd", "vs", "values", "3", "0", "0", "2", "d").Int()
+			ast.Equal(n, 0)
+
+			_, err := rdb.Do(ctx, "vadd", "vs", "values", "2", "1", "0", "e").Result()
+			ast.Equal(err.Error(), "ERR Vector dimension mismatch - got 2 but set has 3")
+			_, err = rdb.Do(ctx, "vadd", "vs", "values", "3", "1", "0", "0", "e", "q8").Result()
+			ast.Equal(err.Error(), errQuantMismatch.Error())
+			_, err = rdb.Do(ctx, "vadd", "vs", "values", "3", "1", "x", "0", "e").Result()
+			ast.Equal(err.Error(), errInvalidVector.Error())
+
+			n, _ = rdb.Do(ctx, "vcard", "vs").Int()
+			ast.Equal(n, 4)
+			n, _ = rdb.Do(ctx, "vdim", "vs").Int()
+			ast.Equal(n, 3)
+			_, err = rdb.Do(ctx, "vdim", "vs-none").Result()
+			ast.Equal(err.Error(), errVectorKeyNotExist.Error())
+
+			// vemb
+			res, _ := rdb.Do(ctx, "vemb", "vs", "d").StringSlice()
+			ast.Equal(res, []string{"0", "0", "2"})
+			_, err = rdb.Do(ctx, "vemb", "vs", "none").Result()
+			ast.Equal(err, redis.Nil)
+
+			// vsim
+			res, _ = rdb.Do(ctx, "vsim", "vs", "ele", "a", "count", "2").StringSlice()
+			ast.Equal(res, []string{"a", "c"})
+			res, _ = rdb.Do(ctx, "vsim", "vs", "values", "3", "0", "0", "1", "withscores", "count", "1").StringSlice()
+			ast.Equal(res, []string{"d", "1"})
+			res, _ = rdb.Do(ctx, "vsim", "vs", "ele", "b", "withscores", "count", "2").StringSlice()
+			ast.Equal(res[:3], []string{"b", "1", "c"})
+			score, _ := strconv.ParseFloat(res[3], 64)
+			ast.InDelta(score, 0.5552, 0.001)
+			_, err = rdb.Do(ctx, "vsim", "vs", "ele", "none").Result()
+			ast.Equal(err.Error(), errElementNotFound.Error())
+			res, _ = rdb.Do(ctx, "vsim", "vs-none", "ele", "a").StringSlice()
+			ast.Equal(res, []string{})
+
+			// l2
+			rdb.Do(ctx, "vadd", "vs-l2", "values", "2", "0", "0", "o", "metric", "l2")
+			rdb.Do(ctx, "vadd", "vs-l2", "values", "2", "3", "4", "p")
+			res, _ = rdb.Do(ctx, "vsim", "vs-l2", "ele", "o", "withscores").StringSlice()
+			ast.Equal(res[2], "p")
+			score, _ = strconv.ParseFloat(res[3], 64)
+			ast.InDelta(score, 1.0/6, 0.01)
+
+			// vrem
+			for _, e := range []string{"a", "b", "c"} {
+				n, _ = rdb.Do(ctx, "vrem", "vs", e).Int()
+				ast.Equal(n, 1)
+			}
+			n, _ = rdb.Do(ctx, "vrem", "vs", "a").Int()
+			ast.Equal(n, 0)
+			res, _ = rdb.Do(ctx, "vsim", "vs", "ele", "d").StringSlice()
+			ast.Equal(res, []string{"d"})
+			rdb.Do(ctx, "vrem", "vs", "d")
+			cnt, _ := rdb.Exists(ctx, "vs").Result()
+			ast.Equal(cnt, int64(0))
+
+			_type, _ := rdb.Type(ctx, "vs-l2").Result()
+			ast.Equal(_type, "vectorset")
+		})
+
+		t.Run("cms", func(t *testing.T) {
+			res, _ := rdb.Do(ctx, "cms.initbydim", "cms1", "1000", "5").Result()
+			ast.Equal(res, "OK")
+			_, err := rdb.Do(ctx, "cms.initbydim", "cms1", "1000", "5").Result()
+			ast.Equal(err.Error(), errCMSKeyExists.Error())
+			// width*depth overflows or exceeds the limit.
+			_, err = rdb.Do(ctx, "cms.initbydim", "cms-big", "4294967296", "4294967296").Result()
+			ast.Equal(err.Error(), errCMSTooLarge.Error())
+			_, err = rdb.Do(ctx, "cms.initbydim", "cms-big", "100000000", "100").Result()
+			ast.Equal(err.Error(), errCMSTooLarge.Error())
+			rdb.Do(ctx, "cms.initbydim", "cms2", "1000", "5")
+			rdb.Do(ctx, "cms.initbydim", "cms3", "100", "5")
+
+			counts, _ := rdb.Do(ctx, "cms.incrby", "cms1", "a", "3", "b", "1", "a", "2").Int64Slice()
+			ast.Equal(counts, []int64{3, 1, 5})
+			_, err = rdb.Do(ctx, "cms.incrby", "cms1", "a", "-1").Result()
+			ast.Equal(err.Error(), errCMSBadNumber.Error())
+			_, err = rdb.Do(ctx, "cms.incrby", "cms-none", "a", "1").Result()
+			ast.Equal(err.Error(), errCMSKeyNotExist.Error())
+			counts, _ = rdb.Do(ctx, "cms.query", "cms1", "a", "b", "c").Int64Slice()
+			ast.Equal(counts, []int64{5, 1, 0})
+
+			rdb.Do(ctx, "cms.incrby", "cms2", "a", "1", "c", "4")
+			res, _ = rdb.Do(ctx, "cms.merge", "cms2", "2", "cms1", "cms2", "weights", "1", "3").Result()
+			ast.Equal(res, "OK")
+			counts, _ = rdb.Do(ctx, "cms.query", "cms2", "a", "b", "c").Int64Slice()
+			ast.Equal(counts, []int64{8, 1, 12})
+			_, err = rdb.Do(ctx, "cms.merge", "cms3", "1", "cms1").Result()
+			ast.Equal(err.Error(), errCMSDimMismatch.Error())
+
+			_type, _ := rdb.Type(ctx, "cms1").Result()
+			ast.Equal(_type, "CMSk-TYPE")
+		})
+
+		t.Run("topk", func(t *testing.T) {
+			res, _ := rdb.Do(ctx, "topk.reserve", "topk1", "2", "50", "4", "0.9").Result()
+			ast.Equal(res, "OK")
+			_, err := rdb.Do(ctx, "topk.reserve", "topk1", "2").Result()
+			ast.Equal(err.Error(), errTopKKeyExists.Error())
+			_, err = rdb.Do(ctx, "topk.reserve", "topk2", "2", "8", "7", "1.5").Result()
+			ast.Equal(err.Error(), errTopKBadDecay.Error())
+			_, err = rdb.Do(ctx, "topk.reserve", "topk2", "2", "4294967296", "4294967296", "0.9").Result()
+			ast.Equal(err.Error(), errTopKTooLarge.Error())
+
+			expelled, _ := rdb.Do(ctx, "topk.add", "topk1", "a", "a", "b", "a", "b").Slice()
+			ast.Equal(expelled, []any{nil, nil, nil, nil, nil})
+			expelled, _ = rdb.Do(ctx, "topk.add", "topk1", "c", "c", "c", "c").Slice()
+			ast.Equal(expelled, []any{nil, nil, "b", nil})
+
+			list, _ := rdb.Do(ctx, "topk.list", "topk1").StringSlice()
+			ast.Equal(list, []string{"c", "a"})
+			items, _ := rdb.Do(ctx, "topk.list", "topk1", "withcount").Slice()
+			ast.Equal(items, []any{"c", int64(4), "a", int64(3)})
+			found, _ := rdb.Do(ctx, "topk.query", "topk1", "a", "b", "d").Int64Slice()
+			ast.Equal(found, []int64{1, 0, 0})
+			_, err = rdb.Do(ctx, "topk.list", "topk-none").Result()
+			ast.Equal(err.Error(), errTopKKeyNotExist.Error())
+
+			_type, _ := rdb.Type(ctx, "topk1").Result()
+			ast.Equal(_type, "TopK-TYPE")
+		})
+
+		t.Run("tdigest", func(t *testing.T) {
+			res, _ := rdb.Do(ctx, "tdigest.create", "td1").Result()
+			ast.Equal(res, "OK")
+			_, err := rdb.Do(ctx, "tdigest.create", "td1").Result()
+			ast.Equal(err.Error(), errTDigestKeyExists.Error())
+			rdb.Do(ctx, "tdigest.create", "td2", "compression", "200")
+			_, err = rdb.Do(ctx, "tdigest.create", "td3", "compression", "0").Result()
+			ast.Equal(err.Error(), errTDigestCompressionRange.Error())
+
+			values, _ := rdb.Do(ctx, "tdigest.quantile", "td1", "0.5").StringSlice()
+			ast.Equal(values, []string{"nan"})
+			res, _ = rdb.Do(ctx, "tdigest.add", "td1", "1", "2", "3", "4", "5").Result()
+			ast.Equal(res, "OK")
+			_, err = rdb.Do(ctx, "tdigest.add", "td1", "x").Result()
+			ast.Equal(err.Error(), errTDigestBadValue.Error())
+
+			values, _ = rdb.Do(ctx, "tdigest.quantile", "td1", "0", "0.5", "1").StringSlice()
+			ast.Equal(values, []string{"1", "3", "5"})
+			_, err = rdb.Do(ctx, "tdigest.quantile", "td1", "2").Result()
+			ast.Equal(err.Error(), errTDigestQuantileRange.Error())
+			values, _ = rdb.Do(ctx, "tdigest.cdf", "td1", "0", "3", "10").StringSlice()
+			ast.Equal(values, []string{"0", "0.5", "1"})
+
+			// merge
+			rdb.Do(ctx, "tdigest.add", "td2", "6", "7", "8", "9", "10")
+			res, _ = rdb.Do(ctx, "tdigest.merge", "td-merged", "2", "td1", "td2").Result()
+			ast.Equal(res, "OK")
+			values, _ = rdb.Do(ctx, "tdigest.quantile", "td-merged", "0", "1").StringSlice()
+			ast.Equal(values, []string{"1", "10"})
+			rdb.Do(ctx, "tdigest.merge", "td-merged", "1", "td2")
+			values, _ = rdb.Do(ctx, "tdigest.cdf", "td-merged", "5").StringSlice()
+			ast.Equal(values, []string{"0.3"})
+			rdb.Do(ctx, "tdigest.merge", "td-merged", "1", "td1", "override")
+			values, _ = rdb.Do(ctx, "tdigest.quantile", "td-merged", "1").StringSlice()
+			ast.Equal(values, []string{"5"})
+			_, err = rdb.Do(ctx, "tdigest.merge", "td-merged", "1", "td-none").Result()
+			ast.Equal(err.Error(), errTDigestKeyNotExist.Error())
+
+			_type, _ := rdb.Type(ctx, "td1").Result()
+			ast.Equal(_type, "TDIS-TYPE")
+		})
+
+		t.Run("cl.throttle", func(t *testing.T) {
+			// limit 3, 1 token per 60 seconds.
+			for i := range 3 {
+				res, _ := rdb.Do(ctx, "cl.throttle", "throttle1", "2", "1", "60").Int64Slice()
+				ast.Equal(res, []int64{0, 3, int64(2 - i), -1, int64(60 * (i + 1))})
+			}
+			res, _ := rdb.Do(ctx, "cl.throttle", "throttle1", "2", "1", "60").Int64Slice()
+			ast.Equal(res, []int64{1, 3, 0, 60, 180})
+
+			// state is a string key expiring at TAT.
+			tat, _ := rdb.Get(ctx, "throttle1").Int64()
+			ast.InDelta(time.Now().Add(180*time.Second).UnixNano(), tat, float64(time.Second))
+
+			// quantity larger than burst is never allowed.
+			res, _ = rdb.Do(ctx, "cl.throttle", "throttle2", "2", "1", "60", "4").Int64Slice()
+			ast.Equal(res, []int64{1, 3, 3, -1, 0})
+			res, _ = rdb.Do(ctx, "cl.throttle", "throttle2", "2", "1", "60", "3").Int64Slice()
+			ast.Equal(res, []int64{0, 3, 0, -1, 180})
+
+			_, err := rdb.Do(ctx, "cl.throttle", "throttle3", "2", "0", "60").Result()
+			ast.Equal(err.Error(), errThrottleRate.Error())
+			_, err = rdb.Do(ctx, "cl.throttle", "throttle3", "2", "-1", "60").Result()
+			ast.Equal(err.Error(), errParseInteger.Error())
+			for _, params := range [][]string{
+				{"2", "1", strconv.FormatInt(math.MaxInt64, 10)},
+				{strconv.FormatInt(math.MaxInt64, 10), "1", "60"},
+				{"2", "1", "60", strconv.FormatInt(math.MaxInt64/1000, 10)},
+				{"2", strconv.FormatInt(math.MaxInt64, 10), "60"},
+			} {
+				args := []any{"cl.throttle", "throttle3"}
+				for _, p := range params {
+					args = append(args, p)
+				}
+				_, err = rdb.Do(ctx, args...).Result()
+				ast.Equal(err.Error(), errThrottleRange.Error())
+			}
+			// TAT far in the future.
+			rdb.Set(ctx, "throttle4", strconv.FormatInt(math.MaxInt64-1, 10), 0)
+			_, err = rdb.Do(ctx, "cl.throttle", "throttle4", "2", "1", "60").Result()
+			ast.Equal(err.Error(), errThrottleRange.Error())
+			rdb.HSet(ctx, "throttle-hash", "k", "v")
+			_, err = rdb.Do(ctx, "cl.throttle", "throttle-hash", "2", "1", "60").Result()
+			ast.Equal(err.Error(), errWrongType.Error())
+		})
+
+		t.Run("lua", func(t *testing.T) {
+			res, err := rdb.Eval(ctx, "redis.call('set', KEYS[1], ARGV[1]) return redis.call('get', KEYS[1])",
+				[]string{"lua-key"}, "v1").Result()
+			ast.Nil(err)
+			ast.Equal(res, "v1")
+
+			body := "return {KEYS[1], tonumber(ARGV[1]) + 1, redis.call('incr', KEYS[1]), redis.call('get', 'lua-none')}"
+			sha, _ := rdb.ScriptLoad(ctx, body).Result()
+			ast.Equal(sha, sha1hex(body))
+			exists, _ := rdb.ScriptExists(ctx, sha, "0000").Result()
+			ast.Equal(exists, []bool{true, false})
+			// null reply is converted to false, and false is converted to null reply
+			res, _ = rdb.EvalSha(ctx, sha, []string{"lua-n"}, 10).Result()
+			ast.Equal(res, []any{"lua-n", int64(11), int64(1), nil})
+
+			res, _ = rdb.Eval(ctx, "return redis.status_reply('DONE')", nil).Result()
+			ast.Equal(res, "DONE")
+			res, _ = rdb.Eval(ctx, "return redis.pcall('incr', KEYS[1])['err']", []string{"lua-key"}).Result()
+			ast.Equal(res, errParseInteger.Error())
+			_, err = rdb.Eval(ctx, "return redis.call('incr', KEYS[1])", []string{"lua-key"}).Result()
+			ast.Equal(err.Error(), errParseInteger.Error())
+			_, err = rdb.EvalRO(ctx, "return redis.call('set', KEYS[1], 'x')", []string{"lua-key"}).Result()
+			ast.Equal(err.Error(), errWriteInReadOnlyScript.Error())
+			_, err = rdb.EvalSha(ctx, "ffff", nil).Result()
+			ast.Equal(err.Error(), errNoScript.Error())
+			_, err = rdb.Eval(ctx, "return +", nil).Result()
+			ast.NotNil(err)
+			_, err = rdb.Eval(ctx, "return 1", nil, 1).Result()
+			ast.Nil(err)
+			_, err = rdb.Do(ctx, "eval", "return 1", "2", "a").Result()
+			ast.Equal(err.Error(), errTooManyNumKeys.Error())
+			// commands flagged noscript
+			for _, call := range []string{"'hello', '3'", "'client', 'id'", "'config', 'set', 'lua.time-limit', '100'", "'multi'", "'eval', 'return 1', '0'"} {
+				res, _ = rdb.Eval(ctx, "return redis.pcall("+call+")['err']", nil).Result()
+				ast.Equal(res, errNotAllowedInScript.Error())
+			}
+			res, _ = rdb.Eval(ctx, "return redis.call('ping')", nil).Result()
+			ast.Equal(res, "PONG")
+
+			// kill busy script
+			errCh := make(chan error)
+			go func() {
+				errCh <- rdb.Eval(ctx, "while true do end", nil).Err()
+			}()
+			time.Sleep(time.Second / 2)
+			_, err = rdb.Get(ctx, "lua-key").Result()
+			ast.Equal(err.Error(), errBusyScript.Error())
+			ast.Equal(rdb.ScriptKill(ctx).Val(), "OK")
+			ast.Equal((<-errCh).Error(), errScriptKilled.Error())
+			ast.Equal(rdb.ScriptKill(ctx).Err().Error(), errNoScriptRunning.Error())
+
+			res, _ = rdb.Get(ctx, "lua-key").Result()
+			ast.Equal(res, "v1")
+
+			ast.Nil(rdb.ScriptFlush(ctx).Err())
+			exists, _ = rdb.ScriptExists(ctx, sha).Result()
+			ast.Equal(exists, []bool{false})
+		})
+
+		t.Run("pubsub-shard", func(t *testing.T) {
+			sub := rdb.SSubscribe(ctx, "shard1")
+			defer sub.Close()
+			_, err := sub.Receive(ctx)
+			ast.Nil(err)
+
+			channels, _ := rdb.PubSubShardChannels(ctx, "*").Result()
+			ast.Equal(channels, []string{"shard1"})
+			numsub, _ := rdb.PubSubShardNumSub(ctx, "shard1").Result()
+			ast.Equal(numsub, map[string]int64{"shard1": 1})
+
+			n, _ := rdb.SPublish(ctx, "shard1", "hello").Result()
+			ast.Equal(n, int64(1))
+			msg, err := sub.ReceiveMessage(ctx)
+			ast.Nil(err)
+			ast.Equal(msg.Channel, "shard1")
+			ast.Equal(msg.Payload, "hello")
+
+			// shard channels are separated from channels.
+			n, _ = rdb.Publish(ctx, "shard1", "hello").Result()
+			ast.Equal(n, int64(0))
+
+			// ping in subscriber mode.
+			ast.Nil(sub.Ping(ctx, "hi"))
+			pong, err := sub.Receive(ctx)
+			ast.Nil(err)
+			ast.Equal(pong, &redis.Pong{Payload: "hi"})
+		})
+
+		t.Run("pubsub-context", func(t *testing.T) {
+			conn, err := net.Dial("tcp", ":7979")
+			ast.Nil(err)
+			defer conn.Close()
+
+			_, err = conn.Write([]byte("*2\r\n$9\r\nsubscribe\r\n$2\r\nch\r\n*2\r\n$3\r\nget\r\n$3\r\nkey\r\n"))
+			ast.Nil(err)
+
+			buf := make([]byte, 1024)
+			var reply []byte
+			for !bytes.Contains(reply, []byte("allowed in this context\r\n")) {
+				n, err := conn.Read(buf)
+				ast.Nil(err)
+				reply = append(reply, buf[:n]...)
+			}
+			ast.Equal(string(reply), "*3\r\n$9\r\nsubscribe\r\n$2\r\nch\r\n:1\r\n"+
+				"-"+errNotAllowedInPubSub("get").Error()+"\r\n")
+		})
+
+		t.Run("command", func(t *testing.T) {
+			infos, err := rdb.Command(ctx).Result()
+			ast.Nil(err)
+			ast.Equal(len(infos), len(cmdTable))
+			set := infos["set"]
+			ast.Equal(set.Arity, int8(-3))
+			ast.Equal(set.Flags, []string{"write", "denyoom"})
+			ast.Equal([]int8{set.FirstKeyPos, set.LastKeyPos, set.StepCount}, []int8{1, 1, 1})
+			ast.Equal(set.ACLFlags, []string{"@write", "@slow", "@string"})
+			ast.True(infos["get"].ReadOnly)
+
+			n, _ := rdb.Do(ctx, "command", "count").Int()
+			ast.Equal(n, len(cmdTable))
+			res, _ := rdb.Do(ctx, "command", "info", "del", "not-exist").Slice()
+			ast.Equal(len(res), 2)
+			ast.Nil(res[1])
+			docs, _ := rdb.Do(ctx, "command", "docs", "get").Result()
+			ast.Equal(docs, map[any]any{
+				"get": map[any]any{"summary": "Returns the string value of a key.", "group": "string"},
+			})
+
+			keys, _ := rdb.CommandGetKeys(ctx, "del", "k1", "k2", "k3").Result()
+			ast.Equal(keys, []string{"k1", "k2", "k3"})
+			keys, _ = rdb.CommandGetKeys(ctx, "zunionstore", "dst", "2", "a", "b", "weights", "1", "2").Result()
+			ast.Equal(keys, []string{"dst", "a", "b"})
+			keys, _ = rdb.CommandGetKeys(ctx, "xread", "count", "1", "streams", "s1", "s2", "0", "0").Result()
+			ast.Equal(keys, []string{"s1", "s2"})
+			keys, _ = rdb.CommandGetKeys(ctx, "eval", "return 1", "1", "k1", "a1").Result()
+			ast.Equal(keys, []string{"k1"})
+
+			_, err = rdb.CommandGetKeys(ctx, "ping").Result()
+			ast.Equal(err.Error(), errNoKeyArguments.Error())
+			_, err = rdb.CommandGetKeys(ctx, "get", "k1", "k2").Result()
+			ast.Equal(err.Error(), errInvalidCommandArgs.Error())
+			_, err = rdb.CommandGetKeys(ctx, "not-exist").Result()
+			ast.Equal(err.Error(), errInvalidCommandSpecified.Error())
+			keys, _ = rdb.CommandGetKeys(ctx, "xgroup", "create", "s1", "g1", "$").Result()
+			ast.Equal(keys, []string{"s1"})
+
+			res, _ = rdb.Do(ctx, "command", "info", "config|get", "config|none").Slice()
+			ast.Equal(res[0].([]any)[0], "config|get")
+			ast.Nil(res[1])
+			res, _ = rdb.Do(ctx, "command", "info", "config").Slice()
+			ast.Equal(len(res[0].([]any)[9].([]any)), len(subcommandTable["config"]))
+		})
+
+		t.Run("registry", func(t *testing.T) {
+			res, err := rdb.Do(ctx, "SeT", "reg-key", "v").Result()
+			ast.Nil(err)
+			ast.Equal(res, "OK")
+			res, _ = rdb.Do(ctx, "GET", "reg-key").Result()
+			ast.Equal(res, "v")
+
+			// exact and variadic arity
+			_, err = rdb.Do(ctx, "get").Result()
+			ast.Equal(err.Error(), errWrongArity("get").Error())
+			_, err = rdb.Do(ctx, "get", "reg-key", "extra").Result()
+			ast.Equal(err.Error(), errWrongArity("get").Error())
+			_, err = rdb.Do(ctx, "set", "reg-key").Result()
+			ast.Equal(err.Error(), errWrongArity("set").Error())
+			_, err = rdb.Do(ctx, "zadd", "reg-zset", "1", "a", "2").Result()
+			ast.Equal(err.Error(), errSyntax.Error())
+
+			// subcommands
+			_, err = rdb.Do(ctx, "config").Result()
+			ast.Equal(err.Error(), errWrongArity("config").Error())
+			_, err = rdb.Do(ctx, "config", "get").Result()
+			ast.Equal(err.Error(), errWrongArity("config|get").Error())
+			_, err = rdb.Do(ctx, "config", "none").Result()
+			ast.Equal(err.Error(), errUnknownSubcommand("none", "config").Error())
+			_, err = rdb.Do(ctx, "config", "set", "a", "b", "c").Result()
+			ast.Equal(err.Error(), errWrongArity("config|set").Error())
+			res, _ = rdb.Do(ctx, "CONFIG", "Get", "tcp.port").Result()
+			ast.Equal(res, map[any]any{"tcp.port": "7979"})
+			_, err = rdb.Do(ctx, "config|get", "tcp.port").Result()
+			ast.NotNil(err)
+
+			// arity is checked when queued in MULTI
+			pipe := rdb.TxPipeline()
+			pipe.Do(ctx, "xgroup", "create", "reg-stream", "g1", "$", "mkstream")
+			pipe.Do(ctx, "xgroup", "destroy", "reg-stream")
+			_, err = pipe.Exec(ctx)
+			ast.Equal(err.Error(), errExecAbort.Error())
+			typ, _ := rdb.Type(ctx, "reg-stream").Result()
+			ast.Equal(typ, "none")
+		})
+
+		t.Run("resp3", func(t *testing.T) {
+			conn, err := net.Dial("tcp", ":7979")
+			ast.Nil(err)
+			defer conn.Close()
+
+			var req []byte
+			for _, args := range [][]string{
+				{"hello", "3", "setname", "r3"},
+				{"hset", "r3-hash", "f", "v"},
+				{"hgetall", "r3-hash"},
+				{"zadd", "r3-zset", "1.5", "a"},
+				{"zrange", "r3-zset", "0", "-1", "withscores"},
+				{"config", "get", "tcp.port"},
+				{"get", "r3-none"},
+				{"hello", "2"},
+				{"hgetall", "r3-hash"},
+			} {
+				req = redcon.AppendArray(req, len(args))
+				for _, arg := range args {
+					req = redcon.AppendBulkString(req, arg)
+				}
+			}
+			_, err = conn.Write(req)
+			ast.Nil(err)
+
+			last := "*2\r\n$1\r\nf\r\n$1\r\nv\r\n"
+			buf := make([]byte, 1024)
+			var reply []byte
+			for !bytes.HasSuffix(reply, []byte(last)) {
+				n, err := conn.Read(buf)
+				ast.Nil(err)
+				reply = append(reply, buf[:n]...)
+			}
+			str := string(reply)
+			ast.True(strings.HasPrefix(str, "%7\r\n$6\r\nserver\r\n$5\r\nrotom\r\n"))
+			ast.Contains(str, "$5\r\nproto\r\n:3\r\n")
+			ast.Contains(str, "%1\r\n$1\r\nf\r\n$1\r\nv\r\n")
+			ast.Contains(str, "*1\r\n*2\r\n$1\r\na\r\n,1.5\r\n")
+			ast.Contains(str, "%1\r\n$8\r\ntcp.port\r\n$4\r\n7979\r\n")
+			ast.Contains(str, "_\r\n")
+			ast.Contains(str, "$5\r\nproto\r\n:2\r\n")
+
+			_, err = rdb.Do(ctx, "hello", "4").Result()
+			ast.Equal(err.Error(), errNoProto.Error())
+			_, err = rdb.Do(ctx, "hello", "3", "setname", "a b").Result()
+			ast.Equal(err.Error(), errInvalidClientName.Error())
+		})
+
+		t.Run("tracking", func(t *testing.T) {
+			dial := func() (send func(args ...string), recv func(suffix string) string) {
+				conn, err := net.Dial("tcp", ":7979")
+				ast.Nil(err)
+				t.Cleanup(func() { conn.Close() })
+				send = func(args ...string) {
+					req := redcon.AppendArray(nil, len(args))
+					for _, arg := range args {
+						req = redcon.AppendBulkString(req, arg)
+					}
+					_, err := conn.Write(req)
+					ast.Nil(err)
+				}
+				var reply []byte
+				buf := make([]byte, 1024)
+				recv = func(suffix string) string {
+					for !bytes.Contains(reply, []byte(suffix)) {
+						_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+						n, err := conn.Read(buf)
+						if !ast.Nil(err) {
+							return string(reply)
+						}
+						reply = append(reply, buf[:n]...)
+					}
+					i := bytes.Index(reply, []byte(suffix)) + len(suffix)
+					res := string(reply[:i])
+					reply = reply[i:]
+					return res
+				}
+				return
+			}
+
+			// default mode in RESP3
+			send, recv := dial()
+			send("hello", "3")
+			recv("modules\r\n*0\r\n")
+			send("client", "tracking", "on")
+			send("get", "tk1")
+			send("get", "tk2")
+			send("get", "tk3")
+			recv("+OK\r\n_\r\n_\r\n_\r\n")
+			rdb.Set(ctx, "tk1", "v", 0)
+			rdb.Set(ctx, "tk1", "v", 0) // invalidated once
+			rdb.Del(ctx, "tk3")
+			ast.Equal(recv("$3\r\ntk1\r\n"), ">2\r\n$10\r\ninvalidate\r\n*1\r\n$3\r\ntk1\r\n")
+			ast.Equal(recv("$3\r\ntk3\r\n"), ">2\r\n$10\r\ninvalidate\r\n*1\r\n$3\r\ntk3\r\n")
+
+			send("incr", "tk2") // invalidated after the reply
+			ast.Equal(recv("$3\r\ntk2\r\n"), ":1\r\n>2\r\n$10\r\ninvalidate\r\n*1\r\n$3\r\ntk2\r\n")
+			rdb.FlushDB(ctx)
+			ast.Equal(recv("invalidate\r\n_\r\n"), ">2\r\n$10\r\ninvalidate\r\n_\r\n")
+
+			// OPTIN tracks the next command after CLIENT CACHING YES
+			send("client", "tracking", "on", "optin")
+			ast.Equal(recv("\r\n"), "-"+errTrackingSwitchMode.Error()+"\r\n")
+			send("client", "tracking", "off")
+			send("client", "tracking", "on", "optin")
+			send("get", "tk4")
+			send("client", "caching", "yes")
+			send("get", "tk5")
+			recv("+OK\r\n+OK\r\n_\r\n+OK\r\n_\r\n")
+			rdb.Set(ctx, "tk4", "v", 0)
+			rdb.Set(ctx, "tk5", "v", 0)
+			ast.Equal(recv("$3\r\ntk5\r\n"), ">2\r\n$10\r\ninvalidate\r\n*1\r\n$3\r\ntk5\r\n")
+
+			// BCAST with prefix and NOLOOP
+			send("client", "tracking", "off")
+			send("client", "tracking", "on", "bcast", "prefix", "user:", "noloop")
+			send("set", "user:2", "v")
+			recv("+OK\r\n+OK\r\n+OK\r\n")
+			rdb.Set(ctx, "item:1", "v", 0)
+			rdb.HSet(ctx, "user:1", "f", "v")
+			ast.Equal(recv("$6\r\nuser:1\r\n"), ">2\r\n$10\r\ninvalidate\r\n*1\r\n$6\r\nuser:1\r\n")
+
+			// REDIRECT to RESP2 client subscribing the invalidate channel
+			sendRedir, recvRedir := dial()
+			sendRedir("client", "id")
+			id := strings.TrimSuffix(strings.TrimPrefix(recvRedir("\r\n"), ":"), "\r\n")
+			sendRedir("subscribe", trackingChannel)
+			recvRedir(":1\r\n")
+
+			send2, recv2 := dial()
+			send2("client", "tracking", "on", "redirect", id)
+			send2("client", "getredir")
+			send2("get", "tk6")
+			ast.Equal(recv2("$-1\r\n"), "+OK\r\n:"+id+"\r\n$-1\r\n")
+			rdb.Set(ctx, "tk6", "v", 0)
+			ast.Equal(recvRedir("$3\r\ntk6\r\n"), "*3\r\n$7\r\nmessage\r\n$20\r\n__redis__:invalidate\r\n*1\r\n$3\r\ntk6\r\n")
+
+			send2("client", "tracking", "on", "redirect", "0")
+			ast.Equal(recv2("\r\n"), "-"+errTrackingRedirect.Error()+"\r\n")
+			send2("client", "tracking", "on", "prefix", "a")
+			ast.Equal(recv2("\r\n"), "-"+errTrackingPrefix.Error()+"\r\n")
+			send2("client", "caching", "yes")
+			ast.Equal(recv2("\r\n"), "-"+errTrackingCaching.Error()+"\r\n")
+		})
+
+		t.Run("client", func(t *testing.T) {
+			conn := rdb.Conn()
+			defer conn.Close()
+
+			id, err := conn.ClientID(ctx).Result()
+			ast.Nil(err)
+			ast.Nil(conn.ClientSetName(ctx, "c1").Err())
+			name, _ := conn.ClientGetName(ctx).Result()
+			ast.Equal(name, "c1")
+			err = conn.ClientSetName(ctx, "a b").Err()
+			ast.Equal(err.Error(), errInvalidClientName.Error())
+
+			info, err := conn.ClientInfo(ctx).Result()
+			ast.Nil(err)
+			ast.Equal(info.ID, id)
+			ast.Equal(info.Name, "c1")
+			ast.Equal(info.LastCmd, "client|info")
+			ast.Equal(info.Flags, redis.ClientFlags(0))
+			ast.Equal(info.Multi, -1)
+
+			list, _ := rdb.ClientList(ctx).Result()
+			ast.Contains(list, fmt.Sprintf("id=%d ", id))
+			list, _ = rdb.Do(ctx, "client", "list", "id", strconv.FormatInt(id, 10)).Text()
+			ast.Equal(strings.Count(list, "\n"), 1)
+			ast.Contains(list, "name=c1 ")
+			_, err = rdb.Do(ctx, "client", "list", "type", "none").Result()
+			ast.Equal(err.Error(), errUnknownClientType("none").Error())
+
+			// kill
+			_, err = rdb.ClientKill(ctx, "1.2.3.4:5").Result()
+			ast.Equal(err.Error(), errNoSuchClient.Error())
+			n, _ := rdb.ClientKillByFilter(ctx, "USER", "nobody").Result()
+			ast.Equal(n, int64(0))
+			n, _ = rdb.ClientKillByFilter(ctx, "ID", strconv.FormatInt(id, 10)).Result()
+			ast.Equal(n, int64(1))
+			ast.NotNil(conn.Ping(ctx).Err())
+
+			// pause
+			ast.Nil(rdb.ClientPause(ctx, 100*time.Millisecond).Err())
+			start := time.Now()
+			ast.Nil(rdb.Ping(ctx).Err())
+			ast.GreaterOrEqual(time.Since(start), 50*time.Millisecond)
+
+			ast.Nil(rdb.Do(ctx, "client", "pause", "5000", "write").Err())
+			ast.Nil(rdb.Type(ctx, "paused-key").Err())
+			done := make(chan struct{})
+			go func() {
+				rdb.Set(ctx, "paused-key", "v", 0)
+				close(done)
+			}()
+			time.Sleep(50 * time.Millisecond)
+			select {
+			case <-done:
+				t.Error("write command is not paused")
+			default:
+			}
+			ast.Nil(rdb.Do(ctx, "client", "unpause").Err())
+			<-done
+			res, _ := rdb.Get(ctx, "paused-key").Result()
+			ast.Equal(res, "v")
+
+			// reply
+			raw, err := net.Dial("tcp", ":7979")
+			ast.Nil(err)
+			defer raw.Close()
+			var req []byte
+			for _, args := range [][]string{
+				{"client", "reply", "off"},
+				{"set", "reply-key", "v"},
+				{"client", "reply", "skip"},
+				{"get", "reply-key"},
+				{"client", "reply", "on"},
+				{"client", "reply", "skip"},
+				{"get", "reply-key"},
+				{"echo"},
+				{"ping"},
+			} {
+				req = redcon.AppendArray(req, len(args))
+				for _, arg := range args {
+					req = redcon.AppendBulkString(req, arg)
+				}
+			}
+			_, err = raw.Write(req)
+			ast.Nil(err)
+			var reply []byte
+			buf := make([]byte, 1024)
+			for !bytes.HasSuffix(reply, []byte("+PONG\r\n")) {
+				n, err := raw.Read(buf)
+				ast.Nil(err)
+				reply = append(reply, buf[:n]...)
+			}
+			ast.Equal(string(reply), "+OK\r\n-ERR unknown command 'echo'\r\n+PONG\r\n")
+		})
+
+		t.Run("large-reply", func(t *testing.T) {
+			// reply larger than socket buffer is sent in several writable events.
+			const num = 100000
+			value := strings.Repeat("x", 64)
+			values := make([]any, num)
+			for i := range values {
+				values[i] = value
+			}
+			n, err := rdb.RPush(ctx, "large-reply", values...).Result()
+			ast.Nil(err)
+			ast.Equal(n, int64(num))
+
+			var wg sync.WaitGroup
+			for range 4 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					res, err := rdb.LRange(ctx, "large-reply", 0, -1).Result()
+					ast.Nil(err)
+					ast.Equal(len(res), num)
+					ast.Equal(res[num-1], value)
+				}()
+			}
+			wg.Wait()
+			ast.Nil(rdb.Del(ctx, "large-reply").Err())
+		})
+
+		t.Run("limits", func(t *testing.T) {
+			// bulk length exceeding proto.max-bulk-len is rejected before read.
+			raw, err := net.Dial("tcp", ":7979")
+			ast.Nil(err)
+			defer raw.Close()
+			_, err = raw.Write([]byte("*2\r\n$3\r\nget\r\n$100000000\r\n"))
+			ast.Nil(err)
+			reply, _ := io.ReadAll(raw)
+			ast.Equal(string(reply), "-"+errInvalidBulkLength.Error()+"\r\n")
+
+			// subscriber not reading is closed for reaching the pubsub hard limit.
+			sub, err := net.Dial("tcp", ":7979")
+			ast.Nil(err)
+			defer sub.Close()
+			req := redcon.AppendArray(nil, 2)
+			req = redcon.AppendBulkString(req, "subscribe")
+			req = redcon.AppendBulkString(req, "obuf-ch")
+			_, err = sub.Write(req)
+			ast.Nil(err)
+			buf := make([]byte, 1024)
+			_, err = sub.Read(buf)
+			ast.Nil(err)
+
+			message := strings.Repeat("x", MB)
+			receivers := int64(1)
+			for i := 0; i < 100 && receivers > 0; i++ {
+				receivers, err = rdb.Publish(ctx, "obuf-ch", message).Result()
+				ast.Nil(err)
+			}
+			ast.Equal(receivers, int64(0))
+			info, _ := rdb.Info(ctx, "stats").Result()
+			ast.Contains(info, "client_output_buffer_limit_disconnections:1\r\n")
+
+			// queryBuf grown by large command shrinks back when client is idle.
+			conn := rdb.Conn()
+			defer conn.Close()
+			ast.Nil(conn.Set(ctx, "qbuf-key", message, 0).Err())
+			ci, _ := conn.ClientInfo(ctx).Result()
+			ast.Greater(ci.QueryBufFree, MB)
+			time.Sleep(3500 * time.Millisecond)
+			ci, _ = conn.ClientInfo(ctx).Result()
+			ast.Less(ci.QueryBufFree, 1024)
+			ast.Nil(rdb.Del(ctx, "qbuf-key").Err())
+		})
+
+		t.Run("unixsocket", func(t *testing.T) {
+			path := "/tmp/rotom_test.sock"
+			fi, err := os.Stat(path)
+			ast.Nil(err)
+			ast.Equal(fi.Mode().Perm(), os.FileMode(0o700))
+
+			urdb := redis.NewClient(&redis.Options{Network: "unix", Addr: path})
+			defer urdb.Close()
+			ast.Nil(urdb.Set(ctx, "unix-key", "v", 0).Err())
+			res, _ := rdb.Get(ctx, "unix-key").Result()
+			ast.Equal(res, "v")
+
+			info, err := urdb.ClientInfo(ctx).Result()
+			ast.Nil(err)
+			ast.Equal(info.Addr, path+":0")
+			ast.Equal(info.LAddr, path+":0")
+			ast.Nil(rdb.Del(ctx, "unix-key").Err())
+		})
+
+		t.Run("bind", func(t *testing.T) {
+			// listening on both loopback addresses in rotom_test.toml.
+			for _, addr := range []string{"127.0.0.1:7979", "[::1]:7979"} {
+				brdb := redis.NewClient(&redis.Options{Addr: addr})
+				info, err := brdb.ClientInfo(ctx).Result()
+				ast.Nil(err)
+				ast.Equal(info.LAddr, addr)
+				ast.Nil(brdb.Close())
+			}
+			// not listening on other interfaces.
+			addrs, _ := net.InterfaceAddrs()
+			for _, addr := range addrs {
+				if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && ipnet.IP.To4() != nil {
+					_, err := net.Dial("tcp", net.JoinHostPort(ipnet.IP.String(), "7979"))
+					ast.NotNil(err)
+				}
+			}
+		})
+
+		t.Run("tls", func(t *testing.T) {
+			config, err := testTLSConfig(testTLSDir, true)
+			ast.Nil(err)
+			trdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:7980", TLSConfig: config})
+			defer trdb.Close()
+			ast.Nil(trdb.Set(ctx, "tls-key", "v", 0).Err())
+			res, _ := rdb.Get(ctx, "tls-key").Result()
+			ast.Equal(res, "v")
+
+			// large request and reply are split into several records.
+			value := strings.Repeat("x", 4*MB)
+			ast.Nil(trdb.Set(ctx, "tls-key", value, 0).Err())
+			res, _ = trdb.Get(ctx, "tls-key").Result()
+			ast.Equal(res, value)
+
+			// pipelined commands in one write.
+			pipe := trdb.Pipeline()
+			for range 100 {
+				pipe.Incr(ctx, "tls-counter")
+			}
+			_, err = pipe.Exec(ctx)
+			ast.Nil(err)
+			n, _ := rdb.Get(ctx, "tls-counter").Int()
+			ast.Equal(n, 100)
+
+			// client certificate is required.
+			config, _ = testTLSConfig(testTLSDir, false)
+			nrdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:7980", TLSConfig: config, MaxRetries: -1})
+			defer nrdb.Close()
+			ast.NotNil(nrdb.Ping(ctx).Err())
+
+			// plaintext connection does not block the loop.
+			raw, err := net.Dial("tcp", "127.0.0.1:7980")
+			ast.Nil(err)
+			defer raw.Close()
+			ast.Nil(rdb.Ping(ctx).Err())
+			ast.Nil(rdb.Del(ctx, "tls-key", "tls-counter").Err())
+		})
+
+		t.Run("io-threads", func(t *testing.T) {
+			// clients resumed together are written by io threads, or in batch by
+			// io_uring if io threads are disabled.
+			ast.Nil(rdb.ClientPause(ctx, 200*time.Millisecond).Err())
+			const numClients, numCommands = 32, 50
+			var req []byte
+			for range numCommands {
+				req = redcon.AppendArray(req, 2)
+				req = redcon.AppendBulkString(req, "incr")
+				req = redcon.AppendBulkString(req, "io-counter")
+			}
+			conns := make([]net.Conn, numClients)
+			for i := range conns {
+				conn, err := net.Dial("tcp", ":7979")
+				ast.Nil(err)
+				defer conn.Close()
+				_, err = conn.Write(req)
+				ast.Nil(err)
+				conns[i] = conn
+			}
+			for _, conn := range conns {
+				var reply []byte
+				buf := make([]byte, 4096)
+				for bytes.Count(reply, []byte("\r\n")) < numCommands {
+					n, err := conn.Read(buf)
+					ast.Nil(err)
+					reply = append(reply, buf[:n]...)
+				}
+			}
+			n, _ := rdb.Get(ctx, "io-counter").Int()
+			ast.Equal(n, numClients*numCommands)
+			info, _ := rdb.Info(ctx, "stats", "server").Result()
+			switch {
+			case server.ioThreads != nil:
+				ast.NotContains(info, "io_threaded_writes_processed:0\r\n")
+			case strings.Contains(info, "multiplexing_api:io_uring"):
+				ast.NotContains(info, "batched_writes_processed:0\r\n")
+			}
+			ast.Nil(rdb.Del(ctx, "io-counter").Err())
+		})
+
+		t.Run("ae-backend", func(t *testing.T) {
+			// selected by ROTOM_AE_BACKEND, falls back to epoll if io_uring is not supported.
+			backend := configGetString("ae.backend")
+			if backend == "io_uring" {
+				if p, err := newUringPoller(4); err != nil {
+					backend = "epoll"
+				} else {
+					p.close()
+				}
+			}
+			info, err := rdb.Info(ctx, "server").Result()
+			ast.Nil(err)
+			ast.Contains(info, "multiplexing_api:"+backend+"\r\n")
+			ast.NotContains(info, "# Clients")
+		})
+
 		t.Run("trans-zipset", func(t *testing.T) {
 			for i := 0; i <= 512; i++ {
 				k := fmt.Sprintf("%06x", i)
@@ -534,6 +2106,24 @@ func testCommand(t *testing.T, testType string, rdb *redis.Client, sleepFn func(
 				redis.Z{Score: 100, Member: "k1"},
 				redis.Z{Score: 300, Member: "k3"})
 
+			rdb.XAdd(ctx, &redis.XAddArgs{Stream: "rdb-stream1", ID: "1-1", Values: []string{"k1", "v1"}})
+			rdb.XGroupCreate(ctx, "rdb-stream1", "g1", "0")
+			rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "g1", Consumer: "c1", Streams: []string{"rdb-stream1", ">"}, Block: -1})
+
+			rdb.JSONSet(ctx, "rdb-json1", "$", `{"k1":[1,2.5,"v1",true,null]}`)
+			rdb.BFAdd(ctx, "rdb-bf1", "k1")
+			rdb.CFAdd(ctx, "rdb-cf1", "k1")
+			rdb.Do(ctx, "ts.create", "rdb-ts1", "labels", "k1", "v1")
+			rdb.Do(ctx, "ts.add", "rdb-ts1", 1, 1.5)
+			rdb.Do(ctx, "vadd", "rdb-vs1", "values", "2", "1", "2", "k1", "noquant")
+			rdb.Do(ctx, "vadd", "rdb-vs1", "values", "2", "2", "1", "k2", "noquant")
+			rdb.Do(ctx, "cms.initbydim", "rdb-cms1", "100", "3")
+			rdb.Do(ctx, "cms.incrby", "rdb-cms1", "k1", "3")
+			rdb.Do(ctx, "topk.reserve", "rdb-topk1", "3")
+			rdb.Do(ctx, "topk.add", "rdb-topk1", "k1", "k1", "k2")
+			rdb.Do(ctx, "tdigest.create", "rdb-td1")
+			rdb.Do(ctx, "tdigest.add", "rdb-td1", "1", "2", "3")
+
 			res, _ := rdb.Save(context.Background()).Result()
 			ast.Equal(res, "OK")
 
@@ -567,6 +2157,31 @@ func testCommand(t *testing.T, testType string, rdb *redis.Client, sleepFn func(
 			ast.Equal(resz, []redis.Z{{
 				Member: "k1", Score: 100,
 			}})
+
+			msgs, _ := rdb.XRange(ctx, "rdb-stream1", "-", "+").Result()
+			ast.Equal(msgs, []redis.XMessage{{ID: "1-1", Values: map[string]interface{}{"k1": "v1"}}})
+			str, _ := rdb.JSONGet(ctx, "rdb-json1").Result()
+			ast.Equal(str, `{"k1":[1,2.5,"v1",true,null]}`)
+			ok, _ := rdb.BFExists(ctx, "rdb-bf1", "k1").Result()
+			ast.True(ok)
+			ok, _ = rdb.CFExists(ctx, "rdb-cf1", "k1").Result()
+			ast.True(ok)
+			samples, _ := rdb.Do(ctx, "ts.mrange", "-", "+", "withlabels", "filter", "k1=v1").Result()
+			ast.Equal(samples, []any{[]any{"rdb-ts1", []any{[]any{"k1", "v1"}}, []any{[]any{int64(1), "1.5"}}}})
+			ress, _ = rdb.Do(ctx, "vemb", "rdb-vs1", "k1").StringSlice()
+			ast.Equal(ress, []string{"1", "2"})
+			ress, _ = rdb.Do(ctx, "vsim", "rdb-vs1", "ele", "k2").StringSlice()
+			ast.Equal(ress, []string{"k2", "k1"})
+			counts, _ := rdb.Do(ctx, "cms.query", "rdb-cms1", "k1", "k2").Int64Slice()
+			ast.Equal(counts, []int64{3, 0})
+			ress, _ = rdb.Do(ctx, "topk.list", "rdb-topk1").StringSlice()
+			ast.Equal(ress, []string{"k1", "k2"})
+			ress, _ = rdb.Do(ctx, "tdigest.quantile", "rdb-td1", "0.5").StringSlice()
+			ast.Equal(ress, []string{"2"})
+			pending, _ := rdb.XPending(ctx, "rdb-stream1", "g1").Result()
+			ast.Equal(pending.Count, int64(1))
+			id, _ := rdb.XAdd(ctx, &redis.XAddArgs{Stream: "rdb-stream1", ID: "1-*", Values: []string{"k2", "v2"}}).Result()
+			ast.Equal(id, "1-2")
 		})
 	}
 
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/resp"
+)
+
+// commandGetKeys returns the key positions of commands with movablekeys flag.
+var commandGetKeys = map[string]func(args []redcon.RESP) []int{
+	"zunion":        numKeysGetKeys(1, false),
+	"zinter":        numKeysGetKeys(1, false),
+	"zdiff":         numKeysGetKeys(1, false),
+	"zintercard":    numKeysGetKeys(1, false),
+	"zunionstore":   numKeysGetKeys(2, true),
+	"zinterstore":   numKeysGetKeys(2, true),
+	"zdiffstore":    numKeysGetKeys(2, true),
+	"cms.merge":     numKeysGetKeys(2, true),
+	"tdigest.merge": numKeysGetKeys(2, true),
+	"eval":          numKeysGetKeys(2, false),
+	"evalsha":       numKeysGetKeys(2, false),
+	"eval_ro":       numKeysGetKeys(2, false),
+	"evalsha_ro":    numKeysGetKeys(2, false),
+	"xread":         streamsGetKeys,
+	"xreadgroup":    streamsGetKeys,
+}
+
+// numKeysGetKeys returns a function that finds keys following numkeys argument at
+// index, and the destination key at index 1 if dest is true.
+func numKeysGetKeys(index int, dest bool) func(args []redcon.RESP) []int {
+	return func(args []redcon.RESP) []int {
+		var keys []int
+		if dest {
+			keys = append(keys, 1)
+		}
+		if index >= len(args) {
+			return keys
+		}
+		n, err := strconv.Atoi(b2s(args[index].Bytes()))
+		if err != nil || n < 0 || index+n >= len(args) {
+			return keys
+		}
+		for i := range n {
+			keys = append(keys, index+1+i)
+		}
+		return keys
+	}
+}
+
+// streamsGetKeys finds keys in the first half arguments after STREAMS.
+func streamsGetKeys(args []redcon.RESP) []int {
+	for i, arg := range args {
+		if !equalFold(b2s(arg.Bytes()), Streams) {
+			continue
+		}
+		n := (len(args) - i - 1) / 2
+		keys := make([]int, n)
+		for j := range keys {
+			keys[j] = i + 1 + j
+		}
+		return keys
+	}
+	return nil
+}
+
+func (cmd *Command) flagList() []string {
+	return strings.Fields(cmd.flags)
+}
+
+func (cmd *Command) hasFlag(flag string) bool {
+	return slices.Contains(cmd.flagList(), flag)
+}
+
+// aclCategories returns ACL categories implied by flags and group of command.
+func (cmd *Command) aclCategories() []string {
+	var categories []string
+	add := func(c ...string) { categories = append(categories, c...) }
+	if cmd.hasFlag("write") {
+		add("@write")
+	}
+	if cmd.hasFlag("readonly") {
+		add("@read")
+	}
+	if cmd.hasFlag("admin") {
+		add("@admin", "@dangerous")
+	}
+	if cmd.hasFlag("pubsub") && cmd.group != "pubsub" {
+		add("@pubsub")
+	}
+	if cmd.hasFlag("fast") {
+		add("@fast")
+	} else {
+		add("@slow")
+	}
+	if cmd.hasFlag("blocking") {
+		add("@blocking")
+	}
+	switch cmd.group {
+	case "generic":
+		add("@keyspace")
+	case "string", "hash", "list", "set", "stream", "pubsub", "connection", "scripting":
+		add("@" + cmd.group)
+	case "sorted-set":
+		add("@sortedset")
+	case "transactions":
+		add("@transaction")
+	case "bf", "cf", "cms", "topk", "tdigest":
+		add("@bloom")
+	case "json", "timeseries":
+		add("@" + cmd.group)
+	}
+	return categories
+}
+
+// keyPositions returns the positions of keys in args including command name.
+func (cmd *Command) keyPositions(args []redcon.RESP) []int {
+	if getKeys := commandGetKeys[cmd.name]; getKeys != nil {
+		return getKeys(args)
+	}
+	if cmd.firstKey == 0 {
+		return nil
+	}
+	last := cmd.lastKey
+	if last < 0 {
+		last += len(args)
+	}
+	var keys []int
+	for i := cmd.firstKey; i <= last && i < len(args); i += cmd.keyStep {
+		keys = append(keys, i)
+	}
+	return keys
+}
+
+// arityMatched reports whether n arguments including command name match arity.
+func (cmd *Command) arityMatched(n int) bool {
+	return cmd.arity == n || cmd.arity < 0 && n >= -cmd.arity
+}
+
+func writeCommandInfo(writer *resp.Writer, cmd *Command) {
+	writer.WriteArray(10)
+	writer.WriteBulkString(cmd.name)
+	writer.WriteInt(cmd.arity)
+	flags := cmd.flagList()
+	writer.WriteSet(len(flags))
+	for _, flag := range flags {
+		writer.WriteString(flag)
+	}
+	writer.WriteInt(cmd.firstKey)
+	writer.WriteInt(cmd.lastKey)
+	writer.WriteInt(cmd.keyStep)
+	categories := cmd.aclCategories()
+	writer.WriteSet(len(categories))
+	for _, c := range categories {
+		writer.WriteString(c)
+	}
+	writer.WriteArray(0) // tips
+	writeKeySpecs(writer, cmd)
+	subs := subcommandTable[cmd.name]
+	writer.WriteArray(len(subs))
+	for _, sub := range subs {
+		writeCommandInfo(writer, sub)
+	}
+}
+
+// writeKeySpecs writes key specifications derived from key positions, commands with
+// movable keys have unknown key specifications.
+func writeKeySpecs(writer *resp.Writer, cmd *Command) {
+	movable := commandGetKeys[cmd.name] != nil
+	if cmd.firstKey == 0 && !movable {
+		writer.WriteArray(0)
+		return
+	}
+	writer.WriteArray(1)
+	writer.WriteMap(3)
+	writer.WriteBulkString("flags")
+	writer.WriteSet(1)
+	if cmd.hasFlag("write") {
+		writer.WriteString("RW")
+	} else {
+		writer.WriteString("RO")
+	}
+
+	writer.WriteBulkString("begin_search")
+	writer.WriteMap(2)
+	writer.WriteBulkString("type")
+	if movable {
+		writer.WriteBulkString("unknown")
+		writer.WriteBulkString("spec")
+		writer.WriteMap(0)
+	} else {
+		writer.WriteBulkString("index")
+		writer.WriteBulkString("spec")
+		writer.WriteMap(1)
+		writer.WriteBulkString("index")
+		writer.WriteInt(cmd.firstKey)
+	}
+
+	writer.WriteBulkString("find_keys")
+	writer.WriteMap(2)
+	writer.WriteBulkString("type")
+	if movable {
+		writer.WriteBulkString("unknown")
+		writer.WriteBulkString("spec")
+		writer.WriteMap(0)
+		return
+	}
+	lastKey := cmd.lastKey
+	if lastKey >= 0 {
+		lastKey -= cmd.firstKey
+	}
+	writer.WriteBulkString("range")
+	writer.WriteBulkString("spec")
+	writer.WriteMap(3)
+	writer.WriteBulkString("lastkey")
+	writer.WriteInt(lastKey)
+	writer.WriteBulkString("keystep")
+	writer.WriteInt(cmd.keyStep)
+	writer.WriteBulkString("limit")
+	writer.WriteInt(0)
+}
+
+func writeCommandDocs(writer *resp.Writer, cmd *Command) {
+	writer.WriteBulkString(cmd.name)
+	writer.WriteMap(2)
+	writer.WriteBulkString("summary")
+	writer.WriteBulkString(cmd.summary)
+	writer.WriteBulkString("group")
+	writer.WriteBulkString(cmd.group)
+}
+
+func commandCommand(writer *resp.Writer, _ []redcon.RESP) {
+	writer.WriteArray(len(cmdTable))
+	for _, cmd := range cmdTable {
+		writeCommandInfo(writer, cmd)
+	}
+}
+
+func commandCountCommand(writer *resp.Writer, _ []redcon.RESP) {
+	writer.WriteInt(len(cmdTable))
+}
+
+// COMMAND INFO [command-name ...]
+func commandInfoCommand(writer *resp.Writer, args []redcon.RESP) {
+	if len(args) == 0 {
+		commandCommand(writer, nil)
+		return
+	}
+	writer.WriteArray(len(args))
+	for _, arg := range args {
+		cmd, err := lookupCommandName(arg.String())
+		if err != nil {
+			writer.WriteNullArray()
+			continue
+		}
+		writeCommandInfo(writer, cmd)
+	}
+}
+
+// COMMAND DOCS [command-name ...]
+func commandDocsCommand(writer *resp.Writer, args []redcon.RESP) {
+	var cmds []*Command
+	if len(args) == 0 {
+		cmds = cmdTable
+	}
+	for _, arg := range args {
+		if cmd, err := lookupCommandName(arg.String()); err == nil {
+			cmds = append(cmds, cmd)
+		}
+	}
+	writer.WriteMap(len(cmds))
+	for _, cmd := range cmds {
+		writeCommandDocs(writer, cmd)
+	}
+}
+
+// COMMAND GETKEYS command [arg ...]
+func commandGetKeysCommand(writer *resp.Writer, args []redcon.RESP) {
+	cmd, _, err := resolveCommand(args[0].String(), args[1:])
+	switch {
+	case errors.Is(err, errWrongArguments):
+		writer.WriteError(errInvalidCommandArgs.Error())
+		return
+	case err != nil:
+		writer.WriteError(errInvalidCommandSpecified.Error())
+		return
+	}
+	keys := cmd.keyPositions(args)
+	if len(keys) == 0 {
+		writer.WriteError(errNoKeyArguments.Error())
+		return
+	}
+	writer.WriteArray(len(keys))
+	for _, i := range keys {
+		writer.WriteBulk(args[i].Bytes())
+	}
+}
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
 	"github.com/spf13/viper"
+	"github.com/xgzlucario/rotom/internal/net"
 )
 
 const (
@@ -9,6 +15,19 @@ const (
 )
 
 func initConfig(fileName string) error {
+	viper.SetDefault("tcp.bind", []string{"*"})
+	viper.SetDefault("tcp.backlog", net.BACKLOG)
+	viper.SetDefault("tcp.keepalive", 300)
+	viper.SetDefault("tls.auth-clients", "yes")
+	viper.SetDefault("io.threads", 1)
+	viper.SetDefault("ae.backend", "epoll")
+	viper.SetDefault("client.query-buffer-limit", "1gb")
+	viper.SetDefault("proto.max-bulk-len", "512mb")
+	viper.SetDefault("client.output-buffer-limit", "normal 0 0 0 replica 256mb 64mb 60 pubsub 32mb 8mb 60")
+	// config can be overridden by env, e.g. ROTOM_AE_BACKEND=io_uring for ae.backend.
+	viper.SetEnvPrefix("rotom")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.AutomaticEnv()
 	viper.SetConfigFile(fileName)
 	return viper.ReadInConfig()
 }
@@ -25,6 +44,52 @@ func configGetPort() int {
 	return configGetInt("tcp.port")
 }
 
+// configGetBind returns the addresses that tcp listens on, address prefixed with
+// "-" is skipped if it is not available.
+func configGetBind() []string {
+	return viper.GetStringSlice("tcp.bind")
+}
+
+func configGetBacklog() int {
+	return configGetInt("tcp.backlog")
+}
+
+// configGetKeepAlive returns the tcp keepalive interval in seconds, 0 means
+// disabled.
+func configGetKeepAlive() int {
+	return configGetInt("tcp.keepalive")
+}
+
+func configGetUnixSocket() string {
+	return configGetString("unix.socket")
+}
+
+// configGetUnixSocketPerm parses unix.socketperm in octal like "700", 0 means the
+// file mode is left as created.
+func configGetUnixSocketPerm() (os.FileMode, error) {
+	perm := configGetString("unix.socketperm")
+	if perm == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(perm, 8, 32)
+	if err != nil || n > 0o777 {
+		return 0, fmt.Errorf("invalid unix socket perm: %q", perm)
+	}
+	return os.FileMode(n), nil
+}
+
+// configGetTLSPort returns the port of TLS listeners on bind addresses, 0 means
+// TLS is disabled.
+func configGetTLSPort() int {
+	return configGetInt("tls.port")
+}
+
+// configGetIOThreads returns the number of io threads including the main thread,
+// which is limited to [1, 128].
+func configGetIOThreads() int {
+	return min(max(configGetInt("io.threads"), 1), 128)
+}
+
 func configGetAppendOnly() bool {
 	return configGetBool("aof.appendonly")
 }
@@ -36,3 +101,74 @@ func configGetAppendFileName() string {
 func configGetDbFileName() string {
 	return configGetString("rdb.dbfilename")
 }
+
+func configGetClientQueryBufferLimit() (int, error) {
+	return parseMemory(configGetString("client.query-buffer-limit"))
+}
+
+func configGetProtoMaxBulkLen() (int, error) {
+	return parseMemory(configGetString("proto.max-bulk-len"))
+}
+
+// configGetClientOutputBufferLimits parses client.output-buffer-limit in the form
+// of "<class> <hard limit> <soft limit> <soft seconds> ...", where class is one of
+// normal, replica and pubsub. Classes not configured use the default limits.
+func configGetClientOutputBufferLimits() ([numClientClasses]outputBufferLimit, error) {
+	limits := defaultOutputBufferLimits
+	value := configGetString("client.output-buffer-limit")
+	errOutputBufferLimit := fmt.Errorf("invalid client output buffer limit: %q", value)
+	fields := strings.Fields(value)
+	if len(fields)%4 != 0 {
+		return limits, errOutputBufferLimit
+	}
+	for i := 0; i < len(fields); i += 4 {
+		class, ok := clientClassByName(strings.ToLower(fields[i]))
+		if !ok {
+			return limits, errOutputBufferLimit
+		}
+		hard, err := parseMemory(fields[i+1])
+		if err != nil {
+			return limits, errOutputBufferLimit
+		}
+		soft, err := parseMemory(fields[i+2])
+		if err != nil {
+			return limits, errOutputBufferLimit
+		}
+		seconds, err := strconv.ParseInt(fields[i+3], 10, 64)
+		if err != nil || seconds < 0 {
+			return limits, errOutputBufferLimit
+		}
+		limits[class] = outputBufferLimit{hard: hard, soft: soft, softSeconds: seconds}
+	}
+	return limits, nil
+}
+
+// parseMemory parses memory size like "1gb" or "512mb" as Redis does, the units
+// k, m and g are powers of 1000, and kb, mb and gb are powers of 1024. Empty
+// string is parsed as 0.
+func parseMemory(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	s = strings.ToLower(s)
+	mul := 1
+	for _, unit := range memoryUnits {
+		if num, ok := strings.CutSuffix(s, unit.suffix); ok {
+			s, mul = num, unit.mul
+			break
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid memory size: %q", s)
+	}
+	return n * mul, nil
+}
+
+var memoryUnits = []struct {
+	suffix string
+	mul    int
+}{
+	{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+	{"k", 1e3}, {"m", 1e6}, {"g", 1e9}, {"b", 1},
+}
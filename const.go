@@ -3,9 +3,18 @@ package main
 import (
 	"github.com/dustin/go-humanize"
 	"github.com/redis/go-redis/v9"
+	"github.com/xgzlucario/rotom/internal/bloom"
+	"github.com/xgzlucario/rotom/internal/cms"
+	"github.com/xgzlucario/rotom/internal/cuckoo"
 	"github.com/xgzlucario/rotom/internal/hash"
 	"github.com/xgzlucario/rotom/internal/iface"
+	"github.com/xgzlucario/rotom/internal/json"
 	"github.com/xgzlucario/rotom/internal/list"
+	"github.com/xgzlucario/rotom/internal/stream"
+	"github.com/xgzlucario/rotom/internal/tdigest"
+	"github.com/xgzlucario/rotom/internal/timeseries"
+	"github.com/xgzlucario/rotom/internal/topk"
+	"github.com/xgzlucario/rotom/internal/vector"
 	"github.com/xgzlucario/rotom/internal/zset"
 )
 
@@ -21,6 +30,15 @@ const (
 	TypeList
 	TypeZSet
 	TypeZipZSet
+	TypeStream
+	TypeBloom
+	TypeCuckoo
+	TypeJSON
+	TypeTimeSeries
+	TypeVectorSet
+	TypeCMS
+	TypeTopK
+	TypeTDigest
 )
 
 const (
@@ -28,16 +46,31 @@ const (
 	KeyNotExist int64 = -2
 )
 
+// max length of zipped structures before converted.
+const (
+	maxZipSetSize  = 512
+	maxZipZSetSize = 256
+)
+
 const (
 	KB = humanize.Byte
 	MB = humanize.MiByte
 )
 
 var type2c = map[ObjectType]func() iface.Encoder{
-	TypeMap:     func() iface.Encoder { return hash.New() },
-	TypeSet:     func() iface.Encoder { return hash.NewSet() },
-	TypeZipSet:  func() iface.Encoder { return hash.NewZipSet() },
-	TypeList:    func() iface.Encoder { return list.New() },
-	TypeZSet:    func() iface.Encoder { return zset.New() },
-	TypeZipZSet: func() iface.Encoder { return zset.NewZipZSet() },
+	TypeMap:        func() iface.Encoder { return hash.New() },
+	TypeSet:        func() iface.Encoder { return hash.NewSet() },
+	TypeZipSet:     func() iface.Encoder { return hash.NewZipSet() },
+	TypeList:       func() iface.Encoder { return list.New() },
+	TypeZSet:       func() iface.Encoder { return zset.New() },
+	TypeZipZSet:    func() iface.Encoder { return zset.NewZipZSet() },
+	TypeStream:     func() iface.Encoder { return stream.New() },
+	TypeBloom:      func() iface.Encoder { return new(bloom.Bloom) },
+	TypeCuckoo:     func() iface.Encoder { return new(cuckoo.Cuckoo) },
+	TypeJSON:       func() iface.Encoder { return new(json.Value) },
+	TypeTimeSeries: func() iface.Encoder { return new(timeseries.Series) },
+	TypeVectorSet:  func() iface.Encoder { return new(vector.Set) },
+	TypeCMS:        func() iface.Encoder { return new(cms.CMS) },
+	TypeTopK:       func() iface.Encoder { return new(topk.TopK) },
+	TypeTDigest:    func() iface.Encoder { return new(tdigest.TDigest) },
 }
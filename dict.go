@@ -41,10 +41,19 @@ func (dict *Dict) Get(key string) (any, int64) {
 }
 
 func (dict *Dict) Set(key string, data any) {
+	signalModifiedKey(key)
 	dict.data.Put(key, data)
 }
 
+// Replace sets key to data and clears its ttl, like a STORE command overwrites
+// the destination.
+func (dict *Dict) Replace(key string, data any) {
+	dict.expire.Delete(key)
+	dict.Set(key, data)
+}
+
 func (dict *Dict) SetWithTTL(key string, data any, ttl int64) {
+	signalModifiedKey(key)
 	if ttl > 0 {
 		dict.expire.Put(key, ttl)
 	}
@@ -52,6 +61,7 @@ func (dict *Dict) SetWithTTL(key string, data any, ttl int64) {
 }
 
 func (dict *Dict) delete(key string) {
+	signalModifiedKey(key)
 	dict.data.Delete(key)
 	dict.expire.Delete(key)
 }
@@ -83,6 +93,7 @@ func (dict *Dict) SetTTL(key string, ttl int64) int {
 	}
 
 	// set ttl
+	signalModifiedKey(key)
 	dict.expire.Put(key, ttl)
 	return 1
 }
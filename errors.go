@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
@@ -10,4 +11,169 @@ var (
 	errWrongArguments = errors.New("ERR wrong number of arguments")
 	errUnknownCommand = errors.New("ERR unknown command")
 	errSyntax         = errors.New("ERR syntax error")
+	errNotFloat       = errors.New("ERR value is not a valid float")
+	errMinMaxNotFloat = errors.New("ERR min or max is not a float")
+
+	errMinMaxNotString   = errors.New("ERR min or max not valid string range item")
+	errLimitWithRank     = errors.New("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	errWithScoresWithLex = errors.New("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	errWeightNotFloat    = errors.New("ERR weight value is not a float")
+	errNumKeys           = errors.New("ERR numkeys should be greater than 0")
+	errLimitNegative     = errors.New("ERR LIMIT can't be negative")
+
+	errMaxLenNegative    = errors.New("ERR The MAXLEN argument must be >= 0.")
+	errInvalidInterval   = errors.New("ERR invalid start or end ID for the interval")
+	errStreamExhausted   = errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+	errStreamIDTooSmall  = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	errStreamIDZero      = errors.New("ERR The ID specified in XADD must be greater than 0-0")
+	errTimeoutNotInteger = errors.New("ERR timeout is not an integer or out of range")
+	errTimeoutNegative   = errors.New("ERR timeout is negative")
+	errXGroupKeyNotExist = errors.New("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+	errBusyGroup         = errors.New("BUSYGROUP Consumer Group name already exists")
+	errInvalidMinIdle    = errors.New("ERR Invalid min-idle-time argument for XCLAIM")
+	errCountPositive     = errors.New("ERR COUNT must be > 0")
+
+	errBadErrorRate        = errors.New("ERR bad error rate")
+	errErrorRateRange      = errors.New("ERR (0 < error rate range < 1)")
+	errBadCapacity         = errors.New("ERR bad capacity")
+	errCapacityRange       = errors.New("ERR (capacity should be larger than 0 and at most 1073741824)")
+	errBloomTooLarge       = errors.New("ERR Insufficient memory to create filter")
+	errBadExpansion        = errors.New("ERR bad expansion")
+	errExpansionRange      = errors.New("ERR expansion should be in range [1, 32768]")
+	errNonScalingExpansion = errors.New("ERR Nonscaling filters cannot expand")
+	errItemExists          = errors.New("ERR item exists")
+	errNotFound            = errors.New("ERR not found")
+	errInvalidInfoArg      = errors.New("ERR Invalid information value")
+	errCuckooNotFound      = errors.New("ERR Not found")
+
+	errJSONNewAtRoot   = errors.New("ERR new objects must be created at the root")
+	errJSONKeyNotExist = errors.New("ERR could not perform this operation on a key that doesn't exist")
+	errJSONNotNumber   = errors.New("ERR expected a number value")
+
+	errTSInvalidTimestamp   = errors.New("ERR TSDB: invalid timestamp")
+	errTSInvalidValue       = errors.New("ERR TSDB: invalid value")
+	errTSKeyExists          = errors.New("ERR TSDB: key already exists")
+	errTSKeyNotExist        = errors.New("ERR TSDB: the key does not exist")
+	errTSBadRetention       = errors.New("ERR TSDB: Couldn't parse RETENTION")
+	errTSBadChunkSize       = errors.New("ERR TSDB: CHUNK_SIZE value must be a multiple of 8 in the range [48 .. 1048576]")
+	errTSBadDuplicatePolicy = errors.New("ERR TSDB: Unknown DUPLICATE_POLICY")
+	errTSBadLabels          = errors.New("ERR TSDB: Couldn't parse LABELS")
+	errTSUnknownAggregation = errors.New("ERR TSDB: Unknown aggregation type")
+	errTSBadBucket          = errors.New("ERR TSDB: bucketDuration must be greater than zero")
+	errTSBadFrom            = errors.New("ERR TSDB: wrong fromTimestamp")
+	errTSBadTo              = errors.New("ERR TSDB: wrong toTimestamp")
+	errTSBadCount           = errors.New("ERR TSDB: Invalid COUNT")
+	errTSBadFilter          = errors.New("ERR TSDB: failed parsing labels")
+	errTSMissingFilter      = errors.New("ERR TSDB: missing FILTER argument")
+	errTSSameKey            = errors.New("ERR TSDB: the source key and destination key should be different")
+	errTSRuleExists         = errors.New("ERR TSDB: compaction rule already exists")
+	errTSDestHasSrc         = errors.New("ERR TSDB: the destination key already has a src rule")
+	errTSDestHasDest        = errors.New("ERR TSDB: the destination key already has a dst rule")
+	errTSSrcHasSrc          = errors.New("ERR TSDB: the source key already has a source rule")
+
+	errInvalidVector     = errors.New("ERR invalid vector specification")
+	errInvalidEF         = errors.New("ERR invalid EF")
+	errInvalidM          = errors.New("ERR invalid M")
+	errInvalidMetric     = errors.New("ERR invalid METRIC, expected COSINE or L2")
+	errQuantMismatch     = errors.New("ERR asked quantization mismatch with existing vector set")
+	errMetricMismatch    = errors.New("ERR asked metric mismatch with existing vector set")
+	errElementNotFound   = errors.New("ERR element not found in set")
+	errVectorKeyNotExist = errors.New("ERR key does not exist")
+
+	errCMSKeyExists   = errors.New("ERR CMS: key already exists")
+	errCMSKeyNotExist = errors.New("ERR CMS: key does not exist")
+	errCMSBadWidth    = errors.New("ERR CMS: invalid width")
+	errCMSBadDepth    = errors.New("ERR CMS: invalid depth")
+	errCMSTooLarge    = errors.New("ERR CMS: width/depth is too large")
+	errCMSBadNumber   = errors.New("ERR CMS: Cannot parse number")
+	errCMSBadNumKeys  = errors.New("ERR CMS: invalid numkeys")
+	errCMSBadWeight   = errors.New("ERR CMS: invalid weight value")
+	errCMSDimMismatch = errors.New("ERR CMS: width/depth is not equal")
+
+	errTopKKeyExists   = errors.New("ERR TopK: key already exists")
+	errTopKKeyNotExist = errors.New("ERR TopK: key does not exist")
+	errTopKBadK        = errors.New("ERR TopK: invalid k")
+	errTopKBadWidth    = errors.New("ERR TopK: invalid width")
+	errTopKBadDepth    = errors.New("ERR TopK: invalid depth")
+	errTopKTooLarge    = errors.New("ERR TopK: width/depth is too large")
+	errTopKBadDecay    = errors.New("ERR TopK: invalid decay value. must be '<= 1' & '> 0'")
+
+	errTDigestKeyExists        = errors.New("ERR T-Digest: key already exists")
+	errTDigestKeyNotExist      = errors.New("ERR T-Digest: key does not exist")
+	errTDigestBadCompression   = errors.New("ERR T-Digest: error parsing compression parameter")
+	errTDigestCompressionRange = errors.New("ERR T-Digest: compression parameter needs to be a positive integer")
+	errTDigestBadValue         = errors.New("ERR T-Digest: error parsing val parameter")
+	errTDigestBadQuantile      = errors.New("ERR T-Digest: error parsing quantile")
+	errTDigestQuantileRange    = errors.New("ERR T-Digest: quantile should be in [0,1]")
+	errTDigestBadCDF           = errors.New("ERR T-Digest: error parsing cdf")
+	errTDigestBadNumKeys       = errors.New("ERR T-Digest: numkeys needs to be a positive integer")
+
+	errNestedMulti         = errors.New("ERR MULTI calls can not be nested")
+	errExecWithoutMulti    = errors.New("ERR EXEC without MULTI")
+	errDiscardWithoutMulti = errors.New("ERR DISCARD without MULTI")
+	errWatchInMulti        = errors.New("ERR WATCH inside MULTI is not allowed")
+	errExecAbort           = errors.New("EXECABORT Transaction discarded because of previous errors.")
+
+	errNegativeNumKeys       = errors.New("ERR Number of keys can't be negative")
+	errTooManyNumKeys        = errors.New("ERR Number of keys can't be greater than number of args")
+	errNoScript              = errors.New("NOSCRIPT No matching script. Please use EVAL.")
+	errNotAllowedInScript    = errors.New("ERR This command is not allowed from script")
+	errWriteInReadOnlyScript = errors.New("ERR Write commands are not allowed from read-only scripts.")
+	errScriptKilled          = errors.New("ERR Script killed by user with SCRIPT KILL...")
+	errNoScriptRunning       = errors.New("NOTBUSY No scripts in execution right now.")
+	errScriptUnkillable      = errors.New("UNKILLABLE Sorry the script already executed write commands against the dataset. You can wait the script termination.")
+	errBusyScript            = errors.New("BUSY Rotom is busy running a script. You can only call SCRIPT KILL.")
+
+	errProtocolVersion   = errors.New("ERR Protocol version is not an integer or out of range")
+	errNoProto           = errors.New("NOPROTO unsupported protocol version")
+	errInvalidClientName = errors.New("ERR Client names cannot contain spaces, newlines or special characters.")
+
+	errInvalidCommandSpecified = errors.New("ERR Invalid command specified")
+	errInvalidCommandArgs      = errors.New("ERR Invalid number of arguments specified for command")
+	errNoKeyArguments          = errors.New("ERR The command has no key arguments")
+
+	errTrackingRedirect    = errors.New("ERR The client ID you want redirect to does not exist")
+	errTrackingPrefix      = errors.New("ERR PREFIX option requires BCAST mode to be enabled")
+	errTrackingOptInOptOut = errors.New("ERR You can't use OPTIN and OPTOUT at the same time")
+	errTrackingBcastOpt    = errors.New("ERR OPTIN and OPTOUT are not compatible with BCAST")
+	errTrackingSwitchMode  = errors.New("ERR You can't switch BCAST, OPTIN or OPTOUT mode before disabling tracking for this client, and then re-enabling it with a different mode.")
+	errTrackingCaching     = errors.New("ERR CLIENT CACHING can be called only when the client is in tracking mode with OPTIN or OPTOUT mode enabled")
+	errTrackingCachingYes  = errors.New("ERR CLIENT CACHING YES is only valid when tracking is enabled in OPTIN mode.")
+	errTrackingCachingNo   = errors.New("ERR CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode.")
+
+	errNoSuchClient    = errors.New("ERR No such client")
+	errInvalidClientID = errors.New("ERR client-id should be greater than 0")
+
+	errInvalidBulkLength = errors.New("ERR Protocol error: invalid bulk length")
+
+	errThrottleRate  = errors.New("ERR count_per_period and period must be greater than 0")
+	errThrottleRange = errors.New("ERR max_burst, count_per_period, period or quantity is out of range")
 )
+
+func errVectorDimMismatch(got, dim int) error {
+	return fmt.Errorf("ERR Vector dimension mismatch - got %d but set has %d", got, dim)
+}
+
+func errScriptCompile(err error) error {
+	return fmt.Errorf("ERR Error compiling script (new function): %v", err)
+}
+
+func errScriptError(msg string) error {
+	return fmt.Errorf("ERR Error running script: %s", msg)
+}
+
+func errHelloSyntax(option string) error {
+	return fmt.Errorf("ERR Syntax error in HELLO option '%s'", option)
+}
+
+func errWrongArity(name string) error {
+	return fmt.Errorf("%w for '%s' command", errWrongArguments, name)
+}
+
+func errUnknownSubcommand(sub, name string) error {
+	return fmt.Errorf("ERR unknown subcommand '%s' for '%s' command", sub, name)
+}
+
+func errUnknownClientType(typ string) error {
+	return fmt.Errorf("ERR Unknown client type '%s'", typ)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/resp"
+)
+
+// infoSection is a section of INFO reply.
+type infoSection struct {
+	name   string
+	fields func() []infoField
+}
+
+type infoField struct {
+	name  string
+	value any
+}
+
+var infoSections = []infoSection{
+	{"server", infoServer},
+	{"clients", infoClients},
+	{"stats", infoStats},
+}
+
+func infoServer() []infoField {
+	return []infoField{
+		{"multiplexing_api", server.aeLoop.PollerName()},
+		{"process_id", os.Getpid()},
+		{"tcp_port", configGetPort()},
+	}
+}
+
+func infoClients() []infoField {
+	var blocked, pubsub int
+	for _, client := range server.clients {
+		if client.blocked != nil {
+			blocked++
+		}
+		if client.subscribed() {
+			pubsub++
+		}
+	}
+	return []infoField{
+		{"connected_clients", len(server.clients)},
+		{"blocked_clients", blocked},
+		{"tracking_clients", len(server.trackingClients)},
+		{"pubsub_clients", pubsub},
+	}
+}
+
+func infoStats() []infoField {
+	return []infoField{
+		{"total_connections_received", server.statNumConnections},
+		{"client_query_buffer_limit_disconnections", server.statQueryBufLimitDisconnections},
+		{"client_output_buffer_limit_disconnections", server.statOutputBufLimitDisconnections},
+		{"io_threaded_reads_processed", server.statIOReadsProcessed},
+		{"io_threaded_writes_processed", server.statIOWritesProcessed},
+		{"batched_writes_processed", server.statBatchedWritesProcessed},
+	}
+}
+
+// INFO [section [section ...]]
+func infoCommand(writer *resp.Writer, args []redcon.RESP) {
+	all := len(args) == 0
+	selected := make(map[string]bool, len(args))
+	for _, arg := range args {
+		switch name := strings.ToLower(arg.String()); name {
+		case "all", "default", "everything":
+			all = true
+		default:
+			selected[name] = true
+		}
+	}
+	var sb strings.Builder
+	for _, section := range infoSections {
+		if !all && !selected[section.name] {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\r\n")
+		}
+		fmt.Fprintf(&sb, "# %s\r\n", strings.ToUpper(section.name[:1])+section.name[1:])
+		for _, field := range section.fields() {
+			fmt.Fprintf(&sb, "%s:%v\r\n", field.name, field.value)
+		}
+	}
+	writer.WriteVerbatim("txt", sb.String())
+}
@@ -0,0 +1,223 @@
+package bloom
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+const (
+	DefaultErrorRate = 0.01
+	DefaultCapacity  = 100
+	DefaultExpansion = 2
+
+	// limits of parameters like RedisBloom, MaxBits limits a sub filter to 512MB.
+	MaxCapacity  = 1 << 30
+	MaxExpansion = 32768
+	MaxBits      = 1 << 32
+
+	// tighteningRatio is the error rate ratio of each sub filter against the previous
+	// one, and the first one is tightened too, so that the sum of error rates of
+	// all sub filters stays under the given one.
+	tighteningRatio = 0.5
+)
+
+var (
+	_ iface.Encoder = (*Bloom)(nil)
+
+	ErrFull       = errors.New("ERR non scaling filter is full")
+	ErrScaleLimit = errors.New("ERR filter cannot scale, the new sub filter is too large")
+)
+
+// filter is a single bloom filter with fixed capacity.
+type filter struct {
+	bits     []uint64
+	numBits  uint64
+	hashes   uint64
+	capacity uint64
+	count    uint64
+}
+
+// filterBits returns the bits and bits per entry of filter, ok is false if bits
+// exceed MaxBits.
+func filterBits(capacity uint64, errorRate float64) (numBits uint64, bpe float64, ok bool) {
+	// bits per entry: -ln(p) / ln(2)^2
+	bpe = -math.Log(errorRate) / (math.Ln2 * math.Ln2)
+	n := math.Ceil(bpe * float64(capacity))
+	if !(n <= MaxBits) {
+		return 0, 0, false
+	}
+	return max(uint64(n), 64), bpe, true
+}
+
+// newFilter creates filter, capacity and errorRate must be checked by filterBits.
+func newFilter(capacity uint64, errorRate float64) *filter {
+	numBits, bpe, _ := filterBits(capacity, errorRate)
+	return &filter{
+		bits:     make([]uint64, (numBits+63)/64),
+		numBits:  numBits,
+		hashes:   uint64(math.Ceil(math.Ln2 * bpe)),
+		capacity: capacity,
+	}
+}
+
+// locations uses double hashing to generate the bit positions of hash.
+func (f *filter) locations(hash uint64, fn func(pos uint64) bool) bool {
+	h1, h2 := hash, hash>>33|hash<<31|1
+	for i := range f.hashes {
+		if !fn((h1 + i*h2) % f.numBits) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *filter) test(hash uint64) bool {
+	return f.locations(hash, func(pos uint64) bool {
+		return f.bits[pos/64]&(1<<(pos%64)) != 0
+	})
+}
+
+func (f *filter) add(hash uint64) {
+	f.locations(hash, func(pos uint64) bool {
+		f.bits[pos/64] |= 1 << (pos % 64)
+		return true
+	})
+	f.count++
+}
+
+// Bloom is a scalable bloom filter, it stacks a new sub filter with larger
+// capacity and tighter error rate when the last one is full.
+type Bloom struct {
+	filters   []*filter
+	errorRate float64
+	expansion uint64 // 0 means non scaling
+}
+
+// ValidParams reports whether the bloom filter of capacity and errorRate can be
+// created, errorRate must be in (0, 1).
+func ValidParams(capacity uint64, errorRate float64) bool {
+	if capacity == 0 || capacity > MaxCapacity {
+		return false
+	}
+	_, _, ok := filterBits(capacity, errorRate*tighteningRatio)
+	return ok
+}
+
+// New creates a bloom filter with initial capacity and error rate, expansion is
+// the capacity ratio of each new sub filter, 0 means the filter is not scaling.
+// Parameters must be checked by ValidParams.
+func New(capacity uint64, errorRate float64, expansion uint64) *Bloom {
+	return &Bloom{
+		filters:   []*filter{newFilter(capacity, errorRate*tighteningRatio)},
+		errorRate: errorRate,
+		expansion: expansion,
+	}
+}
+
+// Add adds item to filter, returns false if item may already exist.
+func (b *Bloom) Add(item string) (bool, error) {
+	hash := xxhash.Sum64String(item)
+	if b.exist(hash) {
+		return false, nil
+	}
+	last := b.filters[len(b.filters)-1]
+	if last.count >= last.capacity {
+		if b.expansion == 0 {
+			return false, ErrFull
+		}
+		errorRate := b.errorRate * math.Pow(tighteningRatio, float64(len(b.filters)+1))
+		hi, capacity := bits.Mul64(last.capacity, b.expansion)
+		if hi != 0 {
+			return false, ErrScaleLimit
+		}
+		if _, _, ok := filterBits(capacity, errorRate); !ok {
+			return false, ErrScaleLimit
+		}
+		last = newFilter(capacity, errorRate)
+		b.filters = append(b.filters, last)
+	}
+	last.add(hash)
+	return true, nil
+}
+
+// Exist returns false if item definitely not exist, true if item may exist.
+func (b *Bloom) Exist(item string) bool {
+	return b.exist(xxhash.Sum64String(item))
+}
+
+func (b *Bloom) exist(hash uint64) bool {
+	for i := len(b.filters) - 1; i >= 0; i-- {
+		if b.filters[i].test(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// Capacity returns the total capacity of all sub filters.
+func (b *Bloom) Capacity() (n uint64) {
+	for _, f := range b.filters {
+		n += f.capacity
+	}
+	return
+}
+
+// Len returns the number of items added.
+func (b *Bloom) Len() (n uint64) {
+	for _, f := range b.filters {
+		n += f.count
+	}
+	return
+}
+
+// Size returns the memory used by bits in bytes.
+func (b *Bloom) Size() (n uint64) {
+	for _, f := range b.filters {
+		n += uint64(len(f.bits)) * 8
+	}
+	return
+}
+
+func (b *Bloom) NumFilters() int { return len(b.filters) }
+
+func (b *Bloom) Expansion() uint64 { return b.expansion }
+
+func (b *Bloom) ReadFrom(rd *iface.Reader) {
+	b.errorRate = math.Float64frombits(rd.ReadUint64())
+	b.expansion = rd.ReadUint64()
+	n := rd.ReadUint64()
+	b.filters = make([]*filter, 0, n)
+	for range n {
+		f := &filter{
+			numBits:  rd.ReadUint64(),
+			hashes:   rd.ReadUint64(),
+			capacity: rd.ReadUint64(),
+			count:    rd.ReadUint64(),
+		}
+		f.bits = make([]uint64, (f.numBits+63)/64)
+		for i := range f.bits {
+			f.bits[i] = rd.ReadUint64()
+		}
+		b.filters = append(b.filters, f)
+	}
+}
+
+// WriteTo encode bloom filter to [errorRate, expansion, filters...].
+func (b *Bloom) WriteTo(w *iface.Writer) {
+	w.WriteUint64(math.Float64bits(b.errorRate))
+	w.WriteUint64(b.expansion)
+	w.WriteUint64(uint64(len(b.filters)))
+	for _, f := range b.filters {
+		w.WriteUint64(f.numBits)
+		w.WriteUint64(f.hashes)
+		w.WriteUint64(f.capacity)
+		w.WriteUint64(f.count)
+		for _, word := range f.bits {
+			w.WriteUint64(word)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+func TestBloom(t *testing.T) {
+	ast := assert.New(t)
+	bf := New(100, 0.01, 2)
+
+	for i := range 1000 {
+		added, err := bf.Add(strconv.Itoa(i))
+		ast.Nil(err)
+		ast.True(added || i > 0) // false positive is possible
+	}
+	for i := range 1000 {
+		ast.True(bf.Exist(strconv.Itoa(i)))
+	}
+	ast.Greater(bf.NumFilters(), 1)
+	ast.GreaterOrEqual(bf.Capacity(), bf.Len())
+
+	// false positive rate
+	var fp int
+	for i := 1000; i < 11000; i++ {
+		if bf.Exist(strconv.Itoa(i)) {
+			fp++
+		}
+	}
+	ast.Less(fp, 10000/100)
+
+	// encode
+	w := iface.NewWriter(nil)
+	bf.WriteTo(w)
+	bf2 := new(Bloom)
+	bf2.ReadFrom(iface.NewReaderFrom(w))
+	ast.Equal(bf, bf2)
+}
+
+func TestBloomNonScaling(t *testing.T) {
+	ast := assert.New(t)
+	bf := New(10, 0.01, 0)
+
+	var err error
+	for i := 0; err == nil; i++ {
+		_, err = bf.Add(strconv.Itoa(i))
+	}
+	ast.Equal(err, ErrFull)
+	ast.Equal(bf.NumFilters(), 1)
+	ast.Equal(bf.Len(), uint64(10))
+}
+
+func TestBloomScaleLimit(t *testing.T) {
+	ast := assert.New(t)
+	ast.True(ValidParams(1<<28, 0.01))
+	ast.True(ValidParams(MaxCapacity, 0.5))
+	ast.False(ValidParams(0, 0.01))
+	ast.False(ValidParams(MaxCapacity+1, 0.5))
+	// bits exceed MaxBits
+	ast.False(ValidParams(MaxCapacity, 0.01))
+	ast.False(ValidParams(1<<25, 1e-300))
+
+	// capacity of the third sub filter is 1<<30, which exceeds MaxBits.
+	bf := New(1, 0.01, MaxExpansion)
+	var err error
+	for i := 0; err == nil; i++ {
+		_, err = bf.Add(strconv.Itoa(i))
+	}
+	ast.Equal(err, ErrScaleLimit)
+	ast.Equal(bf.NumFilters(), 2)
+
+	// capacity overflows.
+	bf = New(1, 0.01, MaxExpansion)
+	bf.filters[0].capacity = 1 << 60
+	bf.filters[0].count = 1 << 60
+	_, err = bf.Add("a")
+	ast.Equal(err, ErrScaleLimit)
+}
+
+func FuzzTestBloom(f *testing.F) {
+	bf := New(100, 0.01, 2)
+
+	f.Fuzz(func(t *testing.T, item string) {
+		_, err := bf.Add(item)
+		assert.Nil(t, err)
+		assert.True(t, bf.Exist(item))
+	})
+}
@@ -0,0 +1,123 @@
+package cms
+
+import (
+	"math"
+	"math/bits"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+var (
+	_ iface.Encoder = (*CMS)(nil)
+)
+
+// CMS is a count-min sketch, each item is counted in one counter of every row and
+// the minimum of them is the estimated count.
+/*
+	          +-------------- width --------------+
+	row0      | c0 | c1 | c2 | ...      | c(w-1)  |
+	row1      | c0 | c1 | c2 | ...      | c(w-1)  |
+	...
+	row(d-1)  | c0 | c1 | c2 | ...      | c(w-1)  |
+	          +-----------------------------------+
+*/
+type CMS struct {
+	width    uint64
+	depth    uint64
+	count    uint64
+	counters []uint32
+}
+
+// MaxCounters limits the counters of sketch, which is 256MB in memory.
+const MaxCounters = 64 << 20
+
+// ValidDims reports whether a sketch of width and depth can be created, that
+// both are positive and the number of counters does not exceed MaxCounters.
+func ValidDims(width, depth uint64) bool {
+	hi, n := bits.Mul64(width, depth)
+	return width > 0 && depth > 0 && hi == 0 && n <= MaxCounters
+}
+
+// New creates a count-min sketch with width counters in each of depth rows,
+// width and depth must be checked by ValidDims.
+func New(width, depth uint64) *CMS {
+	return &CMS{
+		width:    width,
+		depth:    depth,
+		counters: make([]uint32, width*depth),
+	}
+}
+
+func (c *CMS) Width() uint64 { return c.width }
+
+func (c *CMS) Depth() uint64 { return c.depth }
+
+// Count returns the sum of all increments.
+func (c *CMS) Count() uint64 { return c.count }
+
+// locations calls fn with the counter index of item in each row, using double
+// hashing over the 64-bit hash.
+func (c *CMS) locations(item string, fn func(i uint64)) {
+	hash := xxhash.Sum64String(item)
+	h1, h2 := hash&math.MaxUint32, hash>>32
+	for row := range c.depth {
+		fn(row*c.width + (h1+row*h2)%c.width)
+	}
+}
+
+// IncrBy increases the count of item by n and returns the new estimated count,
+// counters are saturated at math.MaxUint32.
+func (c *CMS) IncrBy(item string, n uint32) uint32 {
+	res := uint32(math.MaxUint32)
+	c.locations(item, func(i uint64) {
+		c.counters[i] = uint32(min(uint64(c.counters[i])+uint64(n), math.MaxUint32))
+		res = min(res, c.counters[i])
+	})
+	c.count += uint64(n)
+	return res
+}
+
+// Query returns the estimated count of item.
+func (c *CMS) Query(item string) uint32 {
+	res := uint32(math.MaxUint32)
+	c.locations(item, func(i uint64) {
+		res = min(res, c.counters[i])
+	})
+	return res
+}
+
+// Merge sets c to the weighted sum of sources, all of which must have the same
+// width and depth as c.
+func (c *CMS) Merge(sources []*CMS, weights []int64) {
+	counters := make([]uint32, len(c.counters))
+	var count uint64
+	for n, src := range sources {
+		for i, v := range src.counters {
+			sum := int64(counters[i]) + int64(v)*weights[n]
+			counters[i] = uint32(min(max(sum, 0), math.MaxUint32))
+		}
+		count = uint64(max(int64(count)+int64(src.count)*weights[n], 0))
+	}
+	c.counters, c.count = counters, count
+}
+
+func (c *CMS) ReadFrom(rd *iface.Reader) {
+	c.width = rd.ReadUint64()
+	c.depth = rd.ReadUint64()
+	c.count = rd.ReadUint64()
+	c.counters = make([]uint32, c.width*c.depth)
+	for i := range c.counters {
+		c.counters[i] = rd.ReadUint32()
+	}
+}
+
+// WriteTo encode count-min sketch to [width, depth, count, counters...].
+func (c *CMS) WriteTo(w *iface.Writer) {
+	w.WriteUint64(c.width)
+	w.WriteUint64(c.depth)
+	w.WriteUint64(c.count)
+	for _, v := range c.counters {
+		w.WriteUint32(v)
+	}
+}
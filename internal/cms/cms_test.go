@@ -0,0 +1,56 @@
+package cms
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+func TestCMS(t *testing.T) {
+	ast := assert.New(t)
+	c := New(2000, 5)
+
+	for i := range 1000 {
+		ast.GreaterOrEqual(c.IncrBy(strconv.Itoa(i), uint32(i%10+1)), uint32(i%10+1))
+	}
+	ast.Equal(uint64(5500), c.Count())
+	var overestimated int
+	for i := range 1000 {
+		n := c.Query(strconv.Itoa(i))
+		ast.GreaterOrEqual(n, uint32(i%10+1))
+		if n > uint32(i%10+1) {
+			overestimated++
+		}
+	}
+	ast.Less(overestimated, 50)
+	ast.Equal(uint32(0), c.Query("none"))
+
+	// merge
+	c2 := New(2000, 5)
+	c2.IncrBy("0", 10)
+	dest := New(2000, 5)
+	dest.Merge([]*CMS{c, c2}, []int64{1, 2})
+	ast.Equal(uint32(21), dest.Query("0"))
+	ast.Equal(uint64(5520), dest.Count())
+
+	// encode
+	w := iface.NewWriter(nil)
+	c.WriteTo(w)
+	c3 := new(CMS)
+	c3.ReadFrom(iface.NewReaderFrom(w))
+	ast.Equal(c, c3)
+}
+
+func TestValidDims(t *testing.T) {
+	ast := assert.New(t)
+	ast.True(ValidDims(2000, 5))
+	ast.True(ValidDims(MaxCounters, 1))
+	ast.False(ValidDims(0, 5))
+	ast.False(ValidDims(2000, 0))
+	ast.False(ValidDims(MaxCounters+1, 1))
+	ast.False(ValidDims(1<<32, 1<<32))
+	ast.False(ValidDims(math.MaxUint64, 2))
+}
@@ -0,0 +1,263 @@
+package cuckoo
+
+import (
+	"errors"
+	"math/bits"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+const (
+	DefaultCapacity      = 1024
+	DefaultBucketSize    = 2
+	DefaultMaxIterations = 20
+	DefaultExpansion     = 1
+)
+
+var (
+	_ iface.Encoder = (*Cuckoo)(nil)
+
+	ErrFull = errors.New("ERR Filter is full")
+)
+
+// filter is a single cuckoo filter, buckets stores 8-bit fingerprints, and 0
+// means an empty slot.
+/*
+	+---------- bucket0 ----------+---------- bucket1 ----------+
+	| fp0 | fp1 | ... | fp(size-1) | fp0 | fp1 | ... | fp(size-1) | ...
+	+-----------------------------+-----------------------------+
+*/
+type filter struct {
+	buckets    []uint8
+	numBuckets uint64 // power of 2
+}
+
+func newFilter(numBuckets, bucketSize uint64) *filter {
+	return &filter{
+		buckets:    make([]uint8, numBuckets*bucketSize),
+		numBuckets: numBuckets,
+	}
+}
+
+func (f *filter) bucket(i, bucketSize uint64) []uint8 {
+	return f.buckets[i*bucketSize : (i+1)*bucketSize]
+}
+
+// index returns the two candidate buckets of hash.
+func (f *filter) index(hash uint64, fp uint8) (uint64, uint64) {
+	i1 := hash & (f.numBuckets - 1)
+	return i1, f.altIndex(i1, fp)
+}
+
+// altIndex returns the other candidate bucket by xor the hash of fingerprint,
+// so that it can be computed from either bucket.
+func (f *filter) altIndex(i uint64, fp uint8) uint64 {
+	return (i ^ uint64(fp)*0x5bd1e995) & (f.numBuckets - 1)
+}
+
+// Cuckoo is a scalable cuckoo filter, it supports deletion and counting, a new
+// sub filter is stacked when inserting into the last one fails.
+type Cuckoo struct {
+	filters       []*filter
+	bucketSize    uint64
+	maxIterations uint64
+	expansion     uint64
+	count         uint64
+}
+
+// New creates a cuckoo filter with initial capacity.
+func New(capacity, bucketSize, maxIterations, expansion uint64) *Cuckoo {
+	numBuckets := max(nextPow2((capacity+bucketSize-1)/bucketSize), 1)
+	return &Cuckoo{
+		filters:       []*filter{newFilter(numBuckets, bucketSize)},
+		bucketSize:    bucketSize,
+		maxIterations: maxIterations,
+		expansion:     expansion,
+	}
+}
+
+func nextPow2(n uint64) uint64 {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << (64 - bits.LeadingZeros64(n-1))
+}
+
+func fingerprint(item string) (uint64, uint8) {
+	hash := xxhash.Sum64String(item)
+	fp := uint8(hash >> 56)
+	if fp == 0 {
+		fp = 1
+	}
+	return hash, fp
+}
+
+// Add inserts item to filter, the same item can be added multiple times.
+func (c *Cuckoo) Add(item string) error {
+	hash, fp := fingerprint(item)
+	for _, f := range c.filters {
+		if c.insertEmpty(f, hash, fp) {
+			c.count++
+			return nil
+		}
+	}
+	last := c.filters[len(c.filters)-1]
+	if c.kickInsert(last, hash, fp) {
+		c.count++
+		return nil
+	}
+	if c.expansion == 0 {
+		return ErrFull
+	}
+	f := newFilter(last.numBuckets*nextPow2(c.expansion), c.bucketSize)
+	c.filters = append(c.filters, f)
+	c.insertEmpty(f, hash, fp)
+	c.count++
+	return nil
+}
+
+// AddNX inserts item only if it not exist, returns true if inserted.
+func (c *Cuckoo) AddNX(item string) (bool, error) {
+	if c.Exist(item) {
+		return false, nil
+	}
+	return true, c.Add(item)
+}
+
+func (c *Cuckoo) insertEmpty(f *filter, hash uint64, fp uint8) bool {
+	i1, i2 := f.index(hash, fp)
+	for _, i := range []uint64{i1, i2} {
+		b := f.bucket(i, c.bucketSize)
+		for j := range b {
+			if b[j] == 0 {
+				b[j] = fp
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// kickInsert relocates existing fingerprints to make room for fp. Victims are
+// chosen deterministically so that replaying aof rebuilds the same filter.
+func (c *Cuckoo) kickInsert(f *filter, hash uint64, fp uint8) bool {
+	type kicked struct {
+		i, j uint64
+		fp   uint8
+	}
+	path := make([]kicked, 0, c.maxIterations)
+
+	i, _ := f.index(hash, fp)
+	for n := range c.maxIterations {
+		j := n % c.bucketSize
+		b := f.bucket(i, c.bucketSize)
+		path = append(path, kicked{i, j, b[j]})
+		fp, b[j] = b[j], fp
+
+		i = f.altIndex(i, fp)
+		b = f.bucket(i, c.bucketSize)
+		for j := range b {
+			if b[j] == 0 {
+				b[j] = fp
+				return true
+			}
+		}
+	}
+	// rollback when failed.
+	for k := len(path) - 1; k >= 0; k-- {
+		f.bucket(path[k].i, c.bucketSize)[path[k].j] = path[k].fp
+	}
+	return false
+}
+
+// Exist returns false if item definitely not exist, true if item may exist.
+func (c *Cuckoo) Exist(item string) bool {
+	hash, fp := fingerprint(item)
+	for i := len(c.filters) - 1; i >= 0; i-- {
+		f := c.filters[i]
+		i1, i2 := f.index(hash, fp)
+		for _, i := range []uint64{i1, i2} {
+			for _, v := range f.bucket(i, c.bucketSize) {
+				if v == fp {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Count returns the approximate number of times item was added.
+func (c *Cuckoo) Count(item string) (n int) {
+	hash, fp := fingerprint(item)
+	for _, f := range c.filters {
+		i1, i2 := f.index(hash, fp)
+		for _, v := range f.bucket(i1, c.bucketSize) {
+			if v == fp {
+				n++
+			}
+		}
+		if i1 == i2 {
+			continue
+		}
+		for _, v := range f.bucket(i2, c.bucketSize) {
+			if v == fp {
+				n++
+			}
+		}
+	}
+	return
+}
+
+// Delete removes one occurrence of item, returns true if found.
+func (c *Cuckoo) Delete(item string) bool {
+	hash, fp := fingerprint(item)
+	for k := len(c.filters) - 1; k >= 0; k-- {
+		f := c.filters[k]
+		i1, i2 := f.index(hash, fp)
+		for _, i := range []uint64{i1, i2} {
+			b := f.bucket(i, c.bucketSize)
+			for j := range b {
+				if b[j] == fp {
+					b[j] = 0
+					c.count--
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Len returns the number of items in filter.
+func (c *Cuckoo) Len() uint64 { return c.count }
+
+func (c *Cuckoo) NumFilters() int { return len(c.filters) }
+
+func (c *Cuckoo) ReadFrom(rd *iface.Reader) {
+	c.bucketSize = rd.ReadUint64()
+	c.maxIterations = rd.ReadUint64()
+	c.expansion = rd.ReadUint64()
+	c.count = rd.ReadUint64()
+	n := rd.ReadUint64()
+	c.filters = make([]*filter, 0, n)
+	for range n {
+		f := &filter{numBuckets: rd.ReadUint64()}
+		f.buckets = append([]uint8(nil), rd.ReadBytes()...)
+		c.filters = append(c.filters, f)
+	}
+}
+
+// WriteTo encode cuckoo filter to [bucketSize, maxIterations, expansion, count, filters...].
+func (c *Cuckoo) WriteTo(w *iface.Writer) {
+	w.WriteUint64(c.bucketSize)
+	w.WriteUint64(c.maxIterations)
+	w.WriteUint64(c.expansion)
+	w.WriteUint64(c.count)
+	w.WriteUint64(uint64(len(c.filters)))
+	for _, f := range c.filters {
+		w.WriteUint64(f.numBuckets)
+		w.WriteBytes(f.buckets)
+	}
+}
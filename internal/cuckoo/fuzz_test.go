@@ -0,0 +1,75 @@
+package cuckoo
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+func TestCuckoo(t *testing.T) {
+	ast := assert.New(t)
+	cf := New(64, 2, 20, 1)
+
+	for i := range 1000 {
+		ast.Nil(cf.Add(strconv.Itoa(i)))
+	}
+	ast.Equal(cf.Len(), uint64(1000))
+	ast.Greater(cf.NumFilters(), 1)
+	for i := range 1000 {
+		ast.True(cf.Exist(strconv.Itoa(i)))
+	}
+
+	// encode
+	w := iface.NewWriter(nil)
+	cf.WriteTo(w)
+	cf2 := new(Cuckoo)
+	cf2.ReadFrom(iface.NewReaderFrom(w))
+	ast.Equal(cf, cf2)
+
+	// delete
+	for i := range 1000 {
+		ast.True(cf.Delete(strconv.Itoa(i)))
+	}
+	ast.Equal(cf.Len(), uint64(0))
+}
+
+func TestCuckooCount(t *testing.T) {
+	ast := assert.New(t)
+	cf := New(1024, 2, 20, 1)
+
+	for range 3 {
+		ast.Nil(cf.Add("a"))
+	}
+	ast.Equal(cf.Count("a"), 3)
+
+	added, err := cf.AddNX("a")
+	ast.Nil(err)
+	ast.False(added)
+
+	ast.True(cf.Delete("a"))
+	ast.Equal(cf.Count("a"), 2)
+}
+
+func TestCuckooFull(t *testing.T) {
+	ast := assert.New(t)
+	cf := New(8, 2, 20, 0)
+
+	var err error
+	for i := 0; err == nil; i++ {
+		err = cf.Add(strconv.Itoa(i))
+	}
+	ast.Equal(err, ErrFull)
+	ast.Equal(cf.NumFilters(), 1)
+}
+
+func FuzzTestCuckoo(f *testing.F) {
+	cf := New(1024, 2, 20, 1)
+
+	f.Fuzz(func(t *testing.T, item string) {
+		assert.Nil(t, cf.Add(item))
+		assert.True(t, cf.Exist(item))
+		assert.True(t, cf.Delete(item))
+	})
+}
@@ -103,6 +103,16 @@ func FuzzTestSet(f *testing.F) {
 			ast.ElementsMatch(keys1, keys2)
 			ast.ElementsMatch(keys1, keys3)
 
+			// Range stops at the first one
+			for _, s := range []interface{ Range(func(string) bool) }{hashset, zipset} {
+				var count int
+				s.Range(func(string) bool {
+					count++
+					return true
+				})
+				ast.Equal(min(n, 1), count)
+			}
+
 		case 9: // Encode
 			{
 				w := iface.NewWriter(nil)
@@ -28,12 +28,17 @@ func (s Set) Remove(key string) bool {
 }
 
 func (s Set) Scan(fn func(string)) {
-	s.Set.Each(func(s string) bool {
-		fn(s)
+	s.Range(func(key string) bool {
+		fn(key)
 		return false
 	})
 }
 
+// Range iterates members like Scan until fn returns true.
+func (s Set) Range(fn func(key string) (stop bool)) {
+	s.Set.Each(fn)
+}
+
 func (s Set) Exist(key string) bool { return s.Set.ContainsOne(key) }
 
 func (s Set) Len() int { return s.Cardinality() }
@@ -49,10 +49,19 @@ func (zs *ZipSet) Remove(key string) bool {
 }
 
 func (zs *ZipSet) Scan(fn func(string)) {
+	zs.Range(func(key string) bool {
+		fn(key)
+		return false
+	})
+}
+
+// Range iterates members like Scan until fn returns true.
+func (zs *ZipSet) Range(fn func(key string) (stop bool)) {
 	it := zs.data.Iterator().SeekLast()
 	for !it.IsFirst() {
-		entry := it.Prev()
-		fn(b2s(entry))
+		if fn(b2s(it.Prev())) {
+			return
+		}
 	}
 }
 
@@ -21,6 +21,7 @@ type SetI interface {
 	Remove(key string) bool
 	Pop() (key string, ok bool)
 	Scan(fn func(key string))
+	Range(fn func(key string) (stop bool))
 	Len() int
 }
 
@@ -37,4 +38,5 @@ type ZSetI interface {
 	PopMin() (key string, score float64)
 	Rank(key string) int
 	Scan(fn func(key string, score float64))
+	Range(fn func(key string, score float64) (stop bool))
 }
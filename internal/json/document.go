@@ -0,0 +1,128 @@
+package json
+
+import (
+	"math"
+	"slices"
+
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+var _ iface.Encoder = (*Value)(nil)
+
+// SetPath sets value at path, nx means only set if path not exist, and xx means
+// only set if path exists. A new member is added when the parent of path is an
+// object without the key. It returns false if nothing set.
+func (v *Value) SetPath(path *Path, value *Value, nx, xx bool) bool {
+	matches := path.Select(v)
+	if len(matches) > 0 {
+		if nx {
+			return false
+		}
+		for _, m := range matches {
+			*m.Value = *value.Clone()
+		}
+		return true
+	}
+	if xx {
+		return false
+	}
+	parent, key, ok := path.parentPath()
+	if !ok {
+		return false
+	}
+	set := false
+	for _, m := range parent.Select(v) {
+		if m.Value.kind == KindObject {
+			m.Value.Set(key, value.Clone())
+			set = true
+		}
+	}
+	return set
+}
+
+// DeletePath removes the values matched by path from their parents, and returns
+// the number of removed values. The root can not be removed here.
+func (v *Value) DeletePath(path *Path) (count int) {
+	for _, m := range path.Select(v) {
+		p := m.Parent
+		if p == nil {
+			continue
+		}
+		// remove by pointer since indexes of array may be shifted by previous removals.
+		switch p.kind {
+		case KindObject:
+			i := slices.IndexFunc(p.object, func(mb member) bool { return mb.value == m.Value })
+			if i >= 0 {
+				p.object = slices.Delete(p.object, i, i+1)
+				count++
+			}
+		case KindArray:
+			i := slices.Index(p.array, m.Value)
+			if i >= 0 {
+				p.array = slices.Delete(p.array, i, i+1)
+				count++
+			}
+		}
+	}
+	return
+}
+
+func (v *Value) ReadFrom(rd *iface.Reader) {
+	*v = Value{kind: Kind(rd.ReadUint8())}
+	switch v.kind {
+	case KindBool:
+		v.boolean = rd.ReadUint8() == 1
+	case KindInteger:
+		v.integer = rd.ReadVarint()
+	case KindNumber:
+		v.number = math.Float64frombits(rd.ReadUint64())
+	case KindString:
+		v.str = rd.ReadString()
+	case KindArray:
+		n := rd.ReadVarint()
+		v.array = make([]*Value, n)
+		for i := range v.array {
+			v.array[i] = new(Value)
+			v.array[i].ReadFrom(rd)
+		}
+	case KindObject:
+		n := rd.ReadVarint()
+		v.object = make([]member, n)
+		for i := range v.object {
+			v.object[i].key = rd.ReadString()
+			v.object[i].value = new(Value)
+			v.object[i].value.ReadFrom(rd)
+		}
+	}
+}
+
+// WriteTo encode value to [kind, payload], payload of array is [len, elements...],
+// and payload of object is [len, key1, value1, key2, value2...].
+func (v *Value) WriteTo(w *iface.Writer) {
+	w.WriteUint8(uint8(v.kind))
+	switch v.kind {
+	case KindBool:
+		if v.boolean {
+			w.WriteUint8(1)
+		} else {
+			w.WriteUint8(0)
+		}
+	case KindInteger:
+		w.WriteVarint(int(v.integer))
+	case KindNumber:
+		w.WriteUint64(math.Float64bits(v.number))
+	case KindString:
+		w.WriteString(v.str)
+	case KindArray:
+		w.WriteVarint(len(v.array))
+		for _, e := range v.array {
+			e.WriteTo(w)
+		}
+	case KindObject:
+		w.WriteVarint(len(v.object))
+		for _, m := range v.object {
+			w.WriteString(m.key)
+			m.value.WriteTo(w)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package json
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+const testDoc = `{"store":{"book":[{"title":"a","price":8.95},{"title":"b","price":12,"isbn":"0-553"}],"bicycle":{"color":"red","price":19.95}},"tags":["x","y","z"],"ok":true,"none":null}`
+
+func TestParse(t *testing.T) {
+	ast := assert.New(t)
+
+	v, err := Parse(testDoc)
+	ast.Nil(err)
+	ast.Equal(v.String(), testDoc)
+
+	for _, text := range []string{
+		`1`, `-1.5`, `1.0`, `1e+30`, `""`, `"\"\\\n\u0001"`, `[]`, `{}`, `[1,[2,[3]]]`,
+	} {
+		v, err := Parse(text)
+		ast.Nil(err, text)
+		ast.Equal(v.String(), text)
+	}
+
+	v, _ = Parse(` "é😀" `)
+	ast.Equal(v.String(), `"é😀"`)
+
+	for _, text := range []string{
+		``, `{`, `[1,]`, `{"a"}`, `{"a":1,}`, `tru`, `"abc`, `1 2`, `01x`, `{1:2}`,
+	} {
+		_, err := Parse(text)
+		ast.NotNil(err, text)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	v, _ := Parse(`{"a":[1,2],"b":{}}`)
+	out := v.AppendFormat(nil, &Format{Indent: "  ", Newline: "\n", Space: " "})
+	assert.Equal(t, string(out), "{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": {}\n}")
+}
+
+func TestPath(t *testing.T) {
+	ast := assert.New(t)
+	v, _ := Parse(testDoc)
+
+	selectString := func(path string) string {
+		p, err := ParsePath(path)
+		ast.Nil(err, path)
+		arr := NewArray()
+		for _, m := range p.Select(v) {
+			arr.Append(m.Value)
+		}
+		return arr.String()
+	}
+	ast.Equal(selectString("$"), "["+testDoc+"]")
+	ast.Equal(selectString("$.store.book[0].title"), `["a"]`)
+	ast.Equal(selectString("$['store']['bicycle'].color"), `["red"]`)
+	ast.Equal(selectString("$.store.book[*].title"), `["a","b"]`)
+	ast.Equal(selectString("$.store.book[-1].title"), `["b"]`)
+	ast.Equal(selectString("$..price"), `[8.95,12,19.95]`)
+	ast.Equal(selectString("$..book[0,1].title"), `["a","b"]`)
+	ast.Equal(selectString("$.tags[1:]"), `["y","z"]`)
+	ast.Equal(selectString("$.tags[::2]"), `["x","z"]`)
+	ast.Equal(selectString("$.tags[:-1]"), `["x","y"]`)
+	ast.Equal(selectString("$.store.*.color"), `["red"]`)
+	ast.Equal(selectString("$.not.exist"), `[]`)
+	ast.Equal(selectString(".store.bicycle.color"), `["red"]`)
+	ast.Equal(selectString("store.bicycle"), `[{"color":"red","price":19.95}]`)
+	ast.Equal(selectString("."), "["+testDoc+"]")
+
+	for _, path := range []string{"$.", "$[", "$[abc]", "$['a'", "$[1:2:0]", "$a"} {
+		_, err := ParsePath(path)
+		ast.NotNil(err, path)
+	}
+}
+
+func TestSetDelete(t *testing.T) {
+	ast := assert.New(t)
+	v, _ := Parse(`{"a":{"b":1},"c":[1,2,3]}`)
+	path := func(s string) *Path {
+		p, _ := ParsePath(s)
+		return p
+	}
+
+	ast.True(v.SetPath(path("$.a.b"), NewString("x"), false, false))
+	ast.True(v.SetPath(path("$.a.new"), NewBool(false), false, false))
+	ast.False(v.SetPath(path("$.a.new"), NewNull(), true, false))
+	ast.False(v.SetPath(path("$.a.x"), NewNull(), false, true))
+	ast.False(v.SetPath(path("$.no.x"), NewNull(), false, false))
+	ast.Equal(v.String(), `{"a":{"b":"x","new":false},"c":[1,2,3]}`)
+
+	ast.Equal(v.DeletePath(path("$.c[0,2]")), 2)
+	ast.Equal(v.DeletePath(path("$..new")), 1)
+	ast.Equal(v.String(), `{"a":{"b":"x"},"c":[2]}`)
+
+	n := NewInteger(1)
+	n.IncrBy(NewInteger(2))
+	ast.Equal(n.String(), "3")
+	n.IncrBy(NewNumber(0.5))
+	ast.Equal(n.String(), "3.5")
+	n = NewInteger(1 << 62)
+	n.IncrBy(NewInteger(1 << 62))
+	ast.Equal(n.Kind(), KindNumber)
+}
+
+func FuzzTestJSON(f *testing.F) {
+	f.Add(testDoc)
+	f.Add(`[1,"a",{"b":null}]`)
+
+	f.Fuzz(func(t *testing.T, text string) {
+		v, err := Parse(text)
+		if err != nil {
+			return
+		}
+		// serialize and parse again.
+		v2, err := Parse(v.String())
+		assert.Nil(t, err)
+		assert.Equal(t, v.String(), v2.String())
+
+		// encode
+		w := iface.NewWriter(nil)
+		v.WriteTo(w)
+		v3 := new(Value)
+		v3.ReadFrom(iface.NewReaderFrom(w))
+		assert.Equal(t, v.String(), v3.String())
+	})
+}
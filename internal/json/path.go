@@ -0,0 +1,297 @@
+package json
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type selectorKind byte
+
+const (
+	selectChild    selectorKind = iota // .key, ['a','b']
+	selectIndex                        // [0], [1,-1]
+	selectWildcard                     // .*, [*]
+	selectSlice                        // [start:end:step]
+)
+
+type selector struct {
+	kind      selectorKind
+	recursive bool // ..
+	keys      []string
+	indexes   []int
+	// slice bounds, nil means omitted.
+	start, end *int
+	step       int
+}
+
+// Path is a compiled JSONPath, a legacy path (without the leading '$') matches
+// at most one value in commands.
+type Path struct {
+	text      string
+	selectors []selector
+	legacy    bool
+}
+
+func (p *Path) String() string { return p.text }
+
+// IsLegacy returns whether path is in legacy syntax.
+func (p *Path) IsLegacy() bool { return p.legacy }
+
+// IsRoot returns whether path refers to the root.
+func (p *Path) IsRoot() bool { return len(p.selectors) == 0 }
+
+// ParsePath compiles a JSONPath like "$.store.book[0].title", or a legacy path
+// like ".store.book[0].title".
+func ParsePath(text string) (*Path, error) {
+	p := &Path{text: text}
+	s := text
+	if strings.HasPrefix(s, "$") {
+		s = s[1:]
+	} else {
+		p.legacy = true
+		if s == "." {
+			s = ""
+		} else if s != "" && s[0] != '.' && s[0] != '[' {
+			s = "." + s
+		}
+	}
+	for len(s) > 0 {
+		var sel selector
+		var err error
+		switch {
+		case strings.HasPrefix(s, ".."):
+			sel.recursive = true
+			s = s[2:]
+			if strings.HasPrefix(s, "[") {
+				sel, s, err = parseBracket(s)
+				sel.recursive = true
+			} else {
+				s = "." + s
+				sel, s, err = parseDot(s)
+				sel.recursive = true
+			}
+		case s[0] == '.':
+			sel, s, err = parseDot(s)
+		case s[0] == '[':
+			sel, s, err = parseBracket(s)
+		default:
+			err = errInvalidPath
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ERR JSON Path error: %w '%s'", err, text)
+		}
+		p.selectors = append(p.selectors, sel)
+	}
+	return p, nil
+}
+
+var errInvalidPath = fmt.Errorf("invalid path")
+
+func parseDot(s string) (selector, string, error) {
+	s = s[1:]
+	if strings.HasPrefix(s, "*") {
+		return selector{kind: selectWildcard}, s[1:], nil
+	}
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	if end == 0 {
+		return selector{}, s, errInvalidPath
+	}
+	return selector{kind: selectChild, keys: []string{s[:end]}}, s[end:], nil
+}
+
+func parseBracket(s string) (selector, string, error) {
+	s = strings.TrimLeft(s[1:], " ")
+	if strings.HasPrefix(s, "*") {
+		s = strings.TrimLeft(s[1:], " ")
+		if !strings.HasPrefix(s, "]") {
+			return selector{}, s, errInvalidPath
+		}
+		return selector{kind: selectWildcard}, s[1:], nil
+	}
+	// quoted keys
+	if strings.HasPrefix(s, "'") || strings.HasPrefix(s, `"`) {
+		var sel = selector{kind: selectChild}
+		for {
+			quote := s[0]
+			end := strings.IndexByte(s[1:], quote)
+			if end < 0 {
+				return sel, s, errInvalidPath
+			}
+			sel.keys = append(sel.keys, s[1:end+1])
+			s = strings.TrimLeft(s[end+2:], " ")
+			if strings.HasPrefix(s, "]") {
+				return sel, s[1:], nil
+			}
+			if !strings.HasPrefix(s, ",") {
+				return sel, s, errInvalidPath
+			}
+			s = strings.TrimLeft(s[1:], " ")
+			if s == "" || (s[0] != '\'' && s[0] != '"') {
+				return sel, s, errInvalidPath
+			}
+		}
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return selector{}, s, errInvalidPath
+	}
+	content, rest := s[:end], s[end+1:]
+
+	// slice
+	if strings.Contains(content, ":") {
+		parts := strings.Split(content, ":")
+		if len(parts) > 3 {
+			return selector{}, s, errInvalidPath
+		}
+		sel := selector{kind: selectSlice, step: 1}
+		bounds := []**int{&sel.start, &sel.end}
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return sel, s, errInvalidPath
+			}
+			if i < 2 {
+				*bounds[i] = &n
+			} else if n <= 0 {
+				return sel, s, errInvalidPath
+			} else {
+				sel.step = n
+			}
+		}
+		return sel, rest, nil
+	}
+
+	// indexes
+	sel := selector{kind: selectIndex}
+	for _, part := range strings.Split(content, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return sel, s, errInvalidPath
+		}
+		sel.indexes = append(sel.indexes, n)
+	}
+	return sel, rest, nil
+}
+
+// Match is a value matched by path, with its location in the parent.
+type Match struct {
+	Value *Value
+	// Parent is the object or array holding Value, nil for the root.
+	Parent *Value
+	Key    string
+	Index  int
+}
+
+// Select returns the values matched by path.
+func (p *Path) Select(root *Value) []Match {
+	matches := []Match{{Value: root}}
+	for _, sel := range p.selectors {
+		var next []Match
+		for _, m := range matches {
+			if sel.recursive {
+				walk(m, func(m Match) {
+					next = sel.apply(next, m.Value)
+				})
+			} else {
+				next = sel.apply(next, m.Value)
+			}
+		}
+		matches = next
+	}
+	return matches
+}
+
+// walk calls fn on m and all its descendants in pre-order.
+func walk(m Match, fn func(Match)) {
+	fn(m)
+	v := m.Value
+	switch v.kind {
+	case KindArray:
+		for i, e := range v.array {
+			walk(Match{Value: e, Parent: v, Index: i}, fn)
+		}
+	case KindObject:
+		for _, mb := range v.object {
+			walk(Match{Value: mb.value, Parent: v, Key: mb.key}, fn)
+		}
+	}
+}
+
+func (sel *selector) apply(dst []Match, v *Value) []Match {
+	switch sel.kind {
+	case selectChild:
+		if v.kind == KindObject {
+			for _, key := range sel.keys {
+				if child := v.get(key); child != nil {
+					dst = append(dst, Match{Value: child, Parent: v, Key: key})
+				}
+			}
+		}
+	case selectWildcard:
+		switch v.kind {
+		case KindArray:
+			for i, e := range v.array {
+				dst = append(dst, Match{Value: e, Parent: v, Index: i})
+			}
+		case KindObject:
+			for _, mb := range v.object {
+				dst = append(dst, Match{Value: mb.value, Parent: v, Key: mb.key})
+			}
+		}
+	case selectIndex:
+		if v.kind == KindArray {
+			for _, i := range sel.indexes {
+				if i < 0 {
+					i += len(v.array)
+				}
+				if i >= 0 && i < len(v.array) {
+					dst = append(dst, Match{Value: v.array[i], Parent: v, Index: i})
+				}
+			}
+		}
+	case selectSlice:
+		if v.kind == KindArray {
+			n := len(v.array)
+			start, end := 0, n
+			if sel.start != nil {
+				start = normalizeIndex(*sel.start, n)
+			}
+			if sel.end != nil {
+				end = normalizeIndex(*sel.end, n)
+			}
+			for i := start; i < end; i += sel.step {
+				dst = append(dst, Match{Value: v.array[i], Parent: v, Index: i})
+			}
+		}
+	}
+	return dst
+}
+
+func normalizeIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	return min(max(i, 0), n)
+}
+
+// parentPath returns the path without the last selector and the key of last
+// selector, ok is false if last selector is not a single child key.
+func (p *Path) parentPath() (parent *Path, key string, ok bool) {
+	if len(p.selectors) == 0 {
+		return nil, "", false
+	}
+	last := p.selectors[len(p.selectors)-1]
+	if last.kind != selectChild || last.recursive || len(last.keys) != 1 {
+		return nil, "", false
+	}
+	parent = &Path{text: p.text, selectors: p.selectors[:len(p.selectors)-1], legacy: p.legacy}
+	return parent, last.keys[0], true
+}
@@ -0,0 +1,502 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type Kind byte
+
+const (
+	KindNull Kind = iota
+	KindBool
+	KindInteger
+	KindNumber
+	KindString
+	KindArray
+	KindObject
+)
+
+var kindNames = [...]string{"null", "boolean", "integer", "number", "string", "array", "object"}
+
+func (k Kind) String() string { return kindNames[k] }
+
+// Value is a node of JSON document, integers and floats are kept apart so that
+// they are serialized as they were set.
+type Value struct {
+	kind    Kind
+	boolean bool
+	integer int64
+	number  float64
+	str     string
+	array   []*Value
+	object  []member // keeps insertion order
+}
+
+type member struct {
+	key   string
+	value *Value
+}
+
+func NewNull() *Value            { return &Value{kind: KindNull} }
+func NewBool(b bool) *Value      { return &Value{kind: KindBool, boolean: b} }
+func NewInteger(n int64) *Value  { return &Value{kind: KindInteger, integer: n} }
+func NewNumber(f float64) *Value { return &Value{kind: KindNumber, number: f} }
+func NewString(s string) *Value  { return &Value{kind: KindString, str: s} }
+func NewArray() *Value           { return &Value{kind: KindArray} }
+func NewObject() *Value          { return &Value{kind: KindObject} }
+
+func (v *Value) Kind() Kind { return v.kind }
+
+func (v *Value) IsNumber() bool { return v.kind == KindInteger || v.kind == KindNumber }
+
+// Len returns the length of array.
+func (v *Value) Len() int { return len(v.array) }
+
+// Append appends values to array.
+func (v *Value) Append(vs ...*Value) { v.array = append(v.array, vs...) }
+
+func (v *Value) String() string { return string(v.AppendJSON(nil)) }
+
+func (v *Value) toFloat() float64 {
+	if v.kind == KindInteger {
+		return float64(v.integer)
+	}
+	return v.number
+}
+
+func (v *Value) get(key string) *Value {
+	for _, m := range v.object {
+		if m.key == key {
+			return m.value
+		}
+	}
+	return nil
+}
+
+// Set sets member of object, it is appended if not exist.
+func (v *Value) Set(key string, value *Value) {
+	for i, m := range v.object {
+		if m.key == key {
+			v.object[i].value = value
+			return
+		}
+	}
+	v.object = append(v.object, member{key, value})
+}
+
+// Clone returns a deep copy of value.
+func (v *Value) Clone() *Value {
+	c := *v
+	if v.array != nil {
+		c.array = make([]*Value, len(v.array))
+		for i, e := range v.array {
+			c.array[i] = e.Clone()
+		}
+	}
+	if v.object != nil {
+		c.object = make([]member, len(v.object))
+		for i, m := range v.object {
+			c.object[i] = member{m.key, m.value.Clone()}
+		}
+	}
+	return &c
+}
+
+// IncrBy adds number to value, the result stays integer only if both are integers
+// and not overflow.
+func (v *Value) IncrBy(n *Value) {
+	if v.kind == KindInteger && n.kind == KindInteger {
+		sum := v.integer + n.integer
+		// overflow if both operands have the same sign which differs from the sum.
+		if (v.integer >= 0) == (n.integer >= 0) && (sum >= 0) != (v.integer >= 0) {
+			*v = Value{kind: KindNumber, number: float64(v.integer) + float64(n.integer)}
+			return
+		}
+		v.integer = sum
+		return
+	}
+	*v = Value{kind: KindNumber, number: v.toFloat() + n.toFloat()}
+}
+
+// Format is the options for serializing JSON.
+type Format struct {
+	Indent  string
+	Newline string
+	Space   string
+}
+
+// AppendJSON appends compact JSON of value to dst.
+func (v *Value) AppendJSON(dst []byte) []byte {
+	return v.appendJSON(dst, nil, 0)
+}
+
+// AppendFormat appends JSON of value to dst with format.
+func (v *Value) AppendFormat(dst []byte, f *Format) []byte {
+	return v.appendJSON(dst, f, 0)
+}
+
+func (v *Value) appendJSON(dst []byte, f *Format, depth int) []byte {
+	switch v.kind {
+	case KindNull:
+		return append(dst, "null"...)
+	case KindBool:
+		return strconv.AppendBool(dst, v.boolean)
+	case KindInteger:
+		return strconv.AppendInt(dst, v.integer, 10)
+	case KindNumber:
+		return appendFloat(dst, v.number)
+	case KindString:
+		return appendString(dst, v.str)
+	case KindArray:
+		if len(v.array) == 0 {
+			return append(dst, "[]"...)
+		}
+		dst = append(dst, '[')
+		for i, e := range v.array {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendNewline(dst, f, depth+1)
+			dst = e.appendJSON(dst, f, depth+1)
+		}
+		dst = appendNewline(dst, f, depth)
+		return append(dst, ']')
+	case KindObject:
+		if len(v.object) == 0 {
+			return append(dst, "{}"...)
+		}
+		dst = append(dst, '{')
+		for i, m := range v.object {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendNewline(dst, f, depth+1)
+			dst = appendString(dst, m.key)
+			dst = append(dst, ':')
+			if f != nil {
+				dst = append(dst, f.Space...)
+			}
+			dst = m.value.appendJSON(dst, f, depth+1)
+		}
+		dst = appendNewline(dst, f, depth)
+		return append(dst, '}')
+	}
+	return dst
+}
+
+func appendNewline(dst []byte, f *Format, depth int) []byte {
+	if f == nil {
+		return dst
+	}
+	dst = append(dst, f.Newline...)
+	for range depth {
+		dst = append(dst, f.Indent...)
+	}
+	return dst
+}
+
+func appendFloat(dst []byte, f float64) []byte {
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	n := len(dst)
+	dst = strconv.AppendFloat(dst, f, format, -1, 64)
+	// keep the number a float after serialized.
+	for _, c := range dst[n:] {
+		if c == '.' || c == 'e' {
+			return dst
+		}
+	}
+	return append(dst, ".0"...)
+}
+
+const hex = "0123456789abcdef"
+
+func appendString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			dst = append(dst, '\\', c)
+		case c == '\n':
+			dst = append(dst, '\\', 'n')
+		case c == '\r':
+			dst = append(dst, '\\', 'r')
+		case c == '\t':
+			dst = append(dst, '\\', 't')
+		case c < 0x20:
+			dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return append(dst, '"')
+}
+
+var errUnexpectedEnd = errors.New("unexpected end of input")
+
+// Parse parses JSON text into value.
+func Parse(s string) (*Value, error) {
+	p := &parser{s: s}
+	p.skipSpace()
+	v, err := p.parseValue(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.i < len(p.s) {
+		return nil, p.errorf("trailing characters")
+	}
+	return v, nil
+}
+
+const maxDepth = 128
+
+type parser struct {
+	s string
+	i int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s at offset %d", fmt.Sprintf(format, args...), p.i)
+}
+
+func (p *parser) skipSpace() {
+	for p.i < len(p.s) {
+		switch p.s[p.i] {
+		case ' ', '\t', '\n', '\r':
+			p.i++
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) parseValue(depth int) (*Value, error) {
+	if depth > maxDepth {
+		return nil, p.errorf("exceeds max nesting depth")
+	}
+	if p.i >= len(p.s) {
+		return nil, errUnexpectedEnd
+	}
+	switch c := p.s[p.i]; {
+	case c == '{':
+		return p.parseObject(depth)
+	case c == '[':
+		return p.parseArray(depth)
+	case c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return NewString(s), nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	default:
+		for _, lit := range []struct {
+			text  string
+			value func() *Value
+		}{
+			{"true", func() *Value { return NewBool(true) }},
+			{"false", func() *Value { return NewBool(false) }},
+			{"null", NewNull},
+		} {
+			if len(p.s)-p.i >= len(lit.text) && p.s[p.i:p.i+len(lit.text)] == lit.text {
+				p.i += len(lit.text)
+				return lit.value(), nil
+			}
+		}
+		return nil, p.errorf("expected value")
+	}
+}
+
+func (p *parser) parseObject(depth int) (*Value, error) {
+	p.i++ // '{'
+	obj := NewObject()
+	p.skipSpace()
+	if p.i < len(p.s) && p.s[p.i] == '}' {
+		p.i++
+		return obj, nil
+	}
+	for {
+		p.skipSpace()
+		if p.i >= len(p.s) || p.s[p.i] != '"' {
+			return nil, p.errorf("expected object key")
+		}
+		key, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.i >= len(p.s) || p.s[p.i] != ':' {
+			return nil, p.errorf("expected ':'")
+		}
+		p.i++
+		p.skipSpace()
+		value, err := p.parseValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		obj.Set(key, value)
+		p.skipSpace()
+		if p.i >= len(p.s) {
+			return nil, errUnexpectedEnd
+		}
+		switch p.s[p.i] {
+		case ',':
+			p.i++
+		case '}':
+			p.i++
+			return obj, nil
+		default:
+			return nil, p.errorf("expected ',' or '}'")
+		}
+	}
+}
+
+func (p *parser) parseArray(depth int) (*Value, error) {
+	p.i++ // '['
+	arr := NewArray()
+	p.skipSpace()
+	if p.i < len(p.s) && p.s[p.i] == ']' {
+		p.i++
+		return arr, nil
+	}
+	for {
+		p.skipSpace()
+		value, err := p.parseValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		arr.array = append(arr.array, value)
+		p.skipSpace()
+		if p.i >= len(p.s) {
+			return nil, errUnexpectedEnd
+		}
+		switch p.s[p.i] {
+		case ',':
+			p.i++
+		case ']':
+			p.i++
+			return arr, nil
+		default:
+			return nil, p.errorf("expected ',' or ']'")
+		}
+	}
+}
+
+func (p *parser) parseNumber() (*Value, error) {
+	start := p.i
+	isFloat := false
+	if p.s[p.i] == '-' {
+		p.i++
+	}
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		if c >= '0' && c <= '9' {
+			p.i++
+		} else if c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-' {
+			isFloat = true
+			p.i++
+		} else {
+			break
+		}
+	}
+	text := p.s[start:p.i]
+	if !isFloat {
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return NewInteger(n), nil
+		}
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsInf(f, 0) {
+		p.i = start
+		return nil, p.errorf("invalid number")
+	}
+	return NewNumber(f), nil
+}
+
+func (p *parser) parseString() (string, error) {
+	p.i++ // '"'
+	var buf []byte
+	start := p.i
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		switch {
+		case c == '"':
+			if buf == nil {
+				s := p.s[start:p.i]
+				p.i++
+				return s, nil
+			}
+			buf = append(buf, p.s[start:p.i]...)
+			p.i++
+			return string(buf), nil
+		case c == '\\':
+			buf = append(buf, p.s[start:p.i]...)
+			p.i++
+			if p.i >= len(p.s) {
+				return "", errUnexpectedEnd
+			}
+			esc := p.s[p.i]
+			p.i++
+			switch esc {
+			case '"', '\\', '/':
+				buf = append(buf, esc)
+			case 'b':
+				buf = append(buf, '\b')
+			case 'f':
+				buf = append(buf, '\f')
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'u':
+				r, ok := p.parseHex4()
+				if !ok {
+					return "", p.errorf("invalid unicode escape")
+				}
+				// surrogate pair
+				if utf16.IsSurrogate(r) && p.i+1 < len(p.s) && p.s[p.i] == '\\' && p.s[p.i+1] == 'u' {
+					p.i += 2
+					r2, ok := p.parseHex4()
+					if !ok {
+						return "", p.errorf("invalid unicode escape")
+					}
+					r = utf16.DecodeRune(r, r2)
+				}
+				buf = utf8.AppendRune(buf, r)
+			default:
+				return "", p.errorf("invalid escape")
+			}
+			start = p.i
+		case c < 0x20:
+			return "", p.errorf("control character in string")
+		default:
+			p.i++
+		}
+	}
+	return "", errUnexpectedEnd
+}
+
+func (p *parser) parseHex4() (rune, bool) {
+	if p.i+4 > len(p.s) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(p.s[p.i:p.i+4], 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	p.i += 4
+	return rune(n), true
+}
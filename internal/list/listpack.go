@@ -1,6 +1,7 @@
 package list
 
 import (
+	"bytes"
 	"encoding/binary"
 	"github.com/klauspost/rvarint"
 	"github.com/xgzlucario/rotom/internal/iface"
@@ -170,7 +171,7 @@ func appendEntry(dst []byte, data string) []byte {
 
 func (lp *ListPack) ReadFrom(rd *iface.Reader) {
 	lp.size = rd.ReadUint32()
-	lp.data = rd.ReadBytes()
+	lp.data = bytes.Clone(rd.ReadBytes())
 }
 
 // WriteTo encode zipmap to [size, data].
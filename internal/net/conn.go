@@ -0,0 +1,150 @@
+package net
+
+import (
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Conn is a net.Conn over socket fd, it is used as the transport of TLS.
+// Before Detach, it works in blocking mode through the runtime poller, so that
+// the TLS handshake can run in a goroutine without blocking the event loop.
+// After Detach, it is driven by the event loop: Read returns an error wrapping
+// ErrAgain with Temporary() true when no data is available, and Write buffers
+// data until sent by Flush.
+type Conn struct {
+	fd int
+	// file is the duplicated fd registered in runtime poller, nil after Detach.
+	file net.Conn
+	out  []byte
+}
+
+// NewConn returns Conn of fd, which is not closed by Conn.
+func NewConn(fd int) (*Conn, error) {
+	dfd, err := unix.Dup(fd)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(dfd), "")
+	defer f.Close()
+	file, err := net.FileConn(f)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{fd: fd, file: file}, nil
+}
+
+// Detach stops using runtime poller, it is called when the goroutine using Conn
+// in blocking mode returns.
+func (c *Conn) Detach() error {
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// wouldBlockError is returned by Read of detached Conn when no data is available,
+// it is temporary so that TLS connection can be read again.
+type wouldBlockError struct{}
+
+func (wouldBlockError) Error() string   { return ErrAgain.Error() }
+func (wouldBlockError) Unwrap() error   { return ErrAgain }
+func (wouldBlockError) Timeout() bool   { return true }
+func (wouldBlockError) Temporary() bool { return true }
+
+func (c *Conn) Read(b []byte) (int, error) {
+	if c.file != nil {
+		return c.file.Read(b)
+	}
+	n, err := Read(c.fd, b)
+	if err == ErrAgain {
+		return 0, wouldBlockError{}
+	}
+	if err == nil && n == 0 {
+		return 0, io.EOF
+	}
+	return n, err
+}
+
+func (c *Conn) Write(b []byte) (int, error) {
+	if c.file != nil {
+		return c.file.Write(b)
+	}
+	c.out = append(c.out, b...)
+	return len(b), nil
+}
+
+// Flush sends the buffered data, it returns ErrAgain if not all sent.
+func (c *Conn) Flush() error {
+	for len(c.out) > 0 {
+		n, err := Write(c.fd, c.out)
+		if err != nil {
+			return err
+		}
+		c.out = c.out[n:]
+	}
+	c.out = nil
+	return nil
+}
+
+// Buffered returns the bytes not sent by Flush.
+func (c *Conn) Buffered() int {
+	return len(c.out)
+}
+
+// Close interrupts the blocking operations before Detach, fd is left open.
+func (c *Conn) Close() error {
+	if c.file != nil {
+		return c.file.Close()
+	}
+	return nil
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	sa, _ := unix.Getsockname(c.fd)
+	return sockaddrAddr(sa)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	sa, _ := unix.Getpeername(c.fd)
+	return sockaddrAddr(sa)
+}
+
+func sockaddrAddr(sa unix.Sockaddr) net.Addr {
+	switch sa := sa.(type) {
+	case *unix.SockaddrInet4:
+		return &net.TCPAddr{IP: net.IP(sa.Addr[:]), Port: sa.Port}
+	case *unix.SockaddrInet6:
+		return &net.TCPAddr{IP: net.IP(sa.Addr[:]), Port: sa.Port}
+	case *unix.SockaddrUnix:
+		return &net.UnixAddr{Name: sa.Name, Net: "unix"}
+	}
+	return &net.TCPAddr{}
+}
+
+// SetDeadline and the others apply before Detach only.
+func (c *Conn) SetDeadline(t time.Time) error {
+	if c.file != nil {
+		return c.file.SetDeadline(t)
+	}
+	return nil
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	if c.file != nil {
+		return c.file.SetReadDeadline(t)
+	}
+	return nil
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	if c.file != nil {
+		return c.file.SetWriteDeadline(t)
+	}
+	return nil
+}
@@ -1,11 +1,18 @@
 package net
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+
 	"golang.org/x/sys/unix"
 )
 
 /*
-BACKLOG is TCP listen() backlog.
+BACKLOG is the default listen() backlog.
 In high requests-per-second environments you need a high backlog in order
 to avoid slow clients connections issues. Note that the Linux kernel
 will silently truncate it to the value of /proc/sys/net/core/somaxconn so
@@ -14,39 +21,201 @@ in order to get the desired effect.
 */
 const BACKLOG int = 511
 
+// ErrAgain is returned by operations on non-blocking socket which would block.
+var ErrAgain = unix.EAGAIN
+
+// Accept accepts a connection, it is retried when interrupted by signal.
 func Accept(fd int) (int, error) {
-	nfd, _, err := unix.Accept(fd)
-	return nfd, err
+	for {
+		nfd, _, err := unix.Accept(fd)
+		if err != unix.EINTR {
+			return nfd, err
+		}
+	}
+}
+
+// SetNonblock puts socket fd in non-blocking mode, Read and Write on it return
+// ErrAgain instead of blocking.
+func SetNonblock(fd int) error {
+	return unix.SetNonblock(fd, true)
+}
+
+// PeerName returns the remote address of socket fd in the form of "host:port".
+func PeerName(fd int) (string, error) {
+	sa, err := unix.Getpeername(fd)
+	if err != nil {
+		return "", err
+	}
+	return sockaddrString(sa), nil
+}
+
+// SockName returns the local address of socket fd in the form of "host:port".
+func SockName(fd int) (string, error) {
+	sa, err := unix.Getsockname(fd)
+	if err != nil {
+		return "", err
+	}
+	return sockaddrString(sa), nil
 }
 
+func sockaddrString(sa unix.Sockaddr) string {
+	switch sa := sa.(type) {
+	case *unix.SockaddrInet4:
+		return net.JoinHostPort(net.IP(sa.Addr[:]).String(), strconv.Itoa(sa.Port))
+	case *unix.SockaddrInet6:
+		return net.JoinHostPort(net.IP(sa.Addr[:]).String(), strconv.Itoa(sa.Port))
+	case *unix.SockaddrUnix:
+		return sa.Name
+	}
+	return ""
+}
+
+// Read reads from fd, it is retried when interrupted by signal, and n is 0 when
+// err is not nil.
 func Read(fd int, buf []byte) (int, error) {
-	return unix.Read(fd, buf)
+	for {
+		n, err := unix.Read(fd, buf)
+		if err == nil {
+			return n, nil
+		}
+		if err != unix.EINTR {
+			return 0, err
+		}
+	}
 }
 
+// Write writes to fd, it is retried when interrupted by signal, and n is 0 when
+// err is not nil. n may be less than len(buf) for non-blocking socket.
 func Write(fd int, buf []byte) (int, error) {
-	return unix.Write(fd, buf)
+	for {
+		n, err := unix.Write(fd, buf)
+		if err == nil {
+			return n, nil
+		}
+		if err != unix.EINTR {
+			return 0, err
+		}
+	}
 }
 
 func Close(fd int) error {
 	return unix.Close(fd)
 }
 
-func TcpServer(port int) (int, error) {
-	s, err := unix.Socket(unix.AF_INET, unix.SOCK_STREAM, 0)
+// TcpServer listens on tcp address host:port, host "*" means all IPv4 addresses
+// and "::*" means all IPv6 addresses. IPv6 socket accepts IPv6 connections only,
+// so that the same port can be bound by both families.
+func TcpServer(host string, port, backlog int) (int, error) {
+	sa, err := sockaddr(host, port)
+	if err != nil {
+		return -1, err
+	}
+	family := unix.AF_INET
+	if _, ok := sa.(*unix.SockaddrInet6); ok {
+		family = unix.AF_INET6
+	}
+	s, err := unix.Socket(family, unix.SOCK_STREAM, 0)
 	if err != nil {
 		return -1, err
 	}
 	err = unix.SetsockoptInt(s, unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	if err == nil && family == unix.AF_INET6 {
+		err = unix.SetsockoptInt(s, unix.IPPROTO_IPV6, unix.IPV6_V6ONLY, 1)
+	}
+	if err == nil {
+		err = unix.Bind(s, sa)
+	}
+	if err == nil {
+		err = unix.Listen(s, backlog)
+	}
+	if err != nil {
+		_ = unix.Close(s)
+		return -1, err
+	}
+	return s, nil
+}
+
+func sockaddr(host string, port int) (unix.Sockaddr, error) {
+	switch host {
+	case "*":
+		return &unix.SockaddrInet4{Port: port}, nil
+	case "::*":
+		return &unix.SockaddrInet6{Port: port}, nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid bind address: %q", host)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &unix.SockaddrInet4{Port: port, Addr: [4]byte(ip4)}, nil
+	}
+	return &unix.SockaddrInet6{Port: port, Addr: [16]byte(ip)}, nil
+}
+
+// SetNoDelay disables Nagle's algorithm of tcp socket fd.
+func SetNoDelay(fd int) error {
+	return unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_NODELAY, 1)
+}
+
+// SetKeepAlive enables tcp keepalive of socket fd, the first probe is sent after
+// it is idle for interval seconds, and the connection is closed after 3 probes
+// sent every interval/3 seconds are not acknowledged.
+func SetKeepAlive(fd int, interval int) error {
+	err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_KEEPALIVE, 1)
+	if err == nil {
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPIDLE, interval)
+	}
+	if err == nil {
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, max(interval/3, 1))
+	}
+	if err == nil {
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPCNT, 3)
+	}
+	return err
+}
+
+// UnixServer listens on unix domain socket of path, stale socket file left by
+// previous run is removed first, and the file mode is set to perm if not 0.
+func UnixServer(path string, perm os.FileMode, backlog int) (int, error) {
+	if err := RemoveUnixSocket(path); err != nil {
+		return -1, err
+	}
+	s, err := unix.Socket(unix.AF_UNIX, unix.SOCK_STREAM, 0)
 	if err != nil {
 		return -1, err
 	}
-	err = unix.Bind(s, &unix.SockaddrInet4{Port: port})
+	err = unix.Bind(s, &unix.SockaddrUnix{Name: path})
 	if err != nil {
+		_ = unix.Close(s)
 		return -1, err
 	}
-	err = unix.Listen(s, BACKLOG)
+	if perm != 0 {
+		if err = os.Chmod(path, perm); err != nil {
+			_ = unix.Close(s)
+			return -1, err
+		}
+	}
+	err = unix.Listen(s, backlog)
 	if err != nil {
+		_ = unix.Close(s)
 		return -1, err
 	}
 	return s, nil
 }
+
+// RemoveUnixSocket removes the socket file of path, it is not an error if the
+// file does not exist.
+func RemoveUnixSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// do not remove regular files by misconfiguration.
+	if fi.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("%s is not a unix socket", path)
+	}
+	return os.Remove(path)
+}
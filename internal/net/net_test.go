@@ -3,6 +3,8 @@ package net
 import (
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -15,7 +17,7 @@ func TestServer(t *testing.T) {
 	testCount := 100
 
 	t.Run("echo-server", func(t *testing.T) {
-		fd, err := TcpServer(20083)
+		fd, err := TcpServer("*", 20083, BACKLOG)
 		ast.Nil(err)
 
 		var wg sync.WaitGroup
@@ -62,3 +64,128 @@ func TestServer(t *testing.T) {
 		wg.Wait()
 	})
 }
+
+func TestSockName(t *testing.T) {
+	ast := assert.New(t)
+
+	fd, err := TcpServer("*", 20084, BACKLOG)
+	ast.Nil(err)
+	defer Close(fd)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:20084")
+	ast.Nil(err)
+	defer conn.Close()
+
+	cfd, err := Accept(fd)
+	ast.Nil(err)
+	defer Close(cfd)
+
+	addr, err := PeerName(cfd)
+	ast.Nil(err)
+	ast.Equal(addr, conn.LocalAddr().String())
+	laddr, err := SockName(cfd)
+	ast.Nil(err)
+	ast.Equal(laddr, "127.0.0.1:20084")
+}
+
+func TestNonblock(t *testing.T) {
+	ast := assert.New(t)
+
+	fd, err := TcpServer("*", 20085, BACKLOG)
+	ast.Nil(err)
+	defer Close(fd)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:20085")
+	ast.Nil(err)
+	defer conn.Close()
+
+	cfd, err := Accept(fd)
+	ast.Nil(err)
+	defer Close(cfd)
+	ast.Nil(SetNonblock(cfd))
+
+	var buf [32]byte
+	n, err := Read(cfd, buf[:])
+	ast.Equal(n, 0)
+	ast.ErrorIs(err, ErrAgain)
+
+	// write until the socket buffer is full.
+	data := make([]byte, 64*1024)
+	for {
+		n, err = Write(cfd, data)
+		if err != nil {
+			break
+		}
+	}
+	ast.Equal(n, 0)
+	ast.ErrorIs(err, ErrAgain)
+}
+
+func TestUnixServer(t *testing.T) {
+	ast := assert.New(t)
+	path := filepath.Join(t.TempDir(), "rotom.sock")
+
+	// stale socket file is removed.
+	fd, err := UnixServer(path, 0, BACKLOG)
+	ast.Nil(err)
+	Close(fd)
+	fd, err = UnixServer(path, 0o700, BACKLOG)
+	ast.Nil(err)
+	defer Close(fd)
+
+	fi, err := os.Stat(path)
+	ast.Nil(err)
+	ast.Equal(fi.Mode().Perm(), os.FileMode(0o700))
+
+	conn, err := net.Dial("unix", path)
+	ast.Nil(err)
+	defer conn.Close()
+
+	cfd, err := Accept(fd)
+	ast.Nil(err)
+	defer Close(cfd)
+	laddr, err := SockName(cfd)
+	ast.Nil(err)
+	ast.Equal(laddr, path)
+
+	ast.Nil(RemoveUnixSocket(path))
+	ast.Nil(RemoveUnixSocket(path))
+
+	// regular file is not removed.
+	ast.Nil(os.WriteFile(path, nil, 0o600))
+	_, err = UnixServer(path, 0, BACKLOG)
+	ast.NotNil(err)
+}
+
+func TestTcpServerBind(t *testing.T) {
+	ast := assert.New(t)
+
+	fd4, err := TcpServer("127.0.0.1", 20086, BACKLOG)
+	ast.Nil(err)
+	defer Close(fd4)
+	// IPv6 socket does not conflict with IPv4 one of the same port.
+	fd6, err := TcpServer("::1", 20086, BACKLOG)
+	ast.Nil(err)
+	defer Close(fd6)
+
+	for _, lis := range []struct {
+		fd   int
+		addr string
+	}{{fd4, "127.0.0.1:20086"}, {fd6, "[::1]:20086"}} {
+		conn, err := net.Dial("tcp", lis.addr)
+		ast.Nil(err)
+		defer conn.Close()
+
+		cfd, err := Accept(lis.fd)
+		ast.Nil(err)
+		defer Close(cfd)
+		laddr, err := SockName(cfd)
+		ast.Nil(err)
+		ast.Equal(laddr, lis.addr)
+		ast.Nil(SetNoDelay(cfd))
+		ast.Nil(SetKeepAlive(cfd, 300))
+	}
+
+	_, err = TcpServer("localhost", 20087, BACKLOG)
+	ast.NotNil(err)
+}
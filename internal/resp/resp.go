@@ -2,11 +2,22 @@ package resp
 
 import (
 	"bytes"
+	"math"
+	"strconv"
+
 	"github.com/tidwall/redcon"
 )
 
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
+
+// Writer writes replies in RESP2 by default, and RESP3 after SetProto(RESP3).
+// The RESP3 types are downgraded to the nearest RESP2 types in RESP2.
 type Writer struct {
 	*redcon.Writer
+	proto int
 }
 
 type Reader struct {
@@ -16,6 +27,7 @@ type Reader struct {
 func NewWriter() *Writer {
 	return &Writer{
 		Writer: redcon.NewWriter(bytes.NewBuffer(nil)),
+		proto:  RESP2,
 	}
 }
 
@@ -23,6 +35,122 @@ func (w *Writer) Reset() {
 	w.Writer.SetBuffer(nil)
 }
 
+func (w *Writer) Proto() int { return w.proto }
+
+func (w *Writer) SetProto(proto int) { w.proto = proto }
+
+func (w *Writer) appendHeader(prefix byte, n int) {
+	b := append(w.Buffer(), prefix)
+	b = strconv.AppendInt(b, int64(n), 10)
+	w.SetBuffer(append(b, '\r', '\n'))
+}
+
+// WriteNull writes null in RESP3, or null bulk string in RESP2.
+func (w *Writer) WriteNull() {
+	if w.proto == RESP3 {
+		w.WriteRaw([]byte("_\r\n"))
+		return
+	}
+	w.Writer.WriteNull()
+}
+
+// WriteNullArray writes null in RESP3, or null array in RESP2.
+func (w *Writer) WriteNullArray() {
+	if w.proto == RESP3 {
+		w.WriteRaw([]byte("_\r\n"))
+		return
+	}
+	w.WriteRaw([]byte("*-1\r\n"))
+}
+
+// WriteMap writes a map header of n pairs, or an array header of 2n elements
+// in RESP2. You must then write n keys and values alternately.
+func (w *Writer) WriteMap(n int) {
+	if w.proto == RESP3 {
+		w.appendHeader('%', n)
+		return
+	}
+	w.WriteArray(n * 2)
+}
+
+// WriteSet writes a set header, or an array header in RESP2.
+func (w *Writer) WriteSet(n int) {
+	if w.proto == RESP3 {
+		w.appendHeader('~', n)
+		return
+	}
+	w.WriteArray(n)
+}
+
+// WritePush writes a push header for out of band data such as pubsub messages,
+// or an array header in RESP2.
+func (w *Writer) WritePush(n int) {
+	if w.proto == RESP3 {
+		w.appendHeader('>', n)
+		return
+	}
+	w.WriteArray(n)
+}
+
+// WriteDouble writes a double, or a bulk string in RESP2.
+func (w *Writer) WriteDouble(f float64) {
+	if w.proto != RESP3 {
+		w.WriteBulk(strconv.AppendFloat(nil, f, 'f', -1, 64))
+		return
+	}
+	b := append(w.Buffer(), ',')
+	switch {
+	case math.IsInf(f, 1):
+		b = append(b, "inf"...)
+	case math.IsInf(f, -1):
+		b = append(b, "-inf"...)
+	case math.IsNaN(f):
+		b = append(b, "nan"...)
+	default:
+		b = strconv.AppendFloat(b, f, 'f', -1, 64)
+	}
+	w.SetBuffer(append(b, '\r', '\n'))
+}
+
+// WriteBool writes a boolean, or an integer 1 or 0 in RESP2.
+func (w *Writer) WriteBool(v bool) {
+	switch {
+	case w.proto != RESP3 && v:
+		w.WriteInt(1)
+	case w.proto != RESP3:
+		w.WriteInt(0)
+	case v:
+		w.WriteRaw([]byte("#t\r\n"))
+	default:
+		w.WriteRaw([]byte("#f\r\n"))
+	}
+}
+
+// WriteBigNumber writes a big number in decimal, or a bulk string in RESP2.
+func (w *Writer) WriteBigNumber(num string) {
+	if w.proto != RESP3 {
+		w.WriteBulkString(num)
+		return
+	}
+	b := append(w.Buffer(), '(')
+	b = append(b, num...)
+	w.SetBuffer(append(b, '\r', '\n'))
+}
+
+// WriteVerbatim writes a verbatim string with 3 bytes format such as "txt" or
+// "mkd", or a bulk string in RESP2.
+func (w *Writer) WriteVerbatim(format, s string) {
+	if w.proto != RESP3 {
+		w.WriteBulkString(s)
+		return
+	}
+	w.appendHeader('=', len(format)+1+len(s))
+	b := append(w.Buffer(), format...)
+	b = append(b, ':')
+	b = append(b, s...)
+	w.SetBuffer(append(b, '\r', '\n'))
+}
+
 func NewReader(b []byte) *Reader {
 	return &Reader{redcon.NewReader(bytes.NewReader(b))}
 }
@@ -0,0 +1,181 @@
+package stream
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xgzlucario/rotom/internal/iface"
+	"golang.org/x/exp/maps"
+)
+
+func FuzzTestRax(f *testing.F) {
+	stdmap := make(map[string]int)
+	rax := NewRax()
+
+	f.Fuzz(func(t *testing.T, op int, key string, pivot string) {
+		ast := assert.New(t)
+		switch op % 10 {
+		case 0, 1, 2, 3: // Insert
+			_, ok := stdmap[key]
+			stdmap[key] = op
+			ast.Equal(!ok, rax.Insert([]byte(key), op))
+
+		case 4, 5: // Find
+			val1, ok1 := stdmap[key]
+			val2, ok2 := rax.Find([]byte(key))
+			ast.Equal(ok1, ok2)
+			if ok1 {
+				ast.Equal(val1, val2)
+			}
+
+		case 6, 7: // Remove
+			_, ok := stdmap[key]
+			delete(stdmap, key)
+			ast.Equal(ok, rax.Remove([]byte(key)))
+			ast.Equal(len(stdmap), rax.Len())
+
+		case 8: // Ascend
+			keys := maps.Keys(stdmap)
+			slices.Sort(keys)
+			keys = slices.DeleteFunc(keys, func(k string) bool { return k < pivot })
+			var keys2 []string
+			rax.Ascend([]byte(pivot), func(k []byte, _ any) bool {
+				keys2 = append(keys2, string(k))
+				return true
+			})
+			ast.Equal(len(keys), len(keys2))
+			if len(keys) > 0 {
+				ast.Equal(keys, keys2)
+			}
+
+		case 9: // Descend
+			keys := maps.Keys(stdmap)
+			slices.Sort(keys)
+			slices.Reverse(keys)
+			keys = slices.DeleteFunc(keys, func(k string) bool { return k > pivot })
+			var keys2 []string
+			rax.Descend([]byte(pivot), func(k []byte, _ any) bool {
+				keys2 = append(keys2, string(k))
+				return true
+			})
+			ast.Equal(len(keys), len(keys2))
+			if len(keys) > 0 {
+				ast.Equal(keys, keys2)
+			}
+		}
+	})
+}
+
+func FuzzTestStream(f *testing.F) {
+	var ids []ID
+	s := New()
+	g := s.CreateGroup("group", MinID)
+
+	f.Fuzz(func(t *testing.T, op int, ms uint16, val string) {
+		ast := assert.New(t)
+		switch op % 10 {
+		case 0, 1, 2, 3: // Add
+			id, _ := s.NextID(uint64(ms))
+			s.Add(id, [][]byte{[]byte("key"), []byte(val)})
+			ids = append(ids, id)
+
+		case 4: // Range
+			var ids2 []ID
+			s.Range(MinID, MaxID, op%2 == 0, func(e Entry) bool {
+				ast.Equal(e.Fields[0], []byte("key"))
+				ids2 = append(ids2, e.ID)
+				return true
+			})
+			if op%2 == 0 {
+				slices.Reverse(ids2)
+			}
+			ast.Equal(len(ids), len(ids2))
+			if len(ids) > 0 {
+				ast.Equal(ids, ids2)
+			}
+
+		case 5: // Delete
+			if len(ids) > 0 {
+				i := int(ms) % len(ids)
+				ast.True(s.Delete(ids[i]))
+				ast.False(s.Delete(ids[i]))
+				ids = slices.Delete(ids, i, i+1)
+			}
+			ast.Equal(len(ids), s.Len())
+
+		case 6: // Trim
+			n := uint64(ms % 8)
+			if uint64(len(ids)) > n {
+				ast.Equal(len(ids)-int(n), s.TrimMaxLen(n))
+				ids = ids[len(ids)-int(n):]
+			}
+			ast.Equal(len(ids), s.Len())
+
+		case 7: // Deliver and Ack
+			c, _ := g.Consumer(val, true)
+			for _, id := range ids {
+				g.Deliver(id, c, int64(ms))
+			}
+			ast.Equal(len(ids), g.PendingLen())
+			for _, id := range ids {
+				ast.True(g.Ack(id))
+			}
+			ast.Equal(0, g.PendingLen())
+			ast.Equal(0, c.PendingLen())
+
+		case 8: // Exist
+			for _, id := range ids {
+				ast.True(s.Exist(id))
+			}
+
+		case 9: // Encode
+			c, _ := g.Consumer(val, true)
+			if len(ids) > 0 {
+				g.Deliver(ids[0], c, int64(ms))
+			}
+			w := iface.NewWriter(nil)
+			s.WriteTo(w)
+			pending := g.PendingLen()
+
+			s = New()
+			s.ReadFrom(iface.NewReaderFrom(w))
+			g = s.Group("group")
+			ast.Equal(len(ids), s.Len())
+			ast.Equal(pending, g.PendingLen())
+			if len(ids) > 0 {
+				ast.True(g.Ack(ids[0]))
+			}
+		}
+	})
+}
+
+func TestID(t *testing.T) {
+	ast := assert.New(t)
+
+	id, err := ParseID("1526919030474-55", 0)
+	ast.Nil(err)
+	ast.Equal(ID{1526919030474, 55}, id)
+	ast.Equal("1526919030474-55", id.String())
+
+	id, err = ParseID("1526919030474", 9)
+	ast.Nil(err)
+	ast.Equal(ID{1526919030474, 9}, id)
+
+	_, err = ParseID("abc-1", 0)
+	ast.Equal(ErrInvalidID, err)
+
+	next, ok := MaxID.Incr()
+	ast.False(ok)
+	ast.Equal(MaxID, next)
+
+	prev, ok := ID{1, 0}.Decr()
+	ast.True(ok)
+	ast.Equal(fmt.Sprintf("0-%d", uint64(1<<64-1)), prev.String())
+
+	b := ID{1, 2}.key()
+	ast.Equal(uint64(1), binary.BigEndian.Uint64(b))
+	ast.Equal(ID{1, 2}, idFromKey(b))
+}
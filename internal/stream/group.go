@@ -0,0 +1,219 @@
+package stream
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+// Group is a consumer group of stream.
+type Group struct {
+	Name   string
+	LastID ID
+
+	// pel is the pending entries list, entries delivered but not yet acknowledged.
+	pel       *Rax // ID -> *PendingEntry
+	consumers map[string]*Consumer
+}
+
+// Consumer is a consumer of group, each consumer owns its own PEL.
+type Consumer struct {
+	Name     string
+	SeenTime int64 // ms
+	pel      *Rax  // ID -> *PendingEntry
+}
+
+// PendingEntry is an entry of PEL.
+type PendingEntry struct {
+	ID            ID
+	Consumer      *Consumer
+	DeliveryTime  int64 // ms
+	DeliveryCount uint64
+}
+
+func newGroup(name string, lastID ID) *Group {
+	return &Group{
+		Name:      name,
+		LastID:    lastID,
+		pel:       NewRax(),
+		consumers: make(map[string]*Consumer),
+	}
+}
+
+// CreateGroup creates a new consumer group, returns nil if group already exists.
+func (s *Stream) CreateGroup(name string, lastID ID) *Group {
+	if _, ok := s.groups[name]; ok {
+		return nil
+	}
+	g := newGroup(name, lastID)
+	s.groups[name] = g
+	return g
+}
+
+func (s *Stream) Group(name string) *Group { return s.groups[name] }
+
+func (s *Stream) DestroyGroup(name string) bool {
+	if _, ok := s.groups[name]; !ok {
+		return false
+	}
+	delete(s.groups, name)
+	return true
+}
+
+// Groups returns all groups sorted by name.
+func (s *Stream) Groups() []*Group {
+	names := slices.Sorted(maps.Keys(s.groups))
+	groups := make([]*Group, 0, len(names))
+	for _, name := range names {
+		groups = append(groups, s.groups[name])
+	}
+	return groups
+}
+
+// Consumer returns consumer of name, creates it when not exist and create is true.
+func (g *Group) Consumer(name string, create bool) (c *Consumer, created bool) {
+	c = g.consumers[name]
+	if c == nil && create {
+		c = &Consumer{Name: name, pel: NewRax()}
+		g.consumers[name] = c
+		created = true
+	}
+	return c, created
+}
+
+// DeleteConsumer deletes consumer and its pending entries, returns the
+// number of pending entries the consumer had, or -1 if not exist.
+func (g *Group) DeleteConsumer(name string) int {
+	c := g.consumers[name]
+	if c == nil {
+		return -1
+	}
+	pending := c.pel.Len()
+	c.pel.Ascend(nil, func(key []byte, _ any) bool {
+		g.pel.Remove(key)
+		return true
+	})
+	delete(g.consumers, name)
+	return pending
+}
+
+// Consumers returns all consumers sorted by name.
+func (g *Group) Consumers() []*Consumer {
+	names := slices.Sorted(maps.Keys(g.consumers))
+	consumers := make([]*Consumer, 0, len(names))
+	for _, name := range names {
+		consumers = append(consumers, g.consumers[name])
+	}
+	return consumers
+}
+
+// PendingLen returns the length of group PEL.
+func (g *Group) PendingLen() int { return g.pel.Len() }
+
+// PendingLen returns the length of consumer PEL.
+func (c *Consumer) PendingLen() int { return c.pel.Len() }
+
+// Pending returns the pending entry of id.
+func (g *Group) Pending(id ID) *PendingEntry {
+	pe, ok := g.pel.Find(id.key())
+	if !ok {
+		return nil
+	}
+	return pe.(*PendingEntry)
+}
+
+// RangePending iterates pending entries of group (or consumer if not nil)
+// with ID >= start in ascending order until fn returns false.
+func (g *Group) RangePending(c *Consumer, start ID, fn func(*PendingEntry) bool) {
+	pel := g.pel
+	if c != nil {
+		pel = c.pel
+	}
+	pel.Ascend(start.key(), func(_ []byte, pe any) bool {
+		return fn(pe.(*PendingEntry))
+	})
+}
+
+// Deliver assigns entry of id to consumer, creating or updating its pending entry.
+func (g *Group) Deliver(id ID, c *Consumer, now int64) *PendingEntry {
+	pe := g.Pending(id)
+	if pe == nil {
+		pe = &PendingEntry{ID: id}
+		g.pel.Insert(id.key(), pe)
+	}
+	g.assign(pe, c)
+	pe.DeliveryTime = now
+	pe.DeliveryCount++
+	return pe
+}
+
+// Claim changes the ownership of pending entry to consumer.
+func (g *Group) Claim(pe *PendingEntry, c *Consumer) {
+	g.assign(pe, c)
+}
+
+func (g *Group) assign(pe *PendingEntry, c *Consumer) {
+	if pe.Consumer == c {
+		return
+	}
+	if pe.Consumer != nil {
+		pe.Consumer.pel.Remove(pe.ID.key())
+	}
+	pe.Consumer = c
+	c.pel.Insert(pe.ID.key(), pe)
+}
+
+// Ack removes entry of id from PEL, returns true if exist.
+func (g *Group) Ack(id ID) bool {
+	pe := g.Pending(id)
+	if pe == nil {
+		return false
+	}
+	g.pel.Remove(id.key())
+	pe.Consumer.pel.Remove(id.key())
+	return true
+}
+
+func (g *Group) readFrom(rd *iface.Reader) {
+	g.LastID = ID{rd.ReadUint64(), rd.ReadUint64()}
+
+	numConsumers := rd.ReadUint64()
+	for range numConsumers {
+		c, _ := g.Consumer(rd.ReadString(), true)
+		c.SeenTime = rd.ReadVarint()
+	}
+
+	numPending := rd.ReadUint64()
+	for range numPending {
+		pe := &PendingEntry{ID: ID{rd.ReadUint64(), rd.ReadUint64()}}
+		c, _ := g.Consumer(rd.ReadString(), true)
+		pe.DeliveryTime = rd.ReadVarint()
+		pe.DeliveryCount = rd.ReadUint64()
+		g.pel.Insert(pe.ID.key(), pe)
+		g.assign(pe, c)
+	}
+}
+
+// writeTo encode group to [lastID, consumers..., pending entries...].
+func (g *Group) writeTo(w *iface.Writer) {
+	w.WriteUint64(g.LastID.Ms)
+	w.WriteUint64(g.LastID.Seq)
+
+	w.WriteUint64(uint64(len(g.consumers)))
+	for _, c := range g.consumers {
+		w.WriteString(c.Name)
+		w.WriteVarint(int(c.SeenTime))
+	}
+
+	w.WriteUint64(uint64(g.pel.Len()))
+	g.pel.Ascend(nil, func(_ []byte, v any) bool {
+		pe := v.(*PendingEntry)
+		w.WriteUint64(pe.ID.Ms)
+		w.WriteUint64(pe.ID.Seq)
+		w.WriteString(pe.Consumer.Name)
+		w.WriteVarint(int(pe.DeliveryTime))
+		w.WriteUint64(pe.DeliveryCount)
+		return true
+	})
+}
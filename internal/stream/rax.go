@@ -0,0 +1,220 @@
+package stream
+
+import (
+	"bytes"
+	"slices"
+)
+
+// Rax is a radix tree with ordered keys, like the rax in Redis.
+// Stream uses it to index listpack nodes and pending entries by stream ID.
+type Rax struct {
+	root *raxNode
+	size int
+}
+
+type raxNode struct {
+	prefix   []byte
+	children []*raxNode // sorted by the first byte of prefix
+	value    any
+	isKey    bool
+}
+
+func NewRax() *Rax {
+	return &Rax{root: &raxNode{}}
+}
+
+func (r *Rax) Len() int { return r.size }
+
+// Insert set value of key, returns true if key is new.
+func (r *Rax) Insert(key []byte, value any) bool {
+	n := r.root
+	for {
+		// prefix of current node has been matched, move to child.
+		if len(key) == 0 {
+			isNew := !n.isKey
+			n.value, n.isKey = value, true
+			if isNew {
+				r.size++
+			}
+			return isNew
+		}
+		i, child := n.child(key[0])
+		if child == nil {
+			n.children = slices.Insert(n.children, i, &raxNode{
+				prefix: bytes.Clone(key),
+				value:  value,
+				isKey:  true,
+			})
+			r.size++
+			return true
+		}
+		common := commonPrefix(child.prefix, key)
+		if common < len(child.prefix) {
+			// split child into [common prefix] -> [rest of child].
+			split := &raxNode{prefix: child.prefix[:common:common]}
+			child.prefix = child.prefix[common:]
+			split.children = []*raxNode{child}
+			n.children[i] = split
+			child = split
+		}
+		n = child
+		key = key[common:]
+	}
+}
+
+// Find returns the value of key.
+func (r *Rax) Find(key []byte) (any, bool) {
+	n := r.root
+	for len(key) > 0 {
+		_, child := n.child(key[0])
+		if child == nil || !bytes.HasPrefix(key, child.prefix) {
+			return nil, false
+		}
+		key = key[len(child.prefix):]
+		n = child
+	}
+	return n.value, n.isKey
+}
+
+// Remove deletes the key, returns true if key exist.
+func (r *Rax) Remove(key []byte) bool {
+	if !r.root.remove(key) {
+		return false
+	}
+	r.size--
+	return true
+}
+
+func (n *raxNode) remove(key []byte) bool {
+	if len(key) == 0 {
+		if !n.isKey {
+			return false
+		}
+		n.value, n.isKey = nil, false
+		return true
+	}
+	i, child := n.child(key[0])
+	if child == nil || !bytes.HasPrefix(key, child.prefix) {
+		return false
+	}
+	if !child.remove(key[len(child.prefix):]) {
+		return false
+	}
+	// compress the tree after removal.
+	switch {
+	case !child.isKey && len(child.children) == 0:
+		n.children = slices.Delete(n.children, i, i+1)
+	case !child.isKey && len(child.children) == 1:
+		grandson := child.children[0]
+		grandson.prefix = append(slices.Clip(child.prefix), grandson.prefix...)
+		n.children[i] = grandson
+	}
+	return true
+}
+
+func (n *raxNode) child(b byte) (int, *raxNode) {
+	i, ok := slices.BinarySearchFunc(n.children, b, func(c *raxNode, b byte) int {
+		return int(c.prefix[0]) - int(b)
+	})
+	if ok {
+		return i, n.children[i]
+	}
+	return i, nil
+}
+
+func commonPrefix(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// Ascend iterates keys >= pivot in ascending order until fn returns false,
+// nil pivot means iterates from the first key.
+// The key passed to fn is only valid until fn returns.
+func (r *Rax) Ascend(pivot []byte, fn func(key []byte, value any) bool) {
+	r.root.ascend(nil, pivot, fn)
+}
+
+func (n *raxNode) ascend(path, pivot []byte, fn func([]byte, any) bool) bool {
+	path = append(path, n.prefix...)
+	if pivot != nil {
+		switch bytes.Compare(path, pivot[:min(len(path), len(pivot))]) {
+		case -1:
+			return true // all keys in subtree less than pivot.
+		case 1:
+			pivot = nil
+		default:
+			if len(path) >= len(pivot) {
+				pivot = nil
+			}
+		}
+	}
+	if n.isKey && pivot == nil {
+		if !fn(path, n.value) {
+			return false
+		}
+	}
+	for _, child := range n.children {
+		if !child.ascend(path, pivot, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Descend iterates keys <= pivot in descending order until fn returns false,
+// nil pivot means iterates from the last key.
+// The key passed to fn is only valid until fn returns.
+func (r *Rax) Descend(pivot []byte, fn func(key []byte, value any) bool) {
+	r.root.descend(nil, pivot, fn)
+}
+
+func (n *raxNode) descend(path, pivot []byte, fn func([]byte, any) bool) bool {
+	path = append(path, n.prefix...)
+	exact := false
+	if pivot != nil {
+		switch bytes.Compare(path, pivot[:min(len(path), len(pivot))]) {
+		case 1:
+			return true // all keys in subtree greater than pivot.
+		case -1:
+			pivot = nil
+		default:
+			if len(path) > len(pivot) {
+				return true
+			}
+			exact = len(path) == len(pivot)
+		}
+	}
+	// children keys are greater than pivot when exactly matched.
+	if !exact {
+		for i := len(n.children) - 1; i >= 0; i-- {
+			if !n.children[i].descend(path, pivot, fn) {
+				return false
+			}
+		}
+	}
+	if n.isKey {
+		return fn(path, n.value)
+	}
+	return true
+}
+
+// First returns the minimum key and its value.
+func (r *Rax) First() (key []byte, value any, ok bool) {
+	r.Ascend(nil, func(k []byte, v any) bool {
+		key, value, ok = bytes.Clone(k), v, true
+		return false
+	})
+	return
+}
+
+// Last returns the maximum key and its value.
+func (r *Rax) Last() (key []byte, value any, ok bool) {
+	r.Descend(nil, func(k []byte, v any) bool {
+		key, value, ok = bytes.Clone(k), v, true
+		return false
+	})
+	return
+}
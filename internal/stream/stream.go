@@ -0,0 +1,418 @@
+package stream
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/xgzlucario/rotom/internal/iface"
+	"github.com/xgzlucario/rotom/internal/list"
+)
+
+const (
+	// maxNodeEntries is the max number of entries in a single listpack node.
+	maxNodeEntries = 100
+)
+
+var (
+	_ iface.Encoder = (*Stream)(nil)
+
+	ErrInvalidID = errors.New("ERR Invalid stream ID specified as stream command argument")
+
+	MinID = ID{0, 0}
+	MaxID = ID{math.MaxUint64, math.MaxUint64}
+)
+
+// ID is the stream entry ID, formatted as "<ms>-<seq>".
+type ID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// ParseID parses "<ms>-<seq>" or "<ms>", seq is missingSeq when omitted.
+func ParseID(s string, missingSeq uint64) (id ID, err error) {
+	ms, seq, found := strings.Cut(s, "-")
+	id.Ms, err = strconv.ParseUint(ms, 10, 64)
+	if err != nil {
+		return id, ErrInvalidID
+	}
+	if !found {
+		id.Seq = missingSeq
+		return id, nil
+	}
+	id.Seq, err = strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return id, ErrInvalidID
+	}
+	return id, nil
+}
+
+func (id ID) String() string {
+	b := strconv.AppendUint(make([]byte, 0, 24), id.Ms, 10)
+	b = append(b, '-')
+	b = strconv.AppendUint(b, id.Seq, 10)
+	return string(b)
+}
+
+func (id ID) Compare(other ID) int {
+	switch {
+	case id.Ms < other.Ms:
+		return -1
+	case id.Ms > other.Ms:
+		return 1
+	case id.Seq < other.Seq:
+		return -1
+	case id.Seq > other.Seq:
+		return 1
+	}
+	return 0
+}
+
+func (id ID) Less(other ID) bool { return id.Compare(other) < 0 }
+
+// Incr returns the next ID, ok is false when overflow.
+func (id ID) Incr() (ID, bool) {
+	if id.Seq < math.MaxUint64 {
+		return ID{id.Ms, id.Seq + 1}, true
+	}
+	if id.Ms < math.MaxUint64 {
+		return ID{id.Ms + 1, 0}, true
+	}
+	return id, false
+}
+
+// Decr returns the previous ID, ok is false when overflow.
+func (id ID) Decr() (ID, bool) {
+	if id.Seq > 0 {
+		return ID{id.Ms, id.Seq - 1}, true
+	}
+	if id.Ms > 0 {
+		return ID{id.Ms - 1, math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// key encode ID as big endian bytes so that rax keeps them in order.
+func (id ID) key() []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, id.Ms)
+	binary.BigEndian.PutUint64(b[8:], id.Seq)
+	return b
+}
+
+func idFromKey(b []byte) ID {
+	return ID{binary.BigEndian.Uint64(b), binary.BigEndian.Uint64(b[8:])}
+}
+
+// Entry is a stream entry, Fields stores as [field1, value1, field2, value2...].
+type Entry struct {
+	ID     ID
+	Fields [][]byte
+}
+
+// Stream is the stream data structure of Redis.
+/*
+	Entries are stored in listpack nodes indexed by a radix tree, the key of
+	each node is the ID of its first entry, called master ID.
+
+	+-------- Rax ---------+
+	| master ID0 -> node0  |    node content:
+	| master ID1 -> node1  |    +------------+--------------------+
+	|         ...          |    | ms - master| seq - master | ... |
+	+----------------------+    +------------+--------------------+
+
+	Each entry stores the delta of its ID against the master ID, followed by
+	the number of fields and the field-value pairs.
+*/
+type Stream struct {
+	rax          *Rax // master ID -> *list.ListPack
+	length       uint64
+	lastID       ID
+	maxDeletedID ID
+	entriesAdded uint64
+	groups       map[string]*Group
+}
+
+func New() *Stream {
+	return &Stream{
+		rax:    NewRax(),
+		groups: make(map[string]*Group),
+	}
+}
+
+func (s *Stream) Len() int { return int(s.length) }
+
+func (s *Stream) LastID() ID { return s.lastID }
+
+func (s *Stream) EntriesAdded() uint64 { return s.entriesAdded }
+
+func (s *Stream) MaxDeletedID() ID { return s.maxDeletedID }
+
+// SetLastID set last id of stream, used by XSETID.
+func (s *Stream) SetLastID(id ID) { s.lastID = id }
+
+// NextID returns the auto generated ID for XADD based on time in ms.
+func (s *Stream) NextID(ms uint64) (ID, bool) {
+	if ms > s.lastID.Ms {
+		return ID{ms, 0}, true
+	}
+	return s.lastID.Incr()
+}
+
+// Add appends a new entry to stream, the caller must make sure that id is
+// greater than the last ID.
+func (s *Stream) Add(id ID, fields [][]byte) {
+	var lp *list.ListPack
+	var master ID
+
+	key, node, ok := s.rax.Last()
+	if ok && node.(*list.ListPack).Len() < maxNodeEntries {
+		lp = node.(*list.ListPack)
+		master = idFromKey(key)
+	} else {
+		lp = list.NewListPack()
+		master = id
+		s.rax.Insert(id.key(), lp)
+	}
+	lp.RPush(b2s(encodeEntry(master, id, fields)))
+
+	s.length++
+	s.lastID = id
+	s.entriesAdded++
+}
+
+func encodeEntry(master, id ID, fields [][]byte) []byte {
+	b := make([]byte, 0, 16+len(fields)*8)
+	b = binary.AppendUvarint(b, id.Ms-master.Ms)
+	if id.Ms == master.Ms {
+		b = binary.AppendUvarint(b, id.Seq-master.Seq)
+	} else {
+		b = binary.AppendUvarint(b, id.Seq)
+	}
+	b = binary.AppendUvarint(b, uint64(len(fields)))
+	for _, f := range fields {
+		b = binary.AppendUvarint(b, uint64(len(f)))
+		b = append(b, f...)
+	}
+	return b
+}
+
+// decodeID decodes only the ID of entry.
+func decodeID(master ID, b []byte) (ID, []byte) {
+	msDelta, n := binary.Uvarint(b)
+	b = b[n:]
+	seq, n := binary.Uvarint(b)
+	b = b[n:]
+	if msDelta == 0 {
+		seq += master.Seq
+	}
+	return ID{master.Ms + msDelta, seq}, b
+}
+
+func decodeEntry(master ID, b []byte) Entry {
+	id, b := decodeID(master, b)
+	num, n := binary.Uvarint(b)
+	b = b[n:]
+	fields := make([][]byte, num)
+	for i := range fields {
+		sz, n := binary.Uvarint(b)
+		fields[i] = b[n : n+int(sz)]
+		b = b[n+int(sz):]
+	}
+	return Entry{ID: id, Fields: fields}
+}
+
+// Range iterates entries between start and end (both inclusive) until fn returns false.
+// The fields of entry are only valid before the stream is modified.
+func (s *Stream) Range(start, end ID, rev bool, fn func(Entry) bool) {
+	if start.Compare(end) > 0 {
+		return
+	}
+	if rev {
+		s.rax.Descend(end.key(), func(key []byte, node any) bool {
+			master := idFromKey(key)
+			it := node.(*list.ListPack).Iterator().SeekLast()
+			for !it.IsFirst() {
+				entry := decodeEntry(master, it.Prev())
+				if entry.ID.Compare(end) > 0 {
+					continue
+				}
+				if entry.ID.Less(start) {
+					return false
+				}
+				if !fn(entry) {
+					return false
+				}
+			}
+			return true
+		})
+		return
+	}
+
+	// find the node that may contain start.
+	pivot := start.key()
+	s.rax.Descend(pivot, func(key []byte, _ any) bool {
+		pivot = append(pivot[:0], key...)
+		return false
+	})
+	s.rax.Ascend(pivot, func(key []byte, node any) bool {
+		master := idFromKey(key)
+		it := node.(*list.ListPack).Iterator()
+		for !it.IsLast() {
+			entry := decodeEntry(master, it.Next())
+			if entry.ID.Less(start) {
+				continue
+			}
+			if entry.ID.Compare(end) > 0 {
+				return false
+			}
+			if !fn(entry) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// First returns the first entry ID of stream.
+func (s *Stream) First() (ID, bool) {
+	var first ID
+	var ok bool
+	s.Range(MinID, MaxID, false, func(e Entry) bool {
+		first, ok = e.ID, true
+		return false
+	})
+	return first, ok
+}
+
+// Exist returns whether entry of id exists.
+func (s *Stream) Exist(id ID) bool {
+	var ok bool
+	s.Range(id, id, false, func(Entry) bool {
+		ok = true
+		return false
+	})
+	return ok
+}
+
+// Delete removes the entry of id, returns true if exist.
+func (s *Stream) Delete(id ID) bool {
+	var masterKey []byte
+	var lp *list.ListPack
+	s.rax.Descend(id.key(), func(key []byte, node any) bool {
+		masterKey, lp = append([]byte(nil), key...), node.(*list.ListPack)
+		return false
+	})
+	if lp == nil {
+		return false
+	}
+	master := idFromKey(masterKey)
+	it := lp.Iterator()
+	for !it.IsLast() {
+		entryID, _ := decodeID(master, it.Next())
+		if entryID == id {
+			it.Prev()
+			it.RemoveNext()
+			if lp.Len() == 0 {
+				s.rax.Remove(masterKey)
+			}
+			s.length--
+			if s.maxDeletedID.Less(id) {
+				s.maxDeletedID = id
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// TrimMaxLen evicts the oldest entries until length of stream is at most maxLen,
+// returns the number of evicted entries.
+func (s *Stream) TrimMaxLen(maxLen uint64) int {
+	return s.trim(func(_ ID) bool { return s.length > maxLen })
+}
+
+// TrimMinID evicts entries with IDs lower than minID, returns the number of evicted entries.
+func (s *Stream) TrimMinID(minID ID) int {
+	return s.trim(func(id ID) bool { return id.Less(minID) })
+}
+
+func (s *Stream) trim(shouldEvict func(ID) bool) (count int) {
+	for s.length > 0 {
+		key, node, _ := s.rax.First()
+		master := idFromKey(key)
+		lp := node.(*list.ListPack)
+
+		entry, _ := lp.LPop()
+		id, _ := decodeID(master, s2b(entry))
+		if !shouldEvict(id) {
+			lp.LPush(entry)
+			return
+		}
+		if lp.Len() == 0 {
+			s.rax.Remove(key)
+		}
+		s.length--
+		count++
+		if s.maxDeletedID.Less(id) {
+			s.maxDeletedID = id
+		}
+	}
+	return
+}
+
+func (s *Stream) ReadFrom(rd *iface.Reader) {
+	s.length = rd.ReadUint64()
+	s.lastID = ID{rd.ReadUint64(), rd.ReadUint64()}
+	s.maxDeletedID = ID{rd.ReadUint64(), rd.ReadUint64()}
+	s.entriesAdded = rd.ReadUint64()
+
+	nodes := rd.ReadUint64()
+	for range nodes {
+		key := rd.ReadBytes()
+		lp := list.NewListPack()
+		lp.ReadFrom(rd)
+		s.rax.Insert(key, lp)
+	}
+
+	numGroups := rd.ReadUint64()
+	for range numGroups {
+		g := newGroup(rd.ReadString(), ID{})
+		g.readFrom(rd)
+		s.groups[g.Name] = g
+	}
+}
+
+// WriteTo encode stream to [length, lastID, maxDeletedID, entriesAdded, nodes..., groups...].
+func (s *Stream) WriteTo(w *iface.Writer) {
+	w.WriteUint64(s.length)
+	w.WriteUint64(s.lastID.Ms)
+	w.WriteUint64(s.lastID.Seq)
+	w.WriteUint64(s.maxDeletedID.Ms)
+	w.WriteUint64(s.maxDeletedID.Seq)
+	w.WriteUint64(s.entriesAdded)
+
+	w.WriteUint64(uint64(s.rax.Len()))
+	s.rax.Ascend(nil, func(key []byte, node any) bool {
+		w.WriteBytes(key)
+		node.(*list.ListPack).WriteTo(w)
+		return true
+	})
+
+	w.WriteUint64(uint64(len(s.groups)))
+	for _, g := range s.groups {
+		w.WriteString(g.Name)
+		g.writeTo(w)
+	}
+}
+
+func b2s(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+func s2b(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
@@ -0,0 +1,227 @@
+package tdigest
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+const (
+	DefaultCompression = 100
+)
+
+var (
+	_ iface.Encoder = (*TDigest)(nil)
+)
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// TDigest is a merging t-digest for estimating quantiles of a stream. Values are
+// buffered and merged into centroids, the weight of each centroid is bounded by
+// 4 * total * q * (1 - q) / compression, so that centroids near the tails stay small.
+type TDigest struct {
+	compression float64
+	centroids   []centroid // sorted by mean
+	buffer      []centroid
+	weight      float64 // total weight of centroids and buffer
+	min, max    float64
+}
+
+func New(compression float64) *TDigest {
+	return &TDigest{
+		compression: compression,
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+}
+
+func (t *TDigest) Compression() float64 { return t.compression }
+
+// Weight returns the total weight of added values.
+func (t *TDigest) Weight() float64 { return t.weight }
+
+func (t *TDigest) Min() float64 { return t.min }
+
+func (t *TDigest) Max() float64 { return t.max }
+
+// Add adds value with weight 1.
+func (t *TDigest) Add(value float64) {
+	t.add(centroid{value, 1})
+}
+
+func (t *TDigest) add(c centroid) {
+	t.buffer = append(t.buffer, c)
+	t.weight += c.weight
+	t.min = math.Min(t.min, c.mean)
+	t.max = math.Max(t.max, c.mean)
+	if float64(len(t.buffer)) >= t.compression*5 {
+		t.process()
+	}
+}
+
+// process merges buffered values into centroids.
+func (t *TDigest) process() {
+	if len(t.buffer) == 0 {
+		return
+	}
+	all := append(t.centroids, t.buffer...)
+	slices.SortStableFunc(all, func(a, b centroid) int { return cmp.Compare(a.mean, b.mean) })
+
+	merged := make([]centroid, 0, len(t.centroids))
+	cur := all[0]
+	var weightSoFar float64
+	for _, next := range all[1:] {
+		proposed := cur.weight + next.weight
+		q0 := weightSoFar / t.weight
+		q2 := (weightSoFar + proposed) / t.weight
+		limit := 4 * t.weight * math.Min(q0*(1-q0), q2*(1-q2)) / t.compression
+		if proposed <= limit {
+			cur.mean += (next.mean - cur.mean) * next.weight / proposed
+			cur.weight = proposed
+		} else {
+			weightSoFar += cur.weight
+			merged = append(merged, cur)
+			cur = next
+		}
+	}
+	t.centroids = append(merged, cur)
+	t.buffer = t.buffer[:0]
+}
+
+// merged returns centroids with buffered values merged in, t is unchanged so that
+// reads do not affect the future shape of digest.
+func (t *TDigest) merged() []centroid {
+	if len(t.buffer) == 0 {
+		return t.centroids
+	}
+	c := *t
+	c.centroids = slices.Clone(t.centroids)
+	c.buffer = slices.Clone(t.buffer)
+	c.process()
+	return c.centroids
+}
+
+// Quantile returns the estimated value at quantile q in [0, 1], it returns NaN if
+// digest is empty.
+func (t *TDigest) Quantile(q float64) float64 {
+	cs := t.merged()
+	if len(cs) == 0 {
+		return math.NaN()
+	}
+	if q <= 0 {
+		return t.min
+	}
+	if q >= 1 {
+		return t.max
+	}
+
+	target := q * t.weight
+	first := cs[0]
+	if target < first.weight/2 {
+		if first.weight == 1 {
+			return first.mean
+		}
+		return t.min + (first.mean-t.min)*target/(first.weight/2)
+	}
+
+	var cum float64
+	for i, c := range cs {
+		mid := cum + c.weight/2
+		if i == len(cs)-1 {
+			if c.weight == 1 || target <= mid {
+				return c.mean
+			}
+			return c.mean + (t.max-c.mean)*(target-mid)/(c.weight/2)
+		}
+		next := cs[i+1]
+		nextMid := cum + c.weight + next.weight/2
+		if target <= nextMid {
+			return c.mean + (next.mean-c.mean)*(target-mid)/(nextMid-mid)
+		}
+		cum += c.weight
+	}
+	return t.max
+}
+
+// CDF returns the estimated fraction of values that are less than or equal to
+// value, it returns NaN if digest is empty.
+func (t *TDigest) CDF(value float64) float64 {
+	cs := t.merged()
+	switch {
+	case len(cs) == 0:
+		return math.NaN()
+	case value < t.min:
+		return 0
+	case value >= t.max:
+		return 1
+	}
+
+	prevMean, prevCum := t.min, 0.0
+	var cum float64
+	for _, c := range cs {
+		mid := cum + c.weight/2
+		if value < c.mean {
+			if c.mean == prevMean {
+				return prevCum / t.weight
+			}
+			return (prevCum + (value-prevMean)/(c.mean-prevMean)*(mid-prevCum)) / t.weight
+		}
+		prevMean, prevCum = c.mean, mid
+		cum += c.weight
+	}
+	if t.max == prevMean {
+		return 1
+	}
+	return (prevCum + (value-prevMean)/(t.max-prevMean)*(t.weight-prevCum)) / t.weight
+}
+
+// Merge adds all centroids of sources into t.
+func (t *TDigest) Merge(sources ...*TDigest) {
+	var all []centroid
+	for _, src := range sources {
+		all = append(all, src.merged()...)
+	}
+	for _, c := range all {
+		t.add(c)
+	}
+	t.process()
+}
+
+// Reset removes all values and sets compression.
+func (t *TDigest) Reset(compression float64) {
+	*t = *New(compression)
+}
+
+func (t *TDigest) ReadFrom(rd *iface.Reader) {
+	t.compression = math.Float64frombits(rd.ReadUint64())
+	t.weight = math.Float64frombits(rd.ReadUint64())
+	t.min = math.Float64frombits(rd.ReadUint64())
+	t.max = math.Float64frombits(rd.ReadUint64())
+	if n := rd.ReadUint64(); n > 0 {
+		t.centroids = make([]centroid, n)
+		for i := range t.centroids {
+			t.centroids[i].mean = math.Float64frombits(rd.ReadUint64())
+			t.centroids[i].weight = math.Float64frombits(rd.ReadUint64())
+		}
+	}
+}
+
+// WriteTo encode t-digest to [compression, weight, min, max, centroids...], buffer
+// is merged before encoding.
+func (t *TDigest) WriteTo(w *iface.Writer) {
+	centroids := t.merged()
+	w.WriteUint64(math.Float64bits(t.compression))
+	w.WriteUint64(math.Float64bits(t.weight))
+	w.WriteUint64(math.Float64bits(t.min))
+	w.WriteUint64(math.Float64bits(t.max))
+	w.WriteUint64(uint64(len(centroids)))
+	for _, c := range centroids {
+		w.WriteUint64(math.Float64bits(c.mean))
+		w.WriteUint64(math.Float64bits(c.weight))
+	}
+}
@@ -0,0 +1,65 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+func TestTDigest(t *testing.T) {
+	ast := assert.New(t)
+	td := New(DefaultCompression)
+
+	ast.True(math.IsNaN(td.Quantile(0.5)))
+	ast.True(math.IsNaN(td.CDF(0)))
+
+	for _, i := range rand.Perm(10000) {
+		td.Add(float64(i))
+	}
+	ast.Equal(float64(10000), td.Weight())
+	ast.Equal(float64(0), td.Quantile(0))
+	ast.Equal(float64(9999), td.Quantile(1))
+	for _, q := range []float64{0.001, 0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		ast.InDelta(q*10000, td.Quantile(q), 10000*0.01, "q=%v", q)
+		ast.InDelta(q, td.CDF(q*10000), 0.01, "q=%v", q)
+	}
+	ast.Equal(float64(0), td.CDF(-1))
+	ast.Equal(float64(1), td.CDF(9999))
+	ast.Less(len(td.merged()), DefaultCompression*5)
+
+	// merge
+	td2 := New(DefaultCompression)
+	for i := 10000; i < 20000; i++ {
+		td2.Add(float64(i))
+	}
+	dest := New(DefaultCompression)
+	dest.Merge(td, td2)
+	ast.Equal(float64(20000), dest.Weight())
+	ast.InDelta(10000, dest.Quantile(0.5), 200)
+	ast.Equal(float64(19999), dest.Max())
+
+	// encode
+	w := iface.NewWriter(nil)
+	dest.WriteTo(w)
+	td3 := new(TDigest)
+	td3.ReadFrom(iface.NewReaderFrom(w))
+	ast.Equal(dest.merged(), td3.merged())
+	ast.Equal(dest.Quantile(0.3), td3.Quantile(0.3))
+}
+
+func TestTDigestSmall(t *testing.T) {
+	ast := assert.New(t)
+	td := New(DefaultCompression)
+	for _, v := range []float64{1, 2, 3, 4, 5} {
+		td.Add(v)
+	}
+	ast.Equal(float64(1), td.Quantile(0.1))
+	ast.Equal(float64(3), td.Quantile(0.5))
+	ast.Equal(float64(5), td.Quantile(0.95))
+	ast.Equal(0.5, td.CDF(3))
+	ast.Equal(float64(0), td.CDF(0.5))
+	ast.Equal(float64(1), td.CDF(5))
+}
@@ -0,0 +1,107 @@
+package timeseries
+
+import (
+	"math"
+	"strings"
+)
+
+type Aggregation byte
+
+const (
+	AggAvg Aggregation = iota + 1
+	AggSum
+	AggMin
+	AggMax
+	AggCount
+)
+
+var aggNames = map[string]Aggregation{
+	"avg":   AggAvg,
+	"sum":   AggSum,
+	"min":   AggMin,
+	"max":   AggMax,
+	"count": AggCount,
+}
+
+// ParseAggregation parses aggregation type case-insensitively.
+func ParseAggregation(s string) (Aggregation, bool) {
+	agg, ok := aggNames[strings.ToLower(s)]
+	return agg, ok
+}
+
+func (agg Aggregation) String() string {
+	for name, a := range aggNames {
+		if a == agg {
+			return name
+		}
+	}
+	return ""
+}
+
+// aggregator accumulates samples of a bucket.
+type aggregator struct {
+	agg   Aggregation
+	count int
+	value float64
+}
+
+func (a *aggregator) add(v float64) {
+	switch a.agg {
+	case AggAvg, AggSum:
+		a.value += v
+	case AggMin:
+		if a.count == 0 || v < a.value {
+			a.value = v
+		}
+	case AggMax:
+		if a.count == 0 || v > a.value {
+			a.value = v
+		}
+	}
+	a.count++
+}
+
+func (a *aggregator) result() float64 {
+	switch a.agg {
+	case AggAvg:
+		return a.value / float64(a.count)
+	case AggCount:
+		return float64(a.count)
+	}
+	return a.value
+}
+
+func (a *aggregator) reset() {
+	a.count, a.value = 0, 0
+}
+
+// BucketStart returns the start timestamp of bucket that ts belongs to, buckets
+// are aligned to align.
+func BucketStart(ts, bucket, align int64) int64 {
+	offset := (ts - align) % bucket
+	if offset < 0 {
+		offset += bucket
+	}
+	return ts - offset
+}
+
+// Aggregate groups samples into buckets and calls fn with the aggregated result
+// of each non-empty bucket, samples must be in ascending order.
+func Aggregate(samples []Sample, agg Aggregation, bucket, align int64, fn func(Sample) bool) {
+	a := aggregator{agg: agg}
+	start := int64(math.MinInt64)
+	for _, s := range samples {
+		bs := BucketStart(s.Ts, bucket, align)
+		if a.count > 0 && bs != start {
+			if !fn(Sample{start, a.result()}) {
+				return
+			}
+			a.reset()
+		}
+		start = bs
+		a.add(s.Value)
+	}
+	if a.count > 0 {
+		fn(Sample{start, a.result()})
+	}
+}
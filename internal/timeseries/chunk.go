@@ -0,0 +1,219 @@
+package timeseries
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Sample is a data point of series.
+type Sample struct {
+	Ts    int64 // ms
+	Value float64
+}
+
+// Chunk stores samples in Gorilla compressed format.
+/*
+	Timestamps are encoded as delta-of-delta:
+	+---------------------+------------------+
+	| dod == 0            | '0'              |
+	| dod in [-64,63]     | '10'   + 7 bits  |
+	| dod in [-256,255]   | '110'  + 9 bits  |
+	| dod in [-2048,2047] | '1110' + 12 bits |
+	| otherwise           | '1111' + 64 bits |
+	+---------------------+------------------+
+
+	Values are encoded as xor against the previous one:
+	+------------------------------+----------------------------------------------+
+	| xor == 0                     | '0'                                          |
+	| inside the previous window   | '10' + meaningful bits                       |
+	| otherwise                    | '11' + 5 bits leading + 6 bits length + bits |
+	+------------------------------+----------------------------------------------+
+*/
+type Chunk struct {
+	data  []byte
+	nbits int
+	count int
+
+	firstTs, lastTs int64
+	lastValue       float64
+	lastDelta       int64
+	leading         uint8
+	trailing        uint8
+}
+
+func newChunk() *Chunk {
+	return &Chunk{}
+}
+
+func (c *Chunk) Len() int { return c.count }
+
+// Size returns the compressed size in bytes.
+func (c *Chunk) Size() int { return len(c.data) }
+
+func (c *Chunk) writeBit(bit bool) {
+	if c.nbits%8 == 0 {
+		c.data = append(c.data, 0)
+	}
+	if bit {
+		c.data[c.nbits/8] |= 1 << (7 - c.nbits%8)
+	}
+	c.nbits++
+}
+
+func (c *Chunk) writeBits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		c.writeBit(v>>i&1 == 1)
+	}
+}
+
+// Append adds sample to the end of chunk, the caller must make sure that ts is
+// greater than the last timestamp.
+func (c *Chunk) Append(ts int64, value float64) {
+	if c.count == 0 {
+		c.writeBits(uint64(ts), 64)
+		c.writeBits(math.Float64bits(value), 64)
+		c.firstTs, c.lastTs, c.lastValue = ts, ts, value
+		c.count++
+		return
+	}
+
+	delta := ts - c.lastTs
+	dod := delta - c.lastDelta
+	switch {
+	case dod == 0:
+		c.writeBit(false)
+	case -64 <= dod && dod <= 63:
+		c.writeBits(0b10, 2)
+		c.writeBits(uint64(dod), 7)
+	case -256 <= dod && dod <= 255:
+		c.writeBits(0b110, 3)
+		c.writeBits(uint64(dod), 9)
+	case -2048 <= dod && dod <= 2047:
+		c.writeBits(0b1110, 4)
+		c.writeBits(uint64(dod), 12)
+	default:
+		c.writeBits(0b1111, 4)
+		c.writeBits(uint64(dod), 64)
+	}
+
+	xor := math.Float64bits(value) ^ math.Float64bits(c.lastValue)
+	if xor == 0 {
+		c.writeBit(false)
+	} else {
+		leading := uint8(min(bits.LeadingZeros64(xor), 31))
+		trailing := uint8(bits.TrailingZeros64(xor))
+		if c.count > 1 && c.leading <= leading && c.trailing <= trailing {
+			c.writeBits(0b10, 2)
+			c.writeBits(xor>>c.trailing, 64-int(c.leading)-int(c.trailing))
+		} else {
+			c.leading, c.trailing = leading, trailing
+			meaningful := 64 - int(leading) - int(trailing)
+			c.writeBits(0b11, 2)
+			c.writeBits(uint64(leading), 5)
+			c.writeBits(uint64(meaningful&63), 6) // 64 is encoded as 0
+			c.writeBits(xor>>trailing, meaningful)
+		}
+	}
+
+	c.lastTs, c.lastDelta, c.lastValue = ts, delta, value
+	c.count++
+}
+
+// Iterator returns the iterator of samples in chunk.
+func (c *Chunk) Iterator() *ChunkIterator {
+	return &ChunkIterator{chunk: c}
+}
+
+type ChunkIterator struct {
+	chunk *Chunk
+	pos   int
+	n     int
+
+	ts       int64
+	delta    int64
+	value    uint64
+	leading  uint8
+	trailing uint8
+}
+
+func (it *ChunkIterator) readBit() bool {
+	bit := it.chunk.data[it.pos/8]>>(7-it.pos%8)&1 == 1
+	it.pos++
+	return bit
+}
+
+func (it *ChunkIterator) readBits(n int) (v uint64) {
+	for range n {
+		v <<= 1
+		if it.readBit() {
+			v |= 1
+		}
+	}
+	return v
+}
+
+// signExtend converts the lower n bits of v to signed integer.
+func signExtend(v uint64, n int) int64 {
+	return int64(v<<(64-n)) >> (64 - n)
+}
+
+// Next returns the next sample, ok is false if no more samples.
+func (it *ChunkIterator) Next() (s Sample, ok bool) {
+	if it.n >= it.chunk.count {
+		return s, false
+	}
+	if it.n == 0 {
+		it.ts = int64(it.readBits(64))
+		it.value = it.readBits(64)
+		it.n++
+		return Sample{it.ts, math.Float64frombits(it.value)}, true
+	}
+
+	var dod int64
+	switch {
+	case !it.readBit():
+	case !it.readBit():
+		dod = signExtend(it.readBits(7), 7)
+	case !it.readBit():
+		dod = signExtend(it.readBits(9), 9)
+	case !it.readBit():
+		dod = signExtend(it.readBits(12), 12)
+	default:
+		dod = int64(it.readBits(64))
+	}
+	it.delta += dod
+	it.ts += it.delta
+
+	if it.readBit() {
+		if it.readBit() {
+			it.leading = uint8(it.readBits(5))
+			meaningful := int(it.readBits(6))
+			if meaningful == 0 {
+				meaningful = 64
+			}
+			it.trailing = uint8(64 - int(it.leading) - meaningful)
+		}
+		meaningful := 64 - int(it.leading) - int(it.trailing)
+		it.value ^= it.readBits(meaningful) << it.trailing
+	}
+	it.n++
+	return Sample{it.ts, math.Float64frombits(it.value)}, true
+}
+
+// Samples returns all samples in chunk.
+func (c *Chunk) Samples() []Sample {
+	samples := make([]Sample, 0, c.count)
+	it := c.Iterator()
+	for s, ok := it.Next(); ok; s, ok = it.Next() {
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+func chunkFromSamples(samples []Sample) *Chunk {
+	c := newChunk()
+	for _, s := range samples {
+		c.Append(s.Ts, s.Value)
+	}
+	return c
+}
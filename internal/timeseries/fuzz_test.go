@@ -0,0 +1,191 @@
+package timeseries
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+func TestChunk(t *testing.T) {
+	ast := assert.New(t)
+	c := newChunk()
+
+	var samples []Sample
+	ts := int64(1000)
+	for i := range 10000 {
+		switch i % 4 {
+		case 0:
+			ts += 1000
+		case 1:
+			ts += rand.Int64N(100) + 1
+		case 2:
+			ts += rand.Int64N(5000) + 1
+		case 3:
+			ts += rand.Int64N(math.MaxInt32) + 1
+		}
+		var v float64
+		switch i % 3 {
+		case 0:
+			v = float64(i)
+		case 1:
+			v = rand.NormFloat64()
+		case 2:
+			v = samples[len(samples)-1].Value
+		}
+		c.Append(ts, v)
+		samples = append(samples, Sample{ts, v})
+	}
+	ast.Equal(len(samples), c.Len())
+	ast.Equal(samples, c.Samples())
+	ast.Less(c.Size(), len(samples)*16)
+}
+
+func FuzzChunk(f *testing.F) {
+	f.Fuzz(func(t *testing.T, ts int64, delta uint16, value float64) {
+		c := newChunk()
+		var samples []Sample
+		for i := range 100 {
+			s := Sample{ts + int64(i)*int64(delta+1) + int64(i*i), value * float64(i)}
+			c.Append(s.Ts, s.Value)
+			samples = append(samples, s)
+		}
+		for i, s := range c.Samples() {
+			assert.Equal(t, samples[i].Ts, s.Ts)
+			assert.Equal(t, math.Float64bits(samples[i].Value), math.Float64bits(s.Value))
+		}
+	})
+}
+
+func TestSeries(t *testing.T) {
+	ast := assert.New(t)
+
+	t.Run("add", func(t *testing.T) {
+		s := New(Options{ChunkSize: 128})
+		for i := range 1000 {
+			ast.Nil(s.Add(int64(i*10), float64(i), PolicyNone))
+		}
+		ast.Greater(len(s.chunks), 1)
+		ast.Equal(1000, s.Len())
+
+		// out of order
+		ast.Nil(s.Add(5, 0.5, PolicyNone))
+		ast.Nil(s.Add(-10, -1, PolicyNone))
+		ast.Equal([]Sample{{-10, -1}, {0, 0}, {5, 0.5}, {10, 1}}, s.Range(math.MinInt64, 10))
+		ast.Equal(1002, s.Len())
+
+		// duplicate
+		ast.Equal(ErrDuplicateBlock, s.Add(10, 3, PolicyNone))
+		ast.Nil(s.Add(10, 3, PolicySum))
+		ast.Nil(s.Add(9990, 3, PolicyMin))
+		ast.Nil(s.Add(20, 3, PolicyMax))
+		ast.Nil(s.Add(30, 7, PolicyFirst))
+		ast.Nil(s.Add(40, 7, PolicyLast))
+		ast.Equal([]Sample{{10, 4}, {20, 3}, {30, 3}, {40, 7}}, s.Range(10, 40))
+		ast.Equal([]Sample{{9990, 3}}, s.Range(9990, math.MaxInt64))
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		s := New(Options{Retention: 100, ChunkSize: 64})
+		for i := range 1000 {
+			ast.Nil(s.Add(int64(i), float64(i), PolicyNone))
+		}
+		ast.Equal(ErrTooOld, s.Add(800, 1, PolicyLast))
+		samples := s.Range(0, math.MaxInt64)
+		ast.Equal(101, len(samples))
+		ast.Equal(Sample{899, 899}, samples[0])
+		ast.Less(s.Len(), 1000)
+	})
+
+	t.Run("aggregate", func(t *testing.T) {
+		samples := []Sample{{0, 1}, {5, 3}, {10, 2}, {25, 8}, {29, -1}}
+		aggregate := func(agg Aggregation) (res []Sample) {
+			Aggregate(samples, agg, 10, 0, func(s Sample) bool {
+				res = append(res, s)
+				return true
+			})
+			return
+		}
+		ast.Equal([]Sample{{0, 2}, {10, 2}, {20, 3.5}}, aggregate(AggAvg))
+		ast.Equal([]Sample{{0, 4}, {10, 2}, {20, 7}}, aggregate(AggSum))
+		ast.Equal([]Sample{{0, 1}, {10, 2}, {20, -1}}, aggregate(AggMin))
+		ast.Equal([]Sample{{0, 3}, {10, 2}, {20, 8}}, aggregate(AggMax))
+		ast.Equal([]Sample{{0, 2}, {10, 1}, {20, 2}}, aggregate(AggCount))
+		ast.Equal(int64(-10), BucketStart(-1, 10, 0))
+		ast.Equal(int64(5), BucketStart(12, 10, 5))
+	})
+
+	t.Run("compact", func(t *testing.T) {
+		src, dest := New(Options{}), New(Options{})
+		src.CreateRule("src", "dest", dest, AggSum, 10)
+		ast.Equal("src", dest.SrcKey())
+		ast.NotNil(src.Rule("dest"))
+
+		add := func(ts int64, v float64) {
+			ast.Nil(src.Add(ts, v, PolicyNone))
+			src.Compact(ts, func(r *Rule, s Sample) {
+				ast.Equal("dest", r.DestKey)
+				ast.Nil(dest.Add(s.Ts, s.Value, PolicyLast))
+			})
+		}
+		add(1, 1)
+		add(2, 2)
+		ast.Equal(0, dest.Len())
+		add(15, 3)
+		ast.Equal([]Sample{{0, 3}}, dest.Range(math.MinInt64, math.MaxInt64))
+		add(3, 3)
+		ast.Equal([]Sample{{0, 6}}, dest.Range(math.MinInt64, math.MaxInt64))
+		add(30, 1)
+		ast.Equal([]Sample{{0, 6}, {10, 3}}, dest.Range(math.MinInt64, math.MaxInt64))
+	})
+
+	t.Run("matcher", func(t *testing.T) {
+		s := New(Options{Labels: []Label{{"a", "1"}, {"b", "2"}}})
+		match := func(exprs ...string) bool {
+			var matchers []Matcher
+			for _, expr := range exprs {
+				m, ok := ParseMatcher(expr)
+				ast.True(ok)
+				matchers = append(matchers, m)
+			}
+			return s.Match(matchers)
+		}
+		ast.True(match("a=1"))
+		ast.True(match("a=1", "b!=3"))
+		ast.True(match("a=(1,3)", "c="))
+		ast.True(match("b!="))
+		ast.False(match("a=2"))
+		ast.False(match("a!=(1,2)"))
+		ast.False(match("c!="))
+		ast.False(match("a="))
+
+		_, ok := ParseMatcher("a")
+		ast.False(ok)
+		_, ok = ParseMatcher("!=a")
+		ast.False(ok)
+	})
+
+	t.Run("encode", func(t *testing.T) {
+		s := New(Options{Retention: 1000, ChunkSize: 64, DuplicatePolicy: PolicySum, Labels: []Label{{"a", "1"}}})
+		dest := New(Options{})
+		s.CreateRule("src", "dest", dest, AggMax, 100)
+		for i := range 500 {
+			ast.Nil(s.Add(int64(i*3), rand.Float64(), PolicyNone))
+			s.Compact(int64(i*3), func(*Rule, Sample) {})
+		}
+		w := iface.NewWriter(nil)
+		s.WriteTo(w)
+		s2 := new(Series)
+		s2.ReadFrom(iface.NewReaderFrom(w))
+		ast.Equal(s, s2)
+		ast.Equal(s.Range(0, math.MaxInt64), s2.Range(0, math.MaxInt64))
+
+		w.Reset()
+		dest.WriteTo(w)
+		dest2 := new(Series)
+		dest2.ReadFrom(iface.NewReaderFrom(w))
+		ast.Equal(dest, dest2)
+	})
+}
@@ -0,0 +1,397 @@
+package timeseries
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+const (
+	DefaultChunkSize = 4096
+)
+
+var (
+	_ iface.Encoder = (*Series)(nil)
+
+	ErrDuplicateBlock = errors.New("ERR TSDB: Error at upsert, update is not supported when DUPLICATE_POLICY is set to BLOCK mode")
+	ErrTooOld         = errors.New("ERR TSDB: Timestamp is older than retention")
+)
+
+type DuplicatePolicy byte
+
+const (
+	PolicyNone DuplicatePolicy = iota
+	PolicyBlock
+	PolicyFirst
+	PolicyLast
+	PolicyMin
+	PolicyMax
+	PolicySum
+)
+
+var policyNames = map[string]DuplicatePolicy{
+	"block": PolicyBlock,
+	"first": PolicyFirst,
+	"last":  PolicyLast,
+	"min":   PolicyMin,
+	"max":   PolicyMax,
+	"sum":   PolicySum,
+}
+
+// ParseDuplicatePolicy parses duplicate policy case-insensitively.
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, bool) {
+	policy, ok := policyNames[strings.ToLower(s)]
+	return policy, ok
+}
+
+// resolve returns the value to keep when old sample meets new value.
+func (p DuplicatePolicy) resolve(old, value float64) (float64, error) {
+	switch p {
+	case PolicyFirst:
+		return old, nil
+	case PolicyLast:
+		return value, nil
+	case PolicyMin:
+		return math.Min(old, value), nil
+	case PolicyMax:
+		return math.Max(old, value), nil
+	case PolicySum:
+		return old + value, nil
+	}
+	return 0, ErrDuplicateBlock
+}
+
+type Label struct {
+	Name, Value string
+}
+
+// Rule is a compaction rule that aggregates samples of series into destination key.
+type Rule struct {
+	DestKey string
+	Agg     Aggregation
+	Bucket  int64
+
+	// currentBucket is the start of bucket being aggregated, -1 if none.
+	currentBucket int64
+}
+
+// Options is the options for creating series.
+type Options struct {
+	Retention       int64 // ms, 0 means never expire
+	ChunkSize       int
+	DuplicatePolicy DuplicatePolicy
+	Labels          []Label
+}
+
+// Series is a time series stored in Gorilla compressed chunks.
+type Series struct {
+	chunks          []*Chunk
+	retention       int64
+	chunkSize       int
+	duplicatePolicy DuplicatePolicy
+	labels          []Label
+	rules           []*Rule
+	srcKey          string
+}
+
+func New(opts Options) *Series {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	if opts.DuplicatePolicy == PolicyNone {
+		opts.DuplicatePolicy = PolicyBlock
+	}
+	return &Series{
+		retention:       opts.Retention,
+		chunkSize:       opts.ChunkSize,
+		duplicatePolicy: opts.DuplicatePolicy,
+		labels:          opts.Labels,
+	}
+}
+
+func (s *Series) Retention() int64 { return s.retention }
+
+func (s *Series) Labels() []Label { return s.labels }
+
+func (s *Series) Rules() []*Rule { return s.rules }
+
+// SrcKey returns the source key if series is the destination of a compaction rule.
+func (s *Series) SrcKey() string { return s.srcKey }
+
+// Label returns value of label name.
+func (s *Series) Label(name string) (string, bool) {
+	for _, l := range s.labels {
+		if l.Name == name {
+			return l.Value, true
+		}
+	}
+	return "", false
+}
+
+func (s *Series) Len() (n int) {
+	for _, c := range s.chunks {
+		n += c.Len()
+	}
+	return
+}
+
+// LastTs returns the timestamp of the last sample, ok is false if series is empty.
+func (s *Series) LastTs() (ts int64, ok bool) {
+	if len(s.chunks) == 0 {
+		return 0, false
+	}
+	return s.chunks[len(s.chunks)-1].lastTs, true
+}
+
+// Add inserts sample into series, policy overrides the duplicate policy of series
+// if not PolicyNone. It returns ErrTooOld if ts is out of retention window.
+func (s *Series) Add(ts int64, value float64, policy DuplicatePolicy) error {
+	if policy == PolicyNone {
+		policy = s.duplicatePolicy
+	}
+	lastTs, ok := s.LastTs()
+	if !ok || ts > lastTs {
+		last := s.lastChunk()
+		if last == nil || last.Size() >= s.chunkSize {
+			last = newChunk()
+			s.chunks = append(s.chunks, last)
+		}
+		last.Append(ts, value)
+		s.trim()
+		return nil
+	}
+	if s.retention > 0 && ts < lastTs-s.retention {
+		return ErrTooOld
+	}
+
+	// upsert in the chunk that covers ts.
+	i := sort.Search(len(s.chunks), func(i int) bool { return s.chunks[i].firstTs > ts })
+	i = max(i-1, 0)
+	samples := s.chunks[i].Samples()
+	j, found := slices.BinarySearchFunc(samples, ts, func(s Sample, ts int64) int {
+		switch {
+		case s.Ts < ts:
+			return -1
+		case s.Ts > ts:
+			return 1
+		}
+		return 0
+	})
+	if found {
+		v, err := policy.resolve(samples[j].Value, value)
+		if err != nil {
+			return err
+		}
+		samples[j].Value = v
+	} else {
+		samples = slices.Insert(samples, j, Sample{ts, value})
+	}
+	s.chunks[i] = chunkFromSamples(samples)
+	return nil
+}
+
+func (s *Series) lastChunk() *Chunk {
+	if len(s.chunks) == 0 {
+		return nil
+	}
+	return s.chunks[len(s.chunks)-1]
+}
+
+// minTs returns the minimum timestamp in retention window.
+func (s *Series) minTs() int64 {
+	lastTs, ok := s.LastTs()
+	if !ok || s.retention == 0 {
+		return math.MinInt64
+	}
+	return lastTs - s.retention
+}
+
+// trim removes chunks that are totally out of retention window.
+func (s *Series) trim() {
+	minTs := s.minTs()
+	n := 0
+	for n < len(s.chunks)-1 && s.chunks[n].lastTs < minTs {
+		n++
+	}
+	s.chunks = s.chunks[n:]
+}
+
+// Range returns samples whose timestamp in [from, to].
+func (s *Series) Range(from, to int64) []Sample {
+	from = max(from, s.minTs())
+	var samples []Sample
+	for _, c := range s.chunks {
+		if c.lastTs < from || c.firstTs > to {
+			continue
+		}
+		it := c.Iterator()
+		for sample, ok := it.Next(); ok; sample, ok = it.Next() {
+			if sample.Ts >= from && sample.Ts <= to {
+				samples = append(samples, sample)
+			}
+		}
+	}
+	return samples
+}
+
+// Rule returns the compaction rule of destKey.
+func (s *Series) Rule(destKey string) *Rule {
+	for _, r := range s.rules {
+		if r.DestKey == destKey {
+			return r
+		}
+	}
+	return nil
+}
+
+// CreateRule adds compaction rule from series to dest.
+func (s *Series) CreateRule(srcKey, destKey string, dest *Series, agg Aggregation, bucket int64) {
+	s.rules = append(s.rules, &Rule{DestKey: destKey, Agg: agg, Bucket: bucket, currentBucket: -1})
+	dest.srcKey = srcKey
+}
+
+// Compact updates compaction rules after sample at ts was added, fn is called with
+// aggregated samples which should be upserted into destination of rule.
+func (s *Series) Compact(ts int64, fn func(r *Rule, sample Sample)) {
+	flush := func(r *Rule, start int64) {
+		samples := s.Range(start, start+r.Bucket-1)
+		Aggregate(samples, r.Agg, r.Bucket, 0, func(sample Sample) bool {
+			fn(r, sample)
+			return true
+		})
+	}
+	for _, r := range s.rules {
+		start := BucketStart(ts, r.Bucket, 0)
+		switch {
+		case r.currentBucket == -1:
+			r.currentBucket = start
+		case start > r.currentBucket:
+			flush(r, r.currentBucket)
+			r.currentBucket = start
+		case start < r.currentBucket:
+			flush(r, start)
+		}
+	}
+}
+
+// Match reports whether labels of series satisfy all the matchers.
+func (s *Series) Match(matchers []Matcher) bool {
+	for _, m := range matchers {
+		value, _ := s.Label(m.Name)
+		if slices.Contains(m.Values, value) == m.Negative {
+			return false
+		}
+	}
+	return true
+}
+
+// Matcher is a label filter of TS.MRANGE.
+/*
+	label=value         Values: [value]
+	label!=value        Values: [value], Negative
+	label=              Values: [""]
+	label!=             Values: [""], Negative
+	label=(v1,v2)       Values: [v1, v2]
+	label!=(v1,v2)      Values: [v1, v2], Negative
+*/
+type Matcher struct {
+	Name     string
+	Values   []string
+	Negative bool
+}
+
+// ParseMatcher parses filter expression, ok is false if expression is invalid.
+func ParseMatcher(expr string) (m Matcher, ok bool) {
+	i := strings.IndexByte(expr, '=')
+	if i <= 0 {
+		return m, false
+	}
+	m.Name, expr = expr[:i], expr[i+1:]
+	if strings.HasSuffix(m.Name, "!") {
+		m.Name, m.Negative = m.Name[:len(m.Name)-1], true
+		if m.Name == "" {
+			return m, false
+		}
+	}
+	if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
+		m.Values = strings.Split(expr[1:len(expr)-1], ",")
+	} else {
+		m.Values = []string{expr}
+	}
+	return m, true
+}
+
+// Positive reports whether matcher requires label to have a value.
+func (m Matcher) Positive() bool {
+	return !m.Negative && !slices.Contains(m.Values, "")
+}
+
+// WriteTo encode series to [retention, chunkSize, policy, srcKey, labels, rules, chunks].
+func (s *Series) WriteTo(w *iface.Writer) {
+	w.WriteVarint(int(s.retention))
+	w.WriteVarint(s.chunkSize)
+	w.WriteUint8(uint8(s.duplicatePolicy))
+	w.WriteString(s.srcKey)
+	w.WriteVarint(len(s.labels))
+	for _, l := range s.labels {
+		w.WriteString(l.Name)
+		w.WriteString(l.Value)
+	}
+	w.WriteVarint(len(s.rules))
+	for _, r := range s.rules {
+		w.WriteString(r.DestKey)
+		w.WriteUint8(uint8(r.Agg))
+		w.WriteVarint(int(r.Bucket))
+		w.WriteVarint(int(r.currentBucket))
+	}
+	w.WriteVarint(len(s.chunks))
+	for _, c := range s.chunks {
+		w.WriteBytes(c.data)
+		w.WriteVarint(c.nbits)
+		w.WriteVarint(c.count)
+		w.WriteVarint(int(c.firstTs))
+		w.WriteVarint(int(c.lastTs))
+		w.WriteUint64(math.Float64bits(c.lastValue))
+		w.WriteVarint(int(c.lastDelta))
+		w.WriteUint8(c.leading)
+		w.WriteUint8(c.trailing)
+	}
+}
+
+func (s *Series) ReadFrom(rd *iface.Reader) {
+	s.retention = rd.ReadVarint()
+	s.chunkSize = int(rd.ReadVarint())
+	s.duplicatePolicy = DuplicatePolicy(rd.ReadUint8())
+	s.srcKey = rd.ReadString()
+	if n := rd.ReadVarint(); n > 0 {
+		s.labels = make([]Label, n)
+		for i := range s.labels {
+			s.labels[i] = Label{rd.ReadString(), rd.ReadString()}
+		}
+	}
+	for n := rd.ReadVarint(); n > 0; n-- {
+		s.rules = append(s.rules, &Rule{
+			DestKey:       rd.ReadString(),
+			Agg:           Aggregation(rd.ReadUint8()),
+			Bucket:        rd.ReadVarint(),
+			currentBucket: rd.ReadVarint(),
+		})
+	}
+	for n := rd.ReadVarint(); n > 0; n-- {
+		s.chunks = append(s.chunks, &Chunk{
+			data:      slices.Clone(rd.ReadBytes()),
+			nbits:     int(rd.ReadVarint()),
+			count:     int(rd.ReadVarint()),
+			firstTs:   rd.ReadVarint(),
+			lastTs:    rd.ReadVarint(),
+			lastValue: math.Float64frombits(rd.ReadUint64()),
+			lastDelta: rd.ReadVarint(),
+			leading:   rd.ReadUint8(),
+			trailing:  rd.ReadUint8(),
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package topk
+
+import (
+	"cmp"
+	"math"
+	"math/bits"
+	"slices"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+const (
+	DefaultWidth = 8
+	DefaultDepth = 7
+	DefaultDecay = 0.9
+)
+
+var (
+	_ iface.Encoder = (*TopK)(nil)
+)
+
+// bucket is a counter of HeavyKeeper, it belongs to the item with fingerprint.
+type bucket struct {
+	fp    uint32
+	count uint32
+}
+
+// Item is an item in the top-k list.
+type Item struct {
+	Name  string
+	Count uint32
+}
+
+// TopK tracks the k heaviest items by HeavyKeeper, colliding counters decay with
+// probability decay^count so that light items are evicted from the sketch.
+type TopK struct {
+	k       uint64
+	width   uint64
+	depth   uint64
+	decay   float64
+	buckets []bucket
+	items   []Item // at most k items
+
+	// seed is the state of random generator, it is persisted so that replaying
+	// the same insertions produces the same sketch.
+	seed uint64
+}
+
+// MaxBuckets limits the buckets of sketch, which is 256MB in memory.
+const MaxBuckets = 32 << 20
+
+// ValidDims reports whether a sketch of width and depth can be created, that
+// both are positive and the number of buckets does not exceed MaxBuckets.
+func ValidDims(width, depth uint64) bool {
+	hi, n := bits.Mul64(width, depth)
+	return width > 0 && depth > 0 && hi == 0 && n <= MaxBuckets
+}
+
+// New creates a top-k sketch with depth rows of width buckets, width and depth
+// must be checked by ValidDims.
+func New(k, width, depth uint64, decay float64) *TopK {
+	return &TopK{
+		k:       k,
+		width:   width,
+		depth:   depth,
+		decay:   decay,
+		buckets: make([]bucket, width*depth),
+	}
+}
+
+func (t *TopK) K() uint64 { return t.k }
+
+// random returns a pseudo random float in [0, 1) by splitmix64.
+func (t *TopK) random() float64 {
+	t.seed += 0x9e3779b97f4a7c15
+	z := t.seed
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return float64(z>>11) / (1 << 53)
+}
+
+// Add increases count of item by n, and returns the item expelled from the top-k
+// list if any.
+func (t *TopK) Add(item string, n uint32) (expelled string, ok bool) {
+	hash := xxhash.Sum64String(item)
+	fp := uint32(hash)
+	h1, h2 := hash>>32, hash&math.MaxUint32|1
+
+	var maxCount uint32
+	for row := range t.depth {
+		b := &t.buckets[row*t.width+(h1+row*h2)%t.width]
+		switch {
+		case b.count == 0:
+			b.fp, b.count = fp, n
+		case b.fp == fp:
+			b.count = uint32(min(uint64(b.count)+uint64(n), math.MaxUint32))
+		default:
+			for i := range n {
+				if t.random() < math.Pow(t.decay, float64(b.count)) {
+					b.count--
+					if b.count == 0 {
+						// the remaining increments go to the new owner.
+						b.fp, b.count = fp, n-i
+						break
+					}
+				}
+			}
+			if b.fp != fp {
+				continue
+			}
+		}
+		maxCount = max(maxCount, b.count)
+	}
+
+	// item lost all the buckets.
+	if maxCount == 0 {
+		return "", false
+	}
+	if i := slices.IndexFunc(t.items, func(it Item) bool { return it.Name == item }); i >= 0 {
+		t.items[i].Count = max(t.items[i].Count, maxCount)
+		return "", false
+	}
+	if uint64(len(t.items)) < t.k {
+		t.items = append(t.items, Item{item, maxCount})
+		return "", false
+	}
+	i := t.minIndex()
+	if maxCount > t.items[i].Count {
+		expelled = t.items[i].Name
+		t.items[i] = Item{item, maxCount}
+		return expelled, true
+	}
+	return "", false
+}
+
+func (t *TopK) minIndex() int {
+	i := 0
+	for j, it := range t.items {
+		if it.Count < t.items[i].Count {
+			i = j
+		}
+	}
+	return i
+}
+
+// Query reports whether item is in the top-k list.
+func (t *TopK) Query(item string) bool {
+	return slices.ContainsFunc(t.items, func(it Item) bool { return it.Name == item })
+}
+
+// List returns items in top-k list in descending order of count.
+func (t *TopK) List() []Item {
+	items := slices.Clone(t.items)
+	slices.SortFunc(items, func(a, b Item) int {
+		if a.Count == b.Count {
+			return cmp.Compare(a.Name, b.Name)
+		}
+		return cmp.Compare(b.Count, a.Count)
+	})
+	return items
+}
+
+func (t *TopK) ReadFrom(rd *iface.Reader) {
+	t.k = rd.ReadUint64()
+	t.width = rd.ReadUint64()
+	t.depth = rd.ReadUint64()
+	t.decay = math.Float64frombits(rd.ReadUint64())
+	t.seed = rd.ReadUint64()
+	t.buckets = make([]bucket, t.width*t.depth)
+	for i := range t.buckets {
+		t.buckets[i] = bucket{rd.ReadUint32(), rd.ReadUint32()}
+	}
+	t.items = make([]Item, rd.ReadUint64())
+	for i := range t.items {
+		t.items[i] = Item{rd.ReadString(), rd.ReadUint32()}
+	}
+}
+
+// WriteTo encode top-k to [k, width, depth, decay, seed, buckets..., items...].
+func (t *TopK) WriteTo(w *iface.Writer) {
+	w.WriteUint64(t.k)
+	w.WriteUint64(t.width)
+	w.WriteUint64(t.depth)
+	w.WriteUint64(math.Float64bits(t.decay))
+	w.WriteUint64(t.seed)
+	for _, b := range t.buckets {
+		w.WriteUint32(b.fp)
+		w.WriteUint32(b.count)
+	}
+	w.WriteUint64(uint64(len(t.items)))
+	for _, it := range t.items {
+		w.WriteString(it.Name)
+		w.WriteUint32(it.Count)
+	}
+}
@@ -0,0 +1,53 @@
+package topk
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+func TestTopK(t *testing.T) {
+	ast := assert.New(t)
+	tk := New(5, 50, 5, DefaultDecay)
+
+	// heavy item i occurs i*10 times, and light items occur once.
+	var expelled int
+	for round := range 100 {
+		for i := range 10 {
+			if round < i*10 {
+				if _, ok := tk.Add("heavy"+strconv.Itoa(i), 1); ok {
+					expelled++
+				}
+			}
+		}
+		if _, ok := tk.Add("light"+strconv.Itoa(round), 1); ok {
+			expelled++
+		}
+	}
+	ast.Greater(expelled, 0)
+
+	list := tk.List()
+	ast.Len(list, 5)
+	for i, it := range list {
+		ast.Equal("heavy"+strconv.Itoa(9-i), it.Name)
+		ast.Equal(uint32((9-i)*10), it.Count)
+	}
+	ast.True(tk.Query("heavy9"))
+	ast.False(tk.Query("heavy0"))
+	ast.False(tk.Query("light1"))
+
+	// encode
+	w := iface.NewWriter(nil)
+	tk.WriteTo(w)
+	tk2 := new(TopK)
+	tk2.ReadFrom(iface.NewReaderFrom(w))
+	ast.Equal(tk, tk2)
+
+	// deterministic
+	_, ok1 := tk.Add("light-x", 1)
+	_, ok2 := tk2.Add("light-x", 1)
+	ast.Equal(ok1, ok2)
+	ast.Equal(tk, tk2)
+}
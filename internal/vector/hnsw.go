@@ -0,0 +1,381 @@
+package vector
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/xgzlucario/rotom/internal/iface"
+	"github.com/zyedidia/generic/heap"
+)
+
+const (
+	DefaultM              = 16
+	DefaultEFConstruction = 200
+
+	maxLevel = 16
+)
+
+var (
+	_ iface.Encoder = (*Set)(nil)
+)
+
+type node struct {
+	id    int // index in Set.nodes
+	name  string
+	vec   vec
+	links [][]*node // neighbors of each level
+}
+
+func (n *node) level() int { return len(n.links) - 1 }
+
+// Set is a vector set indexed by HNSW graph for approximate nearest neighbour search.
+type Set struct {
+	dim            int
+	metric         Metric
+	quant          Quant
+	m              int
+	efConstruction int
+
+	nodes []*node
+	index map[string]*node
+	entry *node
+}
+
+// Result is an element of search result.
+type Result struct {
+	Name  string
+	Score float64
+}
+
+// candidate is a node with its distance to the query.
+type candidate struct {
+	node     *node
+	distance float64
+}
+
+func compareCandidate(a, b candidate) int {
+	if a.distance == b.distance {
+		return cmp.Compare(a.node.name, b.node.name)
+	}
+	return cmp.Compare(a.distance, b.distance)
+}
+
+func New(dim int, metric Metric, quant Quant, m, efConstruction int) *Set {
+	return &Set{
+		dim:            dim,
+		metric:         metric,
+		quant:          quant,
+		m:              m,
+		efConstruction: efConstruction,
+		index:          make(map[string]*node),
+	}
+}
+
+func (s *Set) Len() int { return len(s.nodes) }
+
+func (s *Set) Dim() int { return s.dim }
+
+func (s *Set) Metric() Metric { return s.metric }
+
+func (s *Set) Quant() Quant { return s.quant }
+
+// Get returns the approximate vector of element.
+func (s *Set) Get(name string) ([]float32, bool) {
+	n, ok := s.index[name]
+	if !ok {
+		return nil, false
+	}
+	return s.decode(n.vec), true
+}
+
+// maxLinks returns the max number of neighbors of each node in level.
+func (s *Set) maxLinks(level int) int {
+	if level == 0 {
+		return s.m * 2
+	}
+	return s.m
+}
+
+// randomLevel returns the level of element, it is derived from the hash of name so
+// that the graph is the same after replaying the same insertions.
+func (s *Set) randomLevel(name string) int {
+	u := float64(xxhash.Sum64String(name)>>11+1) / (1 << 53)
+	level := int(-math.Log(u) / math.Log(float64(s.m)))
+	return min(level, maxLevel)
+}
+
+// Add inserts element with vector v, the vector is replaced if element exists.
+// It returns true if element is new.
+func (s *Set) Add(name string, v []float32) bool {
+	_, exist := s.index[name]
+	if exist {
+		s.Remove(name)
+	}
+	n := &node{
+		id:    len(s.nodes),
+		name:  name,
+		vec:   s.encode(v),
+		links: make([][]*node, s.randomLevel(name)+1),
+	}
+	s.nodes = append(s.nodes, n)
+	s.index[name] = n
+
+	if s.entry == nil {
+		s.entry = n
+		return !exist
+	}
+	ep := s.entry
+	for level := ep.level(); level > n.level(); level-- {
+		ep = s.greedy(n.vec, ep, level)
+	}
+	for level := min(n.level(), s.entry.level()); level >= 0; level-- {
+		candidates := s.searchLayer(n.vec, ep, s.efConstruction, level)
+		for _, c := range candidates[:min(len(candidates), s.m)] {
+			n.links[level] = append(n.links[level], c.node)
+			c.node.links[level] = append(c.node.links[level], n)
+			if len(c.node.links[level]) > s.maxLinks(level) {
+				s.prune(c.node, level)
+			}
+		}
+		ep = candidates[0].node
+	}
+	if n.level() > s.entry.level() {
+		s.entry = n
+	}
+	return !exist
+}
+
+// prune keeps the closest neighbors of n in level.
+func (s *Set) prune(n *node, level int) {
+	candidates := make([]candidate, len(n.links[level]))
+	for i, nb := range n.links[level] {
+		candidates[i] = candidate{nb, s.distance(n.vec, nb.vec)}
+	}
+	slices.SortFunc(candidates, compareCandidate)
+	n.links[level] = n.links[level][:0]
+	for _, c := range candidates[:s.maxLinks(level)] {
+		n.links[level] = append(n.links[level], c.node)
+	}
+}
+
+// Remove deletes element from set, nodes that linked to it are reconnected to the
+// closest neighbor of the removed one.
+func (s *Set) Remove(name string) bool {
+	n, ok := s.index[name]
+	if !ok {
+		return false
+	}
+	delete(s.index, name)
+	last := s.nodes[len(s.nodes)-1]
+	last.id = n.id
+	s.nodes[n.id] = last
+	s.nodes = s.nodes[:len(s.nodes)-1]
+
+	for _, other := range s.nodes {
+		for level := range min(len(other.links), len(n.links)) {
+			i := slices.Index(other.links[level], n)
+			if i < 0 {
+				continue
+			}
+			other.links[level] = slices.Delete(other.links[level], i, i+1)
+
+			var best *node
+			bestDist := math.Inf(1)
+			for _, nb := range n.links[level] {
+				if nb == other || slices.Contains(other.links[level], nb) {
+					continue
+				}
+				if d := s.distance(other.vec, nb.vec); d < bestDist {
+					best, bestDist = nb, d
+				}
+			}
+			if best != nil {
+				other.links[level] = append(other.links[level], best)
+			}
+		}
+	}
+
+	if s.entry == n {
+		s.entry = nil
+		for _, other := range s.nodes {
+			if s.entry == nil || other.level() > s.entry.level() {
+				s.entry = other
+			}
+		}
+	}
+	return true
+}
+
+// greedy walks to the closest node of q in level starting from ep.
+func (s *Set) greedy(q vec, ep *node, level int) *node {
+	dist := s.distance(q, ep.vec)
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range ep.links[level] {
+			if d := s.distance(q, nb.vec); d < dist {
+				ep, dist, changed = nb, d, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer returns at most ef closest nodes of q in level, sorted by distance.
+func (s *Set) searchLayer(q vec, ep *node, ef int, level int) []candidate {
+	visited := map[*node]struct{}{ep: {}}
+	start := candidate{ep, s.distance(q, ep.vec)}
+	candidates := heap.New(func(a, b candidate) bool { return compareCandidate(a, b) < 0 })
+	results := heap.New(func(a, b candidate) bool { return compareCandidate(a, b) > 0 })
+	candidates.Push(start)
+	results.Push(start)
+
+	for candidates.Size() > 0 {
+		c, _ := candidates.Pop()
+		if farthest, _ := results.Peek(); c.distance > farthest.distance {
+			break
+		}
+		for _, nb := range c.node.links[level] {
+			if _, ok := visited[nb]; ok {
+				continue
+			}
+			visited[nb] = struct{}{}
+			next := candidate{nb, s.distance(q, nb.vec)}
+			farthest, _ := results.Peek()
+			if results.Size() < ef || next.distance < farthest.distance {
+				candidates.Push(next)
+				results.Push(next)
+				if results.Size() > ef {
+					results.Pop()
+				}
+			}
+		}
+	}
+
+	res := make([]candidate, results.Size())
+	for i := len(res) - 1; i >= 0; i-- {
+		res[i], _ = results.Pop()
+	}
+	return res
+}
+
+// Search returns the k nearest elements of v, ef is the size of dynamic candidate
+// list that trades speed for recall.
+func (s *Set) Search(v []float32, k, ef int) []Result {
+	return s.search(s.encode(v), k, ef)
+}
+
+// SearchElement is like Search but uses the vector of element as query.
+func (s *Set) SearchElement(name string, k, ef int) ([]Result, bool) {
+	n, ok := s.index[name]
+	if !ok {
+		return nil, false
+	}
+	return s.search(n.vec, k, ef), true
+}
+
+func (s *Set) search(q vec, k, ef int) []Result {
+	if s.entry == nil || k <= 0 {
+		return nil
+	}
+	ep := s.entry
+	for level := ep.level(); level > 0; level-- {
+		ep = s.greedy(q, ep, level)
+	}
+	candidates := s.searchLayer(q, ep, max(ef, k), 0)
+	res := make([]Result, 0, min(k, len(candidates)))
+	for _, c := range candidates[:min(k, len(candidates))] {
+		res = append(res, Result{c.node.name, s.score(c.distance)})
+	}
+	return res
+}
+
+// WriteTo encode set to [dim, metric, quant, m, ef, nodes..., links..., entry].
+func (s *Set) WriteTo(w *iface.Writer) {
+	w.WriteVarint(s.dim)
+	w.WriteUint8(uint8(s.metric))
+	w.WriteUint8(uint8(s.quant))
+	w.WriteVarint(s.m)
+	w.WriteVarint(s.efConstruction)
+	w.WriteVarint(len(s.nodes))
+	for _, n := range s.nodes {
+		w.WriteString(n.name)
+		w.WriteVarint(n.level())
+		w.WriteUint32(math.Float32bits(n.vec.norm))
+		switch s.quant {
+		case QuantQ8:
+			w.WriteUint32(math.Float32bits(n.vec.scale))
+			for _, q := range n.vec.q8 {
+				w.WriteUint8(uint8(q))
+			}
+		case QuantBin:
+			for _, word := range n.vec.bin {
+				w.WriteUint64(word)
+			}
+		default:
+			for _, f := range n.vec.f32 {
+				w.WriteUint32(math.Float32bits(f))
+			}
+		}
+	}
+	for _, n := range s.nodes {
+		for _, links := range n.links {
+			w.WriteVarint(len(links))
+			for _, nb := range links {
+				w.WriteVarint(nb.id)
+			}
+		}
+	}
+	if s.entry == nil {
+		w.WriteVarint(-1)
+	} else {
+		w.WriteVarint(s.entry.id)
+	}
+}
+
+func (s *Set) ReadFrom(rd *iface.Reader) {
+	s.dim = int(rd.ReadVarint())
+	s.metric = Metric(rd.ReadUint8())
+	s.quant = Quant(rd.ReadUint8())
+	s.m = int(rd.ReadVarint())
+	s.efConstruction = int(rd.ReadVarint())
+	s.nodes = make([]*node, rd.ReadVarint())
+	s.index = make(map[string]*node, len(s.nodes))
+	for i := range s.nodes {
+		n := &node{id: i, name: rd.ReadString()}
+		n.links = make([][]*node, rd.ReadVarint()+1)
+		n.vec.norm = math.Float32frombits(rd.ReadUint32())
+		switch s.quant {
+		case QuantQ8:
+			n.vec.scale = math.Float32frombits(rd.ReadUint32())
+			n.vec.q8 = make([]int8, s.dim)
+			for j := range n.vec.q8 {
+				n.vec.q8[j] = int8(rd.ReadUint8())
+			}
+		case QuantBin:
+			n.vec.bin = make([]uint64, (s.dim+63)/64)
+			for j := range n.vec.bin {
+				n.vec.bin[j] = rd.ReadUint64()
+			}
+		default:
+			n.vec.f32 = make([]float32, s.dim)
+			for j := range n.vec.f32 {
+				n.vec.f32[j] = math.Float32frombits(rd.ReadUint32())
+			}
+		}
+		s.nodes[i] = n
+		s.index[n.name] = n
+	}
+	for _, n := range s.nodes {
+		for level := range n.links {
+			n.links[level] = make([]*node, rd.ReadVarint())
+			for j := range n.links[level] {
+				n.links[level][j] = s.nodes[rd.ReadVarint()]
+			}
+		}
+	}
+	if id := rd.ReadVarint(); id >= 0 {
+		s.entry = s.nodes[id]
+	}
+}
@@ -0,0 +1,174 @@
+package vector
+
+import (
+	"math"
+	"math/bits"
+	"strings"
+)
+
+type Metric byte
+
+const (
+	MetricCosine Metric = iota
+	MetricL2
+)
+
+// ParseMetric parses distance metric case-insensitively.
+func ParseMetric(s string) (Metric, bool) {
+	switch strings.ToLower(s) {
+	case "cosine":
+		return MetricCosine, true
+	case "l2":
+		return MetricL2, true
+	}
+	return 0, false
+}
+
+type Quant byte
+
+const (
+	QuantNone Quant = iota // float32
+	QuantQ8                // int8 with per-vector scale
+	QuantBin               // sign bits
+)
+
+// vec is a vector stored in quantized format.
+/*
+	QuantNone: f32 holds components.
+	QuantQ8:   q8 holds components divided by scale, scale is max(|x|) / 127.
+	QuantBin:  bin holds sign bits, bit set if component > 0.
+
+	With cosine metric, vector is normalized before quantization and norm holds
+	the original norm so that it can be restored.
+*/
+type vec struct {
+	f32   []float32
+	q8    []int8
+	bin   []uint64
+	scale float32
+	norm  float32
+}
+
+// encode quantizes v into vec.
+func (s *Set) encode(v []float32) vec {
+	var x vec
+	if s.metric == MetricCosine {
+		var sum float64
+		for _, f := range v {
+			sum += float64(f) * float64(f)
+		}
+		x.norm = float32(math.Sqrt(sum))
+		if x.norm > 0 {
+			normalized := make([]float32, len(v))
+			for i, f := range v {
+				normalized[i] = f / x.norm
+			}
+			v = normalized
+		}
+	}
+
+	switch s.quant {
+	case QuantQ8:
+		var maxAbs float32
+		for _, f := range v {
+			maxAbs = max(maxAbs, float32(math.Abs(float64(f))))
+		}
+		x.scale = maxAbs / 127
+		x.q8 = make([]int8, len(v))
+		if x.scale > 0 {
+			for i, f := range v {
+				x.q8[i] = int8(math.Round(float64(f / x.scale)))
+			}
+		}
+	case QuantBin:
+		x.bin = make([]uint64, (len(v)+63)/64)
+		for i, f := range v {
+			if f > 0 {
+				x.bin[i/64] |= 1 << (i % 64)
+			}
+		}
+	default:
+		x.f32 = append([]float32(nil), v...)
+	}
+	return x
+}
+
+// decode restores the approximate vector of x.
+func (s *Set) decode(x vec) []float32 {
+	v := make([]float32, s.dim)
+	switch s.quant {
+	case QuantQ8:
+		for i, q := range x.q8 {
+			v[i] = float32(q) * x.scale
+		}
+	case QuantBin:
+		// unit vector with the same signs.
+		f := float32(1 / math.Sqrt(float64(s.dim)))
+		for i := range v {
+			if x.bin[i/64]>>(i%64)&1 == 1 {
+				v[i] = f
+			} else {
+				v[i] = -f
+			}
+		}
+	default:
+		copy(v, x.f32)
+	}
+	if s.metric == MetricCosine {
+		for i := range v {
+			v[i] *= x.norm
+		}
+	}
+	return v
+}
+
+// distance returns the distance between a and b, it is 1 - cosine similarity with
+// cosine metric and squared euclidean distance with L2 metric. Binary quantized
+// vectors use the normalized hamming distance instead.
+func (s *Set) distance(a, b vec) float64 {
+	switch s.quant {
+	case QuantQ8:
+		if s.metric == MetricCosine {
+			var dot int64
+			for i := range a.q8 {
+				dot += int64(a.q8[i]) * int64(b.q8[i])
+			}
+			return max(1-float64(dot)*float64(a.scale)*float64(b.scale), 0)
+		}
+		var sum float64
+		for i := range a.q8 {
+			d := float64(a.q8[i])*float64(a.scale) - float64(b.q8[i])*float64(b.scale)
+			sum += d * d
+		}
+		return sum
+
+	case QuantBin:
+		var diff int
+		for i := range a.bin {
+			diff += bits.OnesCount64(a.bin[i] ^ b.bin[i])
+		}
+		return 2 * float64(diff) / float64(s.dim)
+	}
+
+	if s.metric == MetricCosine {
+		var dot float64
+		for i := range a.f32 {
+			dot += float64(a.f32[i]) * float64(b.f32[i])
+		}
+		return max(1-dot, 0)
+	}
+	var sum float64
+	for i := range a.f32 {
+		d := float64(a.f32[i]) - float64(b.f32[i])
+		sum += d * d
+	}
+	return sum
+}
+
+// score converts distance to similarity score in [0, 1], 1 means identical.
+func (s *Set) score(distance float64) float64 {
+	if s.metric == MetricCosine || s.quant == QuantBin {
+		return max(1-distance/2, 0)
+	}
+	return 1 / (1 + math.Sqrt(distance))
+}
@@ -0,0 +1,132 @@
+package vector
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xgzlucario/rotom/internal/iface"
+)
+
+func randVector(dim int) []float32 {
+	v := make([]float32, dim)
+	for i := range v {
+		v[i] = rand.Float32()*2 - 1
+	}
+	return v
+}
+
+// bruteForce returns the names of k nearest elements of v.
+func bruteForce(s *Set, v []float32, k int) []string {
+	q := s.encode(v)
+	candidates := make([]candidate, 0, len(s.nodes))
+	for _, n := range s.nodes {
+		candidates = append(candidates, candidate{n, s.distance(q, n.vec)})
+	}
+	slices.SortFunc(candidates, compareCandidate)
+	names := make([]string, 0, k)
+	for _, c := range candidates[:k] {
+		names = append(names, c.node.name)
+	}
+	return names
+}
+
+func recall(s *Set, dim, k int) float64 {
+	var hit int
+	for range 50 {
+		v := randVector(dim)
+		expect := bruteForce(s, v, k)
+		for _, r := range s.Search(v, k, 100) {
+			if slices.Contains(expect, r.Name) {
+				hit++
+			}
+		}
+	}
+	return float64(hit) / float64(50*k)
+}
+
+func TestSet(t *testing.T) {
+	ast := assert.New(t)
+	const dim = 16
+
+	for _, metric := range []Metric{MetricCosine, MetricL2} {
+		for _, quant := range []Quant{QuantNone, QuantQ8, QuantBin} {
+			s := New(dim, metric, quant, DefaultM, DefaultEFConstruction)
+			for i := range 1000 {
+				ast.True(s.Add(strconv.Itoa(i), randVector(dim)))
+			}
+			ast.Equal(1000, s.Len())
+			if quant != QuantBin {
+				ast.Greater(recall(s, dim, 10), 0.9)
+			}
+
+			// remove
+			for i := range 500 {
+				ast.True(s.Remove(strconv.Itoa(i * 2)))
+			}
+			ast.False(s.Remove("0"))
+			ast.Equal(500, s.Len())
+			if quant != QuantBin {
+				ast.Greater(recall(s, dim, 10), 0.9)
+			}
+
+			// search element itself
+			res, ok := s.SearchElement("1", 1, 50)
+			ast.True(ok)
+			ast.Equal("1", res[0].Name)
+			// q8 vector is not exactly of unit length after rounding.
+			delta := 0.001
+			if quant == QuantQ8 {
+				delta = 0.01
+			}
+			ast.InDelta(1, res[0].Score, delta)
+			_, ok = s.SearchElement("0", 1, 50)
+			ast.False(ok)
+
+			// encode
+			w := iface.NewWriter(nil)
+			s.WriteTo(w)
+			s2 := new(Set)
+			s2.ReadFrom(iface.NewReaderFrom(w))
+			ast.Equal(s.Len(), s2.Len())
+			for range 10 {
+				v := randVector(dim)
+				ast.Equal(s.Search(v, 10, 50), s2.Search(v, 10, 50))
+			}
+			v1, _ := s.Get("1")
+			v2, _ := s2.Get("1")
+			ast.Equal(v1, v2)
+		}
+	}
+}
+
+func TestQuant(t *testing.T) {
+	ast := assert.New(t)
+	v := []float32{0.5, -1, 2, 0}
+
+	s := New(4, MetricCosine, QuantNone, DefaultM, DefaultEFConstruction)
+	s.Add("a", v)
+	res, _ := s.Get("a")
+	ast.InDeltaSlice(v, res, 1e-6)
+
+	s = New(4, MetricL2, QuantQ8, DefaultM, DefaultEFConstruction)
+	s.Add("a", v)
+	res, _ = s.Get("a")
+	ast.InDeltaSlice(v, res, 0.01)
+
+	s = New(4, MetricCosine, QuantBin, DefaultM, DefaultEFConstruction)
+	s.Add("a", v)
+	s.Add("a", []float32{1, 1, 1, 1})
+	ast.Equal(1, s.Len())
+	res, _ = s.Get("a")
+	ast.InDeltaSlice([]float32{1, 1, 1, 1}, res, 1e-6)
+
+	// empty
+	s = New(4, MetricCosine, QuantNone, DefaultM, DefaultEFConstruction)
+	ast.Nil(s.Search(v, 10, 10))
+	s.Add("a", v)
+	ast.True(s.Remove("a"))
+	ast.Nil(s.Search(v, 10, 10))
+}
@@ -48,6 +48,18 @@ func FuzzTestZSet(f *testing.F) {
 			})
 			ast.Equal(kv1, kv2)
 
+			// Range stops at the half
+			for _, m := range []interface {
+				Range(func(string, float64) bool)
+			}{zs, zzs} {
+				var kv3 []string
+				m.Range(func(k string, v float64) bool {
+					kv3 = append(kv3, fmt.Sprintf("%s->%v", k, v))
+					return len(kv3) >= len(kv1)/2
+				})
+				ast.Equal(kv1[:max(len(kv1)/2, min(len(kv1), 1))], kv3)
+			}
+
 		case 9: // Encode
 			{
 				w := iface.NewWriter(nil)
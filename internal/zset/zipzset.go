@@ -101,11 +101,20 @@ func (zs *ZipZSet) Rank(key string) int {
 }
 
 func (zs *ZipZSet) Scan(fn func(key string, score float64)) {
+	zs.Range(func(key string, score float64) bool {
+		fn(key, score)
+		return false
+	})
+}
+
+// Range iterates members like Scan until fn returns true.
+func (zs *ZipZSet) Range(fn func(key string, score float64) (stop bool)) {
 	it := zs.data.Iterator().SeekLast()
 	for !it.IsFirst() {
 		entry := it.Prev()
-		key, score := zs.decode(entry)
-		fn(key, score)
+		if fn(zs.decode(entry)) {
+			return
+		}
 	}
 }
 
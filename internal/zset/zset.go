@@ -89,9 +89,16 @@ func (z *ZSet) Rank(key string) (index int) {
 }
 
 func (z *ZSet) Scan(fn func(key string, score float64)) {
+	z.Range(func(key string, score float64) bool {
+		fn(key, score)
+		return false
+	})
+}
+
+// Range iterates members like Scan until fn returns true.
+func (z *ZSet) Range(fn func(key string, score float64) (stop bool)) {
 	z.skl.ForEachIf(func(n node, _ struct{}) bool {
-		fn(n.key, n.score)
-		return true
+		return !fn(n.key, n.score)
 	})
 }
 
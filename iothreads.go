@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/xgzlucario/rotom/internal/net"
+)
+
+// parsedCommand is a command parsed by io threads, args refer to queryBuf and n
+// is the bytes of command in queryBuf.
+type parsedCommand struct {
+	args [][]byte
+	n    int
+}
+
+// ioThreadPool reads, parses and writes for clients in parallel like io threads
+// of Redis, the main thread works as one of them. Commands are still executed
+// serially in the event loop, io threads only touch the buffers of clients
+// assigned to them while the main thread waits.
+type ioThreadPool struct {
+	jobs    []chan ioJob
+	wg      sync.WaitGroup
+	buckets [][]*Client
+}
+
+type ioJob struct {
+	clients []*Client
+	fn      func(client *Client)
+}
+
+// newIOThreadPool creates pool of n threads including the main thread.
+func newIOThreadPool(n int) *ioThreadPool {
+	pool := &ioThreadPool{
+		jobs:    make([]chan ioJob, n-1),
+		buckets: make([][]*Client, n),
+	}
+	for i := range pool.jobs {
+		pool.jobs[i] = make(chan ioJob)
+		go pool.worker(pool.jobs[i])
+	}
+	return pool
+}
+
+func (pool *ioThreadPool) worker(jobs chan ioJob) {
+	for job := range jobs {
+		for _, client := range job.clients {
+			job.fn(client)
+		}
+		pool.wg.Done()
+	}
+}
+
+// run calls fn for clients in parallel and waits for all done, and reports
+// whether io threads are used. Few clients are handled by the main thread only,
+// since the cost of dispatching exceeds the gain.
+func (pool *ioThreadPool) run(clients []*Client, fn func(client *Client)) bool {
+	if len(clients) < 2*len(pool.buckets) {
+		for _, client := range clients {
+			fn(client)
+		}
+		return false
+	}
+	for i := range pool.buckets {
+		pool.buckets[i] = pool.buckets[i][:0]
+	}
+	for i, client := range clients {
+		j := i % len(pool.buckets)
+		pool.buckets[j] = append(pool.buckets[j], client)
+	}
+	pool.wg.Add(len(pool.jobs))
+	for i, jobs := range pool.jobs {
+		jobs <- ioJob{clients: pool.buckets[i+1], fn: fn}
+	}
+	for _, client := range pool.buckets[0] {
+		fn(client)
+	}
+	pool.wg.Wait()
+	return true
+}
+
+// clientAlive reports whether client is not freed and not to be freed.
+func clientAlive(client *Client) bool {
+	return server.clientsByID[client.id] == client && !client.closeASAP
+}
+
+// handleClientsWithPendingReads reads and parses queries of clients postponed by
+// ReadQueryFromClient in io threads, and then processes their commands.
+func handleClientsWithPendingReads() {
+	if len(server.clientsPendingRead) == 0 {
+		return
+	}
+	clients := server.clientsPendingRead[:0]
+	for _, client := range server.clientsPendingRead {
+		client.pendingRead = false
+		if clientAlive(client) {
+			clients = append(clients, client)
+		}
+	}
+	server.clientsPendingRead = nil
+
+	threaded := server.ioThreads.run(clients, func(client *Client) {
+		client.ioReadSize, client.ioErr = readQueryFromClient(client)
+		if client.ioErr == nil && client.ioReadSize > 0 && client.blocked == nil {
+			parseQueryBuf(client)
+		}
+	})
+	if threaded {
+		server.statIOReadsProcessed += int64(len(clients))
+	}
+	for _, client := range clients {
+		// client may be freed by commands of others.
+		if !clientAlive(client) || handleReadError(client, client.ioErr) {
+			continue
+		}
+		if client.ioReadSize > 0 {
+			ProcessQueryBuf(client)
+		}
+	}
+}
+
+// sendToClientsInBatch sends the first pending reply of clients by one syscall
+// if supported by poller, ioErr of client is set if failed, and the rest are
+// left to writeToClient. TLS clients are skipped since replies are encrypted.
+func sendToClientsInBatch(clients []*Client) {
+	reqs, batched := server.sendRequests[:0], server.sendClients[:0]
+	for _, client := range clients {
+		client.ioErr = nil
+		if client.tls != nil {
+			continue
+		}
+		if buf := pendingReply(client); len(buf) > 0 {
+			reqs = append(reqs, SendRequest{Fd: client.fd, Buf: buf})
+			batched = append(batched, client)
+		}
+	}
+	// batch of one client costs the same as write(2).
+	if len(reqs) > 1 && server.aeLoop.SendBatch(reqs) {
+		for i, client := range batched {
+			if err := reqs[i].Err; err != nil {
+				client.ioErr = err
+			} else {
+				replySent(client, reqs[i].N)
+			}
+		}
+		server.statBatchedWritesProcessed += int64(len(reqs))
+	}
+	clear(reqs)
+	clear(batched)
+	server.sendRequests, server.sendClients = reqs[:0], batched[:0]
+}
+
+// handleClientsWithPendingWrites writes replies of clients queued by
+// installWriteHandler, by io threads or in batch, the writable event is
+// registered for the ones not fully sent.
+func handleClientsWithPendingWrites() {
+	if len(server.clientsPendingWrite) == 0 {
+		return
+	}
+	clients := server.clientsPendingWrite[:0]
+	for _, client := range server.clientsPendingWrite {
+		client.pendingWrite = false
+		if clientAlive(client) {
+			clients = append(clients, client)
+		}
+	}
+	server.clientsPendingWrite = nil
+
+	if server.ioThreads != nil {
+		threaded := server.ioThreads.run(clients, func(client *Client) {
+			client.ioErr = writeToClient(client)
+		})
+		if threaded {
+			server.statIOWritesProcessed += int64(len(clients))
+		}
+	} else {
+		sendToClientsInBatch(clients)
+		for _, client := range clients {
+			if client.ioErr == nil {
+				client.ioErr = writeToClient(client)
+			}
+		}
+	}
+	for _, client := range clients {
+		switch err := client.ioErr; {
+		case errors.Is(err, net.ErrAgain):
+			server.aeLoop.ModWrite(client.fd, SendReplyToClient, client)
+		case err != nil:
+			log.Error().Msgf("send reply err: %v", err)
+			freeClient(client)
+		case client.closeAfterReply:
+			freeClient(client)
+		}
+	}
+}
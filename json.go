@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/json"
+	"github.com/xgzlucario/rotom/internal/resp"
+)
+
+const (
+	Indent  = "INDENT"
+	Newline = "NEWLINE"
+	Space   = "SPACE"
+	XX      = "XX"
+)
+
+// lookupJSON returns the JSON document of key, doc is nil if key not exist.
+func lookupJSON(key []byte) (*json.Value, error) {
+	object, ttl := db.dict.Get(b2s(key))
+	if ttl == KeyNotExist {
+		return nil, nil
+	}
+	doc, ok := object.(*json.Value)
+	if !ok {
+		return nil, errWrongType
+	}
+	return doc, nil
+}
+
+// parseJSONPath parses path and copies it since keys of path may be stored in document.
+func parseJSONPath(arg redcon.RESP) (*json.Path, error) {
+	return json.ParsePath(arg.String())
+}
+
+func parseJSONValue(arg redcon.RESP) (*json.Value, error) {
+	value, err := json.Parse(arg.String())
+	if err != nil {
+		return nil, fmt.Errorf("ERR %w", err)
+	}
+	return value, nil
+}
+
+func errPathNotExist(path *json.Path) error {
+	return fmt.Errorf("ERR Path '%s' does not exist", path)
+}
+
+func errPathWrongType(expected string, found json.Kind) error {
+	return fmt.Errorf("ERR WRONGTYPE wrong type of path value - expected %s but found %s", expected, found)
+}
+
+func jsonSetCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := args[0].Bytes()
+	path, err := parseJSONPath(args[1])
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	value, err := parseJSONValue(args[2])
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	var nx, xx bool
+	for _, arg := range args[3:] {
+		switch opt := b2s(arg.Bytes()); {
+		case equalFold(opt, NX):
+			nx = true
+		case equalFold(opt, XX):
+			xx = true
+		default:
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+	}
+	if nx && xx {
+		writer.WriteError(errSyntax.Error())
+		return
+	}
+
+	doc, err := lookupJSON(key)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if doc == nil {
+		if !path.IsRoot() {
+			writer.WriteError(errJSONNewAtRoot.Error())
+			return
+		}
+		if xx {
+			writer.WriteNull()
+			return
+		}
+		db.dict.Set(string(key), value)
+		writer.WriteString("OK")
+		return
+	}
+	if !doc.SetPath(path, value, nx, xx) {
+		writer.WriteNull()
+		return
+	}
+	writer.WriteString("OK")
+}
+
+func jsonGetCommand(writer *resp.Writer, args []redcon.RESP) {
+	doc, err := lookupJSON(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+
+	var format *json.Format
+	var paths []*json.Path
+	extra := args[1:]
+	for len(extra) > 0 {
+		arg := b2s(extra[0].Bytes())
+		if len(extra) >= 2 && (equalFold(arg, Indent) || equalFold(arg, Newline) || equalFold(arg, Space)) {
+			if format == nil {
+				format = new(json.Format)
+			}
+			switch strings.ToUpper(arg) {
+			case Indent:
+				format.Indent = extra[1].String()
+			case Newline:
+				format.Newline = extra[1].String()
+			case Space:
+				format.Space = extra[1].String()
+			}
+			extra = extra[2:]
+			continue
+		}
+		path, err := parseJSONPath(extra[0])
+		if err != nil {
+			writer.WriteError(err.Error())
+			return
+		}
+		paths = append(paths, path)
+		extra = extra[1:]
+	}
+	if doc == nil {
+		writer.WriteNull()
+		return
+	}
+	if len(paths) == 0 {
+		root, _ := json.ParsePath(".")
+		paths = append(paths, root)
+	}
+
+	// legacy paths reply single value, JSONPath replies array of all matches.
+	legacy := true
+	for _, path := range paths {
+		legacy = legacy && path.IsLegacy()
+	}
+	result := func(path *json.Path) (*json.Value, error) {
+		matches := path.Select(doc)
+		if legacy {
+			if len(matches) == 0 {
+				return nil, errPathNotExist(path)
+			}
+			return matches[0].Value, nil
+		}
+		arr := json.NewArray()
+		for _, m := range matches {
+			arr.Append(m.Value)
+		}
+		return arr, nil
+	}
+
+	var value *json.Value
+	if len(paths) == 1 {
+		value, err = result(paths[0])
+		if err != nil {
+			writer.WriteError(err.Error())
+			return
+		}
+	} else {
+		value = json.NewObject()
+		for _, path := range paths {
+			v, err := result(path)
+			if err != nil {
+				writer.WriteError(err.Error())
+				return
+			}
+			value.Set(path.String(), v)
+		}
+	}
+	writer.WriteBulk(value.AppendFormat(nil, format))
+}
+
+func jsonDelCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := b2s(args[0].Bytes())
+	doc, err := lookupJSON(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	path, _ := json.ParsePath("$")
+	if len(args) > 1 {
+		if path, err = parseJSONPath(args[1]); err != nil {
+			writer.WriteError(err.Error())
+			return
+		}
+	}
+	if doc == nil {
+		writer.WriteInt(0)
+		return
+	}
+	if path.IsRoot() {
+		db.dict.Delete(key)
+		writer.WriteInt(1)
+		return
+	}
+	writer.WriteInt(doc.DeletePath(path))
+}
+
+func jsonNumIncrByCommand(writer *resp.Writer, args []redcon.RESP) {
+	doc, err := lookupJSON(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	path, err := parseJSONPath(args[1])
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	n, err := parseJSONValue(args[2])
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if !n.IsNumber() {
+		writer.WriteError(errJSONNotNumber.Error())
+		return
+	}
+	if doc == nil {
+		writer.WriteError(errJSONKeyNotExist.Error())
+		return
+	}
+
+	matches := path.Select(doc)
+	if path.IsLegacy() {
+		if len(matches) == 0 {
+			writer.WriteError(errPathNotExist(path).Error())
+			return
+		}
+		for _, m := range matches {
+			if !m.Value.IsNumber() {
+				writer.WriteError(errPathWrongType("a number", m.Value.Kind()).Error())
+				return
+			}
+		}
+	}
+	results := json.NewArray()
+	for _, m := range matches {
+		if m.Value.IsNumber() {
+			m.Value.IncrBy(n)
+			results.Append(m.Value)
+		} else {
+			results.Append(json.NewNull())
+		}
+	}
+	if path.IsLegacy() {
+		writer.WriteBulk(matches[len(matches)-1].Value.AppendJSON(nil))
+		return
+	}
+	writer.WriteBulk(results.AppendJSON(nil))
+}
+
+func jsonArrAppendCommand(writer *resp.Writer, args []redcon.RESP) {
+	doc, err := lookupJSON(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	path, err := parseJSONPath(args[1])
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	values := make([]*json.Value, 0, len(args)-2)
+	for _, arg := range args[2:] {
+		value, err := parseJSONValue(arg)
+		if err != nil {
+			writer.WriteError(err.Error())
+			return
+		}
+		values = append(values, value)
+	}
+	if doc == nil {
+		writer.WriteError(errJSONKeyNotExist.Error())
+		return
+	}
+
+	matches := path.Select(doc)
+	if path.IsLegacy() {
+		if len(matches) == 0 {
+			writer.WriteError(errPathNotExist(path).Error())
+			return
+		}
+		for _, m := range matches {
+			if m.Value.Kind() != json.KindArray {
+				writer.WriteError(errPathWrongType("array", m.Value.Kind()).Error())
+				return
+			}
+		}
+	} else {
+		writer.WriteArray(len(matches))
+	}
+	for _, m := range matches {
+		if m.Value.Kind() != json.KindArray {
+			writer.WriteNull()
+			continue
+		}
+		for _, value := range values {
+			m.Value.Append(value.Clone())
+		}
+		if !path.IsLegacy() {
+			writer.WriteInt(m.Value.Len())
+		}
+	}
+	if path.IsLegacy() {
+		writer.WriteInt(matches[len(matches)-1].Value.Len())
+	}
+}
+
+func jsonTypeCommand(writer *resp.Writer, args []redcon.RESP) {
+	doc, err := lookupJSON(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	path, _ := json.ParsePath(".")
+	if len(args) > 1 {
+		if path, err = parseJSONPath(args[1]); err != nil {
+			writer.WriteError(err.Error())
+			return
+		}
+	}
+	if doc == nil {
+		writer.WriteNull()
+		return
+	}
+	matches := path.Select(doc)
+	if path.IsLegacy() {
+		if len(matches) == 0 {
+			writer.WriteNull()
+		} else {
+			writer.WriteBulkString(matches[0].Value.Kind().String())
+		}
+		return
+	}
+	writer.WriteArray(len(matches))
+	for _, m := range matches {
+		writer.WriteBulkString(m.Value.Kind().String())
+	}
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/resp"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+const defaultLuaTimeLimit = 5000 // ms
+
+// luaEngine is the lua state shared by all scripts, and the script cache indexed
+// by SHA1 digest.
+// Scripts run on a separate goroutine, and commands called by redis.call are sent
+// back to the event loop goroutine, so that database is only accessed by one
+// goroutine and the event loop can serve SCRIPT KILL when a script is busy.
+type luaEngine struct {
+	L       *lua.LState
+	scripts map[string]*lua.FunctionProto
+	calls   chan [][]byte
+	replies chan []byte
+}
+
+// luaScript is the state of the running script.
+type luaScript struct {
+	caller   *Client
+	readOnly bool
+	writer   *resp.Writer
+	cancel   context.CancelFunc
+	// busy is set when script runs longer than time limit.
+	busy bool
+	// wrote is set when script called any write command, it can not be killed
+	// then since the dataset has been modified.
+	wrote  bool
+	killed bool
+	// effects is the write commands called by script, they are written to aof
+	// file instead of the script itself.
+	effects    []byte
+	numEffects int
+}
+
+func newLuaEngine() *luaEngine {
+	e := &luaEngine{
+		L:       lua.NewState(lua.Options{SkipOpenLibs: true}),
+		scripts: make(map[string]*lua.FunctionProto),
+		calls:   make(chan [][]byte),
+		replies: make(chan []byte),
+	}
+	L := e.L
+	for _, lib := range []struct {
+		name string
+		fn   lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.fn))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	// scripts can not access file system.
+	L.SetGlobal("dofile", lua.LNil)
+	L.SetGlobal("loadfile", lua.LNil)
+
+	L.SetGlobal("redis", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"call":         e.luaCall(true),
+		"pcall":        e.luaCall(false),
+		"status_reply": luaStatusReply,
+		"error_reply":  luaErrorReply,
+		"sha1hex":      luaSha1Hex,
+	}))
+	return e
+}
+
+// lookupLuaEngine returns the lua engine of server, and creates it if needed.
+func lookupLuaEngine() *luaEngine {
+	if server.lua == nil {
+		server.lua = newLuaEngine()
+	}
+	return server.lua
+}
+
+func sha1hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadScript compiles script and adds it into cache.
+func (e *luaEngine) loadScript(body string) (string, *lua.FunctionProto, error) {
+	sha := sha1hex(body)
+	if proto, ok := e.scripts[sha]; ok {
+		return sha, proto, nil
+	}
+	chunk, err := parse.Parse(strings.NewReader(body), "@user_script")
+	if err != nil {
+		return "", nil, errScriptCompile(err)
+	}
+	proto, err := lua.Compile(chunk, "@user_script")
+	if err != nil {
+		return "", nil, errScriptCompile(err)
+	}
+	e.scripts[sha] = proto
+	return sha, proto, nil
+}
+
+// luaCall returns redis.call if raise is true, or redis.pcall otherwise. It runs
+// on the script goroutine.
+func (e *luaEngine) luaCall(raise bool) lua.LGFunction {
+	return func(L *lua.LState) int {
+		n := L.GetTop()
+		if n == 0 {
+			L.RaiseError("Please specify at least one argument for this redis lib call")
+		}
+		args := make([][]byte, n)
+		for i := range args {
+			switch v := L.Get(i + 1).(type) {
+			case lua.LString:
+				args[i] = []byte(v)
+			case lua.LNumber:
+				args[i] = []byte(v.String())
+			default:
+				L.RaiseError("Lua redis lib command arguments must be strings or integers")
+			}
+		}
+		e.calls <- args
+		reply := respToLua(L, <-e.replies)
+		if tb, ok := reply.(*lua.LTable); ok && raise && tb.RawGetString("err") != lua.LNil {
+			L.Error(tb, 0)
+		}
+		L.Push(reply)
+		return 1
+	}
+}
+
+func luaStatusReply(L *lua.LState) int {
+	tb := L.NewTable()
+	tb.RawSetString("ok", lua.LString(L.CheckString(1)))
+	L.Push(tb)
+	return 1
+}
+
+func luaErrorReply(L *lua.LState) int {
+	tb := L.NewTable()
+	tb.RawSetString("err", lua.LString(L.CheckString(1)))
+	L.Push(tb)
+	return 1
+}
+
+func luaSha1Hex(L *lua.LState) int {
+	L.Push(lua.LString(sha1hex(L.CheckString(1))))
+	return 1
+}
+
+// respToLua converts reply of command to lua value.
+func respToLua(L *lua.LState, b []byte) lua.LValue {
+	_, r := redcon.ReadNextRESP(b)
+	return respValueToLua(L, r)
+}
+
+func respValueToLua(L *lua.LState, r redcon.RESP) lua.LValue {
+	switch r.Type {
+	case redcon.Integer:
+		return lua.LNumber(r.Int())
+	case redcon.String:
+		tb := L.NewTable()
+		tb.RawSetString("ok", lua.LString(r.Data))
+		return tb
+	case redcon.Error:
+		tb := L.NewTable()
+		tb.RawSetString("err", lua.LString(r.Data))
+		return tb
+	case redcon.Bulk:
+		if r.Data == nil {
+			return lua.LFalse
+		}
+		return lua.LString(r.Data)
+	case redcon.Array:
+		if r.Count < 0 {
+			return lua.LFalse
+		}
+		tb := L.NewTable()
+		r.ForEach(func(item redcon.RESP) bool {
+			tb.Append(respValueToLua(L, item))
+			return true
+		})
+		return tb
+	}
+	return lua.LFalse
+}
+
+// writeLuaReply converts lua value returned by script to reply.
+func writeLuaReply(writer *resp.Writer, v lua.LValue) {
+	switch v := v.(type) {
+	case lua.LNumber:
+		writer.WriteInt64(int64(v))
+	case lua.LString:
+		writer.WriteBulkString(string(v))
+	case lua.LBool:
+		if v {
+			writer.WriteInt(1)
+		} else {
+			writer.WriteNull()
+		}
+	case *lua.LTable:
+		if e, ok := v.RawGetString("err").(lua.LString); ok {
+			writer.WriteError(string(e))
+			return
+		}
+		if s, ok := v.RawGetString("ok").(lua.LString); ok {
+			writer.WriteString(string(s))
+			return
+		}
+		// array stops at the first nil.
+		n := 0
+		for v.RawGetInt(n+1) != lua.LNil {
+			n++
+		}
+		writer.WriteArray(n)
+		for i := 1; i <= n; i++ {
+			writeLuaReply(writer, v.RawGetInt(i))
+		}
+	default:
+		writer.WriteNull()
+	}
+}
+
+// scriptCall executes command called by the running script and returns the reply.
+func scriptCall(s *luaScript, args [][]byte) []byte {
+	writer := s.writer
+	writer.Reset()
+
+	respArgs := make([]redcon.RESP, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		respArgs = append(respArgs, redcon.RESP{Data: arg})
+	}
+	cmd, respArgs, err := resolveCommand(string(args[0]), respArgs)
+	switch {
+	case err != nil:
+		writer.WriteError(err.Error())
+	case cmd.hasFlag("noscript"):
+		writer.WriteError(errNotAllowedInScript.Error())
+	case s.readOnly && cmd.persist:
+		writer.WriteError(errWriteInReadOnlyScript.Error())
+	default:
+		server.current = s.caller
+		cmd.handler(writer, respArgs)
+		if cmd.persist {
+			s.wrote = true
+			signalModifiedCommand(cmd, respArgs, writer.Buffer())
+			if server.propagated {
+				s.effects = append(s.effects, server.propagateBuf...)
+			} else {
+				s.effects = appendCommand(s.effects, cmd.name, respArgs)
+			}
+			s.numEffects++
+		}
+		server.propagated = false
+		server.propagateBuf = server.propagateBuf[:0]
+	}
+	return slices.Clone(writer.Buffer())
+}
+
+// runScript runs fn with keys and argv, and writes the result to writer.
+func runScript(writer *resp.Writer, proto *lua.FunctionProto, keys, argv []redcon.RESP, readOnly bool) {
+	e := lookupLuaEngine()
+	L := e.L
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &luaScript{
+		caller:   server.current,
+		readOnly: readOnly,
+		writer:   resp.NewWriter(),
+		cancel:   cancel,
+	}
+	server.script = s
+	defer func() {
+		server.script = nil
+		server.current = s.caller
+		cancel()
+	}()
+
+	for name, args := range map[string][]redcon.RESP{"KEYS": keys, "ARGV": argv} {
+		tb := L.CreateTable(len(args), 0)
+		for _, arg := range args {
+			tb.Append(lua.LString(arg.Bytes()))
+		}
+		L.SetGlobal(name, tb)
+	}
+	L.SetContext(ctx)
+	L.Push(L.NewFunctionFromProto(proto))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- L.PCall(0, 1, nil)
+	}()
+
+	timeLimit := configGetInt("lua.time-limit")
+	if timeLimit <= 0 {
+		timeLimit = defaultLuaTimeLimit
+	}
+	timer := time.NewTimer(time.Duration(timeLimit) * time.Millisecond)
+	defer timer.Stop()
+
+	var err error
+LOOP:
+	for {
+		select {
+		case args := <-e.calls:
+			e.replies <- scriptCall(s, args)
+		case err = <-done:
+			break LOOP
+		case <-timer.C:
+			// serve SCRIPT KILL while script is busy, the caller is protected from
+			// being processed until script finished.
+			if !s.busy && s.caller != nil {
+				log.Warn().Msgf("script is running over %dms, now busy", timeLimit)
+				s.busy = true
+				server.aeLoop.ModDetach(s.caller.fd)
+			}
+			if s.busy {
+				server.aeLoop.ProcessFileEvents(0)
+			}
+			timer.Reset(10 * time.Millisecond)
+		}
+	}
+	L.RemoveContext()
+	if s.busy {
+		server.aeLoop.AddRead(s.caller.fd, ReadQueryFromClient, s.caller)
+	}
+	propagateEffects(s)
+
+	switch {
+	case s.killed:
+		L.SetTop(0)
+		writer.WriteError(errScriptKilled.Error())
+	case err != nil:
+		L.SetTop(0)
+		if apiErr, ok := err.(*lua.ApiError); ok {
+			if tb, ok := apiErr.Object.(*lua.LTable); ok {
+				writeLuaReply(writer, tb)
+				return
+			}
+			writer.WriteError(errScriptError(apiErr.Object.String()).Error())
+			return
+		}
+		writer.WriteError(errScriptError(err.Error()).Error())
+	default:
+		ret := L.Get(-1)
+		L.SetTop(0)
+		writeLuaReply(writer, ret)
+	}
+}
+
+// propagateEffects replaces the script with its write commands when writing aof
+// file, they are wrapped in MULTI/EXEC to be replayed atomically, unless script
+// is called inside a transaction already.
+func propagateEffects(s *luaScript) {
+	propagate()
+	switch {
+	case s.numEffects == 0:
+	case s.numEffects == 1 || server.inExec:
+		server.propagateBuf = append(server.propagateBuf, s.effects...)
+	default:
+		server.propagateBuf = redcon.AppendArray(server.propagateBuf, 1)
+		server.propagateBuf = redcon.AppendBulkString(server.propagateBuf, "multi")
+		server.propagateBuf = append(server.propagateBuf, s.effects...)
+		server.propagateBuf = redcon.AppendArray(server.propagateBuf, 1)
+		server.propagateBuf = redcon.AppendBulkString(server.propagateBuf, "exec")
+	}
+}
+
+// parseScriptKeys parses numkeys and returns keys and argv.
+func parseScriptKeys(args []redcon.RESP) (keys, argv []redcon.RESP, err error) {
+	numKeys, err := strconv.Atoi(b2s(args[0].Bytes()))
+	if err != nil {
+		return nil, nil, errParseInteger
+	}
+	if numKeys < 0 {
+		return nil, nil, errNegativeNumKeys
+	}
+	if numKeys > len(args)-1 {
+		return nil, nil, errTooManyNumKeys
+	}
+	return args[1 : numKeys+1], args[numKeys+1:], nil
+}
+
+func evalGeneric(writer *resp.Writer, args []redcon.RESP, bySha, readOnly bool) {
+	e := lookupLuaEngine()
+	keys, argv, err := parseScriptKeys(args[1:])
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	var proto *lua.FunctionProto
+	if bySha {
+		var ok bool
+		if proto, ok = e.scripts[strings.ToLower(args[0].String())]; !ok {
+			writer.WriteError(errNoScript.Error())
+			return
+		}
+	} else if _, proto, err = e.loadScript(args[0].String()); err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	runScript(writer, proto, keys, argv, readOnly)
+}
+
+// EVAL script numkeys [key [key ...]] [arg [arg ...]]
+func evalCommand(writer *resp.Writer, args []redcon.RESP) {
+	evalGeneric(writer, args, false, false)
+}
+
+// EVALSHA sha1 numkeys [key [key ...]] [arg [arg ...]]
+func evalShaCommand(writer *resp.Writer, args []redcon.RESP) {
+	evalGeneric(writer, args, true, false)
+}
+
+func evalRoCommand(writer *resp.Writer, args []redcon.RESP) {
+	evalGeneric(writer, args, false, true)
+}
+
+func evalShaRoCommand(writer *resp.Writer, args []redcon.RESP) {
+	evalGeneric(writer, args, true, true)
+}
+
+// SCRIPT LOAD script
+func scriptLoadCommand(writer *resp.Writer, args []redcon.RESP) {
+	sha, _, err := lookupLuaEngine().loadScript(args[0].String())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writer.WriteBulkString(sha)
+}
+
+// SCRIPT EXISTS sha1 [sha1 ...]
+func scriptExistsCommand(writer *resp.Writer, args []redcon.RESP) {
+	e := lookupLuaEngine()
+	writer.WriteArray(len(args))
+	for _, arg := range args {
+		_, ok := e.scripts[strings.ToLower(arg.String())]
+		writer.WriteInt(b2i(ok))
+	}
+}
+
+// SCRIPT FLUSH [ASYNC | SYNC]
+func scriptFlushCommand(writer *resp.Writer, args []redcon.RESP) {
+	if len(args) > 1 {
+		writer.WriteError(errSyntax.Error())
+		return
+	}
+	if server.lua != nil {
+		server.lua.L.Close()
+		server.lua = nil
+	}
+	writer.WriteString("OK")
+}
+
+func scriptKillCommand(writer *resp.Writer, _ []redcon.RESP) {
+	s := server.script
+	switch {
+	case s == nil:
+		writer.WriteError(errNoScriptRunning.Error())
+	case s.wrote:
+		writer.WriteError(errScriptUnkillable.Error())
+	default:
+		s.killed = true
+		s.cancel()
+		writer.WriteString("OK")
+	}
+}
+
+// allowedInBusyScript returns whether command can be executed when a script is busy.
+func allowedInBusyScript(cmd *Command) bool {
+	return cmd.name == "script|kill"
+}
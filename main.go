@@ -5,8 +5,10 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
 	"runtime"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -53,8 +55,18 @@ _  _, _// /_/ / /_ / /_/ /  / / / / /  Build: %s
 
 // RegisterAeLoop register main aeLoop event.
 func RegisterAeLoop(server *Server) {
-	server.aeLoop.AddRead(server.fd, AcceptHandler, nil)
+	for _, fd := range server.fds {
+		server.aeLoop.AddRead(fd, AcceptHandler, nil)
+	}
+	for _, fd := range server.tlsFds {
+		server.aeLoop.AddRead(fd, AcceptTLSHandler, nil)
+	}
+	if server.unixFd >= 0 {
+		server.aeLoop.AddRead(server.unixFd, AcceptUnixHandler, nil)
+	}
+	server.aeLoop.BeforeSleep = BeforeSleep
 	server.aeLoop.AddTimeEvent(AeNormal, 100, CronEvictExpired, nil)
+	server.aeLoop.AddTimeEvent(AeNormal, 1000, CronClients, nil)
 	if configGetAppendOnly() {
 		server.aeLoop.AddTimeEvent(AeNormal, 1000, CronSyncAOF, nil)
 	}
@@ -75,6 +87,15 @@ func main() {
 		go http.ListenAndServe(":6060", nil)
 	}
 
+	// close listeners on shutdown, so that the unix socket file is removed.
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		log.Info().Msgf("received signal %v, shutting down.", <-sig)
+		closeListeners()
+		os.Exit(0)
+	}()
+
 	log.Info().Msg("rotom server is ready to accept.")
 
 	RegisterAeLoop(&server)
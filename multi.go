@@ -0,0 +1,183 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/resp"
+)
+
+// multiState is the state of a client in MULTI, commands are queued and then
+// executed atomically by EXEC.
+type multiState struct {
+	commands []queuedCommand
+	// aborted is set when a command failed to be queued, such as unknown command
+	// or wrong number of arguments, and the next EXEC is discarded.
+	aborted bool
+}
+
+type queuedCommand struct {
+	cmd  *Command
+	args []redcon.RESP
+}
+
+// isMultiCommand returns whether command is executed immediately in MULTI
+// instead of being queued.
+func isMultiCommand(name string) bool {
+	switch name {
+	case "multi", "exec", "discard", "watch", "unwatch", "quit", "reset":
+		return true
+	}
+	return false
+}
+
+// queueMultiCommand queues command of client in MULTI, whose arguments have been
+// checked by resolveCommand.
+func queueMultiCommand(client *Client, cmd *Command, args []redcon.RESP) {
+	qc := queuedCommand{cmd: cmd, args: make([]redcon.RESP, 0, len(args))}
+	// args refer to queryBuf, so copy them.
+	for _, arg := range args {
+		qc.args = append(qc.args, redcon.RESP{Data: slices.Clone(arg.Bytes())})
+	}
+	client.multi.commands = append(client.multi.commands, qc)
+	client.replyWriter.WriteString("QUEUED")
+}
+
+func multiCommand(writer *resp.Writer, _ []redcon.RESP) {
+	client := server.current
+	if client == nil {
+		return
+	}
+	if client.multi != nil {
+		writer.WriteError(errNestedMulti.Error())
+		return
+	}
+	client.multi = &multiState{}
+	writer.WriteString("OK")
+}
+
+func discardCommand(writer *resp.Writer, _ []redcon.RESP) {
+	client := server.current
+	if client == nil {
+		return
+	}
+	if client.multi == nil {
+		writer.WriteError(errDiscardWithoutMulti.Error())
+		return
+	}
+	client.multi = nil
+	unwatchAllKeys(client)
+	writer.WriteString("OK")
+}
+
+// execCommand executes the queued commands of client, the transaction fails if
+// any watched key has been modified since WATCH.
+// Commands are written to aof file as a MULTI/EXEC block in a single write, so
+// that a partial transaction is never replayed.
+func execCommand(writer *resp.Writer, _ []redcon.RESP) {
+	client := server.current
+	if client == nil {
+		return
+	}
+	ms := client.multi
+	if ms == nil {
+		writer.WriteError(errExecWithoutMulti.Error())
+		return
+	}
+	client.multi = nil
+	dirty := client.dirty
+	unwatchAllKeys(client)
+
+	if ms.aborted {
+		writer.WriteError(errExecAbort.Error())
+		return
+	}
+	if dirty {
+		writer.WriteNullArray()
+		return
+	}
+
+	writer.WriteArray(len(ms.commands))
+	server.inExec = true
+	for _, qc := range ms.commands {
+		call(client, qc.cmd, qc.args, nil)
+	}
+	server.inExec = false
+	server.current = client
+
+	if len(server.execBuf) > 0 && configGetAppendOnly() {
+		buf := redcon.AppendArray(nil, 1)
+		buf = redcon.AppendBulkString(buf, "multi")
+		buf = append(buf, server.execBuf...)
+		buf = redcon.AppendArray(buf, 1)
+		buf = redcon.AppendBulkString(buf, "exec")
+		_, _ = db.aof.Write(buf)
+	}
+	server.execBuf = server.execBuf[:0]
+}
+
+// WATCH key [key ...]
+func watchCommand(writer *resp.Writer, args []redcon.RESP) {
+	client := server.current
+	if client == nil {
+		return
+	}
+	if client.multi != nil {
+		writer.WriteError(errWatchInMulti.Error())
+		return
+	}
+	if client.watched == nil {
+		client.watched = make(map[string]struct{})
+	}
+	for _, arg := range args {
+		key := arg.String()
+		if _, ok := client.watched[key]; ok {
+			continue
+		}
+		client.watched[key] = struct{}{}
+		clients := server.watchedKeys[key]
+		if clients == nil {
+			clients = make(map[*Client]struct{})
+			server.watchedKeys[key] = clients
+		}
+		clients[client] = struct{}{}
+	}
+	writer.WriteString("OK")
+}
+
+func unwatchCommand(writer *resp.Writer, _ []redcon.RESP) {
+	if client := server.current; client != nil {
+		unwatchAllKeys(client)
+	}
+	writer.WriteString("OK")
+}
+
+func unwatchAllKeys(client *Client) {
+	for key := range client.watched {
+		clients := server.watchedKeys[key]
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(server.watchedKeys, key)
+		}
+	}
+	client.watched = nil
+	client.dirty = false
+}
+
+// touchWatchedKey marks clients watching key as dirty, so that their next EXEC
+// fails. It is called when key is modified, expired or deleted.
+func touchWatchedKey(key string) {
+	for client := range server.watchedKeys[key] {
+		client.dirty = true
+	}
+}
+
+// touchAllWatchedKeys marks all clients watching any key as dirty, it is called
+// when database is flushed.
+func touchAllWatchedKeys() {
+	for _, clients := range server.watchedKeys {
+		for client := range clients {
+			client.dirty = true
+		}
+	}
+}
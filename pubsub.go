@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/tidwall/match"
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/resp"
+)
+
+// pubsubKind describes a kind of subscription, channels, patterns and shard channels
+// share the same subscribe and unsubscribe logic.
+type pubsubKind struct {
+	subName   string
+	unsubName string
+	message   string
+	// clients returns subscribers of each channel on server.
+	clients func() map[string]map[*Client]struct{}
+	// channels returns channels subscribed by client.
+	channels func(c *Client) *map[string]struct{}
+}
+
+var (
+	pubsubChannel = &pubsubKind{
+		subName:   "subscribe",
+		unsubName: "unsubscribe",
+		message:   "message",
+		clients:   func() map[string]map[*Client]struct{} { return server.pubsubChannels },
+		channels:  func(c *Client) *map[string]struct{} { return &c.channels },
+	}
+	pubsubPattern = &pubsubKind{
+		subName:   "psubscribe",
+		unsubName: "punsubscribe",
+		message:   "pmessage",
+		clients:   func() map[string]map[*Client]struct{} { return server.pubsubPatterns },
+		channels:  func(c *Client) *map[string]struct{} { return &c.patterns },
+	}
+	pubsubShard = &pubsubKind{
+		subName:   "ssubscribe",
+		unsubName: "sunsubscribe",
+		message:   "smessage",
+		clients:   func() map[string]map[*Client]struct{} { return server.pubsubShardChannels },
+		channels:  func(c *Client) *map[string]struct{} { return &c.shardChannels },
+	}
+)
+
+// subscriptionCount returns the count of subscriptions reported in replies, shard
+// channels are counted separately like redis.
+func (kind *pubsubKind) subscriptionCount(client *Client) int {
+	if kind == pubsubShard {
+		return len(client.shardChannels)
+	}
+	return len(client.channels) + len(client.patterns)
+}
+
+// subscribed returns whether client is in subscriber mode.
+func (client *Client) subscribed() bool {
+	return len(client.channels)+len(client.patterns)+len(client.shardChannels) > 0
+}
+
+// allowedInPubSub returns whether command can be executed in subscriber mode.
+func allowedInPubSub(name string) bool {
+	switch name {
+	case "subscribe", "unsubscribe", "psubscribe", "punsubscribe",
+		"ssubscribe", "sunsubscribe", "ping", "quit", "reset":
+		return true
+	}
+	return false
+}
+
+func errNotAllowedInPubSub(name string) error {
+	return fmt.Errorf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", name)
+}
+
+func (kind *pubsubKind) subscribe(client *Client, channel string) {
+	channels := kind.channels(client)
+	if *channels == nil {
+		*channels = make(map[string]struct{})
+	}
+	if _, ok := (*channels)[channel]; !ok {
+		(*channels)[channel] = struct{}{}
+		clients := kind.clients()
+		if clients[channel] == nil {
+			clients[channel] = make(map[*Client]struct{})
+		}
+		clients[channel][client] = struct{}{}
+	}
+	client.replyWriter.WritePush(3)
+	client.replyWriter.WriteBulkString(kind.subName)
+	client.replyWriter.WriteBulkString(channel)
+	client.replyWriter.WriteInt(kind.subscriptionCount(client))
+}
+
+func (kind *pubsubKind) unsubscribe(client *Client, channel string, notify bool) {
+	channels := kind.channels(client)
+	if _, ok := (*channels)[channel]; ok {
+		delete(*channels, channel)
+		clients := kind.clients()
+		delete(clients[channel], client)
+		if len(clients[channel]) == 0 {
+			delete(clients, channel)
+		}
+	}
+	if notify {
+		client.replyWriter.WritePush(3)
+		client.replyWriter.WriteBulkString(kind.unsubName)
+		client.replyWriter.WriteBulkString(channel)
+		client.replyWriter.WriteInt(kind.subscriptionCount(client))
+	}
+}
+
+// unsubscribeAll unsubscribes client from all channels of kind.
+func (kind *pubsubKind) unsubscribeAll(client *Client, notify bool) {
+	channels := kind.channels(client)
+	if len(*channels) == 0 {
+		if notify {
+			client.replyWriter.WritePush(3)
+			client.replyWriter.WriteBulkString(kind.unsubName)
+			client.replyWriter.WriteNull()
+			client.replyWriter.WriteInt(kind.subscriptionCount(client))
+		}
+		return
+	}
+	for _, channel := range slices.Sorted(maps.Keys(*channels)) {
+		kind.unsubscribe(client, channel, notify)
+	}
+}
+
+// pubsubUnsubscribeAllKinds is called when client is freed.
+func pubsubUnsubscribeAllKinds(client *Client) {
+	pubsubChannel.unsubscribeAll(client, false)
+	pubsubPattern.unsubscribeAll(client, false)
+	pubsubShard.unsubscribeAll(client, false)
+}
+
+// publish pushes message to subscribers of channel, and returns the number of
+// clients that received it.
+func (kind *pubsubKind) publish(channel, message string) int {
+	receivers := 0
+	for client := range kind.clients()[channel] {
+		client.replyWriter.WritePush(3)
+		client.replyWriter.WriteBulkString(kind.message)
+		client.replyWriter.WriteBulkString(channel)
+		client.replyWriter.WriteBulkString(message)
+		installWriteHandler(client)
+		receivers++
+	}
+	if kind != pubsubChannel {
+		return receivers
+	}
+	for pattern, clients := range server.pubsubPatterns {
+		if !match.Match(channel, pattern) {
+			continue
+		}
+		for client := range clients {
+			client.replyWriter.WritePush(4)
+			client.replyWriter.WriteBulkString(pubsubPattern.message)
+			client.replyWriter.WriteBulkString(pattern)
+			client.replyWriter.WriteBulkString(channel)
+			client.replyWriter.WriteBulkString(message)
+			installWriteHandler(client)
+			receivers++
+		}
+	}
+	return receivers
+}
+
+func subscribeCommand(writer *resp.Writer, args []redcon.RESP) {
+	subscribeGeneric(writer, pubsubChannel, args)
+}
+
+func psubscribeCommand(writer *resp.Writer, args []redcon.RESP) {
+	subscribeGeneric(writer, pubsubPattern, args)
+}
+
+func ssubscribeCommand(writer *resp.Writer, args []redcon.RESP) {
+	subscribeGeneric(writer, pubsubShard, args)
+}
+
+func subscribeGeneric(writer *resp.Writer, kind *pubsubKind, args []redcon.RESP) {
+	client := server.current
+	for _, arg := range args {
+		kind.subscribe(client, arg.String())
+	}
+}
+
+func unsubscribeCommand(writer *resp.Writer, args []redcon.RESP) {
+	unsubscribeGeneric(writer, pubsubChannel, args)
+}
+
+func punsubscribeCommand(writer *resp.Writer, args []redcon.RESP) {
+	unsubscribeGeneric(writer, pubsubPattern, args)
+}
+
+func sunsubscribeCommand(writer *resp.Writer, args []redcon.RESP) {
+	unsubscribeGeneric(writer, pubsubShard, args)
+}
+
+func unsubscribeGeneric(writer *resp.Writer, kind *pubsubKind, args []redcon.RESP) {
+	client := server.current
+	if len(args) == 0 {
+		kind.unsubscribeAll(client, true)
+		return
+	}
+	for _, arg := range args {
+		kind.unsubscribe(client, b2s(arg.Bytes()), true)
+	}
+}
+
+func publishCommand(writer *resp.Writer, args []redcon.RESP) {
+	writer.WriteInt(pubsubChannel.publish(b2s(args[0].Bytes()), b2s(args[1].Bytes())))
+}
+
+func spublishCommand(writer *resp.Writer, args []redcon.RESP) {
+	writer.WriteInt(pubsubShard.publish(b2s(args[0].Bytes()), b2s(args[1].Bytes())))
+}
+
+// PUBSUB CHANNELS [pattern]
+func pubsubChannelsCommand(writer *resp.Writer, args []redcon.RESP) {
+	if len(args) > 1 {
+		writer.WriteError(errWrongArity("pubsub|channels").Error())
+		return
+	}
+	pubsubListChannels(writer, server.pubsubChannels, args)
+}
+
+// PUBSUB SHARDCHANNELS [pattern]
+func pubsubShardChannelsCommand(writer *resp.Writer, args []redcon.RESP) {
+	if len(args) > 1 {
+		writer.WriteError(errWrongArity("pubsub|shardchannels").Error())
+		return
+	}
+	pubsubListChannels(writer, server.pubsubShardChannels, args)
+}
+
+// PUBSUB NUMSUB [channel [channel ...]]
+func pubsubNumSubCommand(writer *resp.Writer, args []redcon.RESP) {
+	pubsubNumSub(writer, server.pubsubChannels, args)
+}
+
+// PUBSUB SHARDNUMSUB [shardchannel [shardchannel ...]]
+func pubsubShardNumSubCommand(writer *resp.Writer, args []redcon.RESP) {
+	pubsubNumSub(writer, server.pubsubShardChannels, args)
+}
+
+func pubsubNumPatCommand(writer *resp.Writer, _ []redcon.RESP) {
+	writer.WriteInt(len(server.pubsubPatterns))
+}
+
+// pubsubListChannels replies active channels matching the optional pattern.
+func pubsubListChannels(writer *resp.Writer, clients map[string]map[*Client]struct{}, args []redcon.RESP) {
+	channels := make([]string, 0, len(clients))
+	for channel := range clients {
+		if len(args) == 0 || match.Match(channel, b2s(args[0].Bytes())) {
+			channels = append(channels, channel)
+		}
+	}
+	slices.Sort(channels)
+	writer.WriteArray(len(channels))
+	for _, channel := range channels {
+		writer.WriteBulkString(channel)
+	}
+}
+
+// pubsubNumSub replies the number of subscribers of each channel.
+func pubsubNumSub(writer *resp.Writer, clients map[string]map[*Client]struct{}, args []redcon.RESP) {
+	writer.WriteArray(len(args) * 2)
+	for _, arg := range args {
+		channel := b2s(arg.Bytes())
+		writer.WriteBulkString(channel)
+		writer.WriteInt(len(clients[channel]))
+	}
+}
@@ -1,24 +1,35 @@
 package main
 
 import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
 	"github.com/dustin/go-humanize"
 	"github.com/tidwall/redcon"
 	"github.com/xgzlucario/rotom/internal/iface"
 	"github.com/xgzlucario/rotom/internal/list"
 	"github.com/xgzlucario/rotom/internal/net"
 	"github.com/xgzlucario/rotom/internal/resp"
+	"github.com/xgzlucario/rotom/internal/stream"
 )
 
 const (
-	QueryBufSize    = 8 * KB
-	MaxQueryDataLen = 128 * MB
+	QueryBufSize = 8 * KB
 )
 
+var crlf = []byte("\r\n")
+
 type (
-	Map  = iface.MapI
-	Set  = iface.SetI
-	List = *list.QuickList
-	ZSet = iface.ZSetI
+	Map    = iface.MapI
+	Set    = iface.SetI
+	List   = *list.QuickList
+	ZSet   = iface.ZSetI
+	Stream = *stream.Stream
 )
 
 type DB struct {
@@ -28,20 +39,179 @@ type DB struct {
 }
 
 type Client struct {
-	fd          int
-	recvx       int
-	readx       int
-	queryBuf    []byte
+	// id is the unique ID of client, starts from 1.
+	id       uint64
+	fd       int
+	recvx    int
+	readx    int
+	queryBuf []byte
+	// queryBufPeak is the peak of queryBuf usage since last resized by cron.
+	queryBufPeak int
+	// parsed is the commands parsed by io threads, and parsedIdx is the next one
+	// to be processed.
+	parsed    []parsedCommand
+	parsedIdx int
+	// pendingRead and pendingWrite are set when client is queued to be handled
+	// by io threads, and ioReadSize and ioErr are the results.
+	pendingRead  bool
+	pendingWrite bool
+	ioReadSize   int
+	ioErr        error
+	// replyWriter writes replies in the protocol version negotiated by HELLO.
 	replyWriter *resp.Writer
+	// reply is the list of replies not sent yet since socket is not writable,
+	// sentlen is the bytes sent of the first one, and replyBytes is the total
+	// bytes unsent.
+	reply      [][]byte
+	sentlen    int
+	replyBytes int
+	// obufSoftLimitReachedTime is the time in milliseconds when output buffer goes
+	// over the soft limit, 0 means not reached.
+	obufSoftLimitReachedTime int64
+	// name is set by HELLO SETNAME or CLIENT SETNAME.
+	name string
+	// addr and laddr are the remote and local address of connection.
+	addr, laddr string
+	// ctime is the creation time, and lastInteraction is the time of the last
+	// command, both in milliseconds.
+	ctime           int64
+	lastInteraction int64
+	// closeAfterReply is set when client is killed by itself, it is freed after
+	// the pending replies are sent.
+	closeAfterReply bool
+	// closeASAP is set when client is freed asynchronously before the event loop
+	// sleeps, since it may be in use, no more commands are processed or replies
+	// are sent.
+	closeASAP bool
+	// replyOff and replySkipNext are set by CLIENT REPLY, replySkip is set when
+	// executing the command after CLIENT REPLY SKIP. Replies are discarded when
+	// replyOff or replySkip is set.
+	replyOff      bool
+	replySkipNext bool
+	replySkip     bool
 
 	argsBuf [][]byte
 	respBuf []redcon.RESP
+
+	// lastCmd is the last command executed by client.
+	lastCmd *Command
+	// blocked is not nil when client is blocked by commands like XREAD BLOCK.
+	blocked *blockedState
+	// blockDeadline is the unix time(ms) the blocking command times out, 0 means
+	// never. It is kept until the command is served or timed out, so that the
+	// timeout is not extended when it is blocked again.
+	blockDeadline int64
+
+	// channels, patterns and shardChannels are subscribed by client, client
+	// enters subscriber mode when any of them is not empty.
+	channels      map[string]struct{}
+	patterns      map[string]struct{}
+	shardChannels map[string]struct{}
+
+	// multi is not nil when client is in MULTI, commands are queued until EXEC.
+	multi *multiState
+	// watched is the keys watched by client, dirty is set when any of them is
+	// modified, and the next EXEC fails.
+	watched map[string]struct{}
+	dirty   bool
+
+	// tracking is not nil when client side caching is enabled by CLIENT TRACKING.
+	tracking *trackingState
+
+	// tls is not nil when client is connected to the TLS listener.
+	tls *tlsConn
 }
 
 type Server struct {
-	fd      int
+	// fds and tlsFds are the tcp and TLS listeners of bind addresses, and unixFd
+	// is the unix socket listener, -1 if not enabled.
+	fds    []int
+	tlsFds []int
+	unixFd int
+	// tlsConfig is the config of TLS listeners.
+	tlsConfig *tls.Config
+
 	aeLoop  *AeLoop
 	clients map[int]*Client
+	// clientsByID indexes clients by ID, nextClientID is the last assigned ID.
+	clientsByID  map[uint64]*Client
+	nextClientID uint64
+	// clientsToClose is the clients freed asynchronously.
+	clientsToClose []*Client
+
+	// clientsPendingWrite are written before the event loop sleeps, by ioThreads
+	// if io threads are enabled, or in batch of sendRequests for sendClients.
+	// clientsPendingRead are read by io threads if ioThreadsDoReads is set.
+	ioThreads           *ioThreadPool
+	ioThreadsDoReads    bool
+	clientsPendingRead  []*Client
+	clientsPendingWrite []*Client
+	sendRequests        []SendRequest
+	sendClients         []*Client
+
+	// queryBufferLimit and protoMaxBulkLen limit the query of client, and
+	// outputBufferLimits limits the replies of each client class.
+	queryBufferLimit   int
+	protoMaxBulkLen    int
+	outputBufferLimits [numClientClasses]outputBufferLimit
+
+	// statNumConnections is the number of connections accepted, and the others
+	// are the number of clients disconnected for reaching limits.
+	statNumConnections               int64
+	statQueryBufLimitDisconnections  int64
+	statOutputBufLimitDisconnections int64
+	// statIOReadsProcessed and statIOWritesProcessed are the number of reads and
+	// writes handled by io threads.
+	statIOReadsProcessed  int64
+	statIOWritesProcessed int64
+	// statBatchedWritesProcessed is the number of writes sent in batch.
+	statBatchedWritesProcessed int64
+
+	// pause is set by CLIENT PAUSE until pauseTimer fires, commands paused are
+	// postponed and pausedClients are resumed before the event loop sleeps.
+	pause         pauseType
+	pauseTimer    int
+	pausedClients map[*Client]struct{}
+
+	// current is the client whose command is being executed, nil when loading aof.
+	current *Client
+
+	// blockingKeys is the clients blocked on each key.
+	blockingKeys map[string][]*Client
+	// readyKeys is the keys that blocked clients should be served.
+	readyKeys []string
+
+	// pubsubChannels, pubsubPatterns and pubsubShardChannels are the subscribers
+	// of each channel or pattern.
+	pubsubChannels      map[string]map[*Client]struct{}
+	pubsubPatterns      map[string]map[*Client]struct{}
+	pubsubShardChannels map[string]map[*Client]struct{}
+
+	// watchedKeys is the clients watching each key.
+	watchedKeys map[string]map[*Client]struct{}
+	// inExec is set when executing a transaction, commands are buffered into
+	// execBuf and written to aof file together.
+	inExec  bool
+	execBuf []byte
+
+	// lua is the lua engine created by the first script, and script is the
+	// running script, nil if no script is running.
+	lua    *luaEngine
+	script *luaScript
+
+	// trackingTable is the IDs of clients which may have cached each key, and
+	// trackingPrefixes is the BCAST clients of each prefix. trackingClients is
+	// the clients with tracking enabled, and trackingPending is the clients with
+	// invalidations to be sent before the event loop sleeps.
+	trackingTable    map[string]map[uint64]struct{}
+	trackingPrefixes map[string]map[*Client]struct{}
+	trackingClients  map[*Client]struct{}
+	trackingPending  []*Client
+
+	// propagated is set when current command rewrites what to persist into
+	// propagateBuf by propagate(), instead of persisting itself as received.
+	propagated   bool
+	propagateBuf []byte
 }
 
 var (
@@ -69,72 +239,226 @@ func InitDB() (err error) {
 
 		// Load the initial data into memory by processing each stored command.
 		emptyWriter := resp.NewWriter()
-		return db.aof.Read(func(args []redcon.RESP) {
+		process := func(args []redcon.RESP) {
 			command := b2s(args[0].Bytes())
-			cmd, err := lookupCommand(command)
+			cmd, args, err := resolveCommand(command, args[1:])
 			if err == nil {
-				cmd.process(emptyWriter, args[1:])
+				cmd.handler(emptyWriter, args)
 				emptyWriter.Reset()
+				server.propagated = false
+				server.propagateBuf = server.propagateBuf[:0]
+			}
+		}
+		// commands between MULTI and EXEC are replayed only when EXEC is read, a
+		// transaction truncated at the end of file is dropped.
+		var multi [][]redcon.RESP
+		var inMulti bool
+		return db.aof.Read(func(args []redcon.RESP) {
+			switch command := b2s(args[0].Bytes()); {
+			case equalFold(command, "multi"):
+				inMulti, multi = true, multi[:0]
+			case equalFold(command, "exec"):
+				for _, args := range multi {
+					process(args)
+				}
+				inMulti, multi = false, multi[:0]
+			case inMulti:
+				// args is reused by reader, so clone it.
+				multi = append(multi, slices.Clone(args))
+			default:
+				process(args)
 			}
 		})
 	}
 	return nil
 }
 
-// AcceptHandler is the main file event of aeloop.
+// AcceptHandler is the main file event of aeloop, it accepts connections until
+// none is pending, since the poller may be edge-triggered.
 func AcceptHandler(loop *AeLoop, fd int, _ interface{}) {
+	for {
+		cfd, ok := acceptConn(fd)
+		if !ok {
+			return
+		}
+		tuneTcpConn(cfd)
+		addr, _ := net.PeerName(cfd)
+		laddr, _ := net.SockName(cfd)
+		createClient(loop, cfd, addr, laddr)
+	}
+}
+
+// AcceptUnixHandler accepts connections of unix socket, whose peer is unnamed,
+// so both addresses are shown as the socket path.
+func AcceptUnixHandler(loop *AeLoop, fd int, _ interface{}) {
+	addr := configGetUnixSocket() + ":0"
+	for {
+		cfd, ok := acceptConn(fd)
+		if !ok {
+			return
+		}
+		createClient(loop, cfd, addr, addr)
+	}
+}
+
+func acceptConn(fd int) (int, bool) {
 	cfd, err := net.Accept(fd)
+	if errors.Is(err, net.ErrAgain) {
+		return -1, false
+	}
 	if err != nil {
 		log.Error().Msgf("accept err: %v", err)
-		return
+		return -1, false
+	}
+	if err = net.SetNonblock(cfd); err != nil {
+		log.Error().Msgf("set nonblock err: %v", err)
+		_ = net.Close(cfd)
+		return -1, false
 	}
 	log.Info().Msgf("accept new client fd: %d", cfd)
+	return cfd, true
+}
 
+// tuneTcpConn sets TCP_NODELAY and keepalive of accepted tcp connection.
+func tuneTcpConn(cfd int) {
+	if err := net.SetNoDelay(cfd); err != nil {
+		log.Warn().Msgf("set tcp nodelay err: %v", err)
+	}
+	if interval := configGetKeepAlive(); interval > 0 {
+		if err := net.SetKeepAlive(cfd, interval); err != nil {
+			log.Warn().Msgf("set tcp keepalive err: %v", err)
+		}
+	}
+}
+
+func createClient(loop *AeLoop, cfd int, addr, laddr string) *Client {
+	server.statNumConnections++
+	server.nextClientID++
+	now := nowMs()
 	client := &Client{
-		fd:          cfd,
-		replyWriter: resp.NewWriter(),
-		queryBuf:    make([]byte, QueryBufSize),
-		argsBuf:     make([][]byte, 8),
-		respBuf:     make([]redcon.RESP, 8),
+		id:              server.nextClientID,
+		addr:            addr,
+		laddr:           laddr,
+		ctime:           now,
+		lastInteraction: now,
+		fd:              cfd,
+		replyWriter:     resp.NewWriter(),
+		queryBuf:        make([]byte, QueryBufSize),
+		argsBuf:         make([][]byte, 8),
+		respBuf:         make([]redcon.RESP, 8),
 	}
 	server.clients[cfd] = client
+	server.clientsByID[client.id] = client
 	loop.AddRead(cfd, ReadQueryFromClient, client)
+	return client
 }
 
 func ReadQueryFromClient(_ *AeLoop, fd int, extra interface{}) {
 	client := extra.(*Client)
-	readSize := 0
-
-READ:
-	n, err := net.Read(fd, client.queryBuf[client.recvx:])
-	if err != nil {
-		log.Error().Msgf("client %v read err: %v", fd, err)
-		freeClient(client)
+	if client.closeASAP {
 		return
 	}
-	readSize += n
-	client.recvx += n
-
-	if readSize == 0 {
-		freeClient(client)
+	// reading is postponed to io threads before the event loop sleeps, except
+	// when serving clients inside a busy script.
+	if server.ioThreadsDoReads && server.script == nil {
+		if !client.pendingRead {
+			client.pendingRead = true
+			server.clientsPendingRead = append(server.clientsPendingRead, client)
+		}
 		return
 	}
-
-	if client.recvx >= MaxQueryDataLen {
-		log.Error().Msgf("client %d read query data too large, now free", fd)
-		freeClient(client)
+	readSize, err := readQueryFromClient(client)
+	if handleReadError(client, err) {
 		return
 	}
+	if readSize > 0 {
+		ProcessQueryBuf(client)
+	}
+}
 
-	// queryBuf need grow up
-	if client.recvx == len(client.queryBuf) {
+var (
+	errClientClosed  = errors.New("connection closed by peer")
+	errQueryBufLimit = errors.New("query buffer limit reached")
+)
+
+// readQueryFromClient reads query of client into queryBuf until no data is
+// available. It may be called by io threads, so only the query buffer of client
+// is touched, and errors are handled by handleReadError in the event loop.
+func readQueryFromClient(client *Client) (readSize int, err error) {
+	for {
+		n, err := connRead(client, client.queryBuf[client.recvx:])
+		if errors.Is(err, net.ErrAgain) {
+			return readSize, nil
+		}
+		if err != nil {
+			return readSize, err
+		}
+		if n == 0 {
+			return readSize, errClientClosed
+		}
+		readSize += n
+		client.recvx += n
+		client.queryBufPeak = max(client.queryBufPeak, client.recvx)
+
+		if limit := server.queryBufferLimit; limit > 0 && client.recvx-client.readx >= limit {
+			return readSize, errQueryBufLimit
+		}
+		if client.recvx < len(client.queryBuf) {
+			// TLS connection returns one record at a time, so it is read until no
+			// data available.
+			if client.tls == nil {
+				return readSize, nil
+			}
+			continue
+		}
+		// move the unprocessed query to the front, args of blocked client refer to
+		// queryBuf, so it is left as is.
+		if client.readx > 0 && client.blocked == nil {
+			client.recvx = copy(client.queryBuf, client.queryBuf[client.readx:client.recvx])
+			client.readx = 0
+			continue
+		}
+		// queryBuf need grow up
 		client.queryBuf = append(client.queryBuf, make([]byte, client.recvx)...)
 		sz := uint64(len(client.queryBuf))
-		log.Warn().Msgf("client %d queryBuf grow up to size %s", fd, humanize.Bytes(sz))
-		goto READ
+		log.Warn().Msgf("client %d queryBuf grow up to size %s", client.fd, humanize.Bytes(sz))
 	}
+}
 
-	ProcessQueryBuf(client)
+// handleReadError frees client if err is not nil, and reports whether it is freed.
+func handleReadError(client *Client, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, errClientClosed):
+	case errors.Is(err, errQueryBufLimit):
+		log.Warn().Msgf("client %d closed for reaching query buffer limit %s",
+			client.fd, humanize.IBytes(uint64(server.queryBufferLimit)))
+		server.statQueryBufLimitDisconnections++
+	default:
+		log.Error().Msgf("client %v read err: %v", client.fd, err)
+	}
+	freeClient(client)
+	return true
+}
+
+// resizeQueryBuffer shrinks queryBuf grown temporarily, when client is idle or
+// the peak usage since last resized is less than half of it.
+func resizeQueryBuffer(client *Client) {
+	size := len(client.queryBuf)
+	pending := client.recvx - client.readx
+	idle := nowMs()-client.lastInteraction > 2000
+	if size > QueryBufSize && client.blocked == nil && (idle || client.queryBufPeak < size/2) {
+		newSize := max(QueryBufSize, pending)
+		if !idle {
+			newSize = max(newSize, client.queryBufPeak)
+		}
+		buf := make([]byte, newSize)
+		client.recvx = copy(buf, client.queryBuf[client.readx:client.recvx])
+		client.readx = 0
+		client.queryBuf = buf
+	}
+	client.queryBufPeak = pending
 }
 
 func resetClient(client *Client) {
@@ -143,88 +467,467 @@ func resetClient(client *Client) {
 }
 
 func freeClient(client *Client) {
+	if client.blocked != nil {
+		unblockClient(client)
+	}
+	unwatchAllKeys(client)
+	pubsubUnsubscribeAllKinds(client)
+	disableTracking(client)
+	delete(server.pausedClients, client)
 	delete(server.clients, client.fd)
+	delete(server.clientsByID, client.id)
 	server.aeLoop.ModDetach(client.fd)
 	_ = net.Close(client.fd)
 	log.Info().Msgf("free client %d", client.fd)
 }
 
+// freeClientAsync frees client before the event loop sleeps, it is used when
+// client may be in use, such as the current client or subscribers of PUBLISH.
+func freeClientAsync(client *Client) {
+	if client.closeASAP {
+		return
+	}
+	client.closeASAP = true
+	server.clientsToClose = append(server.clientsToClose, client)
+}
+
+func freeClientsInAsyncFreeQueue() {
+	for _, client := range server.clientsToClose {
+		// client may be freed already.
+		if server.clientsByID[client.id] == client {
+			freeClient(client)
+		}
+	}
+	server.clientsToClose = server.clientsToClose[:0]
+}
+
 func ProcessQueryBuf(client *Client) {
-	for client.readx < client.recvx {
+	// commands of blocked client are processed after unblocked.
+	for client.readx < client.recvx && client.blocked == nil && !client.closeAfterReply && !client.closeASAP {
 		queryBuf := client.queryBuf[client.readx:client.recvx]
 		// buffer pre alloc
 		respBuf := client.respBuf[:0]
 		argsBuf := client.argsBuf[:0]
 
-		complete, args, _, left, err := redcon.ReadNextCommand(queryBuf, argsBuf)
-		if err != nil {
-			log.Error().Msgf("read next command error: %v", err)
+		var complete bool
+		var args [][]byte
+		var n int
+		// commands may be parsed by io threads already.
+		if client.parsedIdx < len(client.parsed) {
+			pc := client.parsed[client.parsedIdx]
+			client.parsedIdx++
+			complete, args, n = true, pc.args, pc.n
+		} else {
+			var left []byte
+			var err error
+			complete, args, _, left, err = redcon.ReadNextCommand(queryBuf, argsBuf)
+			if err != nil {
+				log.Error().Msgf("read next command error: %v", err)
+				resetClient(client)
+				break
+			}
+			n = len(queryBuf) - len(left)
+		}
+		if !complete && bulkLenExceeded(queryBuf, server.protoMaxBulkLen) ||
+			complete && slices.ContainsFunc(args, func(arg []byte) bool {
+				return server.protoMaxBulkLen > 0 && len(arg) > server.protoMaxBulkLen
+			}) {
+			log.Warn().Msgf("client %d closed for bulk length exceeding proto-max-bulk-len", client.fd)
+			client.replyWriter.WriteError(errInvalidBulkLength.Error())
+			client.closeAfterReply = true
 			resetClient(client)
-			return
+			break
 		}
 		if !complete {
 			break
 		}
-		n := len(queryBuf) - len(left)
-		client.readx += n
 
 		command := b2s(args[0])
 		for _, arg := range args[1:] {
 			respBuf = append(respBuf, redcon.RESP{Data: arg})
 		}
 
-		cmd, err := lookupCommand(command)
+		cmd, cmdArgs, err := resolveCommand(command, respBuf)
+		if err == nil && client.subscribed() && !allowedInPubSub(cmd.name) {
+			err = errNotAllowedInPubSub(cmd.name)
+		}
+		// only SCRIPT KILL is served when a script is busy.
+		if err == nil && server.script != nil && !allowedInBusyScript(cmd) {
+			err = errBusyScript
+		}
+		// paused command is left in queryBuf and processed after unpaused.
+		if err == nil && commandPaused(client, cmd) {
+			server.pausedClients[client] = struct{}{}
+			break
+		}
+		client.readx += n
+		client.replySkip, client.replySkipNext = client.replySkipNext, false
+		replyLen := len(client.replyWriter.Buffer())
+
 		if err != nil {
+			if client.multi != nil {
+				client.multi.aborted = true
+			}
 			client.replyWriter.WriteError(err.Error())
 			log.Error().Msg(err.Error())
 
+		} else if client.multi != nil && !isMultiCommand(cmd.name) {
+			queueMultiCommand(client, cmd, cmdArgs)
+
 		} else {
-			cmd.process(client.replyWriter, respBuf)
-			// write aof file
-			if cmd.persist && configGetAppendOnly() {
-				_, _ = db.aof.Write(queryBuf[:n])
+			call(client, cmd, cmdArgs, queryBuf[:n])
+			if server.script == nil {
+				handleClientsBlockedOnKeys()
 			}
 		}
+		if client.replyOff || client.replySkip {
+			client.replyWriter.SetBuffer(client.replyWriter.Buffer()[:replyLen])
+		}
 	}
+	// commands parsed but not processed are parsed again next time.
+	client.parsed, client.parsedIdx = client.parsed[:0], 0
 	if client.readx == client.recvx {
 		resetClient(client)
 	}
+	installWriteHandler(client)
+}
+
+// parseQueryBuf parses the complete commands in queryBuf of client by io threads,
+// which are processed by ProcessQueryBuf in the event loop. Args of parsed
+// commands refer to queryBuf.
+func parseQueryBuf(client *Client) {
+	parsed := client.parsed[:0]
+	for pos := client.readx; pos < client.recvx; {
+		// reuse args of the previous parsed commands.
+		var argsBuf [][]byte
+		if i := len(parsed); i < cap(parsed) {
+			argsBuf = parsed[:i+1][i].args[:0]
+		}
+		queryBuf := client.queryBuf[pos:client.recvx]
+		complete, args, _, left, err := redcon.ReadNextCommand(queryBuf, argsBuf)
+		if err != nil || !complete {
+			break
+		}
+		n := len(queryBuf) - len(left)
+		parsed = append(parsed, parsedCommand{args: args, n: n})
+		pos += n
+	}
+	client.parsed, client.parsedIdx = parsed, 0
+}
+
+// bulkLenExceeded reports whether any bulk length in the header of incomplete
+// command buf exceeds limit, so that it is rejected before fully read.
+func bulkLenExceeded(buf []byte, limit int) bool {
+	if limit <= 0 || len(buf) == 0 || buf[0] != '*' {
+		return false
+	}
+	line, buf, ok := bytes.Cut(buf, crlf)
+	if !ok {
+		return false
+	}
+	num, err := strconv.Atoi(b2s(line[1:]))
+	if err != nil {
+		return false
+	}
+	for range num {
+		line, buf, ok = bytes.Cut(buf, crlf)
+		if !ok || len(line) == 0 || line[0] != '$' {
+			return false
+		}
+		n, err := strconv.Atoi(b2s(line[1:]))
+		if err != nil {
+			return false
+		}
+		if n > limit {
+			return true
+		}
+		if len(buf) < n+len(crlf) {
+			return false
+		}
+		buf = buf[n+len(crlf):]
+	}
+	return false
+}
+
+// installWriteHandler registers the writable event to send replies of client, or
+// frees client asynchronously when it reaches the output buffer limits.
+func installWriteHandler(client *Client) {
+	if client.closeASAP {
+		return
+	}
+	if checkClientOutputBufferLimits(client) {
+		log.Warn().Msgf("client %d scheduled to be closed for reaching output buffer limits, size %s",
+			client.fd, humanize.IBytes(uint64(outputBufferSize(client))))
+		server.statOutputBufLimitDisconnections++
+		freeClientAsync(client)
+		return
+	}
+	// replies are written before the event loop sleeps, by io threads or in
+	// batch if supported, except when serving clients inside a busy script.
+	if server.script == nil {
+		if !client.pendingWrite {
+			client.pendingWrite = true
+			server.clientsPendingWrite = append(server.clientsPendingWrite, client)
+		}
+		return
+	}
 	server.aeLoop.ModWrite(client.fd, SendReplyToClient, client)
 }
 
+// call executes command for client and writes it to aof file if needed,
+// raw is the command as received, nil means encoding it from args.
+func call(client *Client, cmd *Command, args []redcon.RESP, raw []byte) {
+	server.current = client
+	client.lastCmd = cmd
+	client.lastInteraction = nowMs()
+	start := len(client.replyWriter.Buffer())
+	cmd.handler(client.replyWriter, args)
+	trackingCommandCalled(client, cmd, args)
+	if cmd.persist {
+		// reply may be sent while a busy script serves other clients.
+		reply := client.replyWriter.Buffer()
+		if start <= len(reply) {
+			reply = reply[start:]
+		}
+		signalModifiedCommand(cmd, args, reply)
+	}
+	server.current = nil
+
+	// write aof file
+	if cmd.persist && configGetAppendOnly() {
+		if server.propagated {
+			feedAppendOnly(server.propagateBuf)
+		} else if raw != nil {
+			feedAppendOnly(raw)
+		} else {
+			feedAppendOnly(appendCommand(nil, cmd.name, args))
+		}
+	}
+	server.propagated = false
+	server.propagateBuf = server.propagateBuf[:0]
+}
+
+// feedAppendOnly writes buf to aof file, or buffers it when executing transaction.
+func feedAppendOnly(buf []byte) {
+	if server.inExec {
+		server.execBuf = append(server.execBuf, buf...)
+		return
+	}
+	_, _ = db.aof.Write(buf)
+}
+
+// propagate replaces the current command with args when writing aof file, it
+// can be called several times to persist multiple commands, and calling it
+// without args means persisting nothing.
+// It is used by commands that are not deterministic, such as XADD with auto ID.
+func propagate(args ...string) {
+	server.propagated = true
+	if len(args) == 0 {
+		return
+	}
+	server.propagateBuf = redcon.AppendArray(server.propagateBuf, len(args))
+	for _, arg := range args {
+		server.propagateBuf = redcon.AppendBulkString(server.propagateBuf, arg)
+	}
+}
+
+// appendCommand appends command encoded in RESP to dst, subcommand name in the
+// form of "container|subcommand" is split into two arguments.
+func appendCommand(dst []byte, name string, args []redcon.RESP) []byte {
+	container, sub, ok := strings.Cut(name, "|")
+	if ok {
+		dst = redcon.AppendArray(dst, len(args)+2)
+		dst = redcon.AppendBulkString(dst, container)
+		dst = redcon.AppendBulkString(dst, sub)
+	} else {
+		dst = redcon.AppendArray(dst, len(args)+1)
+		dst = redcon.AppendBulkString(dst, name)
+	}
+	for _, arg := range args {
+		dst = redcon.AppendBulk(dst, arg.Bytes())
+	}
+	return dst
+}
+
+// SendReplyToClient writes replies of client to the non-blocking socket, unsent
+// bytes are kept in the reply list and the writable event stays registered until
+// all of them are sent.
 func SendReplyToClient(loop *AeLoop, fd int, extra interface{}) {
 	client := extra.(*Client)
-	sentbuf := client.replyWriter.Buffer()
-
-	n, err := net.Write(fd, sentbuf)
+	if client.closeASAP {
+		return
+	}
+	err := writeToClient(client)
+	if errors.Is(err, net.ErrAgain) {
+		return
+	}
 	if err != nil {
 		log.Error().Msgf("send reply err: %v", err)
 		freeClient(client)
 		return
 	}
-	if n != len(sentbuf) {
-		log.Error().Msgf("send packet size error: %d %d", n, len(sentbuf))
+	if client.closeAfterReply {
+		freeClient(client)
+		return
 	}
-
-	client.replyWriter.Reset()
 	loop.ModRead(fd, ReadQueryFromClient, client)
 }
 
+// writeToClient writes replies of client until all sent, it returns net.ErrAgain
+// if socket is not writable. It may be called by io threads, so only the reply
+// buffers of client are touched.
+func writeToClient(client *Client) error {
+	for buf := pendingReply(client); len(buf) > 0; buf = pendingReply(client) {
+		n, err := connWrite(client, buf)
+		if err != nil {
+			return err
+		}
+		replySent(client, n)
+	}
+	client.reply = nil
+	return connFlush(client)
+}
+
+// pendingReply returns the first reply not sent of client, the buffer of
+// replyWriter is queued to replies first.
+func pendingReply(client *Client) []byte {
+	if buf := client.replyWriter.Buffer(); len(buf) > 0 {
+		client.reply = append(client.reply, buf)
+		client.replyBytes += len(buf)
+		client.replyWriter.Reset()
+	}
+	if len(client.reply) == 0 {
+		return nil
+	}
+	return client.reply[0][client.sentlen:]
+}
+
+// replySent advances replies of client by n bytes sent.
+func replySent(client *Client, n int) {
+	client.sentlen += n
+	client.replyBytes -= n
+	if client.sentlen == len(client.reply[0]) {
+		client.reply[0] = nil
+		client.reply = client.reply[1:]
+		client.sentlen = 0
+	}
+}
+
 func initServer() (err error) {
 	server.clients = make(map[int]*Client)
-	// init aeLoop
-	server.aeLoop, err = AeLoopCreate()
-	if err != nil {
+	server.clientsByID = make(map[uint64]*Client)
+	server.pausedClients = make(map[*Client]struct{})
+	server.blockingKeys = make(map[string][]*Client)
+	server.pubsubChannels = make(map[string]map[*Client]struct{})
+	server.pubsubPatterns = make(map[string]map[*Client]struct{})
+	server.pubsubShardChannels = make(map[string]map[*Client]struct{})
+	server.watchedKeys = make(map[string]map[*Client]struct{})
+	server.trackingTable = make(map[string]map[uint64]struct{})
+	server.trackingPrefixes = make(map[string]map[*Client]struct{})
+	server.trackingClients = make(map[*Client]struct{})
+	// init limits
+	if server.queryBufferLimit, err = configGetClientQueryBufferLimit(); err != nil {
 		return err
 	}
-	// init tcp server
-	server.fd, err = net.TcpServer(configGetPort())
+	if server.protoMaxBulkLen, err = configGetProtoMaxBulkLen(); err != nil {
+		return err
+	}
+	if server.outputBufferLimits, err = configGetClientOutputBufferLimits(); err != nil {
+		return err
+	}
+	// init io threads, 1 means the main thread only.
+	if threads := configGetIOThreads(); threads > 1 {
+		server.ioThreads = newIOThreadPool(threads)
+		server.ioThreadsDoReads = configGetBool("io.threads-do-reads")
+	}
+	// init aeLoop
+	server.aeLoop, err = AeLoopCreate(configGetString("ae.backend"))
 	if err != nil {
 		return err
 	}
+	// init listeners, tcp port 0 means not listening on tcp.
+	server.fds, server.tlsFds, server.unixFd = nil, nil, -1
+	if port := configGetPort(); port > 0 {
+		if server.fds, err = listenTcp(port); err != nil {
+			return err
+		}
+	}
+	if port := configGetTLSPort(); port > 0 {
+		if server.tlsConfig, err = newTLSConfig(); err != nil {
+			return err
+		}
+		if server.tlsFds, err = listenTcp(port); err != nil {
+			return err
+		}
+	}
+	if path := configGetUnixSocket(); path != "" {
+		perm, err := configGetUnixSocketPerm()
+		if err != nil {
+			return err
+		}
+		server.unixFd, err = net.UnixServer(path, perm, configGetBacklog())
+		if err == nil {
+			err = net.SetNonblock(server.unixFd)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(server.fds) == 0 && len(server.tlsFds) == 0 && server.unixFd < 0 {
+		return errors.New("no listener enabled, set tcp.port, tls.port or unix.socket")
+	}
 	return nil
 }
 
+// listenTcp listens on port of bind addresses, address prefixed with "-" is
+// skipped if not available. Listeners are non-blocking, so that accept handlers
+// can accept until none is pending.
+func listenTcp(port int) ([]int, error) {
+	var fds []int
+	for _, host := range configGetBind() {
+		host, optional := strings.CutPrefix(host, "-")
+		fd, err := net.TcpServer(host, port, configGetBacklog())
+		if err == nil {
+			if err = net.SetNonblock(fd); err != nil {
+				_ = net.Close(fd)
+			}
+		}
+		if err != nil && optional {
+			log.Warn().Msgf("skip binding %s:%d: %v", host, port, err)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bind %s:%d: %w", host, port, err)
+		}
+		fds = append(fds, fd)
+	}
+	return fds, nil
+}
+
+// closeListeners closes listeners and removes the unix socket file.
+func closeListeners() {
+	for _, fd := range slices.Concat(server.fds, server.tlsFds) {
+		_ = net.Close(fd)
+	}
+	if server.unixFd >= 0 {
+		_ = net.Close(server.unixFd)
+		if err := net.RemoveUnixSocket(configGetUnixSocket()); err != nil {
+			log.Error().Msgf("remove unix socket error: %v", err)
+		}
+	}
+}
+
+// BeforeSleep is called before the event loop sleeps to wait for events.
+func BeforeSleep(_ *AeLoop) {
+	handleClientsWithPendingReads()
+	trackingHandlePending()
+	if server.pause == pauseOff && len(server.pausedClients) > 0 {
+		resumePausedClients()
+	}
+	handleClientsWithPendingWrites()
+	freeClientsInAsyncFreeQueue()
+}
+
 func CronSyncAOF(ae *AeLoop, fd int, extra interface{}) {
 	if err := db.aof.Flush(); err != nil {
 		log.Error().Msgf("sync aof error: %v", err)
@@ -234,3 +937,9 @@ func CronSyncAOF(ae *AeLoop, fd int, extra interface{}) {
 func CronEvictExpired(ae *AeLoop, fd int, extra interface{}) {
 	db.dict.EvictExpired()
 }
+
+func CronClients(ae *AeLoop, fd int, extra interface{}) {
+	for _, client := range server.clients {
+		resizeQueryBuffer(client)
+	}
+}
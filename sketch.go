@@ -0,0 +1,471 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/cms"
+	"github.com/xgzlucario/rotom/internal/resp"
+	"github.com/xgzlucario/rotom/internal/tdigest"
+	"github.com/xgzlucario/rotom/internal/topk"
+)
+
+const (
+	WithCount   = "WITHCOUNT"
+	Compression = "COMPRESSION"
+	Override    = "OVERRIDE"
+)
+
+// lookupObject returns the object of key, ok is false if key not exist.
+func lookupObject[T any](key string) (v T, ok bool, err error) {
+	object, ttl := db.dict.Get(key)
+	if ttl == KeyNotExist {
+		return v, false, nil
+	}
+	if v, ok = object.(T); !ok {
+		return v, false, errWrongType
+	}
+	return v, true, nil
+}
+
+// parseNumKeys parses numkeys and returns the source keys after it.
+func parseNumKeys(args []redcon.RESP, errNumKeys error) ([]string, []redcon.RESP, error) {
+	numKeys, err := strconv.Atoi(b2s(args[0].Bytes()))
+	if err != nil || numKeys <= 0 {
+		return nil, nil, errNumKeys
+	}
+	if len(args) < numKeys+1 {
+		return nil, nil, errWrongArguments
+	}
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = args[i+1].String()
+	}
+	return keys, args[numKeys+1:], nil
+}
+
+func formatSketchFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func cmsInitByDimCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := args[0].String()
+	width, err := strconv.ParseUint(b2s(args[1].Bytes()), 10, 64)
+	if err != nil || width == 0 {
+		writer.WriteError(errCMSBadWidth.Error())
+		return
+	}
+	depth, err := strconv.ParseUint(b2s(args[2].Bytes()), 10, 64)
+	if err != nil || depth == 0 {
+		writer.WriteError(errCMSBadDepth.Error())
+		return
+	}
+	if !cms.ValidDims(width, depth) {
+		writer.WriteError(errCMSTooLarge.Error())
+		return
+	}
+	if _, ttl := db.dict.Get(key); ttl != KeyNotExist {
+		writer.WriteError(errCMSKeyExists.Error())
+		return
+	}
+	db.dict.Set(key, cms.New(width, depth))
+	writer.WriteString("OK")
+}
+
+func cmsIncrByCommand(writer *resp.Writer, args []redcon.RESP) {
+	c, ok, err := lookupObject[*cms.CMS](b2s(args[0].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if !ok {
+		writer.WriteError(errCMSKeyNotExist.Error())
+		return
+	}
+	if len(args)%2 == 0 {
+		writer.WriteError(errWrongArity("cms.incrby").Error())
+		return
+	}
+	incrs := make([]uint32, 0, len(args)/2)
+	for i := 2; i < len(args); i += 2 {
+		n, err := strconv.ParseUint(b2s(args[i].Bytes()), 10, 32)
+		if err != nil {
+			writer.WriteError(errCMSBadNumber.Error())
+			return
+		}
+		incrs = append(incrs, uint32(n))
+	}
+	writer.WriteArray(len(incrs))
+	for i, n := range incrs {
+		writer.WriteInt64(int64(c.IncrBy(b2s(args[i*2+1].Bytes()), n)))
+	}
+}
+
+func cmsQueryCommand(writer *resp.Writer, args []redcon.RESP) {
+	c, ok, err := lookupObject[*cms.CMS](b2s(args[0].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if !ok {
+		writer.WriteError(errCMSKeyNotExist.Error())
+		return
+	}
+	writer.WriteArray(len(args) - 1)
+	for _, arg := range args[1:] {
+		writer.WriteInt64(int64(c.Query(b2s(arg.Bytes()))))
+	}
+}
+
+// CMS.MERGE dest numKeys src1 [src2 ...] [WEIGHTS weight1 ...]
+func cmsMergeCommand(writer *resp.Writer, args []redcon.RESP) {
+	keys, extra, err := parseNumKeys(args[1:], errCMSBadNumKeys)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	weights := make([]int64, len(keys))
+	for i := range weights {
+		weights[i] = 1
+	}
+	if len(extra) > 0 {
+		if !equalFold(b2s(extra[0].Bytes()), Weights) || len(extra) != len(keys)+1 {
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+		for i, arg := range extra[1:] {
+			if weights[i], err = strconv.ParseInt(b2s(arg.Bytes()), 10, 64); err != nil {
+				writer.WriteError(errCMSBadWeight.Error())
+				return
+			}
+		}
+	}
+
+	dest, ok, err := lookupObject[*cms.CMS](b2s(args[0].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if !ok {
+		writer.WriteError(errCMSKeyNotExist.Error())
+		return
+	}
+	sources := make([]*cms.CMS, len(keys))
+	for i, key := range keys {
+		src, ok, err := lookupObject[*cms.CMS](key)
+		if err != nil {
+			writer.WriteError(err.Error())
+			return
+		}
+		if !ok {
+			writer.WriteError(errCMSKeyNotExist.Error())
+			return
+		}
+		if src.Width() != dest.Width() || src.Depth() != dest.Depth() {
+			writer.WriteError(errCMSDimMismatch.Error())
+			return
+		}
+		sources[i] = src
+	}
+	dest.Merge(sources, weights)
+	writer.WriteString("OK")
+}
+
+// TOPK.RESERVE key topk [width depth decay]
+func topkReserveCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := args[0].String()
+	k, err := strconv.ParseUint(b2s(args[1].Bytes()), 10, 64)
+	if err != nil || k == 0 {
+		writer.WriteError(errTopKBadK.Error())
+		return
+	}
+	var width, depth uint64 = topk.DefaultWidth, topk.DefaultDepth
+	decay := topk.DefaultDecay
+	switch len(args) {
+	case 2:
+	case 5:
+		width, err = strconv.ParseUint(b2s(args[2].Bytes()), 10, 64)
+		if err != nil || width == 0 {
+			writer.WriteError(errTopKBadWidth.Error())
+			return
+		}
+		depth, err = strconv.ParseUint(b2s(args[3].Bytes()), 10, 64)
+		if err != nil || depth == 0 {
+			writer.WriteError(errTopKBadDepth.Error())
+			return
+		}
+		decay, err = strconv.ParseFloat(b2s(args[4].Bytes()), 64)
+		if err != nil || decay <= 0 || decay > 1 {
+			writer.WriteError(errTopKBadDecay.Error())
+			return
+		}
+	default:
+		writer.WriteError(errWrongArity("topk.reserve").Error())
+		return
+	}
+	if !topk.ValidDims(width, depth) {
+		writer.WriteError(errTopKTooLarge.Error())
+		return
+	}
+	if _, ttl := db.dict.Get(key); ttl != KeyNotExist {
+		writer.WriteError(errTopKKeyExists.Error())
+		return
+	}
+	db.dict.Set(key, topk.New(k, width, depth, decay))
+	writer.WriteString("OK")
+}
+
+func lookupTopK(writer *resp.Writer, key []byte) *topk.TopK {
+	t, ok, err := lookupObject[*topk.TopK](b2s(key))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return nil
+	}
+	if !ok {
+		writer.WriteError(errTopKKeyNotExist.Error())
+		return nil
+	}
+	return t
+}
+
+func topkAddCommand(writer *resp.Writer, args []redcon.RESP) {
+	t := lookupTopK(writer, args[0].Bytes())
+	if t == nil {
+		return
+	}
+	writer.WriteArray(len(args) - 1)
+	for _, arg := range args[1:] {
+		if expelled, ok := t.Add(arg.String(), 1); ok {
+			writer.WriteBulkString(expelled)
+		} else {
+			writer.WriteNull()
+		}
+	}
+}
+
+func topkQueryCommand(writer *resp.Writer, args []redcon.RESP) {
+	t := lookupTopK(writer, args[0].Bytes())
+	if t == nil {
+		return
+	}
+	writer.WriteArray(len(args) - 1)
+	for _, arg := range args[1:] {
+		writer.WriteInt(b2i(t.Query(b2s(arg.Bytes()))))
+	}
+}
+
+func topkListCommand(writer *resp.Writer, args []redcon.RESP) {
+	var withCount bool
+	if len(args) > 1 {
+		if len(args) > 2 || !equalFold(b2s(args[1].Bytes()), WithCount) {
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+		withCount = true
+	}
+	t := lookupTopK(writer, args[0].Bytes())
+	if t == nil {
+		return
+	}
+	items := t.List()
+	if withCount {
+		writer.WriteArray(len(items) * 2)
+	} else {
+		writer.WriteArray(len(items))
+	}
+	for _, it := range items {
+		writer.WriteBulkString(it.Name)
+		if withCount {
+			writer.WriteInt64(int64(it.Count))
+		}
+	}
+}
+
+func parseCompression(arg redcon.RESP) (float64, error) {
+	n, err := strconv.Atoi(b2s(arg.Bytes()))
+	if err != nil {
+		return 0, errTDigestBadCompression
+	}
+	if n <= 0 {
+		return 0, errTDigestCompressionRange
+	}
+	return float64(n), nil
+}
+
+// TDIGEST.CREATE key [COMPRESSION compression]
+func tdigestCreateCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := args[0].String()
+	compression := float64(tdigest.DefaultCompression)
+	switch len(args) {
+	case 1:
+	case 3:
+		if !equalFold(b2s(args[1].Bytes()), Compression) {
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+		var err error
+		if compression, err = parseCompression(args[2]); err != nil {
+			writer.WriteError(err.Error())
+			return
+		}
+	default:
+		writer.WriteError(errWrongArity("tdigest.create").Error())
+		return
+	}
+	if _, ttl := db.dict.Get(key); ttl != KeyNotExist {
+		writer.WriteError(errTDigestKeyExists.Error())
+		return
+	}
+	db.dict.Set(key, tdigest.New(compression))
+	writer.WriteString("OK")
+}
+
+func lookupTDigest(writer *resp.Writer, key []byte) *tdigest.TDigest {
+	t, ok, err := lookupObject[*tdigest.TDigest](b2s(key))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return nil
+	}
+	if !ok {
+		writer.WriteError(errTDigestKeyNotExist.Error())
+		return nil
+	}
+	return t
+}
+
+// parseTDigestValues parses float arguments, returning errParse if any is invalid.
+func parseTDigestValues(args []redcon.RESP, errParse error) ([]float64, error) {
+	values := make([]float64, len(args))
+	for i, arg := range args {
+		f, err := strconv.ParseFloat(b2s(arg.Bytes()), 64)
+		if err != nil || math.IsNaN(f) {
+			return nil, errParse
+		}
+		values[i] = f
+	}
+	return values, nil
+}
+
+func tdigestAddCommand(writer *resp.Writer, args []redcon.RESP) {
+	t := lookupTDigest(writer, args[0].Bytes())
+	if t == nil {
+		return
+	}
+	values, err := parseTDigestValues(args[1:], errTDigestBadValue)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	for _, v := range values {
+		t.Add(v)
+	}
+	writer.WriteString("OK")
+}
+
+func tdigestQuantileCommand(writer *resp.Writer, args []redcon.RESP) {
+	t := lookupTDigest(writer, args[0].Bytes())
+	if t == nil {
+		return
+	}
+	quantiles, err := parseTDigestValues(args[1:], errTDigestBadQuantile)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	for _, q := range quantiles {
+		if q < 0 || q > 1 {
+			writer.WriteError(errTDigestQuantileRange.Error())
+			return
+		}
+	}
+	writer.WriteArray(len(quantiles))
+	for _, q := range quantiles {
+		writer.WriteBulkString(formatSketchFloat(t.Quantile(q)))
+	}
+}
+
+func tdigestCDFCommand(writer *resp.Writer, args []redcon.RESP) {
+	t := lookupTDigest(writer, args[0].Bytes())
+	if t == nil {
+		return
+	}
+	values, err := parseTDigestValues(args[1:], errTDigestBadCDF)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writer.WriteArray(len(values))
+	for _, v := range values {
+		writer.WriteBulkString(formatSketchFloat(t.CDF(v)))
+	}
+}
+
+// TDIGEST.MERGE dest numkeys src1 [src2 ...] [COMPRESSION compression] [OVERRIDE]
+func tdigestMergeCommand(writer *resp.Writer, args []redcon.RESP) {
+	destKey := args[0].String()
+	keys, extra, err := parseNumKeys(args[1:], errTDigestBadNumKeys)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	var compression float64
+	var override bool
+	for len(extra) > 0 {
+		arg := b2s(extra[0].Bytes())
+		switch {
+		case equalFold(arg, Compression) && len(extra) >= 2:
+			if compression, err = parseCompression(extra[1]); err != nil {
+				writer.WriteError(err.Error())
+				return
+			}
+			extra = extra[2:]
+		case equalFold(arg, Override):
+			override = true
+			extra = extra[1:]
+		default:
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+	}
+
+	sources := make([]*tdigest.TDigest, 0, len(keys)+1)
+	for _, key := range keys {
+		t := lookupTDigest(writer, []byte(key))
+		if t == nil {
+			return
+		}
+		sources = append(sources, t)
+	}
+	dest, ok, err := lookupObject[*tdigest.TDigest](destKey)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+
+	// the compression of dest defaults to the max of sources.
+	if compression == 0 {
+		for _, src := range sources {
+			compression = max(compression, src.Compression())
+		}
+		if ok && !override {
+			compression = max(compression, dest.Compression())
+		}
+	}
+	merged := tdigest.New(compression)
+	if ok && !override && !slices.Contains(keys, destKey) {
+		sources = append(sources, dest)
+	}
+	merged.Merge(sources...)
+	db.dict.Set(destKey, merged)
+	writer.WriteString("OK")
+}
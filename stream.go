@@ -0,0 +1,1103 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/resp"
+	"github.com/xgzlucario/rotom/internal/stream"
+)
+
+const (
+	NoMkStream = "NOMKSTREAM"
+	MaxLen     = "MAXLEN"
+	MinID      = "MINID"
+	Block      = "BLOCK"
+	Streams    = "STREAMS"
+	Group      = "GROUP"
+	NoAck      = "NOACK"
+	MkStream   = "MKSTREAM"
+	Idle       = "IDLE"
+	Time       = "TIME"
+	RetryCount = "RETRYCOUNT"
+	Force      = "FORCE"
+	JustID     = "JUSTID"
+	LastID     = "LASTID"
+)
+
+// streamTrim is the trimming strategy of XADD and XTRIM.
+type streamTrim struct {
+	maxLen    int64 // -1 means not trim by MAXLEN
+	minID     stream.ID
+	withMinID bool
+}
+
+func (t *streamTrim) enabled() bool { return t.maxLen >= 0 || t.withMinID }
+
+// trim trims stream and propagates the exact form to aof.
+func (t *streamTrim) trim(key string, s Stream) int {
+	if t.maxLen >= 0 {
+		n := s.TrimMaxLen(uint64(t.maxLen))
+		propagate("xtrim", key, MaxLen, strconv.FormatInt(t.maxLen, 10))
+		return n
+	}
+	n := s.TrimMinID(t.minID)
+	propagate("xtrim", key, MinID, t.minID.String())
+	return n
+}
+
+// parseStreamTrim parses `MAXLEN|MINID [=|~] threshold [LIMIT count]` at the head of args
+// and returns the number of consumed args. Approximate trimming is performed exactly.
+func parseStreamTrim(args []redcon.RESP, trim *streamTrim) (int, error) {
+	arg := b2s(args[0].Bytes())
+	isMaxLen := equalFold(arg, MaxLen)
+	if !isMaxLen && !equalFold(arg, MinID) {
+		return 0, nil
+	}
+	i := 1
+	if i < len(args) {
+		if op := b2s(args[i].Bytes()); op == "=" || op == "~" {
+			i++
+		}
+	}
+	if i >= len(args) {
+		return 0, errSyntax
+	}
+	threshold := b2s(args[i].Bytes())
+	i++
+	if isMaxLen {
+		n, err := strconv.ParseInt(threshold, 10, 64)
+		if err != nil {
+			return 0, errParseInteger
+		}
+		if n < 0 {
+			return 0, errMaxLenNegative
+		}
+		trim.maxLen = n
+	} else {
+		id, err := stream.ParseID(threshold, 0)
+		if err != nil {
+			return 0, err
+		}
+		trim.minID, trim.withMinID = id, true
+	}
+	if i+1 < len(args) && equalFold(b2s(args[i].Bytes()), Limit) {
+		if _, err := strconv.Atoi(b2s(args[i+1].Bytes())); err != nil {
+			return 0, errParseInteger
+		}
+		i += 2
+	}
+	return i, nil
+}
+
+// parseRangeID parses start or end of XRANGE, supports "-", "+" and exclusive "(".
+func parseRangeID(b []byte, isStart bool) (stream.ID, error) {
+	s := b2s(b)
+	switch s {
+	case "-":
+		return stream.MinID, nil
+	case "+":
+		return stream.MaxID, nil
+	}
+	missingSeq := uint64(0)
+	if !isStart {
+		missingSeq = math.MaxUint64
+	}
+	exclusive := strings.HasPrefix(s, "(")
+	if exclusive {
+		s = s[1:]
+	}
+	id, err := stream.ParseID(s, missingSeq)
+	if err != nil || !exclusive {
+		return id, err
+	}
+	var ok bool
+	if isStart {
+		id, ok = id.Incr()
+	} else {
+		id, ok = id.Decr()
+	}
+	if !ok {
+		return id, errInvalidInterval
+	}
+	return id, nil
+}
+
+func nowMs() int64 { return time.Now().UnixMilli() }
+
+func writeStreamEntry(writer *resp.Writer, e stream.Entry) {
+	writer.WriteArray(2)
+	writer.WriteBulkString(e.ID.String())
+	writer.WriteArray(len(e.Fields))
+	for _, f := range e.Fields {
+		writer.WriteBulk(f)
+	}
+}
+
+func writeStreamEntries(writer *resp.Writer, entries []stream.Entry) {
+	writer.WriteArray(len(entries))
+	for _, e := range entries {
+		writeStreamEntry(writer, e)
+	}
+}
+
+func rangeStream(s Stream, start, end stream.ID, rev bool, count int) []stream.Entry {
+	var entries []stream.Entry
+	if count == 0 {
+		return entries
+	}
+	s.Range(start, end, rev, func(e stream.Entry) bool {
+		entries = append(entries, e)
+		return count < 0 || len(entries) < count
+	})
+	return entries
+}
+
+func xaddCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := args[0].String()
+	extra := args[1:]
+	trim := streamTrim{maxLen: -1}
+	noMkStream := false
+
+	for len(extra) > 0 {
+		arg := b2s(extra[0].Bytes())
+		if equalFold(arg, NoMkStream) {
+			noMkStream = true
+			extra = extra[1:]
+			continue
+		}
+		n, err := parseStreamTrim(extra, &trim)
+		if err != nil {
+			writer.WriteError(err.Error())
+			return
+		}
+		if n == 0 {
+			break
+		}
+		extra = extra[n:]
+	}
+	// id field value [field value ...]
+	if len(extra) < 3 || len(extra)%2 == 0 {
+		writer.WriteError(errWrongArity("xadd").Error())
+		return
+	}
+
+	object, ttl := db.dict.Get(key)
+	if ttl == KeyNotExist && noMkStream {
+		writer.WriteNull()
+		propagate()
+		return
+	}
+	s, err := fetchStream(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	id, err := nextStreamID(s, b2s(extra[0].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if object == nil {
+		db.dict.Set(key, s)
+	}
+
+	fields := make([][]byte, 0, len(extra)-1)
+	for _, arg := range extra[1:] {
+		fields = append(fields, arg.Bytes())
+	}
+	s.Add(id, fields)
+
+	// persist with the generated ID.
+	propagateArgs := make([]string, 0, len(extra)+2)
+	propagateArgs = append(propagateArgs, "xadd", key, id.String())
+	for _, f := range fields {
+		propagateArgs = append(propagateArgs, b2s(f))
+	}
+	propagate(propagateArgs...)
+
+	if trim.enabled() {
+		trim.trim(key, s)
+	}
+	signalKeyAsReady(key)
+	writer.WriteBulkString(id.String())
+}
+
+// nextStreamID returns ID of XADD, which may be "*", "<ms>-*" or an explicit ID.
+func nextStreamID(s Stream, arg string) (stream.ID, error) {
+	lastID := s.LastID()
+	if arg == "*" {
+		id, ok := s.NextID(uint64(nowMs()))
+		if !ok {
+			return id, errStreamExhausted
+		}
+		return id, nil
+	}
+
+	var id stream.ID
+	var err error
+	if ms, ok := strings.CutSuffix(arg, "-*"); ok {
+		id, err = stream.ParseID(ms, 0)
+		if err != nil {
+			return id, err
+		}
+		if id.Ms == lastID.Ms {
+			if id.Seq, ok = lastID.Seq+1, lastID.Seq < math.MaxUint64; !ok {
+				return id, errStreamIDTooSmall
+			}
+		}
+	} else {
+		id, err = stream.ParseID(arg, 0)
+		if err != nil {
+			return id, err
+		}
+	}
+	if id == stream.MinID {
+		return id, errStreamIDZero
+	}
+	if !lastID.Less(id) {
+		return id, errStreamIDTooSmall
+	}
+	return id, nil
+}
+
+func xlenCommand(writer *resp.Writer, args []redcon.RESP) {
+	s, err := fetchStream(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writer.WriteInt(s.Len())
+}
+
+func xrangeCommand(writer *resp.Writer, args []redcon.RESP) {
+	xrangeGeneric(writer, args, false)
+}
+
+func xrevrangeCommand(writer *resp.Writer, args []redcon.RESP) {
+	xrangeGeneric(writer, args, true)
+}
+
+func xrangeGeneric(writer *resp.Writer, args []redcon.RESP, rev bool) {
+	startArg, endArg := args[1].Bytes(), args[2].Bytes()
+	if rev {
+		startArg, endArg = endArg, startArg
+	}
+	start, err := parseRangeID(startArg, true)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	end, err := parseRangeID(endArg, false)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+
+	count := -1
+	extra := args[3:]
+	for len(extra) > 0 {
+		arg := b2s(extra[0].Bytes())
+		// COUNT
+		if equalFold(arg, Count) && len(extra) >= 2 {
+			count, err = strconv.Atoi(b2s(extra[1].Bytes()))
+			if err != nil {
+				writer.WriteError(errParseInteger.Error())
+				return
+			}
+			count = max(count, 0)
+			extra = extra[2:]
+		} else {
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+	}
+
+	s, err := fetchStream(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writeStreamEntries(writer, rangeStream(s, start, end, rev, count))
+}
+
+func xdelCommand(writer *resp.Writer, args []redcon.RESP) {
+	ids := make([]stream.ID, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		id, err := stream.ParseID(b2s(arg.Bytes()), 0)
+		if err != nil {
+			writer.WriteError(err.Error())
+			return
+		}
+		ids = append(ids, id)
+	}
+	s, err := fetchStream(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	var count int
+	for _, id := range ids {
+		if s.Delete(id) {
+			count++
+		}
+	}
+	writer.WriteInt(count)
+}
+
+func xtrimCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := args[0].String()
+	trim := streamTrim{maxLen: -1}
+	n, err := parseStreamTrim(args[1:], &trim)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if n == 0 || n != len(args)-1 {
+		writer.WriteError(errSyntax.Error())
+		return
+	}
+	s, err := fetchStream(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writer.WriteInt(trim.trim(key, s))
+}
+
+// streamReadArgs is the parsed arguments of XREAD and XREADGROUP.
+type streamReadArgs struct {
+	count   int
+	block   int64 // -1 means not blocking
+	noAck   bool
+	keys    []redcon.RESP
+	ids     []redcon.RESP
+	idsArgs int // index of first ID in args
+}
+
+func parseStreamReadArgs(name string, args []redcon.RESP, group bool) (*streamReadArgs, error) {
+	ra := &streamReadArgs{count: -1, block: -1}
+	for i := 0; i < len(args); i++ {
+		arg := b2s(args[i].Bytes())
+		switch {
+		case equalFold(arg, Count) && i+1 < len(args):
+			count, err := strconv.Atoi(b2s(args[i+1].Bytes()))
+			if err != nil {
+				return nil, errParseInteger
+			}
+			if count > 0 {
+				ra.count = count
+			}
+			i++
+		case equalFold(arg, Block) && i+1 < len(args):
+			block, err := strconv.ParseInt(b2s(args[i+1].Bytes()), 10, 64)
+			if err != nil {
+				return nil, errTimeoutNotInteger
+			}
+			if block < 0 {
+				return nil, errTimeoutNegative
+			}
+			ra.block = block
+			i++
+		case group && equalFold(arg, NoAck):
+			ra.noAck = true
+		case equalFold(arg, Streams):
+			rest := args[i+1:]
+			if len(rest) == 0 || len(rest)%2 != 0 {
+				return nil, fmt.Errorf("ERR Unbalanced '%s' list of streams: for each stream key an ID or '$' must be specified.", name)
+			}
+			ra.keys = rest[:len(rest)/2]
+			ra.ids = rest[len(rest)/2:]
+			ra.idsArgs = i + 1 + len(rest)/2
+			return ra, nil
+		default:
+			return nil, errSyntax
+		}
+	}
+	return nil, errSyntax
+}
+
+func xreadCommand(writer *resp.Writer, args []redcon.RESP) {
+	ra, err := parseStreamReadArgs("xread", args, false)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+
+	type result struct {
+		key     []byte
+		entries []stream.Entry
+	}
+	results := make([]result, 0, len(ra.keys))
+	startIDs := make([]stream.ID, len(ra.keys))
+
+	for i, key := range ra.keys {
+		s, err := fetchStream(key.Bytes())
+		if err != nil {
+			writer.WriteError(err.Error())
+			return
+		}
+		idArg := b2s(ra.ids[i].Bytes())
+		var id stream.ID
+		if idArg == "$" {
+			id = s.LastID()
+		} else if id, err = stream.ParseID(idArg, 0); err != nil {
+			writer.WriteError(err.Error())
+			return
+		}
+		startIDs[i] = id
+
+		start, ok := id.Incr()
+		if !ok {
+			continue
+		}
+		if entries := rangeStream(s, start, stream.MaxID, false, ra.count); len(entries) > 0 {
+			results = append(results, result{key.Bytes(), entries})
+		}
+	}
+
+	if len(results) > 0 {
+		writer.WriteArray(len(results))
+		for _, r := range results {
+			writer.WriteArray(2)
+			writer.WriteBulk(r.key)
+			writeStreamEntries(writer, r.entries)
+		}
+		return
+	}
+
+	if ra.block >= 0 {
+		// "$" is replaced with the last ID so that the command is executed
+		// again with the same IDs after unblocked.
+		retryArgs := append([]redcon.RESP(nil), args...)
+		for i, id := range startIDs {
+			retryArgs[ra.idsArgs+i] = redcon.RESP{Data: []byte(id.String())}
+		}
+		if blockForKeys(respStrings(ra.keys), ra.block, retryArgs) {
+			return
+		}
+	}
+	writer.WriteNullArray()
+}
+
+func respStrings(args []redcon.RESP) []string {
+	strs := make([]string, 0, len(args))
+	for _, arg := range args {
+		strs = append(strs, arg.String())
+	}
+	return strs
+}
+
+func noGroupError(key, group string) error {
+	return fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+}
+
+// fetchGroup returns stream and group of key, returns error when not exist.
+func fetchGroup(key []byte, groupName string) (Stream, *stream.Group, error) {
+	object, ttl := db.dict.Get(b2s(key))
+	if ttl == KeyNotExist {
+		return nil, nil, noGroupError(b2s(key), groupName)
+	}
+	s, ok := object.(Stream)
+	if !ok {
+		return nil, nil, errWrongType
+	}
+	g := s.Group(groupName)
+	if g == nil {
+		return nil, nil, noGroupError(b2s(key), groupName)
+	}
+	return s, g, nil
+}
+
+// parseGroupID parses ID of XGROUP, "$" means the last ID of stream.
+func parseGroupID(s Stream, arg []byte) (stream.ID, error) {
+	if b2s(arg) == "$" {
+		return s.LastID(), nil
+	}
+	return stream.ParseID(b2s(arg), 0)
+}
+
+// XGROUP CREATE key group id|$ [MKSTREAM]
+func xgroupCreateCommand(writer *resp.Writer, args []redcon.RESP) {
+	mkStream := false
+	for _, arg := range args[3:] {
+		if equalFold(b2s(arg.Bytes()), MkStream) {
+			mkStream = true
+		} else {
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+	}
+	key := args[0].Bytes()
+	if _, ttl := db.dict.Get(b2s(key)); ttl == KeyNotExist && !mkStream {
+		writer.WriteError(errXGroupKeyNotExist.Error())
+		return
+	}
+	s, err := fetchStream(key, true)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	id, err := parseGroupID(s, args[2].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if s.CreateGroup(args[1].String(), id) == nil {
+		writer.WriteError(errBusyGroup.Error())
+		return
+	}
+	writer.WriteString("OK")
+}
+
+// XGROUP SETID key group id|$
+func xgroupSetIDCommand(writer *resp.Writer, args []redcon.RESP) {
+	s, g, err := fetchGroup(args[0].Bytes(), b2s(args[1].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	id, err := parseGroupID(s, args[2].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	g.LastID = id
+	writer.WriteString("OK")
+}
+
+// XGROUP DESTROY key group
+func xgroupDestroyCommand(writer *resp.Writer, args []redcon.RESP) {
+	s, err := fetchStream(args[0].Bytes())
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if s.DestroyGroup(b2s(args[1].Bytes())) {
+		writer.WriteInt(1)
+	} else {
+		writer.WriteInt(0)
+	}
+}
+
+// XGROUP CREATECONSUMER key group consumer
+func xgroupCreateConsumerCommand(writer *resp.Writer, args []redcon.RESP) {
+	_, g, err := fetchGroup(args[0].Bytes(), b2s(args[1].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	c, created := g.Consumer(args[2].String(), true)
+	if created {
+		c.SeenTime = nowMs()
+		writer.WriteInt(1)
+	} else {
+		writer.WriteInt(0)
+	}
+}
+
+// XGROUP DELCONSUMER key group consumer
+func xgroupDelConsumerCommand(writer *resp.Writer, args []redcon.RESP) {
+	_, g, err := fetchGroup(args[0].Bytes(), b2s(args[1].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	writer.WriteInt(max(g.DeleteConsumer(b2s(args[2].Bytes())), 0))
+}
+
+// propagateClaim persists the ownership of pending entry as a deterministic
+// XCLAIM, as XREADGROUP, XCLAIM and XAUTOCLAIM depend on current time.
+func propagateClaim(key string, g *stream.Group, pe *stream.PendingEntry) {
+	propagate("xclaim", key, g.Name, pe.Consumer.Name, "0", pe.ID.String(),
+		Time, strconv.FormatInt(pe.DeliveryTime, 10),
+		RetryCount, strconv.FormatUint(pe.DeliveryCount, 10),
+		Force, JustID, LastID, g.LastID.String())
+}
+
+func xreadgroupCommand(writer *resp.Writer, args []redcon.RESP) {
+	if len(args) < 6 || !equalFold(b2s(args[0].Bytes()), Group) {
+		writer.WriteError(errSyntax.Error())
+		return
+	}
+	groupName, consumerName := args[1].String(), args[2].String()
+	ra, err := parseStreamReadArgs("xreadgroup", args[3:], true)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+
+	type result struct {
+		key     string
+		s       Stream
+		g       *stream.Group
+		history bool
+		start   stream.ID
+	}
+	results := make([]result, 0, len(ra.keys))
+	for i, key := range ra.keys {
+		s, g, err := fetchGroup(key.Bytes(), groupName)
+		if err != nil {
+			writer.WriteError(err.Error())
+			return
+		}
+		r := result{key: key.String(), s: s, g: g}
+		if idArg := b2s(ra.ids[i].Bytes()); idArg != ">" {
+			id, err := stream.ParseID(idArg, 0)
+			if err != nil {
+				writer.WriteError(err.Error())
+				return
+			}
+			r.history = true
+			r.start = id
+		}
+		results = append(results, r)
+	}
+	// effects are persisted by propagate.
+	propagate()
+
+	now := nowMs()
+	var served int
+	// entries are written after the number of served keys is known.
+	replies := resp.NewWriter()
+	replies.SetProto(writer.Proto())
+	for _, r := range results {
+		c, created := r.g.Consumer(consumerName, true)
+		c.SeenTime = now
+		if created {
+			propagate("xgroup", "createconsumer", r.key, r.g.Name, c.Name)
+		}
+
+		// read history from PEL of consumer.
+		if r.history {
+			start, ok := r.start.Incr()
+			var pending []*stream.PendingEntry
+			if ok {
+				r.g.RangePending(c, start, func(pe *stream.PendingEntry) bool {
+					pending = append(pending, pe)
+					return ra.count < 0 || len(pending) < ra.count
+				})
+			}
+			replies.WriteArray(2)
+			replies.WriteBulkString(r.key)
+			replies.WriteArray(len(pending))
+			for _, pe := range pending {
+				entries := rangeStream(r.s, pe.ID, pe.ID, false, 1)
+				if len(entries) > 0 {
+					writeStreamEntry(replies, entries[0])
+				} else {
+					// entry has been deleted.
+					replies.WriteArray(2)
+					replies.WriteBulkString(pe.ID.String())
+					replies.WriteNull()
+				}
+				r.g.Deliver(pe.ID, c, now)
+				propagateClaim(r.key, r.g, pe)
+			}
+			served++
+			continue
+		}
+
+		// read new entries after last delivered ID.
+		start, ok := r.g.LastID.Incr()
+		if !ok {
+			continue
+		}
+		entries := rangeStream(r.s, start, stream.MaxID, false, ra.count)
+		if len(entries) == 0 {
+			continue
+		}
+		r.g.LastID = entries[len(entries)-1].ID
+		replies.WriteArray(2)
+		replies.WriteBulkString(r.key)
+		writeStreamEntries(replies, entries)
+		for _, e := range entries {
+			if !ra.noAck {
+				propagateClaim(r.key, r.g, r.g.Deliver(e.ID, c, now))
+			}
+		}
+		propagate("xgroup", "setid", r.key, r.g.Name, r.g.LastID.String())
+		served++
+	}
+
+	if served > 0 {
+		writer.WriteArray(served)
+		writer.WriteRaw(replies.Buffer())
+		return
+	}
+	if ra.block >= 0 && blockForKeys(respStrings(ra.keys), ra.block, args) {
+		return
+	}
+	writer.WriteNullArray()
+}
+
+func xackCommand(writer *resp.Writer, args []redcon.RESP) {
+	ids := make([]stream.ID, 0, len(args)-2)
+	for _, arg := range args[2:] {
+		id, err := stream.ParseID(b2s(arg.Bytes()), 0)
+		if err != nil {
+			writer.WriteError(err.Error())
+			return
+		}
+		ids = append(ids, id)
+	}
+	_, g, err := fetchGroup(args[0].Bytes(), b2s(args[1].Bytes()))
+	if err == errWrongType {
+		writer.WriteError(err.Error())
+		return
+	}
+	var count int
+	if g != nil {
+		for _, id := range ids {
+			if g.Ack(id) {
+				count++
+			}
+		}
+	}
+	writer.WriteInt(count)
+}
+
+func xpendingCommand(writer *resp.Writer, args []redcon.RESP) {
+	_, g, err := fetchGroup(args[0].Bytes(), b2s(args[1].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+
+	// summary form
+	if len(args) == 2 {
+		if g.PendingLen() == 0 {
+			writer.WriteArray(4)
+			writer.WriteInt(0)
+			writer.WriteNull()
+			writer.WriteNull()
+			writer.WriteNull()
+			return
+		}
+		var first, last stream.ID
+		g.RangePending(nil, stream.MinID, func(pe *stream.PendingEntry) bool {
+			if first == stream.MinID {
+				first = pe.ID
+			}
+			last = pe.ID
+			return true
+		})
+		writer.WriteArray(4)
+		writer.WriteInt(g.PendingLen())
+		writer.WriteBulkString(first.String())
+		writer.WriteBulkString(last.String())
+
+		consumers := g.Consumers()
+		n := 0
+		for _, c := range consumers {
+			if c.PendingLen() > 0 {
+				n++
+			}
+		}
+		writer.WriteArray(n)
+		for _, c := range consumers {
+			if c.PendingLen() > 0 {
+				writer.WriteArray(2)
+				writer.WriteBulkString(c.Name)
+				writer.WriteBulkString(strconv.Itoa(c.PendingLen()))
+			}
+		}
+		return
+	}
+
+	// extended form: [IDLE min-idle-time] start end count [consumer]
+	extra := args[2:]
+	var minIdle int64
+	if equalFold(b2s(extra[0].Bytes()), Idle) {
+		if len(extra) < 2 {
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+		minIdle, err = strconv.ParseInt(b2s(extra[1].Bytes()), 10, 64)
+		if err != nil {
+			writer.WriteError(errParseInteger.Error())
+			return
+		}
+		extra = extra[2:]
+	}
+	if len(extra) != 3 && len(extra) != 4 {
+		writer.WriteError(errSyntax.Error())
+		return
+	}
+	start, err := parseRangeID(extra[0].Bytes(), true)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	end, err := parseRangeID(extra[1].Bytes(), false)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	count, err := strconv.Atoi(b2s(extra[2].Bytes()))
+	if err != nil {
+		writer.WriteError(errParseInteger.Error())
+		return
+	}
+	var c *stream.Consumer
+	if len(extra) == 4 {
+		if c, _ = g.Consumer(b2s(extra[3].Bytes()), false); c == nil {
+			writer.WriteArray(0)
+			return
+		}
+	}
+
+	now := nowMs()
+	var pending []*stream.PendingEntry
+	if count > 0 {
+		g.RangePending(c, start, func(pe *stream.PendingEntry) bool {
+			if pe.ID.Compare(end) > 0 {
+				return false
+			}
+			if now-pe.DeliveryTime >= minIdle {
+				pending = append(pending, pe)
+			}
+			return len(pending) < count
+		})
+	}
+	writer.WriteArray(len(pending))
+	for _, pe := range pending {
+		writer.WriteArray(4)
+		writer.WriteBulkString(pe.ID.String())
+		writer.WriteBulkString(pe.Consumer.Name)
+		writer.WriteInt64(max(now-pe.DeliveryTime, 0))
+		writer.WriteUint64(pe.DeliveryCount)
+	}
+}
+
+// claimPending claims pending entry of id to consumer like XCLAIM, it returns
+// the entry if claimed, and deleted is true if entry has been deleted from stream.
+func claimPending(key string, s Stream, g *stream.Group, c *stream.Consumer, id stream.ID,
+	minIdle, now int64, opts *claimOptions) (pe *stream.PendingEntry, deleted bool) {
+	pe = g.Pending(id)
+	if pe == nil {
+		if !opts.force || !s.Exist(id) {
+			return nil, false
+		}
+		pe = g.Deliver(id, c, now)
+		pe.DeliveryCount = 0
+	}
+	if minIdle > 0 && now-pe.DeliveryTime < minIdle {
+		return nil, false
+	}
+	if !s.Exist(id) {
+		g.Ack(id)
+		propagate("xack", key, g.Name, id.String())
+		return nil, true
+	}
+	g.Claim(pe, c)
+	pe.DeliveryTime = now - opts.idle
+	if opts.time >= 0 {
+		pe.DeliveryTime = opts.time
+	}
+	if opts.retryCount >= 0 {
+		pe.DeliveryCount = uint64(opts.retryCount)
+	} else if !opts.justID {
+		pe.DeliveryCount++
+	}
+	return pe, false
+}
+
+type claimOptions struct {
+	idle       int64
+	time       int64 // -1 means not set
+	retryCount int64 // -1 means not set
+	force      bool
+	justID     bool
+}
+
+func xclaimCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := args[0].String()
+	s, g, err := fetchGroup(args[0].Bytes(), b2s(args[1].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	minIdle, err := strconv.ParseInt(b2s(args[3].Bytes()), 10, 64)
+	if err != nil {
+		writer.WriteError(errInvalidMinIdle.Error())
+		return
+	}
+
+	// ids followed by options
+	var ids []stream.ID
+	extra := args[4:]
+	for len(extra) > 0 {
+		id, err := stream.ParseID(b2s(extra[0].Bytes()), 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+		extra = extra[1:]
+	}
+	if len(ids) == 0 {
+		writer.WriteError(stream.ErrInvalidID.Error())
+		return
+	}
+
+	opts := claimOptions{time: -1, retryCount: -1}
+	var lastID stream.ID
+	var withLastID bool
+	for len(extra) > 0 {
+		arg := b2s(extra[0].Bytes())
+		if equalFold(arg, Force) {
+			opts.force = true
+			extra = extra[1:]
+		} else if equalFold(arg, JustID) {
+			opts.justID = true
+			extra = extra[1:]
+		} else if len(extra) >= 2 && (equalFold(arg, Idle) || equalFold(arg, Time) || equalFold(arg, RetryCount)) {
+			n, err := strconv.ParseInt(b2s(extra[1].Bytes()), 10, 64)
+			if err != nil {
+				writer.WriteError(errParseInteger.Error())
+				return
+			}
+			switch {
+			case equalFold(arg, Idle):
+				opts.idle = n
+			case equalFold(arg, Time):
+				opts.time = n
+			default:
+				opts.retryCount = n
+			}
+			extra = extra[2:]
+		} else if equalFold(arg, LastID) && len(extra) >= 2 {
+			lastID, err = stream.ParseID(b2s(extra[1].Bytes()), 0)
+			if err != nil {
+				writer.WriteError(err.Error())
+				return
+			}
+			withLastID = true
+			extra = extra[2:]
+		} else {
+			writer.WriteError(fmt.Sprintf("ERR Unrecognized XCLAIM option '%s'", arg))
+			return
+		}
+	}
+
+	if withLastID && g.LastID.Less(lastID) {
+		g.LastID = lastID
+	}
+	now := nowMs()
+	c, created := g.Consumer(args[2].String(), true)
+	c.SeenTime = now
+	if created {
+		propagate("xgroup", "createconsumer", key, g.Name, c.Name)
+	}
+
+	var claimed []*stream.PendingEntry
+	for _, id := range ids {
+		pe, _ := claimPending(key, s, g, c, id, minIdle, now, &opts)
+		if pe != nil {
+			claimed = append(claimed, pe)
+			propagateClaim(key, g, pe)
+		}
+	}
+	// make sure aof is rewritten even if nothing claimed.
+	propagate()
+
+	writer.WriteArray(len(claimed))
+	for _, pe := range claimed {
+		if opts.justID {
+			writer.WriteBulkString(pe.ID.String())
+		} else {
+			writeStreamEntry(writer, rangeStream(s, pe.ID, pe.ID, false, 1)[0])
+		}
+	}
+}
+
+func xautoclaimCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := args[0].String()
+	s, g, err := fetchGroup(args[0].Bytes(), b2s(args[1].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	minIdle, err := strconv.ParseInt(b2s(args[3].Bytes()), 10, 64)
+	if err != nil {
+		writer.WriteError(errInvalidMinIdle.Error())
+		return
+	}
+	start, err := parseRangeID(args[4].Bytes(), true)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+
+	count := 100
+	opts := claimOptions{time: -1, retryCount: -1}
+	extra := args[5:]
+	for len(extra) > 0 {
+		arg := b2s(extra[0].Bytes())
+		if equalFold(arg, Count) && len(extra) >= 2 {
+			count, err = strconv.Atoi(b2s(extra[1].Bytes()))
+			if err != nil || count <= 0 {
+				writer.WriteError(errCountPositive.Error())
+				return
+			}
+			extra = extra[2:]
+		} else if equalFold(arg, JustID) {
+			opts.justID = true
+			extra = extra[1:]
+		} else {
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+	}
+
+	now := nowMs()
+	c, created := g.Consumer(args[2].String(), true)
+	c.SeenTime = now
+	if created {
+		propagate("xgroup", "createconsumer", key, g.Name, c.Name)
+	}
+
+	// collect candidates first since claiming modifies PEL.
+	var candidates []stream.ID
+	next := stream.MinID
+	g.RangePending(nil, start, func(pe *stream.PendingEntry) bool {
+		if len(candidates) == count {
+			next = pe.ID
+			return false
+		}
+		candidates = append(candidates, pe.ID)
+		return true
+	})
+
+	var claimed []*stream.PendingEntry
+	var deleted []stream.ID
+	for _, id := range candidates {
+		pe, isDeleted := claimPending(key, s, g, c, id, minIdle, now, &opts)
+		if pe != nil {
+			claimed = append(claimed, pe)
+			propagateClaim(key, g, pe)
+		} else if isDeleted {
+			deleted = append(deleted, id)
+		}
+	}
+	propagate()
+
+	writer.WriteArray(3)
+	writer.WriteBulkString(next.String())
+	writer.WriteArray(len(claimed))
+	for _, pe := range claimed {
+		if opts.justID {
+			writer.WriteBulkString(pe.ID.String())
+		} else {
+			writeStreamEntry(writer, rangeStream(s, pe.ID, pe.ID, false, 1)[0])
+		}
+	}
+	writer.WriteArray(len(deleted))
+	for _, id := range deleted {
+		writer.WriteBulkString(id.String())
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/resp"
+)
+
+// ceilSeconds converts duration in nanoseconds to seconds, rounding up.
+func ceilSeconds(ns int64) int64 {
+	return (ns + int64(time.Second) - 1) / int64(time.Second)
+}
+
+// mulInt64 returns a*b of non-negative a and b, ok is false if it overflows.
+func mulInt64(a, b int64) (int64, bool) {
+	hi, lo := bits.Mul64(uint64(a), uint64(b))
+	return int64(lo), hi == 0 && lo <= math.MaxInt64
+}
+
+// lookupTAT returns the theoretical arrival time stored in key, ok is false if key
+// not exist.
+func lookupTAT(key string) (tat int64, ok bool, err error) {
+	object, ttl := db.dict.Get(key)
+	if ttl == KeyNotExist {
+		return 0, false, nil
+	}
+	switch v := object.(type) {
+	case []byte:
+		tat, err = strconv.ParseInt(b2s(v), 10, 64)
+		if err != nil {
+			return 0, false, errParseInteger
+		}
+		return tat, true, nil
+	case int:
+		return int64(v), true, nil
+	}
+	return 0, false, errWrongType
+}
+
+// clThrottleCommand is a rate limiter based on generic cell rate algorithm, state
+// of key is its theoretical arrival time (TAT) in unix nanoseconds, stored as a
+// string that expires at TAT.
+// CL.THROTTLE key max_burst count_per_period period [quantity]
+func clThrottleCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := b2s(args[0].Bytes())
+	params := make([]int64, 4)
+	params[3] = 1 // quantity
+	if len(args) > 5 {
+		writer.WriteError(errWrongArity("cl.throttle").Error())
+		return
+	}
+	for i, arg := range args[1:] {
+		n, err := strconv.ParseInt(b2s(arg.Bytes()), 10, 64)
+		if err != nil || n < 0 {
+			writer.WriteError(errParseInteger.Error())
+			return
+		}
+		params[i] = n
+	}
+	maxBurst, count, period, quantity := params[0], params[1], params[2], params[3]
+	if count == 0 || period == 0 {
+		writer.WriteError(errThrottleRate.Error())
+		return
+	}
+
+	tat, ok, err := lookupTAT(key)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	now := time.Now().UnixNano()
+	if !ok {
+		tat = now
+	}
+
+	// all in nanoseconds, emission interval less than 1ns is not supported.
+	periodNs, ok1 := mulInt64(period, int64(time.Second))
+	emissionInterval := periodNs / count
+	tolerance, ok2 := mulInt64(emissionInterval, maxBurst+1)
+	increment, ok3 := mulInt64(emissionInterval, quantity)
+	base := max(tat, now)
+	if !ok1 || !ok2 || !ok3 || emissionInterval == 0 || increment > math.MaxInt64-base {
+		writer.WriteError(errThrottleRange.Error())
+		return
+	}
+	newTat := base + increment
+	diff := tolerance - (newTat - now)
+
+	limited := diff < 0
+	retryAfter, ttl := int64(-1), newTat-now
+	if limited {
+		if increment <= tolerance {
+			retryAfter = ceilSeconds(-diff)
+		}
+		ttl = base - now
+		propagate()
+
+	} else {
+		tatStr := strconv.FormatInt(newTat, 10)
+		db.dict.SetWithTTL(strings.Clone(key), []byte(tatStr), newTat)
+		// persist with the absolute expire time.
+		expireAt := newTat / int64(time.Millisecond)
+		if newTat%int64(time.Millisecond) != 0 {
+			expireAt++
+		}
+		propagate("set", key, tatStr, PXAT, strconv.FormatInt(expireAt, 10))
+	}
+
+	var remaining int64
+	if next := tolerance - ttl; next > -emissionInterval {
+		remaining = max(next/emissionInterval, 0)
+	}
+	writer.WriteArray(5)
+	writer.WriteInt(b2i(limited))
+	writer.WriteInt64(maxBurst + 1)
+	writer.WriteInt64(remaining)
+	writer.WriteInt64(retryAfter)
+	writer.WriteInt64(ceilSeconds(ttl))
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/resp"
+	"github.com/xgzlucario/rotom/internal/timeseries"
+)
+
+const (
+	Retention       = "RETENTION"
+	ChunkSize       = "CHUNK_SIZE"
+	DuplicatePolicy = "DUPLICATE_POLICY"
+	OnDuplicate     = "ON_DUPLICATE"
+	Labels          = "LABELS"
+	FilterByTs      = "FILTER_BY_TS"
+	FilterByValue   = "FILTER_BY_VALUE"
+	Aggregation     = "AGGREGATION"
+	WithLabels      = "WITHLABELS"
+	Filter          = "FILTER"
+)
+
+// lookupSeries returns the time series of key, s is nil if key not exist.
+func lookupSeries(key string) (*timeseries.Series, error) {
+	object, ttl := db.dict.Get(key)
+	if ttl == KeyNotExist {
+		return nil, nil
+	}
+	s, ok := object.(*timeseries.Series)
+	if !ok {
+		return nil, errWrongType
+	}
+	return s, nil
+}
+
+// parseTimestamp parses non-negative timestamp in milliseconds.
+func parseTimestamp(b []byte) (int64, error) {
+	ts, err := strconv.ParseInt(b2s(b), 10, 64)
+	if err != nil || ts < 0 {
+		return 0, errTSInvalidTimestamp
+	}
+	return ts, nil
+}
+
+// parseRangeTimestamp parses timestamp of range, accepting "-" and "+".
+func parseRangeTimestamp(b []byte, err error) (int64, error) {
+	switch b2s(b) {
+	case "-":
+		return 0, nil
+	case "+":
+		return math.MaxInt64, nil
+	}
+	ts, e := strconv.ParseInt(b2s(b), 10, 64)
+	if e != nil || ts < 0 {
+		return 0, err
+	}
+	return ts, nil
+}
+
+func formatSampleValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// parseSeriesOptions parses options of TS.CREATE and TS.ADD, onDuplicate is only
+// accepted by TS.ADD.
+func parseSeriesOptions(args []redcon.RESP, add bool) (opts timeseries.Options, onDuplicate timeseries.DuplicatePolicy, err error) {
+	for len(args) > 0 {
+		arg := b2s(args[0].Bytes())
+		switch {
+		case equalFold(arg, Labels):
+			if len(args)%2 == 0 {
+				return opts, 0, errTSBadLabels
+			}
+			opts.Labels = opts.Labels[:0]
+			for i := 1; i < len(args); i += 2 {
+				name, value := args[i].String(), args[i+1].String()
+				if name == "" || value == "" {
+					return opts, 0, errTSBadLabels
+				}
+				opts.Labels = append(opts.Labels, timeseries.Label{Name: name, Value: value})
+			}
+			return opts, onDuplicate, nil
+
+		case len(args) < 2:
+			return opts, 0, errSyntax
+
+		case equalFold(arg, Retention):
+			opts.Retention, err = strconv.ParseInt(b2s(args[1].Bytes()), 10, 64)
+			if err != nil || opts.Retention < 0 {
+				return opts, 0, errTSBadRetention
+			}
+
+		case equalFold(arg, ChunkSize):
+			opts.ChunkSize, err = strconv.Atoi(b2s(args[1].Bytes()))
+			if err != nil || opts.ChunkSize < 48 || opts.ChunkSize > 1048576 || opts.ChunkSize%8 != 0 {
+				return opts, 0, errTSBadChunkSize
+			}
+
+		case equalFold(arg, DuplicatePolicy):
+			policy, ok := timeseries.ParseDuplicatePolicy(b2s(args[1].Bytes()))
+			if !ok {
+				return opts, 0, errTSBadDuplicatePolicy
+			}
+			opts.DuplicatePolicy = policy
+
+		case add && equalFold(arg, OnDuplicate):
+			policy, ok := timeseries.ParseDuplicatePolicy(b2s(args[1].Bytes()))
+			if !ok {
+				return opts, 0, errTSBadDuplicatePolicy
+			}
+			onDuplicate = policy
+
+		default:
+			return opts, 0, errSyntax
+		}
+		args = args[2:]
+	}
+	return opts, onDuplicate, nil
+}
+
+// parseAggregation parses [aggregator bucketDuration] after AGGREGATION.
+func parseAggregation(args []redcon.RESP) (timeseries.Aggregation, int64, error) {
+	if len(args) < 2 {
+		return 0, 0, errSyntax
+	}
+	agg, ok := timeseries.ParseAggregation(b2s(args[0].Bytes()))
+	if !ok {
+		return 0, 0, errTSUnknownAggregation
+	}
+	bucket, err := strconv.ParseInt(b2s(args[1].Bytes()), 10, 64)
+	if err != nil || bucket <= 0 {
+		return 0, 0, errTSBadBucket
+	}
+	return agg, bucket, nil
+}
+
+// addSample adds sample into series of key and feeds compaction rules of series.
+func addSample(key string, s *timeseries.Series, ts int64, value float64, policy timeseries.DuplicatePolicy) error {
+	if err := s.Add(ts, value, policy); err != nil {
+		return err
+	}
+	s.Compact(ts, func(r *timeseries.Rule, sample timeseries.Sample) {
+		dest, _ := lookupSeries(r.DestKey)
+		if dest != nil {
+			_ = addSample(r.DestKey, dest, sample.Ts, sample.Value, timeseries.PolicyLast)
+		}
+	})
+	return nil
+}
+
+func tsCreateCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := args[0].String()
+	opts, _, err := parseSeriesOptions(args[1:], false)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if _, ttl := db.dict.Get(key); ttl != KeyNotExist {
+		writer.WriteError(errTSKeyExists.Error())
+		return
+	}
+	db.dict.Set(key, timeseries.New(opts))
+	writer.WriteString("OK")
+}
+
+func tsAddCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := args[0].String()
+	var ts int64
+	var err error
+	auto := b2s(args[1].Bytes()) == "*"
+	if auto {
+		ts = time.Now().UnixMilli()
+	} else if ts, err = parseTimestamp(args[1].Bytes()); err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	value, err := strconv.ParseFloat(b2s(args[2].Bytes()), 64)
+	if err != nil || math.IsNaN(value) {
+		writer.WriteError(errTSInvalidValue.Error())
+		return
+	}
+	opts, onDuplicate, err := parseSeriesOptions(args[3:], true)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+
+	s, err := lookupSeries(key)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if s == nil {
+		s = timeseries.New(opts)
+		db.dict.Set(key, s)
+	}
+	if err := addSample(key, s, ts, value, onDuplicate); err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+
+	// persist with the generated timestamp.
+	if auto {
+		propagateArgs := make([]string, 0, len(args)+1)
+		propagateArgs = append(propagateArgs, "ts.add", key, strconv.FormatInt(ts, 10))
+		for _, arg := range args[2:] {
+			propagateArgs = append(propagateArgs, b2s(arg.Bytes()))
+		}
+		propagate(propagateArgs...)
+	}
+	writer.WriteInt64(ts)
+}
+
+func tsCreateRuleCommand(writer *resp.Writer, args []redcon.RESP) {
+	srcKey, destKey := args[0].String(), args[1].String()
+	if !equalFold(b2s(args[2].Bytes()), Aggregation) {
+		writer.WriteError(errSyntax.Error())
+		return
+	}
+	if len(args) != 5 {
+		writer.WriteError(errWrongArity("ts.createrule").Error())
+		return
+	}
+	agg, bucket, err := parseAggregation(args[3:])
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if srcKey == destKey {
+		writer.WriteError(errTSSameKey.Error())
+		return
+	}
+	src, err := lookupSeries(srcKey)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	dest, err := lookupSeries(destKey)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if src == nil || dest == nil {
+		writer.WriteError(errTSKeyNotExist.Error())
+		return
+	}
+	switch {
+	case src.Rule(destKey) != nil:
+		writer.WriteError(errTSRuleExists.Error())
+	case dest.SrcKey() != "":
+		writer.WriteError(errTSDestHasSrc.Error())
+	case len(dest.Rules()) > 0:
+		writer.WriteError(errTSDestHasDest.Error())
+	case src.SrcKey() != "":
+		writer.WriteError(errTSSrcHasSrc.Error())
+	default:
+		src.CreateRule(srcKey, destKey, dest, agg, bucket)
+		writer.WriteString("OK")
+	}
+}
+
+// tsRangeSpec is the options of TS.RANGE and TS.MRANGE.
+type tsRangeSpec struct {
+	from, to   int64
+	filterTs   []int64
+	filterVal  bool
+	minV, maxV float64
+	count      int
+	agg        timeseries.Aggregation
+	bucket     int64
+	withLabels bool
+	matchers   []timeseries.Matcher
+}
+
+func parseTSRangeSpec(args []redcon.RESP, multi bool) (*tsRangeSpec, error) {
+	spec := &tsRangeSpec{count: -1}
+	var err error
+	if spec.from, err = parseRangeTimestamp(args[0].Bytes(), errTSBadFrom); err != nil {
+		return nil, err
+	}
+	if spec.to, err = parseRangeTimestamp(args[1].Bytes(), errTSBadTo); err != nil {
+		return nil, err
+	}
+	extra := args[2:]
+	for len(extra) > 0 {
+		arg := b2s(extra[0].Bytes())
+		switch {
+		case equalFold(arg, FilterByTs):
+			extra = extra[1:]
+			for len(extra) > 0 {
+				ts, err := strconv.ParseInt(b2s(extra[0].Bytes()), 10, 64)
+				if err != nil {
+					break
+				}
+				spec.filterTs = append(spec.filterTs, ts)
+				extra = extra[1:]
+			}
+			if len(spec.filterTs) == 0 {
+				return nil, errTSInvalidTimestamp
+			}
+			slices.Sort(spec.filterTs)
+
+		case equalFold(arg, FilterByValue) && len(extra) >= 3:
+			spec.minV, err = strconv.ParseFloat(b2s(extra[1].Bytes()), 64)
+			if err != nil {
+				return nil, errTSInvalidValue
+			}
+			spec.maxV, err = strconv.ParseFloat(b2s(extra[2].Bytes()), 64)
+			if err != nil {
+				return nil, errTSInvalidValue
+			}
+			spec.filterVal = true
+			extra = extra[3:]
+
+		case equalFold(arg, Count) && len(extra) >= 2:
+			spec.count, err = strconv.Atoi(b2s(extra[1].Bytes()))
+			if err != nil || spec.count <= 0 {
+				return nil, errTSBadCount
+			}
+			extra = extra[2:]
+
+		case equalFold(arg, Aggregation):
+			spec.agg, spec.bucket, err = parseAggregation(extra[1:])
+			if err != nil {
+				return nil, err
+			}
+			extra = extra[3:]
+
+		case multi && equalFold(arg, WithLabels):
+			spec.withLabels = true
+			extra = extra[1:]
+
+		case multi && equalFold(arg, Filter):
+			positive := false
+			for _, expr := range extra[1:] {
+				m, ok := timeseries.ParseMatcher(expr.String())
+				if !ok {
+					return nil, errTSBadFilter
+				}
+				positive = positive || m.Positive()
+				spec.matchers = append(spec.matchers, m)
+			}
+			if !positive {
+				return nil, errTSBadFilter
+			}
+			extra = nil
+
+		default:
+			return nil, errSyntax
+		}
+	}
+	if multi && len(spec.matchers) == 0 {
+		return nil, errTSMissingFilter
+	}
+	return spec, nil
+}
+
+// samples returns the filtered and aggregated samples of series.
+func (spec *tsRangeSpec) samples(s *timeseries.Series) []timeseries.Sample {
+	samples := slices.DeleteFunc(s.Range(spec.from, spec.to), func(sample timeseries.Sample) bool {
+		if spec.filterTs != nil {
+			if _, found := slices.BinarySearch(spec.filterTs, sample.Ts); !found {
+				return true
+			}
+		}
+		return spec.filterVal && (sample.Value < spec.minV || sample.Value > spec.maxV)
+	})
+	if spec.agg != 0 {
+		var res []timeseries.Sample
+		timeseries.Aggregate(samples, spec.agg, spec.bucket, 0, func(sample timeseries.Sample) bool {
+			res = append(res, sample)
+			return spec.count < 0 || len(res) < spec.count
+		})
+		samples = res
+	}
+	if spec.count >= 0 && len(samples) > spec.count {
+		samples = samples[:spec.count]
+	}
+	return samples
+}
+
+func writeSamples(writer *resp.Writer, samples []timeseries.Sample) {
+	writer.WriteArray(len(samples))
+	for _, sample := range samples {
+		writer.WriteArray(2)
+		writer.WriteInt64(sample.Ts)
+		writer.WriteString(formatSampleValue(sample.Value))
+	}
+}
+
+func tsRangeCommand(writer *resp.Writer, args []redcon.RESP) {
+	spec, err := parseTSRangeSpec(args[1:], false)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	s, err := lookupSeries(b2s(args[0].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if s == nil {
+		writer.WriteError(errTSKeyNotExist.Error())
+		return
+	}
+	writeSamples(writer, spec.samples(s))
+}
+
+func tsMRangeCommand(writer *resp.Writer, args []redcon.RESP) {
+	spec, err := parseTSRangeSpec(args, true)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	var keys []string
+	db.dict.data.All(func(key string, object any) bool {
+		if s, ok := object.(*timeseries.Series); ok && s.Match(spec.matchers) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	slices.Sort(keys)
+
+	// skip expired keys.
+	series := make([]*timeseries.Series, 0, len(keys))
+	keys = slices.DeleteFunc(keys, func(key string) bool {
+		s, _ := lookupSeries(key)
+		if s != nil {
+			series = append(series, s)
+		}
+		return s == nil
+	})
+
+	writer.WriteArray(len(keys))
+	for i, key := range keys {
+		writer.WriteArray(3)
+		writer.WriteBulkString(key)
+		if spec.withLabels {
+			labels := series[i].Labels()
+			writer.WriteArray(len(labels))
+			for _, l := range labels {
+				writer.WriteArray(2)
+				writer.WriteBulkString(l.Name)
+				writer.WriteBulkString(l.Value)
+			}
+		} else {
+			writer.WriteArray(0)
+		}
+		writeSamples(writer, spec.samples(series[i]))
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xgzlucario/rotom/internal/net"
+)
+
+// tlsHandshakeTimeout is the time limit of TLS handshake.
+const tlsHandshakeTimeout = 10 * time.Second
+
+// tlsConn is the TLS connection of client. Records are read and decrypted into
+// queryBuf in the event loop, and replies are encrypted into the buffer of raw
+// connection, which is sent when socket is writable.
+type tlsConn struct {
+	*tls.Conn
+	raw *net.Conn
+}
+
+// newTLSConfig creates the TLS config of server from tls section, including the
+// certificate, the CA for authenticating clients, protocols and ciphers.
+func newTLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(configGetString("tls.cert-file"), configGetString("tls.key-file"))
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		MaxVersion:   tls.VersionTLS13,
+	}
+
+	switch auth := strings.ToLower(configGetString("tls.auth-clients")); auth {
+	case "yes":
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	case "optional":
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case "no":
+		config.ClientAuth = tls.NoClientCert
+	default:
+		return nil, fmt.Errorf("invalid tls auth-clients: %q", auth)
+	}
+	if config.ClientAuth != tls.NoClientCert {
+		caFile := configGetString("tls.ca-cert-file")
+		if caFile == "" {
+			return nil, errors.New("tls ca-cert-file is required to authenticate clients")
+		}
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", caFile)
+		}
+	}
+
+	// protocols is like "TLSv1.2 TLSv1.3".
+	if protocols := strings.Fields(configGetString("tls.protocols")); len(protocols) > 0 {
+		config.MinVersion, config.MaxVersion = 0, 0
+		for _, protocol := range protocols {
+			var version uint16
+			switch protocol {
+			case "TLSv1.2":
+				version = tls.VersionTLS12
+			case "TLSv1.3":
+				version = tls.VersionTLS13
+			default:
+				return nil, fmt.Errorf("invalid tls protocol: %q", protocol)
+			}
+			if config.MinVersion == 0 || version < config.MinVersion {
+				config.MinVersion = version
+			}
+			config.MaxVersion = max(config.MaxVersion, version)
+		}
+	}
+
+	// ciphers is the colon separated cipher suites of TLSv1.2, the ones of TLSv1.3
+	// are not configurable.
+	if ciphers := configGetString("tls.ciphers"); ciphers != "" {
+		suites := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			suites[suite.Name] = suite.ID
+		}
+		for _, name := range strings.Split(ciphers, ":") {
+			id, ok := suites[name]
+			if !ok {
+				return nil, fmt.Errorf("invalid tls cipher: %q", name)
+			}
+			config.CipherSuites = append(config.CipherSuites, id)
+		}
+	}
+	return config, nil
+}
+
+// AcceptTLSHandler accepts connections of TLS listeners, the handshake runs in a
+// goroutine, and client is created in the loop after it is done.
+func AcceptTLSHandler(loop *AeLoop, fd int, _ interface{}) {
+	for {
+		cfd, ok := acceptConn(fd)
+		if !ok {
+			return
+		}
+		startTLSHandshake(loop, cfd)
+	}
+}
+
+func startTLSHandshake(loop *AeLoop, cfd int) {
+	tuneTcpConn(cfd)
+	raw, err := net.NewConn(cfd)
+	if err != nil {
+		log.Error().Msgf("create tls conn err: %v", err)
+		_ = net.Close(cfd)
+		return
+	}
+	conn := tls.Server(raw, server.tlsConfig)
+	go func() {
+		_ = conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+		err := conn.Handshake()
+		_ = conn.SetDeadline(time.Time{})
+		_ = raw.Detach()
+		loop.Post(func() {
+			tlsHandshakeDone(loop, cfd, &tlsConn{Conn: conn, raw: raw}, err)
+		})
+	}()
+}
+
+func tlsHandshakeDone(loop *AeLoop, cfd int, conn *tlsConn, err error) {
+	if err != nil {
+		log.Warn().Msgf("tls handshake err: %v", err)
+		_ = net.Close(cfd)
+		return
+	}
+	addr, _ := net.PeerName(cfd)
+	laddr, _ := net.SockName(cfd)
+	client := createClient(loop, cfd, addr, laddr)
+	client.tls = conn
+	// the data arrived during handshake may be buffered by the connection.
+	ReadQueryFromClient(loop, cfd, client)
+}
+
+// connRead reads from the connection of client.
+func connRead(client *Client, buf []byte) (int, error) {
+	if client.tls == nil {
+		return net.Read(client.fd, buf)
+	}
+	n, err := client.tls.Read(buf)
+	if errors.Is(err, io.EOF) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// connWrite writes to the connection of client, buf is encrypted as a whole for
+// TLS connection, and it returns net.ErrAgain if the previous data not sent.
+func connWrite(client *Client, buf []byte) (int, error) {
+	if client.tls == nil {
+		return net.Write(client.fd, buf)
+	}
+	if err := client.tls.raw.Flush(); err != nil {
+		return 0, err
+	}
+	if _, err := client.tls.Write(buf); err != nil {
+		return 0, err
+	}
+	if err := client.tls.raw.Flush(); err != nil && !errors.Is(err, net.ErrAgain) {
+		return 0, err
+	}
+	return len(buf), nil
+}
+
+// connFlush sends the data buffered by the connection of client.
+func connFlush(client *Client) error {
+	if client.tls == nil {
+		return nil
+	}
+	return client.tls.raw.Flush()
+}
+
+// connBuffered returns the bytes buffered by the connection of client.
+func connBuffered(client *Client) int {
+	if client.tls == nil {
+		return 0
+	}
+	return client.tls.raw.Buffered()
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/resp"
+)
+
+const (
+	On       = "ON"
+	Off      = "OFF"
+	Yes      = "YES"
+	No       = "NO"
+	Redirect = "REDIRECT"
+	Prefix   = "PREFIX"
+	Bcast    = "BCAST"
+	OptIn    = "OPTIN"
+	OptOut   = "OPTOUT"
+	NoLoop   = "NOLOOP"
+
+	// trackingChannel is the channel receiving invalidation messages for RESP2
+	// clients in REDIRECT mode.
+	trackingChannel = "__redis__:invalidate"
+)
+
+// trackingState is the client side caching state of client enabled by CLIENT
+// TRACKING.
+// In default mode, keys read by client are remembered in the tracking table and
+// invalidated once when modified. In BCAST mode, client is notified of every
+// modified key matching its prefixes.
+type trackingState struct {
+	bcast  bool
+	optin  bool
+	optout bool
+	noloop bool
+	// redirect is the ID of client receiving invalidation messages, 0 means the
+	// client itself.
+	redirect uint64
+	prefixes []string
+	// caching is set by CLIENT CACHING YES in OPTIN mode, or NO in OPTOUT mode,
+	// and applies to the next command only.
+	caching bool
+	// pending is the invalidated keys sent before the event loop sleeps, and
+	// flushed is set when database is flushed.
+	pending map[string]struct{}
+	flushed bool
+}
+
+// signalModifiedKey is called when key is modified, expired or deleted.
+func signalModifiedKey(key string) {
+	touchWatchedKey(key)
+	trackingInvalidateKey(key)
+}
+
+// signalFlushedDb is called when database is flushed.
+func signalFlushedDb() {
+	touchAllWatchedKeys()
+	trackingInvalidateAll()
+}
+
+// commandKeys returns the keys of cmd in args, which exclude command names.
+func commandKeys(cmd *Command, args []redcon.RESP) []string {
+	names := strings.Split(cmd.name, "|")
+	argv := make([]redcon.RESP, 0, len(names)+len(args))
+	for _, name := range names {
+		argv = append(argv, redcon.RESP{Data: []byte(name)})
+	}
+	argv = append(argv, args...)
+	positions := cmd.keyPositions(argv)
+	keys := make([]string, 0, len(positions))
+	for _, i := range positions {
+		keys = append(keys, b2s(argv[i].Bytes()))
+	}
+	return keys
+}
+
+func enableTracking(client *Client, opts *trackingState) {
+	t := client.tracking
+	if t == nil {
+		t = &trackingState{bcast: opts.bcast}
+		client.tracking = t
+		server.trackingClients[client] = struct{}{}
+	}
+	t.optin, t.optout, t.noloop = opts.optin, opts.optout, opts.noloop
+	t.redirect = opts.redirect
+	t.caching = false
+	if !t.bcast {
+		return
+	}
+	// BCAST without prefix tracks all keys.
+	prefixes := opts.prefixes
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+	for _, prefix := range prefixes {
+		if slices.Contains(t.prefixes, prefix) {
+			continue
+		}
+		t.prefixes = append(t.prefixes, prefix)
+		clients := server.trackingPrefixes[prefix]
+		if clients == nil {
+			clients = make(map[*Client]struct{})
+			server.trackingPrefixes[prefix] = clients
+		}
+		clients[client] = struct{}{}
+	}
+}
+
+// disableTracking disables tracking of client, keys remembered in the tracking
+// table are left and skipped when invalidated.
+func disableTracking(client *Client) {
+	t := client.tracking
+	if t == nil {
+		return
+	}
+	for _, prefix := range t.prefixes {
+		clients := server.trackingPrefixes[prefix]
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(server.trackingPrefixes, prefix)
+		}
+	}
+	delete(server.trackingClients, client)
+	client.tracking = nil
+}
+
+// trackingCommandCalled remembers the keys read by client in default mode, and
+// resets CLIENT CACHING after the next command. Transactions are tracked as a
+// whole, so MULTI does not reset it.
+func trackingCommandCalled(client *Client, cmd *Command, args []redcon.RESP) {
+	t := client.tracking
+	if t == nil {
+		return
+	}
+	track := !t.bcast && cmd.hasFlag("readonly")
+	switch {
+	case t.optin:
+		track = track && t.caching
+	case t.optout:
+		track = track && !t.caching
+	}
+	if track {
+		for _, key := range commandKeys(cmd, args) {
+			ids := server.trackingTable[key]
+			if ids == nil {
+				ids = make(map[uint64]struct{})
+				server.trackingTable[strings.Clone(key)] = ids
+			}
+			ids[client.id] = struct{}{}
+		}
+	}
+	if !server.inExec && cmd.name != "client|caching" && cmd.name != "multi" {
+		t.caching = false
+	}
+}
+
+// signalModifiedCommand signals keys of write command as modified, since commands
+// may modify objects in place without calling dict. reply is the reply of cmd,
+// command failed with error reply modifies nothing.
+func signalModifiedCommand(cmd *Command, args []redcon.RESP, reply []byte) {
+	if len(reply) > 0 && reply[0] == '-' {
+		return
+	}
+	if len(server.watchedKeys) == 0 && len(server.trackingClients) == 0 {
+		return
+	}
+	for _, key := range commandKeys(cmd, args) {
+		signalModifiedKey(key)
+	}
+}
+
+func trackingInvalidateKey(key string) {
+	if len(server.trackingClients) == 0 {
+		return
+	}
+	if ids, ok := server.trackingTable[key]; ok {
+		delete(server.trackingTable, key)
+		for id := range ids {
+			client := server.clientsByID[id]
+			if client == nil || client.tracking == nil || client.tracking.bcast {
+				continue
+			}
+			trackingAddPending(client, key)
+		}
+	}
+	for prefix, clients := range server.trackingPrefixes {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		for client := range clients {
+			trackingAddPending(client, key)
+		}
+	}
+}
+
+// trackingInvalidateAll notifies all tracking clients that database is flushed.
+func trackingInvalidateAll() {
+	clear(server.trackingTable)
+	for client := range server.trackingClients {
+		t := client.tracking
+		if len(t.pending) == 0 && !t.flushed {
+			server.trackingPending = append(server.trackingPending, client)
+		}
+		t.pending, t.flushed = nil, true
+	}
+}
+
+// trackingAddPending adds invalidated key for client, which is sent before the
+// event loop sleeps, so that it follows the reply of current command.
+func trackingAddPending(client *Client, key string) {
+	t := client.tracking
+	if t.noloop && client == server.current {
+		return
+	}
+	if len(t.pending) == 0 && !t.flushed {
+		server.trackingPending = append(server.trackingPending, client)
+	}
+	if t.pending == nil {
+		t.pending = make(map[string]struct{})
+	}
+	if _, ok := t.pending[key]; !ok {
+		t.pending[strings.Clone(key)] = struct{}{}
+	}
+}
+
+// trackingHandlePending sends the pending invalidation messages, it is called
+// before the event loop sleeps.
+func trackingHandlePending() {
+	for _, client := range server.trackingPending {
+		// client may be freed or disable tracking.
+		t := client.tracking
+		if t == nil {
+			continue
+		}
+		if t.flushed {
+			sendTrackingMessage(client, nil)
+		}
+		if len(t.pending) > 0 {
+			sendTrackingMessage(client, slices.Sorted(maps.Keys(t.pending)))
+		}
+		t.pending, t.flushed = nil, false
+	}
+	server.trackingPending = server.trackingPending[:0]
+}
+
+// sendTrackingMessage sends invalidated keys to client or its redirect client,
+// nil keys means database is flushed. Invalidations are pushed to RESP3 clients,
+// and published to RESP2 clients subscribing trackingChannel.
+func sendTrackingMessage(client *Client, keys []string) {
+	target := client
+	if id := client.tracking.redirect; id != 0 {
+		target = server.clientsByID[id]
+		if target == nil {
+			if client.replyWriter.Proto() == resp.RESP3 {
+				client.replyWriter.WritePush(2)
+				client.replyWriter.WriteBulkString("tracking-redir-broken")
+				client.replyWriter.WriteInt64(int64(id))
+				installWriteHandler(client)
+			}
+			return
+		}
+	}
+	writer := target.replyWriter
+	if writer.Proto() == resp.RESP3 {
+		writer.WritePush(2)
+		writer.WriteBulkString("invalidate")
+	} else if _, ok := target.channels[trackingChannel]; ok {
+		writer.WriteArray(3)
+		writer.WriteBulkString("message")
+		writer.WriteBulkString(trackingChannel)
+	} else {
+		return
+	}
+	if keys == nil {
+		writer.WriteNullArray()
+	} else {
+		writer.WriteArray(len(keys))
+		for _, key := range keys {
+			writer.WriteBulkString(key)
+		}
+	}
+	installWriteHandler(target)
+}
+
+// CLIENT TRACKING ON|OFF [REDIRECT client-id] [PREFIX prefix [PREFIX prefix ...]]
+// [BCAST] [OPTIN] [OPTOUT] [NOLOOP]
+func clientTrackingCommand(writer *resp.Writer, args []redcon.RESP) {
+	client := server.current
+	if client == nil {
+		return
+	}
+	var on bool
+	switch mode := b2s(args[0].Bytes()); {
+	case equalFold(mode, On):
+		on = true
+	case !equalFold(mode, Off):
+		writer.WriteError(errSyntax.Error())
+		return
+	}
+
+	var opts trackingState
+	for i := 1; i < len(args); i++ {
+		switch arg := b2s(args[i].Bytes()); {
+		case equalFold(arg, Redirect) && i+1 < len(args):
+			i++
+			id, err := strconv.ParseUint(b2s(args[i].Bytes()), 10, 64)
+			if err != nil || server.clientsByID[id] == nil {
+				writer.WriteError(errTrackingRedirect.Error())
+				return
+			}
+			opts.redirect = id
+		case equalFold(arg, Prefix) && i+1 < len(args):
+			i++
+			opts.prefixes = append(opts.prefixes, args[i].String())
+		case equalFold(arg, Bcast):
+			opts.bcast = true
+		case equalFold(arg, OptIn):
+			opts.optin = true
+		case equalFold(arg, OptOut):
+			opts.optout = true
+		case equalFold(arg, NoLoop):
+			opts.noloop = true
+		default:
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+	}
+
+	if !on {
+		disableTracking(client)
+		writer.WriteString("OK")
+		return
+	}
+	t := client.tracking
+	switch {
+	case len(opts.prefixes) > 0 && !opts.bcast:
+		writer.WriteError(errTrackingPrefix.Error())
+	case opts.optin && opts.optout:
+		writer.WriteError(errTrackingOptInOptOut.Error())
+	case opts.bcast && (opts.optin || opts.optout):
+		writer.WriteError(errTrackingBcastOpt.Error())
+	case t != nil && (t.bcast != opts.bcast || t.optin != opts.optin || t.optout != opts.optout):
+		writer.WriteError(errTrackingSwitchMode.Error())
+	default:
+		enableTracking(client, &opts)
+		writer.WriteString("OK")
+	}
+}
+
+// CLIENT CACHING YES|NO
+func clientCachingCommand(writer *resp.Writer, args []redcon.RESP) {
+	client := server.current
+	if client == nil {
+		return
+	}
+	t := client.tracking
+	if t == nil || !t.optin && !t.optout {
+		writer.WriteError(errTrackingCaching.Error())
+		return
+	}
+	switch arg := b2s(args[0].Bytes()); {
+	case equalFold(arg, Yes) && !t.optin:
+		writer.WriteError(errTrackingCachingYes.Error())
+	case equalFold(arg, No) && !t.optout:
+		writer.WriteError(errTrackingCachingNo.Error())
+	case equalFold(arg, Yes), equalFold(arg, No):
+		t.caching = true
+		writer.WriteString("OK")
+	default:
+		writer.WriteError(errSyntax.Error())
+	}
+}
+
+// clientGetRedirCommand replies the client ID that invalidations are redirected
+// to, 0 if not redirected, or -1 if tracking is not enabled.
+func clientGetRedirCommand(writer *resp.Writer, _ []redcon.RESP) {
+	client := server.current
+	if client == nil || client.tracking == nil {
+		writer.WriteInt(-1)
+		return
+	}
+	writer.WriteInt64(int64(client.tracking.redirect))
+}
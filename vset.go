@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/resp"
+	"github.com/xgzlucario/rotom/internal/vector"
+)
+
+const (
+	Values   = "VALUES"
+	FP32     = "FP32"
+	Ele      = "ELE"
+	NoQuant  = "NOQUANT"
+	Q8       = "Q8"
+	Bin      = "BIN"
+	EF       = "EF"
+	NumLinks = "M"
+	Metric   = "METRIC"
+)
+
+// lookupVectorSet returns the vector set of key, s is nil if key not exist.
+func lookupVectorSet(key string) (*vector.Set, error) {
+	object, ttl := db.dict.Get(key)
+	if ttl == KeyNotExist {
+		return nil, nil
+	}
+	s, ok := object.(*vector.Set)
+	if !ok {
+		return nil, errWrongType
+	}
+	return s, nil
+}
+
+// parseVector parses (FP32 blob | VALUES num v1 v2 ...) and returns the number of
+// arguments consumed.
+func parseVector(args []redcon.RESP) ([]float32, int, error) {
+	if len(args) < 2 {
+		return nil, 0, errInvalidVector
+	}
+	switch arg := b2s(args[0].Bytes()); {
+	case equalFold(arg, FP32):
+		blob := args[1].Bytes()
+		if len(blob) == 0 || len(blob)%4 != 0 {
+			return nil, 0, errInvalidVector
+		}
+		v := make([]float32, len(blob)/4)
+		for i := range v {
+			v[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[i*4:]))
+		}
+		return v, 2, nil
+
+	case equalFold(arg, Values):
+		n, err := strconv.Atoi(b2s(args[1].Bytes()))
+		if err != nil || n <= 0 || len(args) < n+2 {
+			return nil, 0, errInvalidVector
+		}
+		v := make([]float32, n)
+		for i := range v {
+			f, err := strconv.ParseFloat(b2s(args[i+2].Bytes()), 32)
+			if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+				return nil, 0, errInvalidVector
+			}
+			v[i] = float32(f)
+		}
+		return v, n + 2, nil
+	}
+	return nil, 0, errInvalidVector
+}
+
+func formatVectorFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 32)
+}
+
+// vaddCommand adds element with vector into set, the metric and quantization are
+// decided when the set is created.
+// VADD key (FP32 blob | VALUES num v1 ...) element [NOQUANT | Q8 | BIN] [EF n] [M n] [METRIC COSINE | L2]
+func vaddCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := args[0].String()
+	v, n, err := parseVector(args[1:])
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	extra := args[n+1:]
+	if len(extra) == 0 {
+		writer.WriteError(errWrongArity("vadd").Error())
+		return
+	}
+	element := extra[0].String()
+	extra = extra[1:]
+
+	quant, metric := vector.QuantQ8, vector.MetricCosine
+	m, ef := vector.DefaultM, vector.DefaultEFConstruction
+	var hasQuant, hasMetric bool
+	for len(extra) > 0 {
+		arg := b2s(extra[0].Bytes())
+		switch {
+		case equalFold(arg, NoQuant):
+			quant, hasQuant = vector.QuantNone, true
+			extra = extra[1:]
+		case equalFold(arg, Q8):
+			quant, hasQuant = vector.QuantQ8, true
+			extra = extra[1:]
+		case equalFold(arg, Bin):
+			quant, hasQuant = vector.QuantBin, true
+			extra = extra[1:]
+		case equalFold(arg, EF) && len(extra) >= 2:
+			ef, err = strconv.Atoi(b2s(extra[1].Bytes()))
+			if err != nil || ef <= 0 || ef > 1000000 {
+				writer.WriteError(errInvalidEF.Error())
+				return
+			}
+			extra = extra[2:]
+		case equalFold(arg, NumLinks) && len(extra) >= 2:
+			m, err = strconv.Atoi(b2s(extra[1].Bytes()))
+			if err != nil || m < 4 || m > 4096 {
+				writer.WriteError(errInvalidM.Error())
+				return
+			}
+			extra = extra[2:]
+		case equalFold(arg, Metric) && len(extra) >= 2:
+			var ok bool
+			metric, ok = vector.ParseMetric(b2s(extra[1].Bytes()))
+			if !ok {
+				writer.WriteError(errInvalidMetric.Error())
+				return
+			}
+			hasMetric = true
+			extra = extra[2:]
+		default:
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+	}
+
+	s, err := lookupVectorSet(key)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if s == nil {
+		s = vector.New(len(v), metric, quant, m, ef)
+		db.dict.Set(key, s)
+	}
+	if len(v) != s.Dim() {
+		writer.WriteError(errVectorDimMismatch(len(v), s.Dim()).Error())
+		return
+	}
+	if hasQuant && quant != s.Quant() {
+		writer.WriteError(errQuantMismatch.Error())
+		return
+	}
+	if hasMetric && metric != s.Metric() {
+		writer.WriteError(errMetricMismatch.Error())
+		return
+	}
+	writer.WriteInt(b2i(s.Add(element, v)))
+}
+
+func vremCommand(writer *resp.Writer, args []redcon.RESP) {
+	key := b2s(args[0].Bytes())
+	s, err := lookupVectorSet(key)
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if s == nil || !s.Remove(b2s(args[1].Bytes())) {
+		writer.WriteInt(0)
+		return
+	}
+	if s.Len() == 0 {
+		db.dict.Delete(key)
+	}
+	writer.WriteInt(1)
+}
+
+// VSIM key (ELE element | FP32 blob | VALUES num v1 ...) [WITHSCORES] [COUNT n] [EF n]
+func vsimCommand(writer *resp.Writer, args []redcon.RESP) {
+	s, err := lookupVectorSet(b2s(args[0].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+
+	var v []float32
+	var element string
+	extra := args[1:]
+	if equalFold(b2s(extra[0].Bytes()), Ele) {
+		if len(extra) < 2 {
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+		element = extra[1].String()
+		extra = extra[2:]
+	} else {
+		var n int
+		if v, n, err = parseVector(extra); err != nil {
+			writer.WriteError(err.Error())
+			return
+		}
+		extra = extra[n:]
+	}
+
+	count, ef := 10, 0
+	var withScores bool
+	for len(extra) > 0 {
+		arg := b2s(extra[0].Bytes())
+		switch {
+		case equalFold(arg, WithScores):
+			withScores = true
+			extra = extra[1:]
+		case equalFold(arg, Count) && len(extra) >= 2:
+			count, err = strconv.Atoi(b2s(extra[1].Bytes()))
+			if err != nil || count <= 0 {
+				writer.WriteError(errCountPositive.Error())
+				return
+			}
+			extra = extra[2:]
+		case equalFold(arg, EF) && len(extra) >= 2:
+			ef, err = strconv.Atoi(b2s(extra[1].Bytes()))
+			if err != nil || ef <= 0 || ef > 1000000 {
+				writer.WriteError(errInvalidEF.Error())
+				return
+			}
+			extra = extra[2:]
+		default:
+			writer.WriteError(errSyntax.Error())
+			return
+		}
+	}
+	if s == nil {
+		writer.WriteArray(0)
+		return
+	}
+	if ef == 0 {
+		ef = max(count, 100)
+	}
+
+	var results []vector.Result
+	if v != nil {
+		if len(v) != s.Dim() {
+			writer.WriteError(errVectorDimMismatch(len(v), s.Dim()).Error())
+			return
+		}
+		results = s.Search(v, count, ef)
+	} else {
+		var ok bool
+		if results, ok = s.SearchElement(element, count, ef); !ok {
+			writer.WriteError(errElementNotFound.Error())
+			return
+		}
+	}
+
+	if withScores {
+		writer.WriteArray(len(results) * 2)
+	} else {
+		writer.WriteArray(len(results))
+	}
+	for _, r := range results {
+		writer.WriteBulkString(r.Name)
+		if withScores {
+			writer.WriteBulkString(strconv.FormatFloat(r.Score, 'f', -1, 64))
+		}
+	}
+}
+
+func vcardCommand(writer *resp.Writer, args []redcon.RESP) {
+	s, err := lookupVectorSet(b2s(args[0].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if s == nil {
+		writer.WriteInt(0)
+		return
+	}
+	writer.WriteInt(s.Len())
+}
+
+func vdimCommand(writer *resp.Writer, args []redcon.RESP) {
+	s, err := lookupVectorSet(b2s(args[0].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if s == nil {
+		writer.WriteError(errVectorKeyNotExist.Error())
+		return
+	}
+	writer.WriteInt(s.Dim())
+}
+
+func vembCommand(writer *resp.Writer, args []redcon.RESP) {
+	s, err := lookupVectorSet(b2s(args[0].Bytes()))
+	if err != nil {
+		writer.WriteError(err.Error())
+		return
+	}
+	if s == nil {
+		writer.WriteNull()
+		return
+	}
+	v, ok := s.Get(b2s(args[1].Bytes()))
+	if !ok {
+		writer.WriteNull()
+		return
+	}
+	writer.WriteArray(len(v))
+	for _, f := range v {
+		writer.WriteBulkString(formatVectorFloat(float64(f)))
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/redcon"
+	"github.com/xgzlucario/rotom/internal/resp"
+)
+
+const (
+	ByScore = "BYSCORE"
+	ByLex   = "BYLEX"
+	Rev     = "REV"
+	Limit   = "LIMIT"
+)
+
+// zrangeBy indicates how the min and max arguments of a range are interpreted.
+type zrangeBy byte
+
+const (
+	zrangeByRank zrangeBy = iota
+	zrangeByScore
+	zrangeByLex
+)
+
+type zsetEntry struct {
+	key   string
+	score float64
+}
+
+type scoreBound struct {
+	value     float64
+	exclusive bool
+}
+
+// lexBound is a member bound, inf is -1 for "-" and 1 for "+".
+type lexBound struct {
+	value     string
+	exclusive bool
+	inf       int
+}
+
+// zrangeSpec describes a range query over a sorted set, shared by ZRANGE,
+// ZRANGESTORE and all the legacy ZREVRANGE/ZRANGEBYSCORE/ZRANGEBYLEX forms.
+type zrangeSpec struct {
+	by         zrangeBy
+	rev        bool
+	withScores bool
+
+	start, stop int // by rank
+	smin, smax  scoreBound
+	lmin, lmax  lexBound
+
+	// offset and count of LIMIT, negative count means no limit.
+	offset int
+	count  int
+}
+
+// parseZRangeSpec parses `min max [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]`.
+// Legacy commands fix `by` and `rev` and only accept WITHSCORES and LIMIT.
+func parseZRangeSpec(args []redcon.RESP, by zrangeBy, rev bool, legacy bool) (*zrangeSpec, error) {
+	spec := &zrangeSpec{by: by, rev: rev, count: -1}
+	var hasLimit bool
+
+	extra := args[2:]
+	for len(extra) > 0 {
+		arg := b2s(extra[0].Bytes())
+		if !legacy && equalFold(arg, ByScore) && spec.by == zrangeByRank {
+			spec.by = zrangeByScore
+			extra = extra[1:]
+		} else if !legacy && equalFold(arg, ByLex) && spec.by == zrangeByRank {
+			spec.by = zrangeByLex
+			extra = extra[1:]
+		} else if !legacy && equalFold(arg, Rev) {
+			spec.rev = true
+			extra = extra[1:]
+		} else if equalFold(arg, WithScores) {
+			spec.withScores = true
+			extra = extra[1:]
+		} else if equalFold(arg, Limit) && len(extra) >= 3 {
+			offset, err1 := strconv.Atoi(b2s(extra[1].Bytes()))
+			count, err2 := strconv.Atoi(b2s(extra[2].Bytes()))
+			if err1 != nil || err2 != nil {
+				return nil, errParseInteger
+			}
+			spec.offset, spec.count = offset, count
+			hasLimit = true
+			extra = extra[3:]
+		} else {
+			return nil, errSyntax
+		}
+	}
+
+	if hasLimit && spec.by == zrangeByRank {
+		return nil, errLimitWithRank
+	}
+	if spec.withScores && spec.by == zrangeByLex {
+		return nil, errWithScoresWithLex
+	}
+
+	// reversed score and lex ranges take the arguments as `max min`.
+	lo, hi := args[0].Bytes(), args[1].Bytes()
+	if spec.rev && spec.by != zrangeByRank {
+		lo, hi = hi, lo
+	}
+
+	var err error
+	switch spec.by {
+	case zrangeByRank:
+		spec.start, err = strconv.Atoi(b2s(lo))
+		if err != nil {
+			return nil, errParseInteger
+		}
+		spec.stop, err = strconv.Atoi(b2s(hi))
+		if err != nil {
+			return nil, errParseInteger
+		}
+	case zrangeByScore:
+		if spec.smin, err = parseScoreBound(lo); err != nil {
+			return nil, err
+		}
+		if spec.smax, err = parseScoreBound(hi); err != nil {
+			return nil, err
+		}
+	case zrangeByLex:
+		if spec.lmin, err = parseLexBound(lo); err != nil {
+			return nil, err
+		}
+		if spec.lmax, err = parseLexBound(hi); err != nil {
+			return nil, err
+		}
+	}
+	return spec, nil
+}
+
+func parseScoreBound(b []byte) (scoreBound, error) {
+	var bound scoreBound
+	s := b2s(b)
+	if strings.HasPrefix(s, "(") {
+		bound.exclusive = true
+		s = s[1:]
+	}
+	f, err := parseFloat(s)
+	if err != nil {
+		return bound, errMinMaxNotFloat
+	}
+	bound.value = f
+	return bound, nil
+}
+
+func parseLexBound(b []byte) (lexBound, error) {
+	s := b2s(b)
+	switch {
+	case s == "-":
+		return lexBound{inf: -1}, nil
+	case s == "+":
+		return lexBound{inf: 1}, nil
+	case strings.HasPrefix(s, "["):
+		return lexBound{value: s[1:]}, nil
+	case strings.HasPrefix(s, "("):
+		return lexBound{value: s[1:], exclusive: true}, nil
+	}
+	return lexBound{}, errMinMaxNotString
+}
+
+// parseFloat parses score like redis, accepting "inf", "+inf" and "-inf".
+func parseFloat(s string) (float64, error) {
+	switch strings.ToLower(s) {
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, errNotFloat
+	}
+	return f, nil
+}
+
+func (spec *zrangeSpec) scoreInRange(score float64) bool {
+	if spec.smin.exclusive && score <= spec.smin.value || score < spec.smin.value {
+		return false
+	}
+	if spec.smax.exclusive && score >= spec.smax.value || score > spec.smax.value {
+		return false
+	}
+	return true
+}
+
+func (spec *zrangeSpec) lexInRange(key string) bool {
+	switch lo := spec.lmin; {
+	case lo.inf > 0:
+		return false
+	case lo.inf == 0 && (lo.exclusive && key <= lo.value || key < lo.value):
+		return false
+	}
+	switch hi := spec.lmax; {
+	case hi.inf < 0:
+		return false
+	case hi.inf == 0 && (hi.exclusive && key >= hi.value || key > hi.value):
+		return false
+	}
+	return true
+}
+
+// zrangeGeneric is the range engine for all zrange family commands.
+func zrangeGeneric(zs ZSet, spec *zrangeSpec) []zsetEntry {
+	entries := make([]zsetEntry, 0, zs.Len())
+	zs.Scan(func(key string, score float64) {
+		entries = append(entries, zsetEntry{key, score})
+	})
+	if spec.rev {
+		slices.Reverse(entries)
+	}
+
+	switch spec.by {
+	case zrangeByRank:
+		n := len(entries)
+		start, stop := spec.start, spec.stop
+		if start < 0 {
+			start += n
+		}
+		if stop < 0 {
+			stop += n
+		}
+		start = max(start, 0)
+		stop = min(stop, n-1)
+		if start > stop {
+			return nil
+		}
+		return entries[start : stop+1]
+
+	case zrangeByScore:
+		entries = slices.DeleteFunc(entries, func(e zsetEntry) bool {
+			return !spec.scoreInRange(e.score)
+		})
+	case zrangeByLex:
+		entries = slices.DeleteFunc(entries, func(e zsetEntry) bool {
+			return !spec.lexInRange(e.key)
+		})
+	}
+
+	// LIMIT
+	if spec.offset < 0 || spec.offset >= len(entries) {
+		return nil
+	}
+	entries = entries[spec.offset:]
+	if spec.count >= 0 && spec.count < len(entries) {
+		entries = entries[:spec.count]
+	}
+	return entries
+}
+
+// writeZSetEntries writes entries as [member, score, ...], or as [[member, score], ...]
+// with score in double when WITHSCORES in RESP3.
+func writeZSetEntries(writer *resp.Writer, entries []zsetEntry, withScores bool) {
+	if withScores && writer.Proto() == resp.RESP3 {
+		writer.WriteArray(len(entries))
+		for _, e := range entries {
+			writer.WriteArray(2)
+			writer.WriteBulkString(e.key)
+			writer.WriteDouble(e.score)
+		}
+		return
+	}
+	if withScores {
+		writer.WriteArray(len(entries) * 2)
+	} else {
+		writer.WriteArray(len(entries))
+	}
+	for _, e := range entries {
+		writer.WriteBulkString(e.key)
+		if withScores {
+			writer.WriteDouble(e.score)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/redcon"
+)
+
+const (
+	Weights   = "WEIGHTS"
+	Aggregate = "AGGREGATE"
+)
+
+type zsetOp byte
+
+const (
+	zsetOpUnion zsetOp = iota
+	zsetOpInter
+	zsetOpDiff
+)
+
+type zsetAggregate byte
+
+const (
+	aggregateSum zsetAggregate = iota
+	aggregateMin
+	aggregateMax
+)
+
+// zsetOpSpec describes a multi-key aggregation shared by ZUNION, ZINTER, ZDIFF,
+// their STORE variants and ZINTERCARD.
+type zsetOpSpec struct {
+	op         zsetOp
+	keys       [][]byte
+	weights    []float64
+	aggregate  zsetAggregate
+	withScores bool
+	// limit of ZINTERCARD, 0 means unlimited.
+	limit int
+}
+
+// parseZSetOpSpec parses `numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]`.
+func parseZSetOpSpec(name string, op zsetOp, args []redcon.RESP, store bool) (*zsetOpSpec, error) {
+	numKeys, err := strconv.Atoi(b2s(args[0].Bytes()))
+	if err != nil {
+		return nil, errParseInteger
+	}
+	if numKeys <= 0 {
+		return nil, fmt.Errorf("ERR at least 1 input key is needed for '%s' command", name)
+	}
+	if numKeys > len(args)-1 {
+		return nil, errSyntax
+	}
+
+	spec := &zsetOpSpec{op: op, keys: make([][]byte, 0, numKeys)}
+	for _, arg := range args[1 : numKeys+1] {
+		spec.keys = append(spec.keys, arg.Bytes())
+	}
+
+	extra := args[numKeys+1:]
+	for len(extra) > 0 {
+		arg := b2s(extra[0].Bytes())
+		if op != zsetOpDiff && equalFold(arg, Weights) && len(extra) > numKeys {
+			spec.weights = make([]float64, 0, numKeys)
+			for _, w := range extra[1 : numKeys+1] {
+				weight, err := parseFloat(b2s(w.Bytes()))
+				if err != nil {
+					return nil, errWeightNotFloat
+				}
+				spec.weights = append(spec.weights, weight)
+			}
+			extra = extra[numKeys+1:]
+
+		} else if op != zsetOpDiff && equalFold(arg, Aggregate) && len(extra) >= 2 {
+			switch strings.ToUpper(b2s(extra[1].Bytes())) {
+			case "SUM":
+				spec.aggregate = aggregateSum
+			case "MIN":
+				spec.aggregate = aggregateMin
+			case "MAX":
+				spec.aggregate = aggregateMax
+			default:
+				return nil, errSyntax
+			}
+			extra = extra[2:]
+
+		} else if !store && equalFold(arg, WithScores) {
+			spec.withScores = true
+			extra = extra[1:]
+
+		} else {
+			return nil, errSyntax
+		}
+	}
+	return spec, nil
+}
+
+// zsetOperand is a sorted set or a plain set as input of zset commands, members
+// of a plain set have an implicit score of 1. Both are nil if key not exist.
+type zsetOperand struct {
+	zs ZSet
+	s  Set
+}
+
+func fetchZSetOperand(key []byte) (zsetOperand, error) {
+	object, ttl := db.dict.Get(b2s(key))
+	if ttl == KeyNotExist {
+		return zsetOperand{}, nil
+	}
+	switch v := object.(type) {
+	case ZSet:
+		return zsetOperand{zs: v}, nil
+	case Set:
+		return zsetOperand{s: v}, nil
+	}
+	return zsetOperand{}, errWrongType
+}
+
+func (o zsetOperand) len() int {
+	switch {
+	case o.zs != nil:
+		return o.zs.Len()
+	case o.s != nil:
+		return o.s.Len()
+	}
+	return 0
+}
+
+func (o zsetOperand) exist(member string) bool {
+	switch {
+	case o.zs != nil:
+		_, ok := o.zs.Get(member)
+		return ok
+	case o.s != nil:
+		return o.s.Exist(member)
+	}
+	return false
+}
+
+// rangeMembers iterates members until fn returns true.
+func (o zsetOperand) rangeMembers(fn func(key string, score float64) (stop bool)) {
+	switch {
+	case o.zs != nil:
+		o.zs.Range(fn)
+	case o.s != nil:
+		o.s.Range(func(key string) bool { return fn(key, 1) })
+	}
+}
+
+// scanZSetOrSet iterates a sorted set or a plain set, a key not exist is treated
+// as an empty set.
+func scanZSetOrSet(key []byte, fn func(key string, score float64)) error {
+	operand, err := fetchZSetOperand(key)
+	if err != nil {
+		return err
+	}
+	operand.rangeMembers(func(key string, score float64) bool {
+		fn(key, score)
+		return false
+	})
+	return nil
+}
+
+func (spec *zsetOpSpec) weight(i int) float64 {
+	if spec.weights == nil {
+		return 1
+	}
+	return spec.weights[i]
+}
+
+func (spec *zsetOpSpec) merge(a, b float64) float64 {
+	switch spec.aggregate {
+	case aggregateMin:
+		return min(a, b)
+	case aggregateMax:
+		return max(a, b)
+	}
+	// +inf plus -inf is 0 in redis.
+	if sum := a + b; !math.IsNaN(sum) {
+		return sum
+	}
+	return 0
+}
+
+// zsetOpGeneric is the aggregation engine for all multi-key zset commands,
+// it returns entries sorted by score and member.
+func zsetOpGeneric(spec *zsetOpSpec) ([]zsetEntry, error) {
+	result := make(map[string]float64)
+
+	for i, key := range spec.keys {
+		weight := spec.weight(i)
+		var seen map[string]struct{}
+		if spec.op == zsetOpInter && i > 0 {
+			seen = make(map[string]struct{}, len(result))
+		}
+
+		err := scanZSetOrSet(key, func(member string, score float64) {
+			score *= weight
+			if math.IsNaN(score) {
+				score = 0
+			}
+			switch spec.op {
+			case zsetOpUnion:
+				if old, ok := result[member]; ok {
+					result[member] = spec.merge(old, score)
+				} else {
+					result[strings.Clone(member)] = score
+				}
+			case zsetOpInter:
+				if i == 0 {
+					result[strings.Clone(member)] = score
+				} else if old, ok := result[member]; ok {
+					result[member] = spec.merge(old, score)
+					seen[member] = struct{}{}
+				}
+			case zsetOpDiff:
+				if i == 0 {
+					result[strings.Clone(member)] = score
+				} else {
+					delete(result, member)
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if seen != nil {
+			for member := range result {
+				if _, ok := seen[member]; !ok {
+					delete(result, member)
+				}
+			}
+		}
+	}
+
+	entries := make([]zsetEntry, 0, len(result))
+	for member, score := range result {
+		entries = append(entries, zsetEntry{member, score})
+	}
+	slices.SortFunc(entries, func(a, b zsetEntry) int {
+		if a.score == b.score {
+			return cmp.Compare(a.key, b.key)
+		}
+		return cmp.Compare(a.score, b.score)
+	})
+	return entries, nil
+}
+
+// zsetInterCard returns the cardinality of intersection of spec.keys, members of
+// the smallest one are looked up in the others, and the iteration stops once
+// spec.limit is reached.
+func zsetInterCard(spec *zsetOpSpec) (int, error) {
+	operands := make([]zsetOperand, 0, len(spec.keys))
+	for _, key := range spec.keys {
+		operand, err := fetchZSetOperand(key)
+		if err != nil {
+			return 0, err
+		}
+		operands = append(operands, operand)
+	}
+	slices.SortFunc(operands, func(a, b zsetOperand) int {
+		return cmp.Compare(a.len(), b.len())
+	})
+	var card int
+	operands[0].rangeMembers(func(member string, _ float64) bool {
+		for _, operand := range operands[1:] {
+			if !operand.exist(member) {
+				return false
+			}
+		}
+		card++
+		return spec.limit > 0 && card >= spec.limit
+	})
+	return card, nil
+}